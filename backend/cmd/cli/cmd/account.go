@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "アカウントデータの書き出し・削除",
+}
+
+var accountExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "プロフィール・セッション・回答をすべて JSON で書き出す (--user は管理者のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+		if userSub == "" {
+			userSub = actor.Sub
+		}
+		outPath, _ := cmd.Flags().GetString("out")
+
+		archive, err := accountSvc.ExportAccount(actor, userSub)
+		if err != nil {
+			return fmt.Errorf("アカウントデータ書き出し失敗: %w", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if outPath != "" {
+			f, err := os.Create(outPath)
+			if err != nil {
+				return fmt.Errorf("出力ファイル作成失敗: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(archive)
+	},
+}
+
+var accountDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "ユーザーのデータをすべて削除する (管理者のみ。先に Cognito でユーザーを無効化すること)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+		yes, _ := cmd.Flags().GetBool("yes")
+		batchSize, _ := cmd.Flags().GetInt("batch")
+		if !yes {
+			return fmt.Errorf("%s のデータを削除します。元に戻せないため --yes を指定してください", userSub)
+		}
+
+		// 1回の呼び出しで batchSize 件ほど削除されるので、完了するまで繰り返す
+		for {
+			d, err := accountSvc.DeleteAccount(actor, userSub, batchSize)
+			if err != nil {
+				return fmt.Errorf("データ削除失敗 (同じコマンドで途中から再開できます): %w", err)
+			}
+			printDeletion(d)
+			if d.Status == model.DeletionStatusCompleted {
+				return nil
+			}
+		}
+	},
+}
+
+var accountStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "データ削除の進捗を表示する (管理者のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+
+		d, err := accountSvc.GetAccountDeletion(actor, userSub)
+		if err != nil {
+			return fmt.Errorf("削除状況取得失敗: %w", err)
+		}
+		printDeletion(d)
+		return nil
+	},
+}
+
+func printDeletion(d *dto.AccountDeletion) {
+	phases := make([]string, 0, len(d.Deleted))
+	for phase := range d.Deleted {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	counts := make([]string, 0, len(phases))
+	for _, phase := range phases {
+		counts = append(counts, fmt.Sprintf("%s=%d", phase, d.Deleted[phase]))
+	}
+
+	state := d.Status
+	if d.Phase != "" {
+		state += " (" + d.Phase + ")"
+	}
+	fmt.Printf("%s: %s  削除 %s  匿名化 %d  更新 %s\n",
+		d.UserSub, state, strings.Join(counts, " "), d.Anonymized, d.UpdatedAt)
+}
+
+func init() {
+	accountExportCmd.Flags().String("user", "", "ユーザーの Cognito sub (省略時は自分)")
+	accountExportCmd.Flags().String("out", "", "出力先ファイル (省略時は標準出力)")
+
+	accountDeleteCmd.Flags().String("user", "", "ユーザーの Cognito sub")
+	accountDeleteCmd.Flags().Bool("yes", false, "削除を確認済み")
+	accountDeleteCmd.Flags().Int("batch", 0, "1回に削除するアイテム数の目安 (省略時は 500)")
+	accountDeleteCmd.MarkFlagRequired("user")
+
+	accountStatusCmd.Flags().String("user", "", "ユーザーの Cognito sub")
+	accountStatusCmd.MarkFlagRequired("user")
+
+	accountCmd.AddCommand(accountExportCmd, accountDeleteCmd, accountStatusCmd)
+	rootCmd.AddCommand(accountCmd)
+}
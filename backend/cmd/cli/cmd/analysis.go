@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/report"
+)
+
+var analysisCmd = &cobra.Command{
+	Use:   "analysis",
+	Short: "問題作成者向けの分析",
+}
+
+var analysisItemsCmd = &cobra.Command{
+	Use:   "items",
+	Short: "保存済みの問題分析 (問題ごとの難易度・識別力・選択肢の選択率) を出力する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "json" && format != "csv" {
+			return apperr.InvalidParameter("format", "--format は json か csv を指定してください")
+		}
+		outPath, _ := cmd.Flags().GetString("out")
+
+		result, err := analysisSvc.Analyze()
+		if err != nil {
+			return fmt.Errorf("問題分析失敗: %w", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if outPath != "" {
+			f, err := os.Create(outPath)
+			if err != nil {
+				return fmt.Errorf("出力ファイル作成失敗: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if format == "csv" {
+			return report.WriteItemAnalysisCSV(w, result)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+var analysisRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "全セッションの回答から問題分析を計算し直して保存する (テーブル全体を Scan する)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := analysisSvc.Rebuild()
+		if err != nil {
+			return fmt.Errorf("問題分析の再計算失敗: %w", err)
+		}
+		fmt.Printf("問題分析を再計算しました (%s, セッション %d 件, 問題 %d 問)\n", result.GeneratedAt, result.SessionCount, len(result.Items))
+		return nil
+	},
+}
+
+func init() {
+	analysisItemsCmd.Flags().String("format", "csv", "出力形式 (csv / json)")
+	analysisItemsCmd.Flags().String("out", "", "出力先ファイル (省略時は標準出力)")
+
+	analysisCmd.AddCommand(analysisItemsCmd, analysisRebuildCmd)
+	rootCmd.AddCommand(analysisCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "API キーを一覧表示する (失効したキーを含む)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		keys, err := apiKeySvc.ListAPIKeys(actor)
+		if err != nil {
+			return fmt.Errorf("API キー取得失敗: %w", err)
+		}
+		if len(keys) == 0 {
+			fmt.Println("API キーはありません")
+			return nil
+		}
+		for _, k := range keys {
+			fmt.Printf("%s  %s [%s] 作成 %s", k.KeyID, k.Name, strings.Join(k.Scopes, ","), k.CreatedAt)
+			if k.LastUsedAt != "" {
+				fmt.Printf("  最終使用 %s", k.LastUsedAt)
+			}
+			if k.RevokedAt != "" {
+				fmt.Printf("  [失効 %s]", k.RevokedAt)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "API キーを発行する (キーはこのときしか表示しない)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		name, _ := cmd.Flags().GetString("name")
+		scopes, _ := cmd.Flags().GetStringSlice("scope")
+
+		k, err := apiKeySvc.CreateAPIKey(actor, dto.CreateAPIKeyRequest{Name: name, Scopes: scopes})
+		if err != nil {
+			return fmt.Errorf("API キー発行失敗: %w", err)
+		}
+		fmt.Printf("API キーを発行しました: %s (ID: %s, 権限: %s)\n", k.Name, k.KeyID, strings.Join(k.Scopes, ","))
+		fmt.Printf("キー: %s\n", k.Key)
+		fmt.Println("このキーは再表示できません。安全な場所に保存してください")
+		return nil
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "API キーを失効させる",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		keyID, _ := cmd.Flags().GetString("key")
+
+		if err := apiKeySvc.RevokeAPIKey(actor, keyID); err != nil {
+			return fmt.Errorf("API キー失効失敗: %w", err)
+		}
+		fmt.Printf("API キーを失効させました: %s\n", keyID)
+		return nil
+	},
+}
+
+func init() {
+	apikeyCreateCmd.Flags().String("name", "", "用途のメモ")
+	apikeyCreateCmd.Flags().StringSlice("scope", nil, "権限 (results:read / tests:write / admin、複数指定可)")
+	apikeyCreateCmd.MarkFlagRequired("name")
+	apikeyCreateCmd.MarkFlagRequired("scope")
+
+	apikeyRevokeCmd.Flags().String("key", "", "キーID")
+	apikeyRevokeCmd.MarkFlagRequired("key")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd, apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
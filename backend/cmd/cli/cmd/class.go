@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var classCmd = &cobra.Command{
+	Use:   "class",
+	Short: "所属クラスを一覧表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		classes, err := classroomSvc.ListClassrooms(actor)
+		if err != nil {
+			return fmt.Errorf("クラス取得失敗: %w", err)
+		}
+		if len(classes) == 0 {
+			fmt.Println("所属クラスはありません")
+			return nil
+		}
+		for _, c := range classes {
+			fmt.Printf("%s  %s (%s)", c.ClassID, c.Name, c.Role)
+			if c.JoinCode != "" {
+				fmt.Printf("  参加コード: %s", c.JoinCode)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var classCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "クラスを作成して教師として所属する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		name, _ := cmd.Flags().GetString("name")
+
+		c, err := classroomSvc.CreateClassroom(actor, name)
+		if err != nil {
+			return fmt.Errorf("クラス作成失敗: %w", err)
+		}
+		fmt.Printf("クラスを作成しました: %s (ID: %s, 参加コード: %s)\n", c.Name, c.ClassID, c.JoinCode)
+		return nil
+	},
+}
+
+var classJoinCmd = &cobra.Command{
+	Use:   "join",
+	Short: "参加コードでクラスに生徒として参加する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		code, _ := cmd.Flags().GetString("code")
+
+		c, err := classroomSvc.JoinClassroom(actor, code)
+		if err != nil {
+			return fmt.Errorf("クラス参加失敗: %w", err)
+		}
+		fmt.Printf("クラスに参加しました: %s (ID: %s)\n", c.Name, c.ClassID)
+		return nil
+	},
+}
+
+var classRosterCmd = &cobra.Command{
+	Use:   "roster",
+	Short: "クラスの生徒名簿を表示する (教師のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		classID, _ := cmd.Flags().GetUint64("class")
+
+		detail, err := classroomSvc.GetClassroom(actor, classID)
+		if err != nil {
+			return fmt.Errorf("名簿取得失敗: %w", err)
+		}
+		fmt.Printf("%s (参加コード: %s) 生徒 %d人\n", detail.Classroom.Name, detail.Classroom.JoinCode, len(detail.Students))
+		for _, s := range detail.Students {
+			fmt.Printf("  %s  %s (%s 参加)\n", s.UserSub, s.UserName, s.JoinedAt)
+		}
+		return nil
+	},
+}
+
+var classAssignmentsCmd = &cobra.Command{
+	Use:   "assignments",
+	Short: "クラスの課題を一覧表示する (--assignment を指定すると生徒ごとの提出状況)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		classID, _ := cmd.Flags().GetUint64("class")
+		assignmentID, _ := cmd.Flags().GetUint64("assignment")
+
+		if assignmentID != 0 {
+			st, err := assignmentSvc.GetAssignmentStatus(actor, classID, assignmentID)
+			if err != nil {
+				return fmt.Errorf("提出状況取得失敗: %w", err)
+			}
+			fmt.Printf("%s (期限 %s / 締切 %s)\n", st.Assignment.Title, st.Assignment.DueAt, st.Assignment.ClosesAt)
+			fmt.Printf("提出 %d/%d人 (うち期限後 %d人)\n", st.SubmittedCount, len(st.Students), st.LateCount)
+			for _, s := range st.Students {
+				fmt.Printf("  %s  %-12s %s", s.UserSub, s.Status, s.UserName)
+				for _, a := range s.Attempts {
+					if a.FinishedAt != "" {
+						fmt.Printf("  [%d回目 %d/%d]", a.Attempt, a.CorrectCount, a.Total)
+					}
+				}
+				fmt.Println()
+			}
+			return nil
+		}
+
+		assignments, err := assignmentSvc.ListAssignments(actor, classID)
+		if err != nil {
+			return fmt.Errorf("課題取得失敗: %w", err)
+		}
+		if len(assignments) == 0 {
+			fmt.Println("課題はありません")
+			return nil
+		}
+		for _, a := range assignments {
+			fmt.Printf("%s  %s (期限 %s)", a.AssignmentID, a.Title, a.DueAt)
+			if a.Closed {
+				fmt.Print(" [締切済み]")
+			}
+			if a.Status != "" {
+				fmt.Printf(" %s %d/%d回", a.Status, a.AttemptsUsed, a.MaxAttempts)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var classAnalyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "クラス全体の成績を集計する (要注意の生徒は教師のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		classID, _ := cmd.Flags().GetUint64("class")
+
+		a, err := classStatsSvc.GetClassAnalytics(actor, classID)
+		if err != nil {
+			return fmt.Errorf("クラス集計失敗: %w", err)
+		}
+		fmt.Printf("生徒 %d人 (回答あり %d人) / 終了セッション %d件  集計 %s", a.StudentCount, a.ActiveStudents, a.SessionCount, a.GeneratedAt)
+		if a.Cached {
+			fmt.Print(" (キャッシュ)")
+		}
+		fmt.Println()
+		if a.Suppressed {
+			fmt.Println("回答した生徒が少ないため集計は表示できません")
+			return nil
+		}
+		if a.AverageScore != nil {
+			fmt.Printf("平均得点率: %.1f%%\n", *a.AverageScore)
+		}
+
+		fmt.Println("\nカテゴリ別平均正答率:")
+		for _, c := range a.Categories {
+			fmt.Printf("  %-10s %5.1f%%  (%d人, %d問)\n", c.CategoryName, c.AverageAccuracy*100, c.StudentCount, c.Attempts)
+		}
+		fmt.Println("\n得点分布:")
+		for _, b := range a.ScoreDistribution {
+			fmt.Printf("  %3d-%3d%%  %d\n", b.Min, b.Max, b.Count)
+		}
+		fmt.Println("\nよく間違えられた問題:")
+		for _, p := range a.MostMissed {
+			fmt.Printf("  #%d [%s] %d/%d 誤答 (%d人)  %s\n", p.ProblemID, p.CategoryName, p.MissCount, p.Attempts, p.StudentCount, p.Question)
+		}
+		if a.AtRiskStudents != nil {
+			fmt.Println("\n要注意の生徒:")
+			for _, r := range a.AtRiskStudents {
+				fmt.Printf("  %s  %s  正答率 %.1f%%  苦手: %v\n", r.UserSub, r.UserName, r.Accuracy*100, r.WeakCategories)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	classCreateCmd.Flags().String("name", "", "クラス名")
+	classCreateCmd.MarkFlagRequired("name")
+
+	classJoinCmd.Flags().String("code", "", "参加コード")
+	classJoinCmd.MarkFlagRequired("code")
+
+	classRosterCmd.Flags().Uint64("class", 0, "クラスID")
+	classRosterCmd.MarkFlagRequired("class")
+
+	classAssignmentsCmd.Flags().Uint64("class", 0, "クラスID")
+	classAssignmentsCmd.Flags().Uint64("assignment", 0, "課題ID (指定すると提出状況を表示、教師のみ)")
+	classAssignmentsCmd.MarkFlagRequired("class")
+
+	classAnalyticsCmd.Flags().Uint64("class", 0, "クラスID")
+	classAnalyticsCmd.MarkFlagRequired("class")
+
+	classCmd.AddCommand(classCreateCmd, classJoinCmd, classRosterCmd, classAssignmentsCmd, classAnalyticsCmd)
+	rootCmd.AddCommand(classCmd)
+}
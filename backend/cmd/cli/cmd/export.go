@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/report"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "回答結果を CSV / JSON / 印刷用 HTML で書き出す (--session 省略時は全セッション)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("format")
+		if format != "json" && format != "csv" && format != "html" {
+			return apperr.InvalidParameter("format", "--format は csv / json / html のいずれかを指定してください")
+		}
+		sessionID, _ := cmd.Flags().GetUint64("session")
+		outPath, _ := cmd.Flags().GetString("out")
+
+		result, err := mypageSvc.Export(user, dto.ExportQuery{SessionID: sessionID})
+		if err != nil {
+			return fmt.Errorf("エクスポート失敗: %w", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if outPath != "" {
+			f, err := os.Create(outPath)
+			if err != nil {
+				return fmt.Errorf("出力ファイル作成失敗: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch format {
+		case "csv":
+			return report.WriteExportCSV(w, result)
+		case "html":
+			return report.WriteExportHTML(w, result)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("format", "csv", "出力形式 (csv / json / html)")
+	exportCmd.Flags().Uint64("session", 0, "セッションID (省略時は全セッション)")
+	exportCmd.Flags().String("out", "", "出力先ファイル (省略時は標準出力)")
+
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// tokenEnv が設定されていれば、保存したトークンより優先して使う
+const tokenEnv = "MATHOVERCOME_TOKEN"
+
+// ログインを必要としないコマンドに付ける注釈
+const annotationNoLogin = "noLogin"
+
+var loginCmd = &cobra.Command{
+	Use:         "login",
+	Short:       "Cognito の ID トークンを検証して保存する (--token 省略時は標準入力から読む)",
+	Annotations: map[string]string{annotationNoLogin: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			fmt.Print("ID トークン: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("トークンの読み込みに失敗: %w", err)
+			}
+			token = strings.TrimSpace(line)
+		}
+
+		p, err := authenticateToken(cmd, token)
+		if err != nil {
+			return err
+		}
+		if err := saveToken(token); err != nil {
+			return err
+		}
+		fmt.Printf("ログインしました: %s (%s)\n", p.Name, p.Sub)
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:         "logout",
+	Short:       "保存したトークンを削除する",
+	Annotations: map[string]string{annotationNoLogin: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := tokenPath()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("トークンの削除に失敗: %w", err)
+		}
+		fmt.Println("ログアウトしました")
+		return nil
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "ログイン中のユーザーを表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s (%s)\n", actor.Name, actor.Sub)
+		return nil
+	},
+}
+
+// login は保存したトークン (または MATHOVERCOME_TOKEN) を検証し、利用者をコマンドの context に入れる。
+// トークンが無い場合は何もしない。ログインが必要なコマンドは currentPrincipal でエラーにする。
+func login(cmd *cobra.Command) error {
+	if cmd.Annotations[annotationNoLogin] != "" {
+		return nil
+	}
+	token, err := loadToken()
+	if err != nil || token == "" {
+		return err
+	}
+	p, err := authenticateToken(cmd, token)
+	if err != nil {
+		return err
+	}
+	if api != nil {
+		api.Token = token
+	}
+	cmd.SetContext(identity.NewContext(cmd.Context(), p))
+	return nil
+}
+
+// currentPrincipal はログイン中の利用者を返す。
+func currentPrincipal(cmd *cobra.Command) (*identity.Principal, error) {
+	p, ok := identity.FromContext(cmd.Context())
+	if !ok {
+		return nil, fmt.Errorf("ログインしていません。mathovercome login でログインしてください")
+	}
+	return p, nil
+}
+
+// currentUser はログイン中の利用者を、プロフィールの表示名とタイムゾーンで返す。
+func currentUser(cmd *cobra.Command) (*model.User, error) {
+	actor, err := currentPrincipal(cmd)
+	if err != nil {
+		return nil, err
+	}
+	u, err := profileSvc.User(actor)
+	if err != nil {
+		return nil, fmt.Errorf("プロフィール取得失敗: %w", err)
+	}
+	return u, nil
+}
+
+func authenticateToken(cmd *cobra.Command, token string) (*identity.Principal, error) {
+	authn, err := authenticator()
+	if err != nil {
+		return nil, err
+	}
+	p, err := authn.Authenticate(cmd.Context(), token)
+	if errors.Is(err, identity.ErrUnauthenticated) {
+		return nil, fmt.Errorf("トークンが無効です (期限切れの場合は mathovercome login で再ログインしてください): %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("トークンの検証に失敗: %w", err)
+	}
+	return p, nil
+}
+
+// authenticator はトークンを検証する Authenticator を返す。リモートモードでは API に問い合わせる。
+func authenticator() (identity.Authenticator, error) {
+	if api != nil {
+		return remoteAuthenticator{api}, nil
+	}
+	cfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("認証設定の読み込みに失敗: %w", err)
+	}
+	return auth.NewVerifier(cfg), nil
+}
+
+// tokenPath はトークンの保存先 (<ユーザー設定ディレクトリ>/mathovercome/token) を返す。
+func tokenPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("設定ディレクトリが見つかりません: %w", err)
+	}
+	return filepath.Join(dir, "mathovercome", "token"), nil
+}
+
+func loadToken() (string, error) {
+	if token := os.Getenv(tokenEnv); token != "" {
+		return token, nil
+	}
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("トークンの読み込みに失敗: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// saveToken はトークンを本人だけが読めるファイルに保存する。
+func saveToken(token string) error {
+	path, err := tokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("設定ディレクトリの作成に失敗: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return fmt.Errorf("トークンの保存に失敗: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	loginCmd.Flags().String("token", "", "Cognito の ID トークン")
+
+	rootCmd.AddCommand(loginCmd, logoutCmd, whoamiCmd)
+}
@@ -5,24 +5,30 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
 )
 
 var mypageCmd = &cobra.Command{
 	Use:   "mypage",
 	Short: "マイページ情報を表示する",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if userSub == "" {
-			return fmt.Errorf("--user フラグが必要です")
-		}
-		userName, _ := cmd.Flags().GetString("name")
-
-		user := &model.User{
-			Sub:      userSub,
-			UserName: userName,
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
 		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		cursor, _ := cmd.Flags().GetString("cursor")
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		details, _ := cmd.Flags().GetBool("details")
 
-		data, err := mypageSvc.GetUserData(user)
+		data, err := mypageSvc.GetUserData(user, dto.MypageQuery{
+			Limit:          limit,
+			Cursor:         cursor,
+			From:           from,
+			To:             to,
+			IncludeDetails: details,
+		})
 		if err != nil {
 			return fmt.Errorf("マイページ取得失敗: %w", err)
 		}
@@ -33,17 +39,174 @@ var mypageCmd = &cobra.Command{
 		for _, sess := range data.TestSessDtos {
 			fmt.Printf("--- セッション %d (%s) ---\n", sess.SessionID, sess.StartTime)
 			fmt.Printf("正答率: %d/%d\n", sess.CorrectCount, sess.Total)
+			if sess.HintedCount > 0 {
+				fmt.Printf("  うちヒントあり: %d/%d\n", sess.HintedCorrectCount, sess.HintedCount)
+			}
 			if len(sess.WeakCategories) > 0 {
 				fmt.Printf("苦手分野: %v\n", sess.WeakCategories)
 			}
+			if p := sess.Pacing; p != nil {
+				fmt.Printf("1問あたり: %.0f秒 (目標 %.0f秒, 超過 %d問)\n", p.AvgSec, p.TargetSec, p.OverTargetCount)
+			}
+			for _, c := range sess.CategoryDtos {
+				if c.TimedCount > 0 {
+					fmt.Printf("  %s: 平均 %.0f秒 (%d問)\n", c.CategoryName, c.AvgTimeSec, c.TimedCount)
+				}
+			}
+			for _, pt := range sess.ProblemTimes {
+				mark := ""
+				if pt.OverTarget {
+					mark = " ⚠"
+				}
+				fmt.Printf("  問題%d [%s]: %.0f秒%s\n", pt.Idx+1, pt.CategoryName, pt.TimeSpentSec, mark)
+			}
 			fmt.Println()
 		}
 
+		if data.NextCursor != "" {
+			fmt.Printf("続きを表示: --cursor %s\n", data.NextCursor)
+		}
+
+		return nil
+	},
+}
+
+var mypageSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "全セッションの累計成績を表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+
+		data, err := mypageSvc.GetSummary(user)
+		if err != nil {
+			return fmt.Errorf("サマリー取得失敗: %w", err)
+		}
+
+		fmt.Printf("テストセッション数: %d\n", data.SessionCount)
+		fmt.Printf("累計正答率: %d/%d (%.1f%%)\n", data.CorrectCount, data.Total, data.Accuracy*100)
+		fmt.Printf("ヒントなしの正答率: %.1f%% (ヒントあり %d問中 %d問正解)\n",
+			data.UnaidedAccuracy*100, data.HintedCount, data.HintedCorrectCount)
+		if data.LastStartTime != "" {
+			fmt.Printf("最終受験: %s\n", data.LastStartTime)
+		}
+		if p := data.Pacing; p != nil {
+			fmt.Printf("1問あたり平均: %.0f秒 (目標 %.0f秒, %d問中 %d問が超過)\n",
+				p.AvgSec, p.TargetSec, p.TimedCount, p.OverTargetCount)
+		}
+		if len(data.WeakCategories) > 0 {
+			fmt.Printf("苦手分野 (全セッション): %v\n", data.WeakCategories)
+		}
 		return nil
 	},
 }
 
+var mypageTrendsCmd = &cobra.Command{
+	Use:   "trends",
+	Short: "直近のセッションを通した分野別の推移を表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+		sessions, _ := cmd.Flags().GetInt("sessions")
+		window, _ := cmd.Flags().GetInt("window")
+
+		data, err := mypageSvc.GetTrends(user, dto.TrendQuery{Sessions: sessions, Window: window})
+		if err != nil {
+			return fmt.Errorf("推移取得失敗: %w", err)
+		}
+
+		fmt.Printf("対象セッション数: %d (移動平均: 直近%d回)\n", data.SessionCount, data.Window)
+		fmt.Printf("連続学習: %d日 (最長 %d日)\n\n", data.Streak.CurrentDays, data.Streak.LongestDays)
+
+		for _, c := range data.Categories {
+			fmt.Printf("--- %s ---\n", c.CategoryName)
+			for _, p := range c.Points {
+				fmt.Printf("  %s  %d/%d  移動正答率 %.0f%%\n", p.StartTime, p.CorrectCount, p.Total, p.RollingAccuracy*100)
+			}
+			if c.Change != nil {
+				fmt.Printf("  変化: %+.0fpt\n", *c.Change*100)
+			}
+		}
+
+		fmt.Println()
+		for _, r := range data.Best {
+			fmt.Printf("得意: %s (%.0f%%)\n", r.CategoryName, r.Accuracy*100)
+		}
+		for _, r := range data.Worst {
+			fmt.Printf("正答率が低い: %s (%.0f%%)\n", r.CategoryName, r.Accuracy*100)
+		}
+		if len(data.WeakCategories) > 0 {
+			fmt.Printf("苦手分野: %v\n", data.WeakCategories)
+		}
+		return nil
+	},
+}
+
+var mypageCategoriesCmd = &cobra.Command{
+	Use:   "categories",
+	Short: "分野別の累計成績を表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+
+		data, err := mypageSvc.GetCategoryStats(user)
+		if err != nil {
+			return fmt.Errorf("分野別累計取得失敗: %w", err)
+		}
+		printCategoryStats(data)
+		return nil
+	},
+}
+
+var mypageCategoriesRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "分野別の累計を全セッションの回答から計算し直す",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+
+		data, err := mypageSvc.RebuildCategoryStats(actor.Sub)
+		if err != nil {
+			return fmt.Errorf("分野別累計の再計算失敗: %w", err)
+		}
+		fmt.Println("分野別の累計を再計算しました")
+		printCategoryStats(data)
+		return nil
+	},
+}
+
+func printCategoryStats(data *dto.CategoryStats) {
+	for _, c := range data.Categories {
+		fmt.Printf("  %s: %d/%d (%.0f%%)", c.CategoryName, c.CorrectCount, c.Attempts, c.Accuracy*100)
+		if c.LastAttemptedAt != "" {
+			fmt.Printf("  最終: %s", c.LastAttemptedAt)
+		}
+		fmt.Println()
+	}
+	if len(data.WeakCategories) > 0 {
+		fmt.Printf("苦手分野: %v\n", data.WeakCategories)
+	}
+}
+
 func init() {
-	mypageCmd.Flags().String("name", "", "ユーザー名 (表示用)")
+	mypageCmd.Flags().Int("limit", 0, "1ページの件数 (既定: 20, 最大: 100)")
+	mypageCmd.Flags().String("cursor", "", "前回表示された続きのカーソル")
+	mypageCmd.Flags().String("from", "", "開始日 (YYYY-MM-DD)")
+	mypageCmd.Flags().String("to", "", "終了日 (YYYY-MM-DD, 当日を含む)")
+	mypageCmd.Flags().Bool("details", true, "分野別の内訳を含める")
+
+	mypageTrendsCmd.Flags().Int("sessions", 0, "対象とする直近のセッション数 (既定: 10, 最大: 100)")
+	mypageTrendsCmd.Flags().Int("window", 0, "移動正答率に使うセッション数 (既定: 3)")
+
+	mypageCategoriesCmd.AddCommand(mypageCategoriesRebuildCmd)
+	mypageCmd.AddCommand(mypageSummaryCmd, mypageTrendsCmd, mypageCategoriesCmd)
 	rootCmd.AddCommand(mypageCmd)
 }
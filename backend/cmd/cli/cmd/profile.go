@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "プロフィールを表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		p, err := profileSvc.GetProfile(actor)
+		if err != nil {
+			return fmt.Errorf("プロフィール取得失敗: %w", err)
+		}
+		printProfile(p)
+		return nil
+	},
+}
+
+var profileSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "プロフィールを更新する (指定した項目だけを変更する)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		p, err := profileSvc.GetProfile(actor)
+		if err != nil {
+			return fmt.Errorf("プロフィール取得失敗: %w", err)
+		}
+
+		req := dto.UpdateProfileRequest{
+			DisplayName:         p.DisplayName,
+			Grade:               p.Grade,
+			TargetExam:          p.TargetExam,
+			PreferredCategories: p.PreferredCategories,
+			Timezone:            p.Timezone,
+			SessionDefaults:     p.SessionDefaults,
+		}
+		flags := cmd.Flags()
+		if flags.Changed("name") {
+			req.DisplayName, _ = flags.GetString("name")
+		}
+		if flags.Changed("grade") {
+			req.Grade, _ = flags.GetString("grade")
+		}
+		if flags.Changed("target-exam") {
+			req.TargetExam, _ = flags.GetString("target-exam")
+		}
+		if flags.Changed("categories") {
+			req.PreferredCategories, _ = flags.GetIntSlice("categories")
+		}
+		if flags.Changed("timezone") {
+			req.Timezone, _ = flags.GetString("timezone")
+		}
+		if flags.Changed("integers") {
+			req.SessionDefaults.IncludeIntegers, _ = flags.GetBool("integers")
+		}
+		if flags.Changed("exam") {
+			req.SessionDefaults.ExamMode, _ = flags.GetBool("exam")
+		}
+
+		updated, err := profileSvc.UpdateProfile(actor, req)
+		if err != nil {
+			return fmt.Errorf("プロフィール更新失敗: %w", err)
+		}
+		printProfile(updated)
+		return nil
+	},
+}
+
+func printProfile(p *dto.Profile) {
+	categories := make([]string, len(p.PreferredCategories))
+	for i, id := range p.PreferredCategories {
+		categories[i] = fmt.Sprint(id)
+	}
+	fmt.Printf("表示名: %s (%s)\n", p.DisplayName, p.UserSub)
+	fmt.Printf("学年: %s\n", p.Grade)
+	fmt.Printf("志望: %s\n", p.TargetExam)
+	fmt.Printf("重点カテゴリ: %s\n", strings.Join(categories, ", "))
+	fmt.Printf("タイムゾーン: %s\n", p.Timezone)
+	fmt.Printf("セッションの既定値: 整数問題=%v 試験モード=%v\n", p.SessionDefaults.IncludeIntegers, p.SessionDefaults.ExamMode)
+	if p.CreatedAt != "" {
+		fmt.Printf("作成: %s  更新: %s\n", p.CreatedAt, p.UpdatedAt)
+	}
+}
+
+func init() {
+	profileSetCmd.Flags().String("name", "", "表示名")
+	profileSetCmd.Flags().String("grade", "", "学年")
+	profileSetCmd.Flags().String("target-exam", "", "志望 (目標の試験)")
+	profileSetCmd.Flags().IntSlice("categories", nil, "重点カテゴリの ID (カンマ区切り)")
+	profileSetCmd.Flags().String("timezone", "", "タイムゾーン (例: Asia/Tokyo)")
+	profileSetCmd.Flags().Bool("integers", false, "セッション開始時に整数問題を含める")
+	profileSetCmd.Flags().Bool("exam", false, "セッション開始時に試験モードにする")
+
+	profileCmd.AddCommand(profileSetCmd)
+	rootCmd.AddCommand(profileCmd)
+}
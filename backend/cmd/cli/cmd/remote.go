@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apiclient"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// apiURLEnv (または --api-url) を指定すると、DynamoDB に直接つながずに HTTP API を呼び出す (リモートモード)。
+// 資格情報は保存したトークンか MATHOVERCOME_TOKEN (API キーも可) を使う。
+const apiURLEnv = "MATHOVERCOME_API_URL"
+
+var errRemoteUnsupported = errors.New("リモートモードでは使えません")
+
+// api はリモートモードのクライアント。ローカルモードでは nil
+var api *apiclient.Client
+
+// setupRemote はサービスを baseURL の API を呼び出すものにする。
+// 利用者は API が資格情報から特定するので、サービスに渡す actor や user は使わない。
+func setupRemote(baseURL string) error {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return apperr.InvalidParameter("api-url", "--api-url は http:// か https:// で始まる URL を指定してください")
+	}
+	api = apiclient.New(baseURL, "")
+	r := remoteServices{api}
+	testSessSvc = r
+	mypageSvc = r
+	analysisSvc = r
+	classroomSvc = r
+	assignmentSvc = r
+	classStatsSvc = r
+	apiKeySvc = r
+	roleSvc = r
+	profileSvc = r
+	accountSvc = r
+	return nil
+}
+
+// remoteAuthenticator は資格情報で GET /v1/me を呼び出して利用者を確認する。
+type remoteAuthenticator struct {
+	c *apiclient.Client
+}
+
+func (a remoteAuthenticator) Authenticate(ctx context.Context, credential string) (*identity.Principal, error) {
+	c := *a.c
+	c.Token = credential
+	profile, err := c.GetProfile(ctx)
+	if errors.Is(err, apperr.ErrUnauthenticated) {
+		return nil, fmt.Errorf("%w: %s", identity.ErrUnauthenticated, apperr.Format(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &identity.Principal{Sub: profile.UserSub, Name: profile.DisplayName, Method: identity.MethodJWT, Scopes: identity.AllScopes()}
+	if strings.HasPrefix(credential, identity.APIKeyPrefix) {
+		// キーの権限は API が判定する
+		p.Method, p.Scopes = identity.MethodAPIKey, nil
+	}
+	return p, nil
+}
+
+// remoteServices は各サービスのインターフェースを API の呼び出しで実装する。
+type remoteServices struct {
+	c *apiclient.Client
+}
+
+func (r remoteServices) ctx() context.Context {
+	return context.Background()
+}
+
+// TestSessionServicer
+
+func (r remoteServices) CreateTestSess(actor *identity.Principal, includeIntegers, examMode bool) (*model.TestSession, error) {
+	created, err := r.c.CreateSession(r.ctx(), &apiclient.CreateSessionParams{IncludeIntegers: &includeIntegers, ExamMode: &examMode})
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(created.SessionID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("セッション ID が不正です: %w", err)
+	}
+	state, err := r.c.GetSession(r.ctx(), id)
+	if err != nil {
+		return nil, err
+	}
+	sess := &model.TestSession{ID: id, UserID: actor.Sub, IncludeIntegers: state.IncludeIntegers, ExamMode: state.ExamMode, Status: state.Status}
+	for _, p := range state.Problems {
+		sess.SessionProblems = append(sess.SessionProblems, model.SessionProblem{TestSessionID: id, CategoryName: p.CategoryName, Version: p.Version})
+	}
+	return sess, nil
+}
+
+func (r remoteServices) GetProblem(sessionID uint64, actor *identity.Principal, idx int) (*dto.SessionProblem, error) {
+	return r.c.GetProblem(r.ctx(), sessionID, idx)
+}
+
+func (r remoteServices) RevealHint(sessionID uint64, actor *identity.Principal, idx int) (*dto.Hint, error) {
+	return r.c.RevealHint(r.ctx(), sessionID, idx)
+}
+
+func (r remoteServices) SubmitAnswer(sessionID uint64, actor *identity.Principal, idx int, choiceID *int64, version *int64) error {
+	return r.c.SubmitAnswer(r.ctx(), sessionID, idx, dto.AnswerRequest{SelectedChoiceID: choiceID, Version: version})
+}
+
+func (r remoteServices) GetAnswerHistory(sessionID uint64, actor *identity.Principal) (*dto.AnswerHistory, error) {
+	return r.c.GetAnswerHistory(r.ctx(), sessionID)
+}
+
+func (r remoteServices) FinishSession(sessionID uint64, actor *identity.Principal) error {
+	return r.c.FinishSession(r.ctx(), sessionID)
+}
+
+func (r remoteServices) ListSessions(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error) {
+	return r.c.ListSessions(r.ctx(), &apiclient.ListSessionsParams{Status: q.Status, Limit: q.Limit, Cursor: q.Cursor})
+}
+
+func (r remoteServices) GetSession(sessionID uint64, user *model.User) (*dto.SessionState, error) {
+	return r.c.GetSession(r.ctx(), sessionID)
+}
+
+// MypageServicer
+
+func (r remoteServices) GetUserData(user *model.User, q dto.MypageQuery) (*dto.User, error) {
+	return r.c.GetMypage(r.ctx(), &apiclient.GetMypageParams{Limit: q.Limit, Cursor: q.Cursor, From: q.From, To: q.To, IncludeDetails: &q.IncludeDetails})
+}
+
+func (r remoteServices) GetSummary(user *model.User) (*dto.MypageSummary, error) {
+	return r.c.GetMypageSummary(r.ctx())
+}
+
+func (r remoteServices) GetTrends(user *model.User, q dto.TrendQuery) (*dto.Trends, error) {
+	return r.c.GetMypageTrends(r.ctx(), &apiclient.GetMypageTrendsParams{Sessions: q.Sessions, Window: q.Window})
+}
+
+func (r remoteServices) GetCategoryStats(user *model.User) (*dto.CategoryStats, error) {
+	return r.c.GetMypageCategories(r.ctx())
+}
+
+// 分野別の累計の再計算は API に無い (DynamoDB に直接つないで実行する)
+func (r remoteServices) RebuildCategoryStats(userSub string) (*dto.CategoryStats, error) {
+	return nil, errRemoteUnsupported
+}
+
+func (r remoteServices) Export(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+	return r.c.Export(r.ctx(), &apiclient.ExportParams{SessionID: q.SessionID})
+}
+
+// ItemAnalysisServicer
+
+func (r remoteServices) Analyze() (*dto.ItemAnalysis, error) {
+	return r.c.GetItemAnalysis(r.ctx())
+}
+
+// 問題分析の再計算は API に無い (DynamoDB に直接つないで実行する)
+func (r remoteServices) Rebuild() (*dto.ItemAnalysis, error) {
+	return nil, errRemoteUnsupported
+}
+
+// ClassroomServicer
+
+func (r remoteServices) CreateClassroom(actor *identity.Principal, name string) (*dto.Classroom, error) {
+	return r.c.CreateClassroom(r.ctx(), dto.CreateClassroomRequest{Name: name})
+}
+
+func (r remoteServices) JoinClassroom(actor *identity.Principal, joinCode string) (*dto.Classroom, error) {
+	return r.c.JoinClassroom(r.ctx(), dto.JoinClassroomRequest{JoinCode: joinCode})
+}
+
+func (r remoteServices) ListClassrooms(actor *identity.Principal) ([]dto.Classroom, error) {
+	list, err := r.c.ListClassrooms(r.ctx())
+	if err != nil {
+		return nil, err
+	}
+	return list.Classes, nil
+}
+
+func (r remoteServices) GetClassroom(actor *identity.Principal, classID uint64) (*dto.ClassroomDetail, error) {
+	return r.c.GetClassroom(r.ctx(), classID)
+}
+
+func (r remoteServices) RemoveStudent(actor *identity.Principal, classID uint64, studentSub string) error {
+	return r.c.RemoveStudent(r.ctx(), classID, studentSub)
+}
+
+// 生徒の成績は API では GetStudentMypage などで取得する
+func (r remoteServices) StudentUser(actor *identity.Principal, classID uint64, studentSub string) (*model.User, error) {
+	return nil, errRemoteUnsupported
+}
+
+// AssignmentServicer
+
+func (r remoteServices) CreateAssignment(actor *identity.Principal, classID uint64, req dto.CreateAssignmentRequest) (*dto.Assignment, error) {
+	return r.c.CreateAssignment(r.ctx(), classID, req)
+}
+
+func (r remoteServices) ListAssignments(actor *identity.Principal, classID uint64) ([]dto.Assignment, error) {
+	list, err := r.c.ListAssignments(r.ctx(), classID)
+	if err != nil {
+		return nil, err
+	}
+	return list.Assignments, nil
+}
+
+func (r remoteServices) GetAssignmentStatus(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentStatus, error) {
+	return r.c.GetAssignmentStatus(r.ctx(), classID, assignmentID)
+}
+
+// 生成したクライアントは 200 (続きから) と 201 (新規) を区別しないので使わない
+func (r remoteServices) StartAttempt(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error) {
+	return nil, false, errRemoteUnsupported
+}
+
+// ClassAnalyticsServicer
+
+func (r remoteServices) GetClassAnalytics(actor *identity.Principal, classID uint64) (*dto.ClassAnalytics, error) {
+	return r.c.GetClassAnalytics(r.ctx(), classID)
+}
+
+// APIKeyServicer
+
+func (r remoteServices) CreateAPIKey(actor *identity.Principal, req dto.CreateAPIKeyRequest) (*dto.CreatedAPIKey, error) {
+	return r.c.CreateAPIKey(r.ctx(), req)
+}
+
+func (r remoteServices) ListAPIKeys(actor *identity.Principal) ([]dto.APIKey, error) {
+	return r.c.ListAPIKeys(r.ctx())
+}
+
+func (r remoteServices) RevokeAPIKey(actor *identity.Principal, keyID string) error {
+	return r.c.RevokeAPIKey(r.ctx(), keyID)
+}
+
+// RoleServicer
+
+func (r remoteServices) HasRole(ctx context.Context, p *identity.Principal, role string) (bool, error) {
+	roles, err := r.c.GetMyRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	return identity.RolesInclude(roles.Roles, role), nil
+}
+
+// 自分の役割は管理者でなくても取得できる
+func (r remoteServices) GetRoles(actor *identity.Principal, userSub string) (*dto.UserRoles, error) {
+	if userSub == actor.Sub {
+		return r.c.GetMyRoles(r.ctx())
+	}
+	return r.c.GetUserRoles(r.ctx(), userSub)
+}
+
+func (r remoteServices) GrantRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	return r.c.GrantRole(r.ctx(), userSub, role)
+}
+
+func (r remoteServices) RevokeRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	return r.c.RevokeRole(r.ctx(), userSub, role)
+}
+
+// ProfileServicer
+
+func (r remoteServices) GetProfile(actor *identity.Principal) (*dto.Profile, error) {
+	return r.c.GetProfile(r.ctx())
+}
+
+func (r remoteServices) UpdateProfile(actor *identity.Principal, req dto.UpdateProfileRequest) (*dto.Profile, error) {
+	return r.c.UpdateProfile(r.ctx(), req)
+}
+
+func (r remoteServices) User(actor *identity.Principal) (*model.User, error) {
+	p, err := r.c.GetProfile(r.ctx())
+	if err != nil {
+		return nil, err
+	}
+	u := actor.User()
+	u.UserName = p.DisplayName
+	// 読み込めないタイムゾーンは nil (JST) のまま
+	u.Location, _ = time.LoadLocation(p.Timezone)
+	return u, nil
+}
+
+// AccountServicer
+
+// 自分のデータは管理者でなくても取得できる
+func (r remoteServices) ExportAccount(actor *identity.Principal, userSub string) (*dto.AccountArchive, error) {
+	if userSub == actor.Sub {
+		return r.c.GetMyArchive(r.ctx())
+	}
+	return r.c.GetUserArchive(r.ctx(), userSub)
+}
+
+func (r remoteServices) DeleteAccount(actor *identity.Principal, userSub string, batchSize int) (*dto.AccountDeletion, error) {
+	return r.c.DeleteUserData(r.ctx(), userSub, &apiclient.DeleteUserDataParams{BatchSize: batchSize})
+}
+
+func (r remoteServices) GetAccountDeletion(actor *identity.Principal, userSub string) (*dto.AccountDeletion, error) {
+	return r.c.GetUserDeletion(r.ctx(), userSub)
+}
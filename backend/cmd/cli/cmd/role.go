@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "役割 (教師・管理者) を表示する (--user は管理者のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+		if userSub == "" {
+			userSub = actor.Sub
+		}
+
+		roles, err := roleSvc.GetRoles(actor, userSub)
+		if err != nil {
+			return fmt.Errorf("役割取得失敗: %w", err)
+		}
+		printRoles(roles)
+		return nil
+	},
+}
+
+var roleGrantCmd = &cobra.Command{
+	Use:   "grant",
+	Short: "ユーザーに役割を付与する (管理者のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+		role, _ := cmd.Flags().GetString("role")
+
+		roles, err := roleSvc.GrantRole(actor, userSub, role)
+		if err != nil {
+			return fmt.Errorf("役割付与失敗: %w", err)
+		}
+		printRoles(roles)
+		return nil
+	},
+}
+
+var roleRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "ユーザーの役割を剥奪する (管理者のみ)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		userSub, _ := cmd.Flags().GetString("user")
+		role, _ := cmd.Flags().GetString("role")
+
+		roles, err := roleSvc.RevokeRole(actor, userSub, role)
+		if err != nil {
+			return fmt.Errorf("役割剥奪失敗: %w", err)
+		}
+		printRoles(roles)
+		return nil
+	},
+}
+
+func printRoles(r *dto.UserRoles) {
+	if len(r.Roles) == 0 {
+		fmt.Printf("%s: 生徒\n", r.UserSub)
+		return
+	}
+	fmt.Printf("%s: %s\n", r.UserSub, strings.Join(r.Roles, ", "))
+}
+
+func init() {
+	roleCmd.Flags().String("user", "", "ユーザーの Cognito sub (省略時は自分)")
+
+	for _, c := range []*cobra.Command{roleGrantCmd, roleRevokeCmd} {
+		c.Flags().String("user", "", "ユーザーの Cognito sub")
+		c.Flags().String("role", "", "役割 (teacher / admin)")
+		c.MarkFlagRequired("user")
+		c.MarkFlagRequired("role")
+	}
+
+	roleCmd.AddCommand(roleGrantCmd, roleRevokeCmd)
+	rootCmd.AddCommand(roleCmd)
+}
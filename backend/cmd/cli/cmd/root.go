@@ -10,35 +10,52 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/spf13/cobra"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
 
 var (
-	userSub     string
-	testSessSvc service.TestSessionServicer
-	mypageSvc   service.MypageServicer
+	testSessSvc   service.TestSessionServicer
+	mypageSvc     service.MypageServicer
+	analysisSvc   service.ItemAnalysisServicer
+	classroomSvc  service.ClassroomServicer
+	assignmentSvc service.AssignmentServicer
+	classStatsSvc service.ClassAnalyticsServicer
+	apiKeySvc     service.APIKeyServicer
+	roleSvc       service.RoleServicer
+	profileSvc    service.ProfileServicer
+	accountSvc    service.AccountServicer
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "mathovercome",
 	Short: "MathOvercome CLI",
+	// エラーは Execute で API や MCP と同じ "説明 (コード)" の形に揃えて表示する
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		return setupServices()
+		if apiURL, _ := cmd.Flags().GetString("api-url"); apiURL != "" {
+			if err := setupRemote(apiURL); err != nil {
+				return err
+			}
+		} else if err := setupServices(); err != nil {
+			return err
+		}
+		return login(cmd)
 	},
 }
 
+func init() {
+	rootCmd.PersistentFlags().String("api-url", os.Getenv(apiURLEnv), "HTTP API の URL。指定すると DynamoDB ではなく API を呼び出す (環境変数 "+apiURLEnv+")")
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, "エラー:", apperr.Format(err))
 		os.Exit(1)
 	}
 }
 
-func init() {
-	rootCmd.PersistentFlags().StringVar(&userSub, "user", "", "ユーザーID")
-}
-
 func setupServices() error {
 	region := os.Getenv("AWS_REGION")
 	if region == "" {
@@ -59,8 +76,17 @@ func setupServices() error {
 	client := dynamodb.NewFromConfig(cfg, opts...)
 
 	repo := repository.NewRepository(client)
-	testSessSvc = service.NewTestSessionService(repo)
-	mypageSvc = service.NewMypageService(repo)
+	policy := service.NewAccessPolicy(repo)
+	testSessSvc = service.NewTestSessionService(repo).WithAccessPolicy(policy)
+	mypageSvc = service.NewMypageService(repo).WithAccessPolicy(policy)
+	analysisSvc = service.NewItemAnalysisService(repo)
+	classroomSvc = service.NewClassroomService(repo)
+	assignmentSvc = service.NewAssignmentService(repo)
+	classStatsSvc = service.NewClassAnalyticsService(repo)
+	apiKeySvc = service.NewAPIKeyService(repo)
+	roleSvc = service.NewRoleService(repo).WithBootstrapFromEnv()
+	profileSvc = service.NewProfileService(repo)
+	accountSvc = service.NewAccountService(repo, roleSvc)
 
 	return nil
 }
@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
 )
 
 var sessionCmd = &cobra.Command{
@@ -19,12 +22,24 @@ var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "テストセッションを開始する",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if userSub == "" {
-			return fmt.Errorf("--user フラグが必要です")
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		// 指定しなかったフラグはプロフィールの既定値を使う
+		profile, err := profileSvc.GetProfile(actor)
+		if err != nil {
+			return fmt.Errorf("プロフィール取得失敗: %w", err)
+		}
+		includeIntegers, examMode := profile.SessionDefaults.IncludeIntegers, profile.SessionDefaults.ExamMode
+		if cmd.Flags().Changed("integers") {
+			includeIntegers, _ = cmd.Flags().GetBool("integers")
+		}
+		if cmd.Flags().Changed("exam") {
+			examMode, _ = cmd.Flags().GetBool("exam")
 		}
-		includeIntegers, _ := cmd.Flags().GetBool("integers")
 
-		sess, err := testSessSvc.CreateTestSess(userSub, includeIntegers)
+		sess, err := testSessSvc.CreateTestSess(actor, includeIntegers, examMode)
 		if err != nil {
 			return fmt.Errorf("セッション作成失敗: %w", err)
 		}
@@ -40,16 +55,17 @@ var problemCmd = &cobra.Command{
 	Short: "問題を表示する (0始まり)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if userSub == "" {
-			return fmt.Errorf("--user フラグが必要です")
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
 		}
 		idx, err := strconv.Atoi(args[0])
 		if err != nil {
-			return fmt.Errorf("idxは数値で指定してください")
+			return apperr.InvalidParameter("idx", "idx は0以上の整数で指定してください")
 		}
 		sessionID, _ := cmd.Flags().GetUint64("session")
 
-		p, err := testSessSvc.GetProblem(sessionID, userSub, idx)
+		p, err := testSessSvc.GetProblem(sessionID, actor, idx)
 		if err != nil {
 			return fmt.Errorf("問題取得失敗: %w", err)
 		}
@@ -59,11 +75,11 @@ var problemCmd = &cobra.Command{
 		for i, c := range p.Choices {
 			fmt.Printf("%d) %s  (id:%d)\n", i+1, c.ChoiceText, c.ID)
 		}
-		if p.Hint != "" {
-			fmt.Printf("\nヒント: %s\n", p.Hint)
+		if p.HintAvailable {
+			fmt.Printf("\nヒントあり (session hint %d --session %d で表示)\n", idx, sessionID)
 		}
 		if p.SelectedID != nil {
-			fmt.Printf("\n回答済み (選択肢ID: %d)\n", *p.SelectedID)
+			fmt.Printf("\n回答済み (選択肢ID: %d, version: %d)\n", *p.SelectedID, p.Version)
 		}
 		return nil
 	},
@@ -74,17 +90,23 @@ var answerCmd = &cobra.Command{
 	Short: "回答を送信する (0始まり)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if userSub == "" {
-			return fmt.Errorf("--user フラグが必要です")
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
 		}
 		idx, err := strconv.Atoi(args[0])
 		if err != nil {
-			return fmt.Errorf("idxは数値で指定してください")
+			return apperr.InvalidParameter("idx", "idx は0以上の整数で指定してください")
 		}
 		sessionID, _ := cmd.Flags().GetUint64("session")
 		choiceID, _ := cmd.Flags().GetInt64("choice")
+		var version *int64
+		if cmd.Flags().Changed("version") {
+			v, _ := cmd.Flags().GetInt64("version")
+			version = &v
+		}
 
-		if err := testSessSvc.SubmitAnswer(sessionID, userSub, idx, &choiceID); err != nil {
+		if err := testSessSvc.SubmitAnswer(sessionID, actor, idx, &choiceID, version); err != nil {
 			return fmt.Errorf("回答送信失敗: %w", err)
 		}
 
@@ -97,15 +119,36 @@ var playCmd = &cobra.Command{
 	Use:   "play",
 	Short: "テストセッションを対話形式で進める",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if userSub == "" {
-			return fmt.Errorf("--user フラグが必要です")
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
 		}
 		sessionID, _ := cmd.Flags().GetUint64("session")
 
+		// 中断したセッションは最初の未回答の問題から再開する
+		start := 0
+		if !cmd.Flags().Changed("from") {
+			user, err := currentUser(cmd)
+			if err != nil {
+				return err
+			}
+			state, err := testSessSvc.GetSession(sessionID, user)
+			if err != nil {
+				return fmt.Errorf("セッション取得失敗: %w", err)
+			}
+			if state.NextIdx == nil {
+				fmt.Println("全問題に回答済みです (回答を見直す場合は --from 0)")
+				return nil
+			}
+			start = *state.NextIdx
+		} else {
+			start, _ = cmd.Flags().GetInt("from")
+		}
+
 		scanner := bufio.NewScanner(os.Stdin)
 
-		for idx := 0; ; idx++ {
-			p, err := testSessSvc.GetProblem(sessionID, userSub, idx)
+		for idx := start; ; idx++ {
+			p, err := testSessSvc.GetProblem(sessionID, actor, idx)
 			if err != nil {
 				fmt.Println("\n全問題が終わりました")
 				break
@@ -116,15 +159,29 @@ var playCmd = &cobra.Command{
 			for i, c := range p.Choices {
 				fmt.Printf("  %d) %s  (id:%d)\n", i+1, c.ChoiceText, c.ID)
 			}
-			if p.Hint != "" {
-				fmt.Printf("\nヒント: %s\n", p.Hint)
-			}
 
-			fmt.Print("\n選択肢IDを入力 (スキップ: s): ")
+			prompt := "\n選択肢IDを入力 (スキップ: s): "
+			if p.HintAvailable {
+				prompt = "\n選択肢IDを入力 (ヒント: h, スキップ: s): "
+			}
+			fmt.Print(prompt)
 			if !scanner.Scan() {
 				break
 			}
 			input := strings.TrimSpace(scanner.Text())
+			if input == "h" && p.HintAvailable {
+				h, err := testSessSvc.RevealHint(sessionID, actor, idx)
+				if err != nil {
+					fmt.Printf("ヒント取得エラー: %s\n", apperr.Format(err))
+				} else {
+					fmt.Printf("ヒント: %s\n", h.Hint)
+				}
+				fmt.Print("選択肢IDを入力 (スキップ: s): ")
+				if !scanner.Scan() {
+					break
+				}
+				input = strings.TrimSpace(scanner.Text())
+			}
 			if input == "s" || input == "" {
 				fmt.Println("スキップしました")
 				continue
@@ -136,8 +193,8 @@ var playCmd = &cobra.Command{
 				continue
 			}
 
-			if err := testSessSvc.SubmitAnswer(sessionID, userSub, idx, &choiceID); err != nil {
-				fmt.Printf("送信エラー: %v\n", err)
+			if err := testSessSvc.SubmitAnswer(sessionID, actor, idx, &choiceID, nil); err != nil {
+				fmt.Printf("送信エラー: %s\n", apperr.Format(err))
 				continue
 			}
 			fmt.Println("回答しました")
@@ -147,8 +204,169 @@ var playCmd = &cobra.Command{
 	},
 }
 
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "自分のセッションを新しい順に一覧する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+		status, _ := cmd.Flags().GetString("status")
+		limit, _ := cmd.Flags().GetInt("limit")
+		cursor, _ := cmd.Flags().GetString("cursor")
+
+		list, err := testSessSvc.ListSessions(user, dto.SessionListQuery{Limit: limit, Cursor: cursor, Status: status})
+		if err != nil {
+			return fmt.Errorf("セッション一覧取得失敗: %w", err)
+		}
+
+		if len(list.Sessions) == 0 {
+			fmt.Println("セッションはありません")
+		}
+		for _, s := range list.Sessions {
+			mode := "通常"
+			if s.ExamMode {
+				mode = "試験"
+			}
+			if s.AssignmentID != "" {
+				mode += fmt.Sprintf(" 課題%s-%d回目", s.AssignmentID, s.Attempt)
+			}
+			fmt.Printf("%s  %s  %-11s  %d/%d問回答  [%s]\n", s.SessionID, s.CreatedAt, s.Status, s.Answered, s.Total, mode)
+		}
+		if list.NextCursor != "" {
+			fmt.Printf("\n続き: session list --cursor %s\n", list.NextCursor)
+		}
+		return nil
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "セッションの進み具合と、次に解く問題を表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := currentUser(cmd)
+		if err != nil {
+			return err
+		}
+		sessionID, _ := cmd.Flags().GetUint64("session")
+
+		state, err := testSessSvc.GetSession(sessionID, user)
+		if err != nil {
+			return fmt.Errorf("セッション取得失敗: %w", err)
+		}
+
+		fmt.Printf("セッション %s (%s)  開始: %s\n", state.SessionID, state.Status, state.CreatedAt)
+		fmt.Printf("回答済み: %d/%d問\n", state.Answered, state.Total)
+		if state.Deadline != "" {
+			fmt.Printf("制限時間の終わり: %s\n", state.Deadline)
+		}
+		if state.ClosesAt != "" {
+			fmt.Printf("締切: %s\n", state.ClosesAt)
+		}
+		for _, p := range state.Problems {
+			mark := "  "
+			if p.Answered {
+				mark = "済"
+			}
+			fmt.Printf("  %s 問題%d [%s]\n", mark, p.Idx+1, p.CategoryName)
+		}
+		switch {
+		case !state.AcceptsAnswers:
+			fmt.Println("\nこのセッションには回答できません")
+		case state.NextIdx != nil:
+			fmt.Printf("\n次の問題: %d (session play --session %s で再開)\n", *state.NextIdx, state.SessionID)
+		default:
+			fmt.Printf("\n全問題に回答済みです (session finish --session %s で終了)\n", state.SessionID)
+		}
+		return nil
+	},
+}
+
+var hintCmd = &cobra.Command{
+	Use:   "hint <idx>",
+	Short: "ヒントを表示する (0始まり)。表示したことが記録される",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil {
+			return apperr.InvalidParameter("idx", "idx は0以上の整数で指定してください")
+		}
+		sessionID, _ := cmd.Flags().GetUint64("session")
+
+		h, err := testSessSvc.RevealHint(sessionID, actor, idx)
+		if err != nil {
+			return fmt.Errorf("ヒント取得失敗: %w", err)
+		}
+
+		fmt.Printf("ヒント: %s\n", h.Hint)
+		return nil
+	},
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "セッション内の回答履歴を時系列で表示する",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		sessionID, _ := cmd.Flags().GetUint64("session")
+
+		h, err := testSessSvc.GetAnswerHistory(sessionID, actor)
+		if err != nil {
+			return fmt.Errorf("回答履歴取得失敗: %w", err)
+		}
+
+		fmt.Printf("=== 回答履歴 (セッション %d) ===\n", h.SessionID)
+		for _, e := range h.Timeline {
+			mark := "✗"
+			if e.IsCorrect {
+				mark = "○"
+			}
+			fmt.Printf("%s  問題%d  選択肢ID:%d  %s\n", e.AnsweredAt, e.Idx+1, e.ChoiceID, mark)
+		}
+
+		fmt.Println("\n=== 問題別 ===")
+		for _, p := range h.Problems {
+			if p.Attempts == 0 {
+				continue
+			}
+			fmt.Printf("問題%d [%s]  回答%d回  正→誤:%d  誤→正:%d\n",
+				p.Idx+1, p.CategoryName, p.Attempts, p.RightToWrong, p.WrongToRight)
+		}
+		fmt.Printf("\n合計  正→誤:%d  誤→正:%d\n", h.RightToWrong, h.WrongToRight)
+		return nil
+	},
+}
+
+var finishCmd = &cobra.Command{
+	Use:   "finish",
+	Short: "セッションを終了する。未回答の問題は不正解として分野別の累計に加わる",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actor, err := currentPrincipal(cmd)
+		if err != nil {
+			return err
+		}
+		sessionID, _ := cmd.Flags().GetUint64("session")
+
+		if err := testSessSvc.FinishSession(sessionID, actor); err != nil {
+			return fmt.Errorf("セッション終了失敗: %w", err)
+		}
+
+		fmt.Println("セッションを終了しました")
+		return nil
+	},
+}
+
 func init() {
-	createCmd.Flags().Bool("integers", false, "整数問題を含める")
+	createCmd.Flags().Bool("integers", false, "整数問題を含める (省略時はプロフィールの既定値)")
+	createCmd.Flags().Bool("exam", false, "試験モード (ヒントを表示しない。省略時はプロフィールの既定値)")
 
 	problemCmd.Flags().Uint64("session", 0, "セッションID")
 	problemCmd.MarkFlagRequired("session")
@@ -157,10 +375,28 @@ func init() {
 	answerCmd.MarkFlagRequired("session")
 	answerCmd.Flags().Int64("choice", 0, "選択肢ID")
 	answerCmd.MarkFlagRequired("choice")
+	answerCmd.Flags().Int64("version", 0, "problem で表示された version (省略時は最新の回答状態に対して送信)")
+
+	hintCmd.Flags().Uint64("session", 0, "セッションID")
+	hintCmd.MarkFlagRequired("session")
 
 	playCmd.Flags().Uint64("session", 0, "セッションID")
 	playCmd.MarkFlagRequired("session")
+	playCmd.Flags().Int("from", 0, "この問題から始める (0始まり。省略時は最初の未回答の問題)")
+
+	listCmd.Flags().String("status", "", "状態で絞り込む (in_progress / finished / expired)")
+	listCmd.Flags().Int("limit", 0, "件数 (省略時は20)")
+	listCmd.Flags().String("cursor", "", "前回の一覧の続き")
+
+	showCmd.Flags().Uint64("session", 0, "セッションID")
+	showCmd.MarkFlagRequired("session")
+
+	historyCmd.Flags().Uint64("session", 0, "セッションID")
+	historyCmd.MarkFlagRequired("session")
+
+	finishCmd.Flags().Uint64("session", 0, "セッションID")
+	finishCmd.MarkFlagRequired("session")
 
-	sessionCmd.AddCommand(createCmd, problemCmd, answerCmd, playCmd)
+	sessionCmd.AddCommand(createCmd, listCmd, showCmd, problemCmd, hintCmd, answerCmd, playCmd, historyCmd, finishCmd)
 	rootCmd.AddCommand(sessionCmd)
 }
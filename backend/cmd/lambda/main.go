@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/router"
 )
 
@@ -30,7 +31,12 @@ func init() {
 
 	client := dynamodb.NewFromConfig(cfg)
 
-	r := router.New(client)
+	authCfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load auth config: %v", err)
+	}
+
+	r := router.New(client, auth.NewVerifier(authCfg))
 	ginLambda = ginadapter.NewV2(r)
 }
 
@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/router"
 )
 
@@ -31,7 +32,12 @@ func main() {
 	}
 	client := dynamodb.NewFromConfig(cfg, opts...)
 
-	r := router.New(client)
+	authCfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load auth config: %v", err)
+	}
+
+	r := router.New(client, auth.NewVerifier(authCfg))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -13,7 +13,9 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
-	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
@@ -38,25 +40,38 @@ func main() {
 	client := dynamodb.NewFromConfig(cfg, opts...)
 
 	repo := repository.NewRepository(client)
-	testSessSvc := service.NewTestSessionService(repo)
-	mypageSvc := service.NewMypageService(repo)
+	policy := service.NewAccessPolicy(repo)
+	testSessSvc := service.NewTestSessionService(repo).WithAccessPolicy(policy)
+	mypageSvc := service.NewMypageService(repo).WithAccessPolicy(policy)
+	profileSvc := service.NewProfileService(repo)
 
-	s := server.NewMCPServer("mathovercome", "1.0.0")
+	// 利用者はツールの引数ではなく、起動時に渡された API キー (POST /v1/api-keys で発行) から決める
+	actor, err := service.NewAPIKeyService(repo).Authenticate(context.Background(), os.Getenv("MATHOVERCOME_API_KEY"))
+	if err != nil {
+		log.Fatalf("API キーの認証に失敗 (MATHOVERCOME_API_KEY を確認してください): %v", err)
+	}
+
+	s := server.NewMCPServer("mathovercome", "1.0.0", server.WithToolHandlerMiddleware(withPrincipal(actor)))
 
 	// create_test_session
 	s.AddTool(
 		mcp.NewTool("create_test_session",
 			mcp.WithDescription("数学のテストセッションを作成する。セッションIDを返すので以降のツールで使う。"),
-			mcp.WithString("user_sub", mcp.Required(), mcp.Description("ユーザーID")),
-			mcp.WithBoolean("include_integers", mcp.Description("整数問題を含めるか（デフォルト: false）")),
+			mcp.WithBoolean("include_integers", mcp.Description("整数問題を含めるか（デフォルト: プロフィールの既定値）")),
+			mcp.WithBoolean("exam_mode", mcp.Description("試験モード。ヒントを表示できなくする（デフォルト: プロフィールの既定値）")),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			userSub := req.GetString("user_sub", "")
-			includeIntegers := req.GetBool("include_integers", false)
+			actor, _ := identity.FromContext(ctx)
+			profile, err := profileSvc.GetProfile(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+			includeIntegers := req.GetBool("include_integers", profile.SessionDefaults.IncludeIntegers)
+			examMode := req.GetBool("exam_mode", profile.SessionDefaults.ExamMode)
 
-			sess, err := testSessSvc.CreateTestSess(userSub, includeIntegers)
+			sess, err := testSessSvc.CreateTestSess(actor, includeIntegers, examMode)
 			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+				return toolError(err), nil
 			}
 
 			return mcp.NewToolResultText(fmt.Sprintf(
@@ -70,93 +85,403 @@ func main() {
 	s.AddTool(
 		mcp.NewTool("get_problem",
 			mcp.WithDescription("テストセッションの問題を取得する。問題文と選択肢を返す。"),
-			mcp.WithString("user_sub", mcp.Required(), mcp.Description("ユーザーID")),
 			mcp.WithString("session_id", mcp.Required(), mcp.Description("セッションID（create_test_sessionで返された文字列をそのまま使う）")),
 			mcp.WithNumber("index", mcp.Required(), mcp.Description("問題のインデックス（0始まり）")),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			userSub := req.GetString("user_sub", "")
+			actor, _ := identity.FromContext(ctx)
 			sessionIDStr := req.GetString("session_id", "")
 			sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("session_idが不正です: %v", err)), nil
+				return toolError(apperr.InvalidParameter("session_id", "session_id は数値で指定してください")), nil
 			}
 			idx := int(req.GetFloat("index", 0))
 
-			p, err := testSessSvc.GetProblem(sessionID, userSub, idx)
+			p, err := testSessSvc.GetProblem(sessionID, actor, idx)
 			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+				return toolError(err), nil
 			}
 
-			result := fmt.Sprintf("[問題 %d/%d]\nQ: %s\n\n選択肢:\n", idx+1, p.Total, p.Question)
+			result := fmt.Sprintf("[問題 %d/%d] (version: %d)\nQ: %s\n\n選択肢:\n", idx+1, p.Total, p.Version, p.Question)
 			for i, c := range p.Choices {
 				result += fmt.Sprintf("%d) %s (id:%d)\n", i+1, c.ChoiceText, c.ID)
 			}
-			if p.Hint != "" {
-				result += fmt.Sprintf("\nヒント: %s", p.Hint)
+			if p.HintAvailable {
+				result += "\nヒントあり（get_hintで表示。表示すると記録される）"
 			}
 
 			return mcp.NewToolResultText(result), nil
 		},
 	)
 
+	// get_hint
+	s.AddTool(
+		mcp.NewTool("get_hint",
+			mcp.WithDescription("問題のヒントを取得する。ヒントを見たことが記録され、成績ではヒントありの回答として区別される。"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("セッションID")),
+			mcp.WithNumber("index", mcp.Required(), mcp.Description("問題のインデックス（0始まり）")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			sessionIDStr := req.GetString("session_id", "")
+			sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
+			if err != nil {
+				return toolError(apperr.InvalidParameter("session_id", "session_id は数値で指定してください")), nil
+			}
+			idx := int(req.GetFloat("index", 0))
+
+			h, err := testSessSvc.RevealHint(sessionID, actor, idx)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			return mcp.NewToolResultText(fmt.Sprintf("ヒント: %s", h.Hint)), nil
+		},
+	)
+
 	// submit_answer
 	s.AddTool(
 		mcp.NewTool("submit_answer",
 			mcp.WithDescription("問題に回答を送信する。選択肢IDはget_problemで取得したidを使う。"),
-			mcp.WithString("user_sub", mcp.Required(), mcp.Description("ユーザーID")),
 			mcp.WithString("session_id", mcp.Required(), mcp.Description("セッションID（create_test_sessionで返された文字列をそのまま使う）")),
 			mcp.WithNumber("index", mcp.Required(), mcp.Description("問題のインデックス（0始まり）")),
 			mcp.WithString("choice_id", mcp.Required(), mcp.Description("選択肢ID（get_problemで取得したidを文字列で渡す）")),
+			mcp.WithNumber("version", mcp.Description("get_problemで取得したversion。他の回答と競合した場合はエラーになる")),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			userSub := req.GetString("user_sub", "")
+			actor, _ := identity.FromContext(ctx)
 			sessionIDStr := req.GetString("session_id", "")
 			sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("session_idが不正です: %v", err)), nil
+				return toolError(apperr.InvalidParameter("session_id", "session_id は数値で指定してください")), nil
 			}
 			idx := int(req.GetFloat("index", 0))
 			choiceIDStr := req.GetString("choice_id", "")
 			choiceID, err := strconv.ParseInt(choiceIDStr, 10, 64)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("choice_idが不正です: %v", err)), nil
+				return toolError(apperr.InvalidParameter("choice_id", "choice_id は数値で指定してください")), nil
 			}
 
-			if err := testSessSvc.SubmitAnswer(sessionID, userSub, idx, &choiceID); err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+			var version *int64
+			if _, ok := req.GetArguments()["version"]; ok {
+				v := int64(req.GetFloat("version", 0))
+				version = &v
+			}
+
+			if err := testSessSvc.SubmitAnswer(sessionID, actor, idx, &choiceID, version); err != nil {
+				return toolError(err), nil
 			}
 
 			return mcp.NewToolResultText("回答を送信しました"), nil
 		},
 	)
 
+	// finish_session
+	s.AddTool(
+		mcp.NewTool("finish_session",
+			mcp.WithDescription("テストセッションを終了する。未回答の問題は不正解として分野別の累計に加わり、以降は回答できない。"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("セッションID")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			sessionIDStr := req.GetString("session_id", "")
+			sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
+			if err != nil {
+				return toolError(apperr.InvalidParameter("session_id", "session_id は数値で指定してください")), nil
+			}
+
+			if err := testSessSvc.FinishSession(sessionID, actor); err != nil {
+				return toolError(err), nil
+			}
+
+			return mcp.NewToolResultText("セッションを終了しました"), nil
+		},
+	)
+
+	// list_sessions
+	s.AddTool(
+		mcp.NewTool("list_sessions",
+			mcp.WithDescription("ユーザーのテストセッションを新しい順に一覧する。状態と回答済みの問題数を返すので、中断したセッションを探すのに使う。"),
+			mcp.WithString("status", mcp.Description("状態で絞り込む（in_progress / finished / expired）")),
+			mcp.WithNumber("limit", mcp.Description("取得件数（デフォルト: 20、最大: 100）")),
+			mcp.WithString("cursor", mcp.Description("前回の結果で返されたカーソル")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			list, err := testSessSvc.ListSessions(user, dto.SessionListQuery{
+				Limit:  int(req.GetFloat("limit", 0)),
+				Cursor: req.GetString("cursor", ""),
+				Status: req.GetString("status", ""),
+			})
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := fmt.Sprintf("セッション数: %d\n", len(list.Sessions))
+			for _, sess := range list.Sessions {
+				result += fmt.Sprintf("- %s (%s) 状態: %s 回答済み: %d/%d", sess.SessionID, sess.CreatedAt, sess.Status, sess.Answered, sess.Total)
+				if sess.ExamMode {
+					result += " 試験モード"
+				}
+				if sess.AssignmentID != "" {
+					result += fmt.Sprintf(" 課題%s (%d回目)", sess.AssignmentID, sess.Attempt)
+				}
+				result += "\n"
+			}
+			if list.NextCursor != "" {
+				result += fmt.Sprintf("続きのカーソル: %s\n", list.NextCursor)
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// get_session
+	s.AddTool(
+		mcp.NewTool("get_session",
+			mcp.WithDescription("テストセッションの進み具合を取得する。回答済みの問題と、再開するときに次に解く問題のインデックスを返す。"),
+			mcp.WithString("session_id", mcp.Required(), mcp.Description("セッションID")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			sessionIDStr := req.GetString("session_id", "")
+			sessionID, err := strconv.ParseUint(sessionIDStr, 10, 64)
+			if err != nil {
+				return toolError(apperr.InvalidParameter("session_id", "session_id は数値で指定してください")), nil
+			}
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			state, err := testSessSvc.GetSession(sessionID, user)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := fmt.Sprintf("セッション %s (%s)\n状態: %s\n回答済み: %d/%d\n", state.SessionID, state.CreatedAt, state.Status, state.Answered, state.Total)
+			if state.Deadline != "" {
+				result += fmt.Sprintf("制限時間の終わり: %s\n", state.Deadline)
+			}
+			var unanswered []int
+			for _, p := range state.Problems {
+				if !p.Answered {
+					unanswered = append(unanswered, p.Idx)
+				}
+			}
+			if len(unanswered) > 0 {
+				result += fmt.Sprintf("未回答のインデックス: %v\n", unanswered)
+			}
+			switch {
+			case !state.AcceptsAnswers:
+				result += "このセッションには回答できません"
+			case state.NextIdx != nil:
+				result += fmt.Sprintf("次の問題のインデックス: %d（get_problemで再開）", *state.NextIdx)
+			default:
+				result += "全問題に回答済みです（finish_sessionで終了）"
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
 	// get_mypage
 	s.AddTool(
 		mcp.NewTool("get_mypage",
-			mcp.WithDescription("ユーザーの過去のテスト結果・正答率・苦手分野を取得する。"),
-			mcp.WithString("user_sub", mcp.Required(), mcp.Description("ユーザーID")),
-			mcp.WithString("user_name", mcp.Description("ユーザー名（表示用）")),
+			mcp.WithDescription("ユーザーの過去のテスト結果・正答率・苦手分野を新しい順に取得する。続きがある場合はcursorを返す。"),
+			mcp.WithNumber("limit", mcp.Description("取得件数（デフォルト: 20、最大: 100）")),
+			mcp.WithString("cursor", mcp.Description("前回の結果で返されたカーソル")),
+			mcp.WithString("from", mcp.Description("開始日（YYYY-MM-DD）")),
+			mcp.WithString("to", mcp.Description("終了日（YYYY-MM-DD、当日を含む）")),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			userSub := req.GetString("user_sub", "")
-			userName := req.GetString("user_name", "")
+			actor, _ := identity.FromContext(ctx)
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
 
-			user := &model.User{Sub: userSub, UserName: userName}
-			data, err := mypageSvc.GetUserData(user)
+			data, err := mypageSvc.GetUserData(user, dto.MypageQuery{
+				Limit:          int(req.GetFloat("limit", 0)),
+				Cursor:         req.GetString("cursor", ""),
+				From:           req.GetString("from", ""),
+				To:             req.GetString("to", ""),
+				IncludeDetails: true,
+			})
 			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
+				return toolError(err), nil
 			}
 
 			result := fmt.Sprintf("ユーザー: %s\nテストセッション数: %d\n\n", data.UserName, len(data.TestSessDtos))
 			for _, sess := range data.TestSessDtos {
 				result += fmt.Sprintf("--- セッション %d (%s) ---\n正答率: %d/%d\n",
 					sess.SessionID, sess.StartTime, sess.CorrectCount, sess.Total)
+				if sess.HintedCount > 0 {
+					result += fmt.Sprintf("うちヒントあり: %d/%d\n", sess.HintedCorrectCount, sess.HintedCount)
+				}
 				if len(sess.WeakCategories) > 0 {
 					result += fmt.Sprintf("苦手分野: %v\n", sess.WeakCategories)
 				}
+				if p := sess.Pacing; p != nil {
+					result += fmt.Sprintf("1問あたり: %.0f秒 (目標 %.0f秒, 超過 %d問)\n", p.AvgSec, p.TargetSec, p.OverTargetCount)
+				}
 				result += "\n"
 			}
+			if data.NextCursor != "" {
+				result += fmt.Sprintf("続きのカーソル: %s\n", data.NextCursor)
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// get_mypage_summary
+	s.AddTool(
+		mcp.NewTool("get_mypage_summary",
+			mcp.WithDescription("ユーザーの全セッションを通した累計正答率とセッション数を取得する。"),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			data, err := mypageSvc.GetSummary(user)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := fmt.Sprintf("テストセッション数: %d\n累計正答率: %d/%d (%.1f%%)\nヒントなしの正答率: %.1f%% (ヒントあり %d問中 %d問正解)\n",
+				data.SessionCount, data.CorrectCount, data.Total, data.Accuracy*100,
+				data.UnaidedAccuracy*100, data.HintedCount, data.HintedCorrectCount)
+			if len(data.WeakCategories) > 0 {
+				result += fmt.Sprintf("苦手分野（全セッション合算）: %v\n", data.WeakCategories)
+			}
+			if data.LastStartTime != "" {
+				result += fmt.Sprintf("最終受験: %s\n", data.LastStartTime)
+			}
+			if p := data.Pacing; p != nil {
+				result += fmt.Sprintf("1問あたり平均: %.0f秒 (目標 %.0f秒, %d問中 %d問が超過)\n",
+					p.AvgSec, p.TargetSec, p.TimedCount, p.OverTargetCount)
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// get_trends
+	s.AddTool(
+		mcp.NewTool("get_trends",
+			mcp.WithDescription("直近のセッションを通した分野別の正答率の推移・得意/苦手分野・連続学習日数を取得する。学習のアドバイスに使う。"),
+			mcp.WithNumber("sessions", mcp.Description("対象とする直近のセッション数（デフォルト: 10、最大: 100）")),
+			mcp.WithNumber("window", mcp.Description("移動正答率に使うセッション数（デフォルト: 3）")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			data, err := mypageSvc.GetTrends(user, dto.TrendQuery{
+				Sessions: int(req.GetFloat("sessions", 0)),
+				Window:   int(req.GetFloat("window", 0)),
+			})
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := fmt.Sprintf("対象セッション数: %d（移動正答率は直近%d回）\n連続学習: %d日（最長 %d日、最終 %s）\n\n",
+				data.SessionCount, data.Window, data.Streak.CurrentDays, data.Streak.LongestDays, data.Streak.LastStudyDate)
+			result += "分野別の推移（古い順の移動正答率）:\n"
+			for _, c := range data.Categories {
+				result += fmt.Sprintf("- %s:", c.CategoryName)
+				for _, p := range c.Points {
+					result += fmt.Sprintf(" %.0f%%", p.RollingAccuracy*100)
+				}
+				if c.Change != nil {
+					result += fmt.Sprintf("（変化 %+.0fpt）", *c.Change*100)
+				}
+				result += "\n"
+			}
+			for _, r := range data.Best {
+				result += fmt.Sprintf("得意: %s %d/%d\n", r.CategoryName, r.CorrectCount, r.Total)
+			}
+			for _, r := range data.Worst {
+				result += fmt.Sprintf("正答率が低い: %s %d/%d\n", r.CategoryName, r.CorrectCount, r.Total)
+			}
+			if len(data.WeakCategories) > 0 {
+				result += fmt.Sprintf("苦手分野: %v\n", data.WeakCategories)
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// get_category_stats
+	s.AddTool(
+		mcp.NewTool("get_category_stats",
+			mcp.WithDescription("ユーザーの分野別の累計成績（回答数・正答数・最終回答日時）と苦手分野を取得する。"),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+			user, err := profileSvc.User(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			data, err := mypageSvc.GetCategoryStats(user)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := "分野別の累計:\n"
+			for _, c := range data.Categories {
+				result += fmt.Sprintf("- %s: %d/%d (%.0f%%)", c.CategoryName, c.CorrectCount, c.Attempts, c.Accuracy*100)
+				if c.LastAttemptedAt != "" {
+					result += fmt.Sprintf(" 最終 %s", c.LastAttemptedAt)
+				}
+				result += "\n"
+			}
+			if len(data.WeakCategories) > 0 {
+				result += fmt.Sprintf("苦手分野: %v\n", data.WeakCategories)
+			}
+
+			return mcp.NewToolResultText(result), nil
+		},
+	)
+
+	// get_profile
+	s.AddTool(
+		mcp.NewTool("get_profile",
+			mcp.WithDescription("ユーザーのプロフィール（表示名・学年・志望・重点カテゴリ・タイムゾーン・セッションの既定値）を取得する。"),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			actor, _ := identity.FromContext(ctx)
+
+			p, err := profileSvc.GetProfile(actor)
+			if err != nil {
+				return toolError(err), nil
+			}
+
+			result := fmt.Sprintf("表示名: %s\n", p.DisplayName)
+			if p.Grade != "" {
+				result += fmt.Sprintf("学年: %s\n", p.Grade)
+			}
+			if p.TargetExam != "" {
+				result += fmt.Sprintf("志望: %s\n", p.TargetExam)
+			}
+			if len(p.PreferredCategories) > 0 {
+				result += fmt.Sprintf("重点カテゴリ: %v\n", p.PreferredCategories)
+			}
+			result += fmt.Sprintf("タイムゾーン: %s\n", p.Timezone)
+			result += fmt.Sprintf("セッションの既定値: 整数問題=%v 試験モード=%v\n", p.SessionDefaults.IncludeIntegers, p.SessionDefaults.ExamMode)
 
 			return mcp.NewToolResultText(result), nil
 		},
@@ -166,3 +491,44 @@ func main() {
 		log.Fatalf("MCP server error: %v", err)
 	}
 }
+
+// withPrincipal は各ツールの呼び出しの context に認証済みの利用者を入れる。
+// toolScopes はツールごとに API キーに必要な権限。
+var toolScopes = map[string]string{
+	"create_test_session": identity.ScopeTakeTests,
+	"get_problem":         identity.ScopeTakeTests,
+	"get_hint":            identity.ScopeTakeTests,
+	"submit_answer":       identity.ScopeTakeTests,
+	"finish_session":      identity.ScopeTakeTests,
+	"list_sessions":       identity.ScopeReadResults,
+	"get_session":         identity.ScopeReadResults,
+	"get_mypage":          identity.ScopeReadResults,
+	"get_mypage_summary":  identity.ScopeReadResults,
+	"get_trends":          identity.ScopeReadResults,
+	"get_category_stats":  identity.ScopeReadResults,
+	"get_profile":         identity.ScopeReadResults,
+}
+
+// withPrincipal は利用者を context に入れ、キーに権限の無いツールの呼び出しをエラーにする。
+// toolScopes に無いツールは呼び出せない (権限の付け忘れで素通りしないように)。
+func withPrincipal(p *identity.Principal) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			scope, ok := toolScopes[req.Params.Name]
+			if !ok || !p.HasScope(scope) {
+				return toolError(apperr.ScopeRequired(scope)), nil
+			}
+			return next(identity.NewContext(ctx, p), req)
+		}
+	}
+}
+
+// toolError はエラーを API や CLI と同じ "説明 (コード)" の形で返す。
+// 回答が競合した場合は再同期できるよう現在の状態も返す。
+func toolError(err error) *mcp.CallToolResult {
+	msg := apperr.Format(err)
+	if current, ok := apperr.From(err).Details["current"]; ok {
+		msg += fmt.Sprintf(" 現在の状態: %+v", current)
+	}
+	return mcp.NewToolResultError(msg)
+}
@@ -0,0 +1,134 @@
+// Package apiclient は MathOvercome の HTTP API のクライアント。
+// 操作ごとのメソッド (client_gen.go) は OpenAPI 文書から生成する (go generate ./internal/openapi)。
+// エラーの problem+json は *apperr.Error にするので、ローカルと同じく errors.Is や apperr.Format で扱える。
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+)
+
+// Client は API を呼び出す。Token は Authorization: Bearer で送る (Cognito の ID トークンまたは API キー)。
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New は baseURL (例: https://api.example.com) の API を token で呼び出すクライアントを作る。
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// do はリクエストを送り、成功時のレスポンスの JSON を out に読み込む (out が nil なら読まない)。
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("リクエストの作成に失敗: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return fmt.Errorf("リクエストの作成に失敗: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("API に接続できません: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeProblem(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("レスポンスの読み込みに失敗 (%s %s): %w", method, path, err)
+	}
+	return nil
+}
+
+// problem+json の標準のメンバー。これ以外は拡張メンバーとして Details に入れる
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true, "code": true}
+
+// decodeProblem はエラーのレスポンスを *apperr.Error にする。
+// 種類 (Kind) はコードとステータスから決める。problem+json でなければ内部エラーとして本文をそのまま返す。
+func decodeProblem(resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var members map[string]json.RawMessage
+	if ct != "application/problem+json" || json.Unmarshal(b, &members) != nil {
+		return fmt.Errorf("API がエラーを返しました (%s): %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var code apperr.Code
+	var detail string
+	json.Unmarshal(members["code"], &code)
+	json.Unmarshal(members["detail"], &detail)
+	e := apperr.New(kind(code, resp.StatusCode), code, detail)
+	for k, raw := range members {
+		if problemMembers[k] {
+			continue
+		}
+		var v any
+		json.Unmarshal(raw, &v)
+		e = e.WithDetail(k, v)
+	}
+	return e
+}
+
+// kind はコードとステータスに対応するエラーの種類を返す (middleware.Errors の逆)。
+func kind(code apperr.Code, status int) error {
+	switch code {
+	case apperr.CodeProblemOutOfRange:
+		return apperr.ErrOutOfRange
+	case apperr.CodeSessionFinished:
+		return apperr.ErrSessionFinished
+	case apperr.CodeAssignmentClosed:
+		return apperr.ErrClosed
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return apperr.ErrInvalidArgument
+	case http.StatusUnauthorized:
+		return apperr.ErrUnauthenticated
+	case http.StatusForbidden:
+		return apperr.ErrForbidden
+	case http.StatusNotFound:
+		return apperr.ErrNotFound
+	case http.StatusConflict:
+		return apperr.ErrConflict
+	case http.StatusServiceUnavailable:
+		return apperr.ErrUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,546 @@
+// Code generated by go generate ./internal/openapi; DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+// GetOpenAPI はこの API の OpenAPI 文書を取得する。
+// GET /openapi.json
+func (c *Client) GetOpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/openapi.json", nil, nil, &out)
+	return out, err
+}
+
+// GetItemAnalysis は保存済みの問題ごとの分析を取得する。
+// GET /v1/admin/item-analysis
+func (c *Client) GetItemAnalysis(ctx context.Context) (*dto.ItemAnalysis, error) {
+	var out dto.ItemAnalysis
+	if err := c.do(ctx, "GET", "/v1/admin/item-analysis", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserArchive は利用者のデータ一式を取得する。
+// GET /v1/admin/users/{userSub}/archive
+func (c *Client) GetUserArchive(ctx context.Context, userSub string) (*dto.AccountArchive, error) {
+	var out dto.AccountArchive
+	if err := c.do(ctx, "GET", "/v1/admin/users/"+url.PathEscape(userSub)+"/archive", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserDeletion は利用者のデータ削除の進捗を取得する。
+// GET /v1/admin/users/{userSub}/deletion
+func (c *Client) GetUserDeletion(ctx context.Context, userSub string) (*dto.AccountDeletion, error) {
+	var out dto.AccountDeletion
+	if err := c.do(ctx, "GET", "/v1/admin/users/"+url.PathEscape(userSub)+"/deletion", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteUserDataParams は DeleteUserData のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type DeleteUserDataParams struct {
+	// 1回で削除するアイテム数の目安
+	BatchSize int
+}
+
+// DeleteUserData は利用者のデータ削除を1回分進める (status が completed になるまで繰り返し呼ぶ)。
+// POST /v1/admin/users/{userSub}/deletion
+func (c *Client) DeleteUserData(ctx context.Context, userSub string, params *DeleteUserDataParams) (*dto.AccountDeletion, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.BatchSize != 0 {
+			q.Set("batchSize", strconv.Itoa(params.BatchSize))
+		}
+	}
+	var out dto.AccountDeletion
+	if err := c.do(ctx, "POST", "/v1/admin/users/"+url.PathEscape(userSub)+"/deletion", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserRoles は利用者の役割を取得する。
+// GET /v1/admin/users/{userSub}/roles
+func (c *Client) GetUserRoles(ctx context.Context, userSub string) (*dto.UserRoles, error) {
+	var out dto.UserRoles
+	if err := c.do(ctx, "GET", "/v1/admin/users/"+url.PathEscape(userSub)+"/roles", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GrantRole は利用者に役割を付与する。
+// PUT /v1/admin/users/{userSub}/roles/{role}
+func (c *Client) GrantRole(ctx context.Context, userSub string, role string) (*dto.UserRoles, error) {
+	var out dto.UserRoles
+	if err := c.do(ctx, "PUT", "/v1/admin/users/"+url.PathEscape(userSub)+"/roles/"+url.PathEscape(role), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeRole は利用者の役割を剥奪する。
+// DELETE /v1/admin/users/{userSub}/roles/{role}
+func (c *Client) RevokeRole(ctx context.Context, userSub string, role string) (*dto.UserRoles, error) {
+	var out dto.UserRoles
+	if err := c.do(ctx, "DELETE", "/v1/admin/users/"+url.PathEscape(userSub)+"/roles/"+url.PathEscape(role), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAPIKeys は API キーを一覧する。
+// GET /v1/api-keys
+func (c *Client) ListAPIKeys(ctx context.Context) ([]dto.APIKey, error) {
+	var out []dto.APIKey
+	err := c.do(ctx, "GET", "/v1/api-keys", nil, nil, &out)
+	return out, err
+}
+
+// CreateAPIKey は API キーを発行する。
+// POST /v1/api-keys
+func (c *Client) CreateAPIKey(ctx context.Context, body dto.CreateAPIKeyRequest) (*dto.CreatedAPIKey, error) {
+	var out dto.CreatedAPIKey
+	if err := c.do(ctx, "POST", "/v1/api-keys", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeAPIKey は API キーを失効させる。
+// DELETE /v1/api-keys/{keyId}
+func (c *Client) RevokeAPIKey(ctx context.Context, keyID string) error {
+	return c.do(ctx, "DELETE", "/v1/api-keys/"+url.PathEscape(keyID), nil, nil, nil)
+}
+
+// ListClassrooms は所属するクラスを一覧する。
+// GET /v1/classes
+func (c *Client) ListClassrooms(ctx context.Context) (*dto.ClassroomList, error) {
+	var out dto.ClassroomList
+	if err := c.do(ctx, "GET", "/v1/classes", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateClassroom はクラスを作成する。
+// POST /v1/classes
+func (c *Client) CreateClassroom(ctx context.Context, body dto.CreateClassroomRequest) (*dto.Classroom, error) {
+	var out dto.Classroom
+	if err := c.do(ctx, "POST", "/v1/classes", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JoinClassroom は参加コードでクラスに参加する。
+// POST /v1/classes/join
+func (c *Client) JoinClassroom(ctx context.Context, body dto.JoinClassroomRequest) (*dto.Classroom, error) {
+	var out dto.Classroom
+	if err := c.do(ctx, "POST", "/v1/classes/join", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetClassroom はクラスと名簿を取得する (クラスの教師のみ)。
+// GET /v1/classes/{classId}
+func (c *Client) GetClassroom(ctx context.Context, classID uint64) (*dto.ClassroomDetail, error) {
+	var out dto.ClassroomDetail
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetClassAnalytics はクラスの成績の集計を取得する。
+// GET /v1/classes/{classId}/analytics
+func (c *Client) GetClassAnalytics(ctx context.Context, classID uint64) (*dto.ClassAnalytics, error) {
+	var out dto.ClassAnalytics
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/analytics", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAssignments はクラスの課題を一覧する。
+// GET /v1/classes/{classId}/assignments
+func (c *Client) ListAssignments(ctx context.Context, classID uint64) (*dto.AssignmentList, error) {
+	var out dto.AssignmentList
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/assignments", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateAssignment は課題を作成する。
+// POST /v1/classes/{classId}/assignments
+func (c *Client) CreateAssignment(ctx context.Context, classID uint64, body dto.CreateAssignmentRequest) (*dto.Assignment, error) {
+	var out dto.Assignment
+	if err := c.do(ctx, "POST", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/assignments", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartAttempt は課題を受験する (続きから受験できれば 200、新しく作成すれば 201)。
+// POST /v1/classes/{classId}/assignments/{assignmentId}/attempts
+func (c *Client) StartAttempt(ctx context.Context, classID uint64, assignmentID uint64) (*dto.AssignmentAttempt, error) {
+	var out dto.AssignmentAttempt
+	if err := c.do(ctx, "POST", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/assignments/"+strconv.FormatUint(assignmentID, 10)+"/attempts", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAssignmentStatus は課題の提出状況を取得する (クラスの教師のみ)。
+// GET /v1/classes/{classId}/assignments/{assignmentId}/status
+func (c *Client) GetAssignmentStatus(ctx context.Context, classID uint64, assignmentID uint64) (*dto.AssignmentStatus, error) {
+	var out dto.AssignmentStatus
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/assignments/"+strconv.FormatUint(assignmentID, 10)+"/status", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveStudent は生徒をクラスから外す。
+// DELETE /v1/classes/{classId}/students/{studentSub}
+func (c *Client) RemoveStudent(ctx context.Context, classID uint64, studentSub string) error {
+	return c.do(ctx, "DELETE", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/students/"+url.PathEscape(studentSub), nil, nil, nil)
+}
+
+// GetStudentCategories は生徒の分野別の累計の成績を取得する (クラスの教師のみ)。
+// GET /v1/classes/{classId}/students/{studentSub}/categories
+func (c *Client) GetStudentCategories(ctx context.Context, classID uint64, studentSub string) (*dto.CategoryStats, error) {
+	var out dto.CategoryStats
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/students/"+url.PathEscape(studentSub)+"/categories", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentMypageParams は GetStudentMypage のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type GetStudentMypageParams struct {
+	// 1ページのセッション数
+	Limit int
+	// 前のページの nextCursor
+	Cursor string
+	// この日以降のセッション (YYYY-MM-DD)
+	From string
+	// この日以前のセッション (YYYY-MM-DD)
+	To string
+	// 分野別の成績と時間配分を含める (省略時は true)
+	IncludeDetails *bool
+}
+
+// GetStudentMypage は生徒のセッションごとの成績を取得する (クラスの教師のみ)。
+// GET /v1/classes/{classId}/students/{studentSub}/mypage
+func (c *Client) GetStudentMypage(ctx context.Context, classID uint64, studentSub string, params *GetStudentMypageParams) (*dto.User, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.Limit != 0 {
+			q.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			q.Set("cursor", params.Cursor)
+		}
+		if params.From != "" {
+			q.Set("from", params.From)
+		}
+		if params.To != "" {
+			q.Set("to", params.To)
+		}
+		if params.IncludeDetails != nil {
+			q.Set("includeDetails", strconv.FormatBool(*params.IncludeDetails))
+		}
+	}
+	var out dto.User
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/students/"+url.PathEscape(studentSub)+"/mypage", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentSummary は生徒の成績の概要を取得する (クラスの教師のみ)。
+// GET /v1/classes/{classId}/students/{studentSub}/summary
+func (c *Client) GetStudentSummary(ctx context.Context, classID uint64, studentSub string) (*dto.MypageSummary, error) {
+	var out dto.MypageSummary
+	if err := c.do(ctx, "GET", "/v1/classes/"+strconv.FormatUint(classID, 10)+"/students/"+url.PathEscape(studentSub)+"/summary", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProfile はプロフィールを取得する。
+// GET /v1/me
+func (c *Client) GetProfile(ctx context.Context) (*dto.Profile, error) {
+	var out dto.Profile
+	if err := c.do(ctx, "GET", "/v1/me", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateProfile はプロフィールを更新する。
+// PUT /v1/me
+func (c *Client) UpdateProfile(ctx context.Context, body dto.UpdateProfileRequest) (*dto.Profile, error) {
+	var out dto.Profile
+	if err := c.do(ctx, "PUT", "/v1/me", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMyArchive は自分のデータ一式を取得する。
+// GET /v1/me/archive
+func (c *Client) GetMyArchive(ctx context.Context) (*dto.AccountArchive, error) {
+	var out dto.AccountArchive
+	if err := c.do(ctx, "GET", "/v1/me/archive", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportParams は Export のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type ExportParams struct {
+	// このセッションだけを書き出す (省略時は全セッション)
+	SessionID uint64
+}
+
+// Export は成績を書き出す。
+// GET /v1/me/export
+func (c *Client) Export(ctx context.Context, params *ExportParams) (*dto.Export, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.SessionID != 0 {
+			q.Set("sessionId", strconv.FormatUint(params.SessionID, 10))
+		}
+	}
+	var out dto.Export
+	if err := c.do(ctx, "GET", "/v1/me/export", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMypageParams は GetMypage のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type GetMypageParams struct {
+	// 1ページのセッション数
+	Limit int
+	// 前のページの nextCursor
+	Cursor string
+	// この日以降のセッション (YYYY-MM-DD)
+	From string
+	// この日以前のセッション (YYYY-MM-DD)
+	To string
+	// 分野別の成績と時間配分を含める (省略時は true)
+	IncludeDetails *bool
+}
+
+// GetMypage はセッションごとの成績を取得する。
+// GET /v1/me/mypage
+func (c *Client) GetMypage(ctx context.Context, params *GetMypageParams) (*dto.User, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.Limit != 0 {
+			q.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			q.Set("cursor", params.Cursor)
+		}
+		if params.From != "" {
+			q.Set("from", params.From)
+		}
+		if params.To != "" {
+			q.Set("to", params.To)
+		}
+		if params.IncludeDetails != nil {
+			q.Set("includeDetails", strconv.FormatBool(*params.IncludeDetails))
+		}
+	}
+	var out dto.User
+	if err := c.do(ctx, "GET", "/v1/me/mypage", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMypageCategories は分野別の累計の成績を取得する。
+// GET /v1/me/mypage/categories
+func (c *Client) GetMypageCategories(ctx context.Context) (*dto.CategoryStats, error) {
+	var out dto.CategoryStats
+	if err := c.do(ctx, "GET", "/v1/me/mypage/categories", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMypageSummary は成績の概要を取得する。
+// GET /v1/me/mypage/summary
+func (c *Client) GetMypageSummary(ctx context.Context) (*dto.MypageSummary, error) {
+	var out dto.MypageSummary
+	if err := c.do(ctx, "GET", "/v1/me/mypage/summary", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMypageTrendsParams は GetMypageTrends のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type GetMypageTrendsParams struct {
+	// 対象にする直近のセッション数
+	Sessions int
+	// 移動平均のセッション数
+	Window int
+}
+
+// GetMypageTrends は分野別の正答率の推移を取得する。
+// GET /v1/me/mypage/trends
+func (c *Client) GetMypageTrends(ctx context.Context, params *GetMypageTrendsParams) (*dto.Trends, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.Sessions != 0 {
+			q.Set("sessions", strconv.Itoa(params.Sessions))
+		}
+		if params.Window != 0 {
+			q.Set("window", strconv.Itoa(params.Window))
+		}
+	}
+	var out dto.Trends
+	if err := c.do(ctx, "GET", "/v1/me/mypage/trends", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMyRoles は自分の役割を取得する。
+// GET /v1/me/roles
+func (c *Client) GetMyRoles(ctx context.Context) (*dto.UserRoles, error) {
+	var out dto.UserRoles
+	if err := c.do(ctx, "GET", "/v1/me/roles", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSessionsParams は ListSessions のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type ListSessionsParams struct {
+	// 状態で絞り込む
+	Status string
+	// 1ページの件数
+	Limit int
+	// 前のページの nextCursor
+	Cursor string
+}
+
+// ListSessions は自分のセッションを新しい順に一覧する。
+// GET /v1/sessions
+func (c *Client) ListSessions(ctx context.Context, params *ListSessionsParams) (*dto.SessionList, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.Status != "" {
+			q.Set("status", params.Status)
+		}
+		if params.Limit != 0 {
+			q.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			q.Set("cursor", params.Cursor)
+		}
+	}
+	var out dto.SessionList
+	if err := c.do(ctx, "GET", "/v1/sessions", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateSessionParams は CreateSession のクエリパラメータ。ゼロ値 (nil) の項目は送らない。
+type CreateSessionParams struct {
+	// 整数の問題を含める (省略時はプロフィールの既定値)
+	IncludeIntegers *bool
+	// 試験モードにする (省略時はプロフィールの既定値)
+	ExamMode *bool
+}
+
+// CreateSession はセッションを作成する。
+// POST /v1/sessions
+func (c *Client) CreateSession(ctx context.Context, params *CreateSessionParams) (*dto.CreatedSession, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.IncludeIntegers != nil {
+			q.Set("includeIntegers", strconv.FormatBool(*params.IncludeIntegers))
+		}
+		if params.ExamMode != nil {
+			q.Set("examMode", strconv.FormatBool(*params.ExamMode))
+		}
+	}
+	var out dto.CreatedSession
+	if err := c.do(ctx, "POST", "/v1/sessions", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSession はセッションを再開するための状態を取得する。
+// GET /v1/sessions/{sessionId}
+func (c *Client) GetSession(ctx context.Context, sessionID uint64) (*dto.SessionState, error) {
+	var out dto.SessionState
+	if err := c.do(ctx, "GET", "/v1/sessions/"+strconv.FormatUint(sessionID, 10), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FinishSession はセッションを終了する。
+// POST /v1/sessions/{sessionId}/finish
+func (c *Client) FinishSession(ctx context.Context, sessionID uint64) error {
+	return c.do(ctx, "POST", "/v1/sessions/"+strconv.FormatUint(sessionID, 10)+"/finish", nil, nil, nil)
+}
+
+// GetAnswerHistory は回答の履歴を取得する。
+// GET /v1/sessions/{sessionId}/history
+func (c *Client) GetAnswerHistory(ctx context.Context, sessionID uint64) (*dto.AnswerHistory, error) {
+	var out dto.AnswerHistory
+	if err := c.do(ctx, "GET", "/v1/sessions/"+strconv.FormatUint(sessionID, 10)+"/history", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProblem は問題を取得する。
+// GET /v1/sessions/{sessionId}/problems/{idx}
+func (c *Client) GetProblem(ctx context.Context, sessionID uint64, idx int) (*dto.SessionProblem, error) {
+	var out dto.SessionProblem
+	if err := c.do(ctx, "GET", "/v1/sessions/"+strconv.FormatUint(sessionID, 10)+"/problems/"+strconv.Itoa(idx), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitAnswer は回答を送信する (version が古ければ 409)。
+// PUT /v1/sessions/{sessionId}/problems/{idx}/answer
+func (c *Client) SubmitAnswer(ctx context.Context, sessionID uint64, idx int, body dto.AnswerRequest) error {
+	return c.do(ctx, "PUT", "/v1/sessions/"+strconv.FormatUint(sessionID, 10)+"/problems/"+strconv.Itoa(idx)+"/answer", nil, body, nil)
+}
+
+// RevealHint はヒントを表示する (試験モードでは 403)。
+// POST /v1/sessions/{sessionId}/problems/{idx}/hint
+func (c *Client) RevealHint(ctx context.Context, sessionID uint64, idx int) (*dto.Hint, error) {
+	var out dto.Hint
+	if err := c.do(ctx, "POST", "/v1/sessions/"+strconv.FormatUint(sessionID, 10)+"/problems/"+strconv.Itoa(idx)+"/hint", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,102 @@
+package apiclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apiclient"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+func TestClient_Request(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mok_test" {
+			t.Errorf("unexpected Authorization %q", r.Header.Get("Authorization"))
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v1/sessions":
+			if got := r.URL.RawQuery; got != "examMode=true" {
+				t.Errorf("unexpected query %q", got)
+			}
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"sessionId":"5"}`)
+		case "PUT /v1/sessions/5/problems/2/answer":
+			var req dto.AnswerRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SelectedChoiceID == nil || *req.SelectedChoiceID != 7 {
+				t.Errorf("unexpected body: %v", err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE /v1/classes/1/students/a b":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := apiclient.New(srv.URL+"/", "mok_test")
+	ctx := context.Background()
+
+	examMode := true
+	created, err := c.CreateSession(ctx, &apiclient.CreateSessionParams{ExamMode: &examMode})
+	if err != nil || created.SessionID != "5" {
+		t.Fatalf("CreateSession: %+v, %v", created, err)
+	}
+	choiceID := int64(7)
+	if err := c.SubmitAnswer(ctx, 5, 2, dto.AnswerRequest{SelectedChoiceID: &choiceID}); err != nil {
+		t.Errorf("SubmitAnswer: %v", err)
+	}
+	if err := c.RemoveStudent(ctx, 1, "a b"); err != nil {
+		t.Errorf("RemoveStudent: %v", err)
+	}
+}
+
+// problem+json はローカルのサービスと同じ種類・コードの *apperr.Error になる
+func TestClient_Problem(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sessions/1":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"type":"about:blank","title":"Not Found","status":404,"detail":"セッションが見つかりません","instance":"/v1/sessions/1","code":"session_not_found"}`)
+		case "/v1/sessions/1/problems/0/answer":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"type":"about:blank","title":"Conflict","status":409,"detail":"他の操作で先に回答が更新されました","instance":"/v1/sessions/1/problems/0/answer","code":"version_conflict","current":{"idx":0,"selectedId":3,"version":2}}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "bad gateway")
+		}
+	}))
+	defer srv.Close()
+
+	c := apiclient.New(srv.URL, "")
+	ctx := context.Background()
+
+	_, err := c.GetSession(ctx, 1)
+	if !errors.Is(err, apperr.ErrNotFound) || apperr.Format(err) != "セッションが見つかりません (session_not_found)" {
+		t.Errorf("GetSession: unexpected error %v", err)
+	}
+
+	err = c.SubmitAnswer(ctx, 1, 0, dto.AnswerRequest{})
+	e := apperr.From(err)
+	if !errors.Is(err, apperr.ErrConflict) || e.Code != apperr.CodeVersionConflict {
+		t.Errorf("SubmitAnswer: unexpected error %v", err)
+	}
+	if current, ok := e.Details["current"].(map[string]any); !ok || current["version"] != float64(2) {
+		t.Errorf("SubmitAnswer: unexpected details %+v", e.Details)
+	}
+
+	// problem+json 以外のエラーは本文をそのまま返す
+	_, err = c.GetProfile(ctx)
+	if err == nil || apperr.From(err).Code != apperr.CodeInternal || apperr.Format(err) != "API がエラーを返しました (502 Bad Gateway): bad gateway" {
+		t.Errorf("GetProfile: unexpected error %v", err)
+	}
+}
@@ -1,9 +1,164 @@
 package apperr
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
+// エラーの種類。HTTP のステータスは種類で決まる。
 var (
-	ErrNotFound   = errors.New("not found")
-	ErrForbidden  = errors.New("forbidden")
-	ErrOutOfRange = errors.New("index out of range")
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden")
+	ErrOutOfRange      = errors.New("index out of range")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrConflict        = errors.New("conflict")
+	ErrSessionFinished = errors.New("session finished")
+	ErrClosed          = errors.New("closed")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrUnavailable     = errors.New("unavailable")
+)
+
+// Code はクライアントがエラーを判別するためのコード。HTTP (problem+json の code)・CLI・MCP で共通。
+type Code string
+
+const (
+	CodeInvalidArgument    Code = "invalid_argument"
+	CodeInvalidParameter   Code = "invalid_parameter"
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodeForbidden          Code = "forbidden"
+	CodeScopeRequired      Code = "scope_required"
+	CodeRoleRequired       Code = "role_required"
+	CodeHintNotAllowed     Code = "hint_not_allowed"
+	CodeNotFound           Code = "not_found"
+	CodeSessionNotFound    Code = "session_not_found"
+	CodeProblemOutOfRange  Code = "problem_out_of_range"
+	CodeChoiceNotFound     Code = "choice_not_found"
+	CodeHintNotFound       Code = "hint_not_found"
+	CodeConflict           Code = "conflict"
+	CodeVersionConflict    Code = "version_conflict"
+	CodeSessionFinished    Code = "session_finished"
+	CodeAssignmentClosed   Code = "assignment_closed"
+	CodeNoAttemptsLeft     Code = "no_attempts_left"
+	CodeAnalysisNotReady   Code = "analysis_not_ready"
+	CodeServiceUnavailable Code = "service_unavailable"
+	CodeInternal           Code = "internal"
 )
+
+// Error はコードと詳細を持つエラー。Unwrap で種類 (ErrNotFound など) を返すので errors.Is で判定できる。
+type Error struct {
+	Kind    error
+	Code    Code
+	Message string         // 利用者向けの説明
+	Details map[string]any // 例: 不正だったパラメータ名 {"param": "idx"}
+}
+
+// New は kind の種類で code のエラーを作る。
+func New(kind error, code Code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// WithDetail は詳細を加えたコピーを返す。
+func (e *Error) WithDetail(key string, value any) *Error {
+	details := make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// よく使うエラー
+var (
+	ErrSessionNotFound      = New(ErrNotFound, CodeSessionNotFound, "セッションが見つかりません")
+	ErrChoiceNotFound       = New(ErrInvalidArgument, CodeChoiceNotFound, "選択肢がこの問題にありません")
+	ErrHintNotFound         = New(ErrNotFound, CodeHintNotFound, "この問題にヒントはありません")
+	ErrHintNotAllowed       = New(ErrForbidden, CodeHintNotAllowed, "試験モードではヒントを表示できません")
+	ErrAssignmentClosed     = New(ErrClosed, CodeAssignmentClosed, "課題の締切を過ぎています")
+	ErrNoAttemptsLeft       = New(ErrConflict, CodeNoAttemptsLeft, "受験できる回数を超えています")
+	ErrItemAnalysisNotReady = New(ErrNotFound, CodeAnalysisNotReady, "問題分析はまだ作成されていません (mathovercome analysis rebuild で作成します)")
+)
+
+// InvalidParameter は param の値が不正なことを表す。
+func InvalidParameter(param, message string) *Error {
+	return New(ErrInvalidArgument, CodeInvalidParameter, message).WithDetail("param", param)
+}
+
+// ScopeRequired は API キーに scope の権限が無いことを表す。
+func ScopeRequired(scope string) *Error {
+	return New(ErrForbidden, CodeScopeRequired, "API キーにこの操作の権限 ("+scope+") がありません").WithDetail("scope", scope)
+}
+
+// RoleRequired は role の役割が無いことを表す。
+func RoleRequired(role string) *Error {
+	return New(ErrForbidden, CodeRoleRequired, "この操作には "+role+" の役割が必要です").WithDetail("role", role)
+}
+
+// 種類ごとの既定のコードと説明。先に一致したものを使う
+var defaults = []struct {
+	kind    error
+	code    Code
+	message string
+}{
+	{ErrInvalidArgument, CodeInvalidArgument, "指定された値が不正です"},
+	{ErrOutOfRange, CodeProblemOutOfRange, "問題の番号が範囲外です"},
+	{ErrUnauthenticated, CodeUnauthenticated, "ログインが必要です"},
+	{ErrForbidden, CodeForbidden, "この操作は許可されていません"},
+	{ErrNotFound, CodeNotFound, "見つかりません"},
+	{ErrSessionFinished, CodeSessionFinished, "セッションは終了しているため回答できません"},
+	{ErrClosed, CodeAssignmentClosed, "締切を過ぎています"},
+	{ErrConflict, CodeConflict, "他の操作と競合しました"},
+	{ErrUnavailable, CodeServiceUnavailable, "一時的に利用できません"},
+}
+
+// From は err を *Error にする。*Error を含まない場合は種類から既定のコードを付け、
+// どの種類でもなければ CodeInternal (Kind は nil) にする。
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var ce *ConflictError
+	if errors.As(err, &ce) {
+		return New(ErrConflict, CodeVersionConflict, "他の操作で先に回答が更新されました").WithDetail("current", ce.Current)
+	}
+	for _, d := range defaults {
+		if errors.Is(err, d.kind) {
+			return New(d.kind, d.code, d.message)
+		}
+	}
+	return New(nil, CodeInternal, "内部エラーが発生しました")
+}
+
+// Format は CLI や MCP で表示するための "説明 (コード)" を返す。
+// コードの無いエラー (入力ミスや通信の失敗など) は原因が分かるよう err をそのまま返す。
+func Format(err error) string {
+	e := From(err)
+	if e.Code == CodeInternal {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+// ConflictError は楽観ロックの競合を表す。
+// Current にはクライアントが再同期するための現在の状態を入れる。
+type ConflictError struct {
+	Current any
+}
+
+func (e *ConflictError) Error() string {
+	return ErrConflict.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
@@ -0,0 +1,65 @@
+package apperr_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+)
+
+func TestFrom_Codes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code apperr.Code
+	}{
+		{apperr.ErrSessionNotFound, apperr.CodeSessionNotFound},
+		{fmt.Errorf("submit: %w", apperr.ErrChoiceNotFound), apperr.CodeChoiceNotFound},
+		{apperr.ErrNotFound, apperr.CodeNotFound},
+		{fmt.Errorf("problem 3: %w", apperr.ErrInvalidArgument), apperr.CodeInvalidArgument},
+		{apperr.ErrOutOfRange, apperr.CodeProblemOutOfRange},
+		{&apperr.ConflictError{Current: 1}, apperr.CodeVersionConflict},
+		{errors.New("dial tcp: connection refused"), apperr.CodeInternal},
+	}
+	for _, tt := range tests {
+		if got := apperr.From(tt.err).Code; got != tt.code {
+			t.Errorf("%v: expected %s, got %s", tt.err, tt.code, got)
+		}
+	}
+}
+
+func TestError_KeepsKind(t *testing.T) {
+	err := fmt.Errorf("submit: %w", apperr.ErrChoiceNotFound)
+	if !errors.Is(err, apperr.ErrInvalidArgument) {
+		t.Errorf("expected choice_not_found to be ErrInvalidArgument")
+	}
+	if errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected choice_not_found not to be ErrNotFound")
+	}
+
+	e := apperr.InvalidParameter("idx", "idx は0以上の整数で指定してください")
+	if e.Details["param"] != "idx" || !errors.Is(e, apperr.ErrInvalidArgument) {
+		t.Errorf("unexpected error: %+v", e)
+	}
+	// WithDetail は元のエラーを変更しない
+	if d := apperr.ErrSessionNotFound.WithDetail("sessionId", "1"); d.Details["sessionId"] != "1" || apperr.ErrSessionNotFound.Details != nil {
+		t.Errorf("expected WithDetail to copy")
+	}
+}
+
+func TestFrom_ConflictCurrent(t *testing.T) {
+	e := apperr.From(fmt.Errorf("answer: %w", &apperr.ConflictError{Current: "state"}))
+	if e.Details["current"] != "state" || !errors.Is(e, apperr.ErrConflict) {
+		t.Errorf("unexpected conflict: %+v", e)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := apperr.Format(fmt.Errorf("回答送信失敗: %w", apperr.ErrSessionNotFound)); got != "セッションが見つかりません (session_not_found)" {
+		t.Errorf("unexpected format: %q", got)
+	}
+	// コードの無いエラーは原因が分かるようそのまま
+	if got := apperr.Format(errors.New("トークンの読み込みに失敗: permission denied")); got != "トークンの読み込みに失敗: permission denied" {
+		t.Errorf("unexpected format: %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Package authtest はテスト用に JWKS を配信するローカルサーバーと、その鍵で署名したトークンを提供する。
+package authtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Issuer と Audience はテスト用トークンの既定の発行者・対象。
+const (
+	Issuer   = "https://issuer.example.com/pool"
+	Audience = "test-client"
+)
+
+// Server は JWKS を配信するローカルの認可サーバーの代わり。
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests atomic.Int32
+}
+
+// NewServer は kid の鍵を1つ持つサーバーを起動する。テスト終了時に停止する。
+func NewServer(t testing.TB, kid string) *Server {
+	t.Helper()
+	s := &Server{keys: make(map[string]*rsa.PrivateKey)}
+	s.AddKey(t, kid)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveJWKS))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// JWKSURL は JWKS の URL を返す。
+func (s *Server) JWKSURL() string {
+	return s.URL + "/.well-known/jwks.json"
+}
+
+// Requests は JWKS が取得された回数を返す。
+func (s *Server) Requests() int {
+	return int(s.requests.Load())
+}
+
+// AddKey は鍵を追加する (鍵のローテーション)。
+func (s *Server) AddKey(t testing.TB, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+}
+
+// RemoveKey は鍵を配信から外す。
+func (s *Server) RemoveKey(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, kid)
+}
+
+// Sign は kid の鍵で claims に署名した RS256 のトークンを返す。
+func (s *Server) Sign(t testing.TB, kid string, claims map[string]any) string {
+	t.Helper()
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	s.mu.Unlock()
+	if !ok {
+		t.Fatalf("unknown kid %q", kid)
+	}
+	return SignWith(t, key, map[string]any{"alg": "RS256", "kid": kid, "typ": "JWT"}, claims)
+}
+
+// SignWith は任意のヘッダーと鍵で署名したトークンを返す。不正なトークンを作るのに使う。
+func SignWith(t testing.TB, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+	input := encode(t, header) + "." + encode(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func encode(t testing.TB, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *Server) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]map[string]string, 0, len(s.keys))
+	for kid, key := range s.keys {
+		keys = append(keys, map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// 取得した鍵を使い続ける時間。過ぎたら次の検証時に取り直す
+	defaultKeyTTL = time.Hour
+	// 未知の kid による取り直しの最短間隔。不正な kid を大量に送られても JWKS を叩き続けない
+	defaultMinRefresh = time.Minute
+)
+
+// ErrUnknownKey は JWKS を取り直しても kid に対応する鍵が無いことを表す。
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet は JWKS から取得した RSA 公開鍵を kid ごとに保持する。
+// 鍵は TTL の間キャッシュし、未知の kid が来たら (最短間隔を空けて) 取り直すことで鍵のローテーションに追従する。
+// 取り直しに失敗した場合は、手元の鍵を引き続き使う。
+type KeySet struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// NewKeySet は url の JWKS を使う KeySet を返す。鍵は最初の検証時に取得する。
+func NewKeySet(url string) *KeySet {
+	return &KeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        defaultKeyTTL,
+		minRefresh: defaultMinRefresh,
+		now:        time.Now,
+	}
+}
+
+// WithHTTPClient は JWKS の取得に使う HTTP クライアントを差し替える。
+func (k *KeySet) WithHTTPClient(c *http.Client) *KeySet {
+	k.client = c
+	return k
+}
+
+// WithClock は鍵の期限の判定に使う現在時刻を差し替える。
+func (k *KeySet) WithClock(now func() time.Time) *KeySet {
+	k.now = now
+	return k
+}
+
+// Key は kid に対応する公開鍵を返す。
+func (k *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	if k.keys == nil || now.Sub(k.fetchedAt) >= k.ttl {
+		if err := k.refresh(ctx, now); err != nil && k.keys == nil {
+			return nil, err
+		}
+	}
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	if now.Sub(k.attemptedAt) < k.minRefresh {
+		return nil, ErrUnknownKey
+	}
+	if err := k.refresh(ctx, now); err != nil {
+		return nil, err
+	}
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh は JWKS を取得して鍵を置き換える。呼び出し側で mu を取っていること。
+func (k *KeySet) refresh(ctx context.Context, now time.Time) error {
+	k.attemptedAt = now
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return fmt.Errorf("jwks request: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(body.Keys))
+	for _, j := range body.Keys {
+		// 署名用の RSA 鍵以外 (暗号化用や EC 鍵) は使わない
+		if j.Kty != "RSA" || (j.Use != "" && j.Use != "sig") || (j.Alg != "" && j.Alg != algRS256) {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwk %s: %w", j.Kid, err)
+		}
+		keys[j.Kid] = key
+	}
+	k.keys = keys
+	k.fetchedAt = now
+	return nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (j jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(j.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode n: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(j.E)
+	if err != nil {
+		return nil, fmt.Errorf("decode e: %w", err)
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
@@ -0,0 +1,240 @@
+// Package auth は Cognito が発行した JWT を JWKS の公開鍵で検証する。
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+const algRS256 = "RS256"
+
+// 既定で受け付ける時計のずれ
+const defaultLeeway = 30 * time.Second
+
+// ErrInvalidToken は署名・クレームのいずれかの検証に失敗したことを表す。詳細はラップしたメッセージに含める。
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config はトークンの検証条件。
+// Audiences は ID トークンの aud、アクセストークンの client_id のいずれかと一致すればよい。
+// TokenUse は Cognito の token_use クレーム ("id" または "access")。
+type Config struct {
+	Issuer    string
+	JWKSURL   string
+	Audiences []string
+	TokenUse  string
+	Leeway    time.Duration
+}
+
+// ConfigFromEnv は JWT_ISSUER / JWT_AUDIENCE (カンマ区切り) / JWT_TOKEN_USE / JWT_JWKS_URL から検証条件を作る。
+// JWKS の URL を省略した場合は Cognito と同じ <issuer>/.well-known/jwks.json を使う。
+// 検証を省略できないよう、発行者と対象が未設定ならエラーを返す。
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Issuer:   strings.TrimRight(os.Getenv("JWT_ISSUER"), "/"),
+		JWKSURL:  os.Getenv("JWT_JWKS_URL"),
+		TokenUse: os.Getenv("JWT_TOKEN_USE"),
+		Leeway:   defaultLeeway,
+	}
+	for _, aud := range strings.Split(os.Getenv("JWT_AUDIENCE"), ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			cfg.Audiences = append(cfg.Audiences, aud)
+		}
+	}
+	if cfg.Issuer == "" || len(cfg.Audiences) == 0 {
+		return cfg, errors.New("JWT_ISSUER and JWT_AUDIENCE are required")
+	}
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = cfg.Issuer + "/.well-known/jwks.json"
+	}
+	if cfg.TokenUse == "" {
+		cfg.TokenUse = "id"
+	}
+	return cfg, nil
+}
+
+// Claims は検証済みトークンから取り出したクレーム。
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Name      string   `json:"name"`
+	Username  string   `json:"cognito:username"`
+	Groups    []string `json:"cognito:groups"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ClientID  string   `json:"client_id"`
+	TokenUse  string   `json:"token_use"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+}
+
+// audience は aud クレームの文字列・配列の両方の形式を受け付ける。
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verifier は RS256 で署名された JWT を検証する。
+type Verifier struct {
+	cfg  Config
+	keys *KeySet
+	now  func() time.Time
+}
+
+// NewVerifier は cfg.JWKSURL の鍵で検証する Verifier を返す。
+func NewVerifier(cfg Config) *Verifier {
+	return &Verifier{cfg: cfg, keys: NewKeySet(cfg.JWKSURL), now: time.Now}
+}
+
+// WithKeySet は鍵の取得元を差し替える。
+func (v *Verifier) WithKeySet(k *KeySet) *Verifier {
+	v.keys = k
+	return v
+}
+
+// WithClock は有効期限の判定に使う現在時刻を差し替える。
+func (v *Verifier) WithClock(now func() time.Time) *Verifier {
+	v.now = now
+	return v
+}
+
+// Verify は署名・発行者・対象・有効期限・token_use を検証してクレームを返す。
+// 検証に失敗した場合は ErrInvalidToken をラップしたエラーを返す。
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	// alg を固定し、none や HS256 への差し替えを受け付けない
+	if header.Alg != algRS256 || header.Kid == "" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return &claims, nil
+}
+
+// Authenticate はトークンを検証して Principal を返す。identity.Authenticator を満たす。
+// 表示名は name クレーム、無ければ Cognito のユーザー名を使う。
+// Cognito のグループのうち役割の名前 (teacher / admin) のものを役割として扱う。
+func (v *Verifier) Authenticate(ctx context.Context, token string) (*identity.Principal, error) {
+	claims, err := v.Verify(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		return nil, fmt.Errorf("%w: %v", identity.ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.Username
+	}
+	var roles []string
+	for _, g := range claims.Groups {
+		if identity.IsRole(g) {
+			roles = append(roles, g)
+		}
+	}
+	return &identity.Principal{Sub: claims.Subject, Name: name, Method: identity.MethodJWT, Scopes: identity.AllScopes(), Roles: roles}, nil
+}
+
+func (v *Verifier) checkClaims(c *Claims) error {
+	if c.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("issuer %q", c.Issuer)
+	}
+	if c.TokenUse != v.cfg.TokenUse {
+		return fmt.Errorf("token_use %q", c.TokenUse)
+	}
+	if !v.audienceAllowed(c) {
+		return errors.New("audience")
+	}
+	if c.Subject == "" {
+		return errors.New("missing sub")
+	}
+
+	now := v.now()
+	if c.ExpiresAt == 0 || !now.Before(time.Unix(c.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		return errors.New("expired")
+	}
+	if c.NotBefore != 0 && now.Add(v.cfg.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return errors.New("not yet valid")
+	}
+	if c.IssuedAt != 0 && now.Add(v.cfg.Leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("issued in the future")
+	}
+	return nil
+}
+
+// audienceAllowed は aud (ID トークン) または client_id (アクセストークン) が設定と一致するかを返す。
+func (v *Verifier) audienceAllowed(c *Claims) bool {
+	for _, want := range v.cfg.Audiences {
+		if c.ClientID == want {
+			return true
+		}
+		for _, aud := range c.Audience {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,260 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth/authtest"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+var now = time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+
+func newVerifier(srv *authtest.Server) *auth.Verifier {
+	cfg := auth.Config{
+		Issuer:    authtest.Issuer,
+		JWKSURL:   srv.JWKSURL(),
+		Audiences: []string{authtest.Audience},
+		TokenUse:  "id",
+		Leeway:    30 * time.Second,
+	}
+	clock := func() time.Time { return now }
+	return auth.NewVerifier(cfg).
+		WithKeySet(auth.NewKeySet(cfg.JWKSURL).WithClock(clock)).
+		WithClock(clock)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":       "user-1",
+		"name":      "テスト",
+		"iss":       authtest.Issuer,
+		"aud":       authtest.Audience,
+		"token_use": "id",
+		"iat":       now.Add(-time.Minute).Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify_Valid(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := newVerifier(srv)
+
+	claims, err := v.Verify(context.Background(), srv.Sign(t, "k1", validClaims()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Name != "テスト" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestAuthenticate_RolesFromGroups(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := newVerifier(srv)
+
+	claims := validClaims()
+	claims["cognito:groups"] = []string{"teacher", "beta-testers"}
+	p, err := v.Authenticate(context.Background(), srv.Sign(t, "k1", claims))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(p.Roles) != 1 || p.Roles[0] != identity.RoleTeacher {
+		t.Errorf("roles = %v, want [teacher]", p.Roles)
+	}
+	if !p.HasRole(identity.RoleTeacher) || p.HasRole(identity.RoleAdmin) {
+		t.Errorf("unexpected role check for %v", p.Roles)
+	}
+}
+
+func TestVerify_AccessTokenUsesClientID(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := newVerifier(srv)
+	c := validClaims()
+	delete(c, "aud")
+	c["client_id"] = authtest.Audience
+	c["token_use"] = "access"
+
+	// 設定が id トークンのみなので access トークンは拒否する
+	if _, err := v.Verify(context.Background(), srv.Sign(t, "k1", c)); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for access token, got %v", err)
+	}
+
+	cfg := auth.Config{Issuer: authtest.Issuer, JWKSURL: srv.JWKSURL(), Audiences: []string{authtest.Audience}, TokenUse: "access"}
+	access := auth.NewVerifier(cfg).WithClock(func() time.Time { return now })
+	if _, err := access.Verify(context.Background(), srv.Sign(t, "k1", c)); err != nil {
+		t.Errorf("expected access token with client_id to be accepted, got %v", err)
+	}
+}
+
+func TestVerify_RejectsInvalidClaims(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := newVerifier(srv)
+
+	tests := map[string]func(c map[string]any){
+		"issuer":       func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"audience":     func(c map[string]any) { c["aud"] = []string{"other-client"} },
+		"token_use":    func(c map[string]any) { c["token_use"] = "access" },
+		"expired":      func(c map[string]any) { c["exp"] = now.Add(-time.Minute).Unix() },
+		"no exp":       func(c map[string]any) { delete(c, "exp") },
+		"not before":   func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() },
+		"future iat":   func(c map[string]any) { c["iat"] = now.Add(time.Hour).Unix() },
+		"missing sub":  func(c map[string]any) { delete(c, "sub") },
+		"wrong format": func(c map[string]any) { c["aud"] = 1 },
+	}
+	for name, mutate := range tests {
+		c := validClaims()
+		mutate(c)
+		if _, err := v.Verify(context.Background(), srv.Sign(t, "k1", c)); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	// 期限切れでも許容するずれの範囲内なら通す
+	c := validClaims()
+	c["exp"] = now.Add(-10 * time.Second).Unix()
+	if _, err := v.Verify(context.Background(), srv.Sign(t, "k1", c)); err != nil {
+		t.Errorf("expected token within leeway to be accepted, got %v", err)
+	}
+}
+
+func TestVerify_RejectsBadSignatureAndAlg(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := newVerifier(srv)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tokens := map[string]string{
+		"other key": authtest.SignWith(t, other, map[string]any{"alg": "RS256", "kid": "k1"}, validClaims()),
+		"alg none":  authtest.SignWith(t, other, map[string]any{"alg": "none", "kid": "k1"}, validClaims()),
+		"alg hs256": authtest.SignWith(t, other, map[string]any{"alg": "HS256", "kid": "k1"}, validClaims()),
+		"no kid":    authtest.SignWith(t, other, map[string]any{"alg": "RS256"}, validClaims()),
+		"malformed": "not-a-jwt",
+	}
+	// 署名を別のペイロードに付け替えたトークン
+	valid := srv.Sign(t, "k1", validClaims())
+	forged := srv.Sign(t, "k1", map[string]any{"sub": "admin"})
+	tokens["swapped payload"] = valid[:len(valid)-len(lastSegment(valid))] + lastSegment(forged)
+
+	for name, token := range tokens {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestKeySet_CachesAndRotates(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	current := now
+	clock := func() time.Time { return current }
+	v := auth.NewVerifier(auth.Config{
+		Issuer: authtest.Issuer, JWKSURL: srv.JWKSURL(), Audiences: []string{authtest.Audience}, TokenUse: "id",
+	}).WithKeySet(auth.NewKeySet(srv.JWKSURL()).WithClock(clock)).WithClock(clock)
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), srv.Sign(t, "k1", validClaims())); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if srv.Requests() != 1 {
+		t.Errorf("expected keys to be cached, got %d fetches", srv.Requests())
+	}
+
+	// 新しい鍵で署名されたトークンが来たら取り直す
+	srv.AddKey(t, "k2")
+	current = current.Add(2 * time.Minute)
+	if _, err := v.Verify(context.Background(), srv.Sign(t, "k2", validClaims())); err != nil {
+		t.Fatalf("expected rotated key to be fetched, got %v", err)
+	}
+	if srv.Requests() != 2 {
+		t.Errorf("expected 2 fetches after rotation, got %d", srv.Requests())
+	}
+
+	// 未知の kid が続いても最短間隔の間は取り直さない
+	unknown := authtest.SignWith(t, mustKey(t), map[string]any{"alg": "RS256", "kid": "k9"}, validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), unknown); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken for unknown kid, got %v", err)
+		}
+	}
+	if srv.Requests() != 2 {
+		t.Errorf("expected no refetch within min refresh interval, got %d fetches", srv.Requests())
+	}
+
+	// 配信から外れた鍵は TTL 後の取り直しで使えなくなる
+	srv.RemoveKey("k1")
+	current = current.Add(2 * time.Hour)
+	c := validClaims()
+	c["iat"] = current.Unix()
+	c["exp"] = current.Add(time.Hour).Unix()
+	if _, err := v.Verify(context.Background(), srv.Sign(t, "k2", c)); err != nil {
+		t.Fatalf("expected k2 to stay valid, got %v", err)
+	}
+	if srv.Requests() != 3 {
+		t.Errorf("expected refetch after ttl, got %d fetches", srv.Requests())
+	}
+	stale := validClaims()
+	stale["iat"] = current.Unix()
+	stale["exp"] = current.Add(time.Hour).Unix()
+	if _, err := v.Verify(context.Background(), authtest.SignWith(t, mustKey(t), map[string]any{"alg": "RS256", "kid": "k1"}, stale)); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected removed key to be rejected, got %v", err)
+	}
+}
+
+func TestKeySet_FetchFailure(t *testing.T) {
+	v := auth.NewVerifier(auth.Config{
+		Issuer: authtest.Issuer, JWKSURL: "http://127.0.0.1:0/jwks.json", Audiences: []string{authtest.Audience}, TokenUse: "id",
+	})
+	srv := authtest.NewServer(t, "k1")
+
+	_, err := v.Verify(context.Background(), srv.Sign(t, "k1", validClaims()))
+	if err == nil || errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected infrastructure error distinct from ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "")
+	if _, err := auth.ConfigFromEnv(); err == nil {
+		t.Error("expected error when issuer and audience are missing")
+	}
+
+	t.Setenv("JWT_ISSUER", "https://cognito-idp.ap-northeast-1.amazonaws.com/pool/")
+	t.Setenv("JWT_AUDIENCE", "a, b")
+	cfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.JWKSURL != "https://cognito-idp.ap-northeast-1.amazonaws.com/pool/.well-known/jwks.json" {
+		t.Errorf("unexpected jwks url %q", cfg.JWKSURL)
+	}
+	if len(cfg.Audiences) != 2 || cfg.TokenUse != "id" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func lastSegment(token string) string {
+	for i := len(token) - 1; i >= 0; i-- {
+		if token[i] == '.' {
+			return token[i+1:]
+		}
+	}
+	return token
+}
+
+func mustKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
@@ -4,6 +4,32 @@ package dto
 
 type AnswerRequest struct {
 	SelectedChoiceID *int64 `json:"selectedChoiceId"`
+	// Version は取得時の version。省略時はサーバーが読み取った時点の version で判定する。
+	Version *int64 `json:"version"`
+}
+
+// MypageQuery はマイページ履歴の取得条件。
+// From / To は JST の日付 (2006-01-02) で、To の日付も含む。
+type MypageQuery struct {
+	Limit          int
+	Cursor         string
+	From           string
+	To             string
+	IncludeDetails bool
+}
+
+// TrendQuery はセッション横断の推移の取得条件。
+// Sessions は対象とする直近のセッション数、Window は移動正答率に使うセッション数 (0 は既定値)。
+type TrendQuery struct {
+	Sessions int
+	Window   int
+}
+
+// SessionListQuery はセッション一覧の取得条件。Status が空なら全状態。
+type SessionListQuery struct {
+	Limit  int
+	Cursor string
+	Status string
 }
 
 // --- Responses ---
@@ -13,37 +39,600 @@ type Choice struct {
 	ChoiceText string `json:"choiceText"`
 }
 
+// SessionProblem は問題の表示内容。ヒント本文は含めず、Hint で別途取得する。
 type SessionProblem struct {
-	ID         int64    `json:"id"`
-	Question   string   `json:"question"`
-	Choices    []Choice `json:"choices"`
-	Hint       string   `json:"hint"`
-	SelectedID *int64   `json:"selectedId"`
-	Total      int      `json:"total"`
+	ID            int64    `json:"id"`
+	Question      string   `json:"question"`
+	Choices       []Choice `json:"choices"`
+	HintAvailable bool     `json:"hintAvailable"` // ヒントがあり、かつ試験モードでない
+	HintRevealed  bool     `json:"hintRevealed"`
+	SelectedID    *int64   `json:"selectedId"`
+	Total         int      `json:"total"`
+	Version       int64    `json:"version"`
+}
+
+type Hint struct {
+	Idx  int    `json:"idx"`
+	Hint string `json:"hint"`
 }
 
+// AnswerState は回答が競合した際に返す現在の回答状態。
+type AnswerState struct {
+	Idx        int    `json:"idx"`
+	SelectedID *int64 `json:"selectedId"`
+	Version    int64  `json:"version"`
+}
+
+// Category の HintedCount はヒント表示後に回答した問題数、HintedCorrectCount はそのうちの正答数。
 type Category struct {
-	CategoryName string `json:"categoryName"`
-	Total        int    `json:"total"`
-	CorrectCount int    `json:"correctCount"`
+	CategoryName       string  `json:"categoryName"`
+	Total              int     `json:"total"`
+	CorrectCount       int     `json:"correctCount"`
+	HintedCount        int     `json:"hintedCount,omitempty"`
+	HintedCorrectCount int     `json:"hintedCorrectCount,omitempty"`
+	TimedCount         int     `json:"timedCount,omitempty"`
+	TimeSpentSec       float64 `json:"timeSpentSec,omitempty"`
+	AvgTimeSec         float64 `json:"avgTimeSec,omitempty"`
 }
 
-type ProblemCategory struct {
-	IsCorrect    *bool  `json:"isCorrect"`
-	CategoryName string `json:"categoryName"`
+// ProblemTime は1問の所要時間 (初回表示から初回回答まで)。
+type ProblemTime struct {
+	Idx          int     `json:"idx"`
+	CategoryName string  `json:"categoryName"`
+	TimeSpentSec float64 `json:"timeSpentSec"`
+	OverTarget   bool    `json:"overTarget"`
+}
+
+// Pacing は1問あたりの目標時間に対するペース。
+// Ratio は AvgSec / TargetSec で、1 を超えると目標より遅い。
+type Pacing struct {
+	TargetSec       float64 `json:"targetSec"`
+	AvgSec          float64 `json:"avgSec"`
+	Ratio           float64 `json:"ratio"`
+	TimedCount      int     `json:"timedCount"`
+	OverTargetCount int     `json:"overTargetCount"`
 }
 
 type TestSession struct {
-	SessionID        int64             `json:"sessionId"`
-	StartTime        string            `json:"startTime"`
-	Total            int               `json:"total"`
-	CorrectCount     int               `json:"correctCount"`
-	ProbCategoryDtos []ProblemCategory `json:"-"`
-	CategoryDtos     []Category        `json:"categoryDtos"`
-	WeakCategories   []string          `json:"weakCategories"`
+	SessionID          int64      `json:"sessionId"`
+	StartTime          string     `json:"startTime"`
+	Total              int        `json:"total"`
+	CorrectCount       int        `json:"correctCount"`
+	HintedCount        int        `json:"hintedCount,omitempty"`
+	HintedCorrectCount int        `json:"hintedCorrectCount,omitempty"`
+	ExamMode           bool       `json:"examMode,omitempty"`
+	CategoryDtos       []Category `json:"categoryDtos,omitempty"`
+	WeakCategories     []string   `json:"weakCategories,omitempty"`
+	// 所要時間を計測できた問題が無い場合は省略する
+	Pacing       *Pacing       `json:"pacing,omitempty"`
+	ProblemTimes []ProblemTime `json:"problemTimes,omitempty"`
 }
 
 type User struct {
 	UserName     string        `json:"userName"`
 	TestSessDtos []TestSession `json:"testSessDtos"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+type MypageSummary struct {
+	UserName           string  `json:"userName"`
+	SessionCount       int     `json:"sessionCount"`
+	Total              int     `json:"total"`
+	CorrectCount       int     `json:"correctCount"`
+	Accuracy           float64 `json:"accuracy"`
+	HintedCount        int     `json:"hintedCount"`
+	HintedCorrectCount int     `json:"hintedCorrectCount"`
+	UnaidedAccuracy    float64 `json:"unaidedAccuracy"` // ヒントなしで回答した問題だけの正答率
+	// 全セッションを合算した成績から判定した苦手カテゴリ
+	WeakCategories []string `json:"weakCategories"`
+	LastStartTime  string   `json:"lastStartTime,omitempty"`
+	Pacing         *Pacing  `json:"pacing,omitempty"`
+}
+
+type AnswerEvent struct {
+	Idx        int    `json:"idx"`
+	ChoiceID   int64  `json:"choiceId"`
+	IsCorrect  bool   `json:"isCorrect"`
+	WithHint   bool   `json:"withHint"`
+	AnsweredAt string `json:"answeredAt"`
+}
+
+// ProblemHistory は1問分の回答履歴。
+// RightToWrong / WrongToRight は連続する回答間で正誤が入れ替わった回数。
+type ProblemHistory struct {
+	Idx          int           `json:"idx"`
+	CategoryName string        `json:"categoryName"`
+	Attempts     int           `json:"attempts"`
+	RightToWrong int           `json:"rightToWrong"`
+	WrongToRight int           `json:"wrongToRight"`
+	Events       []AnswerEvent `json:"events"`
+}
+
+type AnswerHistory struct {
+	SessionID    int64            `json:"sessionId"`
+	Timeline     []AnswerEvent    `json:"timeline"`
+	Problems     []ProblemHistory `json:"problems"`
+	RightToWrong int              `json:"rightToWrong"`
+	WrongToRight int              `json:"wrongToRight"`
+}
+
+// TrendPoint はあるセッションでの1カテゴリの成績。
+// RollingAccuracy はこのセッションを含む直近 Window 回の合計から求めた正答率。
+type TrendPoint struct {
+	SessionID       int64   `json:"sessionId"`
+	StartTime       string  `json:"startTime"`
+	Total           int     `json:"total"`
+	CorrectCount    int     `json:"correctCount"`
+	Accuracy        float64 `json:"accuracy"`
+	RollingAccuracy float64 `json:"rollingAccuracy"`
+}
+
+// CategoryTrend は1カテゴリの推移 (古い順)。
+// Change は最後と最初の移動正答率の差で、出題が1回だけの場合は null。
+type CategoryTrend struct {
+	CategoryName string       `json:"categoryName"`
+	Points       []TrendPoint `json:"points"`
+	Change       *float64     `json:"change"`
+}
+
+type CategoryRank struct {
+	CategoryName string  `json:"categoryName"`
+	Total        int     `json:"total"`
+	CorrectCount int     `json:"correctCount"`
+	Accuracy     float64 `json:"accuracy"`
+}
+
+// Streak は受験した日 (JST) の連続日数。対象期間内のセッションのみで数える。
+type Streak struct {
+	CurrentDays   int    `json:"currentDays"`
+	LongestDays   int    `json:"longestDays"`
+	LastStudyDate string `json:"lastStudyDate,omitempty"`
+}
+
+// Trends は直近のセッションを通した成績の推移。
+// Best / Worst は対象期間の合計正答率が高い順・低い順のカテゴリ。
+// WeakCategories は対象期間を合算した成績から苦手カテゴリの判定基準で選んだもの。
+type Trends struct {
+	SessionCount   int             `json:"sessionCount"`
+	Window         int             `json:"window"`
+	Categories     []CategoryTrend `json:"categories"`
+	Best           []CategoryRank  `json:"best"`
+	Worst          []CategoryRank  `json:"worst"`
+	WeakCategories []string        `json:"weakCategories"`
+	Streak         Streak          `json:"streak"`
+}
+
+// CategoryStat はカテゴリ別の累計成績。Attempts には終了したセッションで未回答だった問題も含む。
+type CategoryStat struct {
+	CategoryName    string  `json:"categoryName"`
+	Attempts        int     `json:"attempts"`
+	CorrectCount    int     `json:"correctCount"`
+	Accuracy        float64 `json:"accuracy"`
+	LastAttemptedAt string  `json:"lastAttemptedAt,omitempty"`
+}
+
+// CategoryStats はユーザーのカテゴリ別累計と、それを基に判定した苦手カテゴリ。
+type CategoryStats struct {
+	Categories     []CategoryStat `json:"categories"`
+	WeakCategories []string       `json:"weakCategories"`
+}
+
+// ChoiceStat は選択肢ごとの選択回数。Rate は回答数に対する割合。
+type ChoiceStat struct {
+	ChoiceID   int64   `json:"choiceId"`
+	ChoiceText string  `json:"choiceText"`
+	IsCorrect  bool    `json:"isCorrect"`
+	Count      int     `json:"count"`
+	Rate       float64 `json:"rate"`
+}
+
+// ItemStat は1問分の分析結果。
+// PValue は正答率 (難易度)、Discrimination は正誤とセッション得点率の点双列相関 (識別力) で、
+// 正答者・誤答者の一方しかいない場合は null。AvgTimeSec は所要時間を計測できた TimedCount 問の平均。
+type ItemStat struct {
+	ProblemID      int64        `json:"problemId"`
+	CategoryName   string       `json:"categoryName"`
+	Question       string       `json:"question"`
+	Attempts       int          `json:"attempts"`
+	CorrectCount   int          `json:"correctCount"`
+	PValue         float64      `json:"pValue"`
+	Discrimination *float64     `json:"discrimination"`
+	TimedCount     int          `json:"timedCount"`
+	AvgTimeSec     *float64     `json:"avgTimeSec"`
+	Choices        []ChoiceStat `json:"choices"`
+	Flags          []string     `json:"flags"`
+}
+
+// ItemAnalysis は全セッションの回答から求めた問題分析。
+type ItemAnalysis struct {
+	GeneratedAt  string     `json:"generatedAt"`
+	SessionCount int        `json:"sessionCount"`
+	Items        []ItemStat `json:"items"`
+}
+
+// ExportQuery はエクスポートの対象。SessionID が 0 の場合はユーザーの全セッション。
+type ExportQuery struct {
+	SessionID uint64
+}
+
+// ExportProblem はエクスポートする1問分の回答。未回答の場合 Selected* は空。
+type ExportProblem struct {
+	Idx                int      `json:"idx"`
+	CategoryName       string   `json:"categoryName"`
+	Question           string   `json:"question"`
+	SelectedChoiceID   *int64   `json:"selectedChoiceId"`
+	SelectedChoiceText string   `json:"selectedChoiceText"`
+	CorrectChoiceID    int64    `json:"correctChoiceId"`
+	CorrectChoiceText  string   `json:"correctChoiceText"`
+	IsCorrect          bool     `json:"isCorrect"`
+	WithHint           bool     `json:"withHint,omitempty"`
+	TimeSpentSec       *float64 `json:"timeSpentSec,omitempty"`
+}
+
+// ExportSession は1セッション分のエクスポート。
+type ExportSession struct {
+	SessionID    int64           `json:"sessionId"`
+	StartTime    string          `json:"startTime"`
+	ExamMode     bool            `json:"examMode,omitempty"`
+	Total        int             `json:"total"`
+	CorrectCount int             `json:"correctCount"`
+	Categories   []Category      `json:"categories"`
+	Problems     []ExportProblem `json:"problems"`
+}
+
+// Export はセッションの回答をファイルに書き出すためのデータ。Categories は全セッションの合計。
+type Export struct {
+	UserName    string          `json:"userName"`
+	GeneratedAt string          `json:"generatedAt"`
+	Categories  []Category      `json:"categories"`
+	Sessions    []ExportSession `json:"sessions"`
+}
+
+// CreateClassroomRequest はクラス作成のリクエスト。
+type CreateClassroomRequest struct {
+	Name string `json:"name"`
+}
+
+// JoinClassroomRequest は参加コードによるクラス参加のリクエスト。
+type JoinClassroomRequest struct {
+	JoinCode string `json:"joinCode"`
+}
+
+// Classroom は所属クラス。Role はリクエストしたユーザーの役割で、JoinCode は教師にのみ返す。
+type Classroom struct {
+	ClassID   string `json:"classId"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	JoinCode  string `json:"joinCode,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// ClassroomList は所属クラスの一覧。
+type ClassroomList struct {
+	Classes []Classroom `json:"classes"`
+}
+
+// ClassMember はクラスの名簿の1人分。
+type ClassMember struct {
+	UserSub  string `json:"userSub"`
+	UserName string `json:"userName"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joinedAt"`
+}
+
+// ClassroomDetail は教師向けのクラス詳細。Students は生徒のみで、名前順。
+type ClassroomDetail struct {
+	Classroom Classroom     `json:"classroom"`
+	Students  []ClassMember `json:"students"`
+}
+
+// AssignmentRule は課題の出題ルール。
+type AssignmentRule struct {
+	CategoryID int `json:"categoryId"`
+	Count      int `json:"count"`
+}
+
+// CreateAssignmentRequest は課題作成のリクエスト。ProblemIDs と Rules のどちらか一方を指定する。
+// 日時は RFC3339。ClosesAt を省略した場合は DueAt の24時間後に締め切る。MaxAttempts の省略時は1回。
+type CreateAssignmentRequest struct {
+	Title            string           `json:"title"`
+	ProblemIDs       []int64          `json:"problemIds"`
+	Rules            []AssignmentRule `json:"rules"`
+	DueAt            string           `json:"dueAt"`
+	ClosesAt         string           `json:"closesAt"`
+	TimeLimitMinutes int              `json:"timeLimitMinutes"`
+	MaxAttempts      int              `json:"maxAttempts"`
+	ExamMode         bool             `json:"examMode"`
+}
+
+// Assignment は課題。Status / AttemptsUsed / CurrentSessionID は生徒が取得した場合のみ入る。
+// GeneratedSessions は作成時に生徒へ配ったセッション数。
+type Assignment struct {
+	AssignmentID      string           `json:"assignmentId"`
+	ClassID           string           `json:"classId"`
+	Title             string           `json:"title"`
+	ProblemIDs        []int64          `json:"problemIds,omitempty"`
+	Rules             []AssignmentRule `json:"rules,omitempty"`
+	DueAt             string           `json:"dueAt"`
+	ClosesAt          string           `json:"closesAt"`
+	TimeLimitMinutes  int              `json:"timeLimitMinutes,omitempty"`
+	MaxAttempts       int              `json:"maxAttempts"`
+	ExamMode          bool             `json:"examMode,omitempty"`
+	Closed            bool             `json:"closed"`
+	Status            string           `json:"status,omitempty"`
+	AttemptsUsed      int              `json:"attemptsUsed,omitempty"`
+	CurrentSessionID  string           `json:"currentSessionId,omitempty"`
+	GeneratedSessions int              `json:"generatedSessions,omitempty"`
+}
+
+// AssignmentList はクラスの課題の一覧。
+type AssignmentList struct {
+	Assignments []Assignment `json:"assignments"`
+}
+
+// AssignmentAttempt は1回分の受験。Late は期限後に提出したか。
+type AssignmentAttempt struct {
+	Attempt      int    `json:"attempt"`
+	SessionID    string `json:"sessionId"`
+	Status       string `json:"status"`
+	Late         bool   `json:"late"`
+	StartedAt    string `json:"startedAt"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+	Total        int    `json:"total"`
+	CorrectCount int    `json:"correctCount"`
+}
+
+// StudentAssignmentStatus は生徒1人の課題の進み具合。Status は受験のうち最も進んだもの。
+type StudentAssignmentStatus struct {
+	UserSub  string              `json:"userSub"`
+	UserName string              `json:"userName"`
+	Status   string              `json:"status"`
+	Late     bool                `json:"late"`
+	Attempts []AssignmentAttempt `json:"attempts"`
+}
+
+// AssignmentStatus は教師向けの課題の提出状況。
+type AssignmentStatus struct {
+	Assignment     Assignment                `json:"assignment"`
+	SubmittedCount int                       `json:"submittedCount"`
+	LateCount      int                       `json:"lateCount"`
+	Students       []StudentAssignmentStatus `json:"students"`
+}
+
+// ClassAnalytics はクラス全体の集計。AtRiskStudents は教師にのみ返す。
+// 生徒に返す場合、回答のある生徒が少なく個人が特定できるときは Suppressed を立てて集計を省く。
+type ClassAnalytics struct {
+	ClassID           string              `json:"classId"`
+	GeneratedAt       string              `json:"generatedAt"`
+	Cached            bool                `json:"cached"`
+	StudentCount      int                 `json:"studentCount"`
+	ActiveStudents    int                 `json:"activeStudents"`
+	SessionCount      int                 `json:"sessionCount"`
+	AverageScore      *float64            `json:"averageScore,omitempty"`
+	Categories        []ClassCategoryStat `json:"categories"`
+	ScoreDistribution []ScoreBucket       `json:"scoreDistribution"`
+	MostMissed        []MissedProblem     `json:"mostMissed"`
+	AtRiskStudents    []AtRiskStudent     `json:"atRiskStudents,omitempty"`
+	Suppressed        bool                `json:"suppressed,omitempty"`
+}
+
+// ClassCategoryStat はカテゴリ別のクラス平均。AverageAccuracy は生徒ごとの正答率の平均 (生徒を同じ重みで扱う)。
+type ClassCategoryStat struct {
+	CategoryName    string  `json:"categoryName"`
+	StudentCount    int     `json:"studentCount"`
+	Attempts        int     `json:"attempts"`
+	CorrectCount    int     `json:"correctCount"`
+	AverageAccuracy float64 `json:"averageAccuracy"`
+}
+
+// ScoreBucket は終了したセッションの得点率 (%) が Min 以上 Max 未満の件数。最後の区間は100を含む。
+type ScoreBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// MissedProblem はクラスでよく間違えられた問題。終了したセッションの未回答も不正解に数える。
+type MissedProblem struct {
+	ProblemID    int64   `json:"problemId"`
+	CategoryName string  `json:"categoryName"`
+	Question     string  `json:"question"`
+	Attempts     int     `json:"attempts"`
+	MissCount    int     `json:"missCount"`
+	MissRate     float64 `json:"missRate"`
+	StudentCount int     `json:"studentCount"` // 1回以上間違えた生徒数
+}
+
+// AtRiskStudent は累計でも直近でも苦手なままのカテゴリがある生徒。
+type AtRiskStudent struct {
+	UserSub        string   `json:"userSub"`
+	UserName       string   `json:"userName"`
+	WeakCategories []string `json:"weakCategories"`
+	Accuracy       float64  `json:"accuracy"`
+}
+
+// CreateAPIKeyRequest は API キーの作成内容。Scopes は results:read / tests:write / admin から1つ以上。
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKey は API キーの一覧の1件。キーそのものは含まない。
+type APIKey struct {
+	KeyID      string   `json:"keyId"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"createdAt"`
+	LastUsedAt string   `json:"lastUsedAt,omitempty"`
+	RevokedAt  string   `json:"revokedAt,omitempty"`
+}
+
+// CreatedAPIKey は作成した API キー。Key は作成時にしか返さない。
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// UserRoles はユーザーの役割。生徒の役割は全員が持つため含めない。
+type UserRoles struct {
+	UserSub string   `json:"userSub"`
+	Roles   []string `json:"roles"`
+}
+
+// Profile はプロフィール。未保存の場合は表示名にログイン名、タイムゾーンに Asia/Tokyo を入れて返し、createdAt は空。
+type Profile struct {
+	UserSub             string          `json:"userSub"`
+	DisplayName         string          `json:"displayName"`
+	Grade               string          `json:"grade"`
+	TargetExam          string          `json:"targetExam"`
+	PreferredCategories []int           `json:"preferredCategories"`
+	Timezone            string          `json:"timezone"`
+	SessionDefaults     SessionDefaults `json:"sessionDefaults"`
+	CreatedAt           string          `json:"createdAt,omitempty"`
+	UpdatedAt           string          `json:"updatedAt,omitempty"`
+}
+
+// SessionDefaults はセッション作成時にクエリを省略した場合の既定値。
+type SessionDefaults struct {
+	IncludeIntegers bool `json:"includeIntegers"`
+	ExamMode        bool `json:"examMode"`
+}
+
+// UpdateProfileRequest はプロフィールの更新内容。すべての項目を置き換える。
+type UpdateProfileRequest struct {
+	DisplayName         string          `json:"displayName"`
+	Grade               string          `json:"grade"`
+	TargetExam          string          `json:"targetExam"`
+	PreferredCategories []int           `json:"preferredCategories"`
+	Timezone            string          `json:"timezone"`
+	SessionDefaults     SessionDefaults `json:"sessionDefaults"`
+}
+
+// AccountArchive は利用者のデータ一式 (GET /me/archive)。
+// 日時はプロフィールのタイムゾーンのオフセット付き RFC3339。ID は桁あふれしないよう文字列で返す。
+type AccountArchive struct {
+	UserSub       string            `json:"userSub"`
+	ExportedAt    string            `json:"exportedAt"`
+	Profile       Profile           `json:"profile"`
+	Roles         []string          `json:"roles"`
+	CategoryStats []CategoryStat    `json:"categoryStats"`
+	Sessions      []ArchivedSession `json:"sessions"`
+	Classes       []ArchivedClass   `json:"classes"`
+	Attempts      []ArchivedAttempt `json:"attempts"`
+	APIKeys       []APIKey          `json:"apiKeys"`
+}
+
+// ArchivedSession はセッション1回分の記録。Problems は出題順。
+type ArchivedSession struct {
+	SessionID       string                   `json:"sessionId"`
+	Status          string                   `json:"status"`
+	IncludeIntegers bool                     `json:"includeIntegers"`
+	ExamMode        bool                     `json:"examMode"`
+	StartTime       string                   `json:"startTime"`
+	FinishedAt      string                   `json:"finishedAt,omitempty"`
+	AssignmentID    string                   `json:"assignmentId,omitempty"`
+	Attempt         int                      `json:"attempt,omitempty"`
+	Problems        []ArchivedSessionProblem `json:"problems"`
+	AnswerEvents    []ArchivedAnswerEvent    `json:"answerEvents"`
+}
+
+// ArchivedSessionProblem は出題された問題と最後の回答。
+type ArchivedSessionProblem struct {
+	Idx              int    `json:"idx"`
+	ProblemID        string `json:"problemId"`
+	CategoryName     string `json:"categoryName"`
+	SelectedChoiceID string `json:"selectedChoiceId,omitempty"`
+	IsCorrect        *bool  `json:"isCorrect,omitempty"`
+	AnsweredWithHint bool   `json:"answeredWithHint"`
+	FirstViewedAt    string `json:"firstViewedAt,omitempty"`
+	AnsweredAt       string `json:"answeredAt,omitempty"`
+	HintRevealedAt   string `json:"hintRevealedAt,omitempty"`
+}
+
+// ArchivedAnswerEvent は回答1回分の記録 (回答の変更も含む)。
+type ArchivedAnswerEvent struct {
+	ProblemID    string `json:"problemId"`
+	CategoryName string `json:"categoryName"`
+	ChoiceID     string `json:"choiceId"`
+	IsCorrect    bool   `json:"isCorrect"`
+	WithHint     bool   `json:"withHint"`
+	AnsweredAt   string `json:"answeredAt"`
+}
+
+// ArchivedClass は所属するクラス。
+type ArchivedClass struct {
+	ClassID  string `json:"classId"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joinedAt"`
+}
+
+// ArchivedAttempt は課題の受験枠。
+type ArchivedAttempt struct {
+	ClassID      string `json:"classId"`
+	AssignmentID string `json:"assignmentId"`
+	Title        string `json:"title"`
+	Attempt      int    `json:"attempt"`
+	SessionID    string `json:"sessionId,omitempty"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// AccountDeletion は利用者のデータ削除の進捗。Phase は実行中の段階 (完了後は空)。
+// Deleted は段階ごとの削除したアイテム数。
+type AccountDeletion struct {
+	UserSub     string         `json:"userSub"`
+	RequestedBy string         `json:"requestedBy"`
+	Status      string         `json:"status"`
+	Phase       string         `json:"phase,omitempty"`
+	Deleted     map[string]int `json:"deleted"`
+	Anonymized  int            `json:"anonymized"`
+	StartedAt   string         `json:"startedAt"`
+	UpdatedAt   string         `json:"updatedAt"`
+	CompletedAt string         `json:"completedAt,omitempty"`
+}
+
+// CreatedSession は作成したセッションの ID。
+type CreatedSession struct {
+	SessionID string `json:"sessionId"`
+}
+
+// SessionListItem はセッション一覧の1件。Answered / Total で進み具合を表す。
+type SessionListItem struct {
+	SessionID       string `json:"sessionId"`
+	Status          string `json:"status"` // in_progress / finished / expired
+	ExamMode        bool   `json:"examMode"`
+	IncludeIntegers bool   `json:"includeIntegers"`
+	AssignmentID    string `json:"assignmentId,omitempty"`
+	Attempt         int    `json:"attempt,omitempty"`
+	Answered        int    `json:"answered"`
+	Total           int    `json:"total"`
+	CreatedAt       string `json:"createdAt"`
+	FinishedAt      string `json:"finishedAt,omitempty"`
+	ClosesAt        string `json:"closesAt,omitempty"`
+}
+
+type SessionList struct {
+	Sessions   []SessionListItem `json:"sessions"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// SessionProblemState は1問分の進み具合。正誤は含めない。
+type SessionProblemState struct {
+	Idx          int    `json:"idx"`
+	CategoryName string `json:"categoryName"`
+	Answered     bool   `json:"answered"`
+	Viewed       bool   `json:"viewed"`
+	HintRevealed bool   `json:"hintRevealed"`
+	Version      int64  `json:"version"`
+}
+
+// SessionState はセッションを再開するための状態。
+// NextIdx は最初の未回答の問題で、すべて回答済みなら null。Deadline は制限時間の終わり (計測前・無制限なら省略)。
+type SessionState struct {
+	SessionListItem
+	TimeLimitSec   int64                 `json:"timeLimitSec,omitempty"`
+	Deadline       string                `json:"deadline,omitempty"`
+	AcceptsAnswers bool                  `json:"acceptsAnswers"`
+	NextIdx        *int                  `json:"nextIdx"`
+	Problems       []SessionProblemState `json:"problems"`
 }
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type AccountHandler struct {
+	accountService service.AccountServicer
+}
+
+func NewAccountHandler(s service.AccountServicer) *AccountHandler {
+	return &AccountHandler{accountService: s}
+}
+
+// GET /me/archive
+// 自分のデータ一式 (プロフィール・セッション・回答履歴など) を JSON ファイルとして返す。
+func (h *AccountHandler) GetMyArchive(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	h.writeArchive(c, actor.Sub)
+}
+
+// GET /admin/users/:userSub/archive (管理者のみ)
+func (h *AccountHandler) GetUserArchive(c *gin.Context) {
+	if middleware.Principal(c) == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	h.writeArchive(c, c.Param("userSub"))
+}
+
+func (h *AccountHandler) writeArchive(c *gin.Context, userSub string) {
+	result, err := h.accountService.ExportAccount(middleware.Principal(c), userSub)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="mathovercome_archive_%s.json"`, userSub))
+	c.JSON(http.StatusOK, result)
+}
+
+// POST /admin/users/:userSub/deletion?batchSize= (管理者のみ)
+// 利用者のデータ削除を1回分 (batchSize 件程度) 進めて進捗を返す。status が completed になるまで繰り返し呼ぶ。
+// 自分自身は削除できない (409)。
+func (h *AccountHandler) DeleteUserData(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	batchSize := 0
+	if s := c.Query("batchSize"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			badRequest(c, "batchSize", "batchSize は1以上の整数で指定してください")
+			return
+		}
+		batchSize = n
+	}
+
+	result, err := h.accountService.DeleteAccount(actor, c.Param("userSub"), batchSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /admin/users/:userSub/deletion (管理者のみ)
+// 削除を始めていなければ 404。
+func (h *AccountHandler) GetUserDeletion(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.accountService.GetAccountDeletion(actor, c.Param("userSub"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/report"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type AdminHandler struct {
+	analysisService service.ItemAnalysisServicer
+}
+
+func NewAdminHandler(as service.ItemAnalysisServicer) *AdminHandler {
+	return &AdminHandler{analysisService: as}
+}
+
+// GET /admin/item-analysis?format=json|csv
+// CLI の analysis rebuild で保存した結果を返す (リクエストごとには計算しない)。
+func (h *AdminHandler) GetItemAnalysis(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		badRequest(c, "format", "format は json か csv を指定してください")
+		return
+	}
+
+	result, err := h.analysisService.Analyze()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="item_analysis.csv"`)
+		c.Status(http.StatusOK)
+		if err := report.WriteItemAnalysisCSV(c.Writer, result); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
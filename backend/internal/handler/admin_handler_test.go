@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+)
+
+type mockItemAnalysisService struct {
+	result *dto.ItemAnalysis
+}
+
+func (m *mockItemAnalysisService) Analyze() (*dto.ItemAnalysis, error) {
+	return m.result, nil
+}
+
+func (m *mockItemAnalysisService) Rebuild() (*dto.ItemAnalysis, error) {
+	return m.result, nil
+}
+
+func newAdminEngine(t *testing.T, admins ...string) *gin.Engine {
+	roles := testRoles{}
+	for _, sub := range admins {
+		roles[sub] = []string{identity.RoleAdmin}
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), testAuth())
+
+	h := handler.NewAdminHandler(&mockItemAnalysisService{result: &dto.ItemAnalysis{
+		SessionCount: 3,
+		Items: []dto.ItemStat{{
+			ProblemID: 10, CategoryName: "確率", Question: "Q", Attempts: 3, CorrectCount: 1, PValue: 1.0 / 3,
+			Choices: []dto.ChoiceStat{{ChoiceID: 1, IsCorrect: true, Count: 1}, {ChoiceID: 2, Count: 2}},
+			Flags:   []string{},
+		}},
+	}})
+	r.GET("/admin/item-analysis", middleware.RequireRole(roles, identity.RoleAdmin), h.GetItemAnalysis)
+	return r
+}
+
+func TestGetItemAnalysis_Unauthorized(t *testing.T) {
+	r := newAdminEngine(t, "admin-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/item-analysis", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestGetItemAnalysis_NonAdminForbidden(t *testing.T) {
+	r := newAdminEngine(t, "admin-1", "admin-2")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/item-analysis", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
+func TestGetItemAnalysis_JSON(t *testing.T) {
+	r := newAdminEngine(t, "admin-1", "admin-2")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/item-analysis", nil)
+	addUserSub(req, "admin-2")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.ItemAnalysis
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.SessionCount != 3 || len(resp.Items) != 1 || resp.Items[0].ProblemID != 10 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGetItemAnalysis_CSVOneRowPerChoice(t *testing.T) {
+	r := newAdminEngine(t, "admin-1")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/item-analysis?format=csv", nil)
+	addUserSub(req, "admin-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %s", ct)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected header + 2 choice rows, got %d", len(rows))
+	}
+	if rows[1][0] != "10" || rows[2][13] != "2" {
+		t.Errorf("unexpected rows: %v", rows[1:])
+	}
+}
+
+func TestGetItemAnalysis_BadFormat(t *testing.T) {
+	r := newAdminEngine(t, "admin-1")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/item-analysis?format=xml", nil)
+	addUserSub(req, "admin-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
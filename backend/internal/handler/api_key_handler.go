@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyServicer
+}
+
+func NewAPIKeyHandler(s service.APIKeyServicer) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: s}
+}
+
+// POST /api-keys
+// 作成したキーはこのレスポンスでしか返さない。API キーでの呼び出しは 403。
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req dto.CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.apiKeyService.CreateAPIKey(actor, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// GET /api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.apiKeyService.ListAPIKeys(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DELETE /api-keys/:keyId
+// 失効済みのキーでも 204 を返す。
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(actor, c.Param("keyId")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type AssignmentHandler struct {
+	assignmentService service.AssignmentServicer
+}
+
+func NewAssignmentHandler(as service.AssignmentServicer) *AssignmentHandler {
+	return &AssignmentHandler{assignmentService: as}
+}
+
+// POST /classes/:classId/assignments (クラスの教師のみ)
+func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.CreateAssignmentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.assignmentService.CreateAssignment(actor, classID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// GET /classes/:classId/assignments (クラスの教師・生徒)
+func (h *AssignmentHandler) ListAssignments(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.assignmentService.ListAssignments(actor, classID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.AssignmentList{Assignments: result})
+}
+
+// GET /classes/:classId/assignments/:assignmentId/status (クラスの教師のみ)
+func (h *AssignmentHandler) GetAssignmentStatus(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+	assignmentID, ok := assignmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.assignmentService.GetAssignmentStatus(actor, classID, assignmentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// POST /classes/:classId/assignments/:assignmentId/attempts (クラスの生徒のみ)
+// 続きから受験できるセッションがあれば 200、新しく作成した場合は 201 を返す。
+func (h *AssignmentHandler) StartAttempt(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+	assignmentID, ok := assignmentIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, created, err := h.assignmentService.StartAttempt(actor, classID, assignmentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if created {
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
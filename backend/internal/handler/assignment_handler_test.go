@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+type mockAssignmentService struct {
+	startFn func(user *model.User, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error)
+}
+
+func (m *mockAssignmentService) CreateAssignment(actor *identity.Principal, classID uint64, req dto.CreateAssignmentRequest) (*dto.Assignment, error) {
+	return nil, apperr.ErrForbidden
+}
+
+func (m *mockAssignmentService) ListAssignments(actor *identity.Principal, classID uint64) ([]dto.Assignment, error) {
+	return []dto.Assignment{}, nil
+}
+
+func (m *mockAssignmentService) GetAssignmentStatus(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentStatus, error) {
+	return nil, apperr.ErrForbidden
+}
+
+func (m *mockAssignmentService) StartAttempt(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error) {
+	return m.startFn(actor.User(), classID, assignmentID)
+}
+
+func TestStartAttempt_StatusCodes(t *testing.T) {
+	as := &mockAssignmentService{
+		startFn: func(user *model.User, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error) {
+			switch assignmentID {
+			case 1:
+				return &dto.AssignmentAttempt{Attempt: 2, SessionID: "10"}, true, nil
+			case 2:
+				return &dto.AssignmentAttempt{Attempt: 1, SessionID: "9"}, false, nil
+			case 3:
+				return nil, false, apperr.ErrAssignmentClosed
+			case 4:
+				return nil, false, apperr.ErrNoAttemptsLeft
+			default:
+				return nil, false, apperr.ErrNotFound
+			}
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), testAuth())
+	h := handler.NewAssignmentHandler(as)
+	r.POST("/classes/:classId/assignments/:assignmentId/attempts", h.StartAttempt)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"/classes/1/assignments/1/attempts", http.StatusCreated, ""},
+		{"/classes/1/assignments/2/attempts", http.StatusOK, ""},
+		{"/classes/1/assignments/3/attempts", http.StatusConflict, "assignment_closed"},
+		{"/classes/1/assignments/4/attempts", http.StatusConflict, "no_attempts_left"},
+		{"/classes/1/assignments/5/attempts", http.StatusNotFound, "not_found"},
+		{"/classes/1/assignments/x/attempts", http.StatusBadRequest, "invalid_parameter"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		addUserSub(req, "student")
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantStatus, w.Code)
+		}
+		if tt.wantCode != "" {
+			if code := problemCode(t, w); code != tt.wantCode {
+				t.Errorf("%s: expected code %q, got %q", tt.path, tt.wantCode, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type ClassAnalyticsHandler struct {
+	analyticsService service.ClassAnalyticsServicer
+}
+
+func NewClassAnalyticsHandler(as service.ClassAnalyticsServicer) *ClassAnalyticsHandler {
+	return &ClassAnalyticsHandler{analyticsService: as}
+}
+
+// GET /classes/:classId/analytics (教師は要注意の生徒を含む全体、生徒はクラス全体の集計のみ)
+func (h *ClassAnalyticsHandler) GetClassAnalytics(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.analyticsService.GetClassAnalytics(actor, classID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
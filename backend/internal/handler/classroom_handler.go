@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type ClassroomHandler struct {
+	classroomService service.ClassroomServicer
+	mypageService    service.MypageServicer
+}
+
+func NewClassroomHandler(cs service.ClassroomServicer, ms service.MypageServicer) *ClassroomHandler {
+	return &ClassroomHandler{classroomService: cs, mypageService: ms}
+}
+
+// POST /classes (教師のみ)
+func (h *ClassroomHandler) CreateClassroom(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req dto.CreateClassroomRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.classroomService.CreateClassroom(actor, req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// POST /classes/join
+func (h *ClassroomHandler) JoinClassroom(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req dto.JoinClassroomRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.classroomService.JoinClassroom(actor, req.JoinCode)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// GET /classes
+func (h *ClassroomHandler) ListClassrooms(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.classroomService.ListClassrooms(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, dto.ClassroomList{Classes: result})
+}
+
+// GET /classes/:classId (クラスの教師のみ)
+func (h *ClassroomHandler) GetClassroom(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.classroomService.GetClassroom(actor, classID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DELETE /classes/:classId/students/:studentSub (クラスの教師のみ)
+func (h *ClassroomHandler) RemoveStudent(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.classroomService.RemoveStudent(actor, classID, c.Param("studentSub")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GET /classes/:classId/students/:studentSub/mypage?limit=&cursor=&from=&to=&includeDetails=
+// 生徒の /session/mypage と同じ内容を教師に返す。
+func (h *ClassroomHandler) GetStudentMypage(c *gin.Context) {
+	student, ok := h.studentUser(c)
+	if !ok {
+		return
+	}
+	q, ok := getMypageQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.mypageService.GetUserData(student, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /classes/:classId/students/:studentSub/summary
+func (h *ClassroomHandler) GetStudentSummary(c *gin.Context) {
+	student, ok := h.studentUser(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.mypageService.GetSummary(student)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /classes/:classId/students/:studentSub/categories
+func (h *ClassroomHandler) GetStudentCategories(c *gin.Context) {
+	student, ok := h.studentUser(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.mypageService.GetCategoryStats(student)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// studentUser はリクエストしたユーザーがクラスの教師であることを確認し、対象の生徒を返す。
+// 確認できなかった場合はレスポンスを書き込んで false を返す。
+func (h *ClassroomHandler) studentUser(c *gin.Context) (*model.User, bool) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return nil, false
+	}
+	classID, ok := classIDParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	student, err := h.classroomService.StudentUser(actor, classID, c.Param("studentSub"))
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return student, true
+}
@@ -0,0 +1,162 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+type mockClassroomService struct {
+	createFn      func(teacher *model.User, name string) (*dto.Classroom, error)
+	joinFn        func(user *model.User, joinCode string) (*dto.Classroom, error)
+	studentUserFn func(actorSub string, classID uint64, studentSub string) (*model.User, error)
+}
+
+func (m *mockClassroomService) CreateClassroom(actor *identity.Principal, name string) (*dto.Classroom, error) {
+	return m.createFn(actor.User(), name)
+}
+
+func (m *mockClassroomService) JoinClassroom(actor *identity.Principal, joinCode string) (*dto.Classroom, error) {
+	return m.joinFn(actor.User(), joinCode)
+}
+
+func (m *mockClassroomService) ListClassrooms(actor *identity.Principal) ([]dto.Classroom, error) {
+	return []dto.Classroom{}, nil
+}
+
+func (m *mockClassroomService) GetClassroom(actor *identity.Principal, classID uint64) (*dto.ClassroomDetail, error) {
+	return nil, apperr.ErrForbidden
+}
+
+func (m *mockClassroomService) RemoveStudent(actor *identity.Principal, classID uint64, studentSub string) error {
+	return apperr.ErrForbidden
+}
+
+func (m *mockClassroomService) StudentUser(actor *identity.Principal, classID uint64, studentSub string) (*model.User, error) {
+	return m.studentUserFn(actor.Sub, classID, studentSub)
+}
+
+func newClassroomEngine(t *testing.T, cs *mockClassroomService, ms *mockMypageService) *gin.Engine {
+	roles := testRoles{"teacher": {identity.RoleTeacher}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), testAuth())
+
+	h := handler.NewClassroomHandler(cs, ms)
+	r.POST("/classes", middleware.RequireRole(roles, identity.RoleTeacher), h.CreateClassroom)
+	r.POST("/classes/join", h.JoinClassroom)
+	r.GET("/classes/:classId/students/:studentSub/mypage", h.GetStudentMypage)
+	return r
+}
+
+func TestCreateClassroom_RequiresTeacherRole(t *testing.T) {
+	cs := &mockClassroomService{
+		createFn: func(teacher *model.User, name string) (*dto.Classroom, error) {
+			return &dto.Classroom{ClassID: "1", Name: name, Role: model.ClassRoleTeacher, JoinCode: "ABCDEFGH"}, nil
+		},
+	}
+	r := newClassroomEngine(t, cs, &mockMypageService{})
+
+	tests := []struct {
+		sub  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"student", http.StatusForbidden},
+		{"teacher", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/classes", bytes.NewBufferString(`{"name":"1年A組"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.sub != "" {
+			addUserSub(req, tt.sub)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("sub %q: expected %d, got %d", tt.sub, tt.want, w.Code)
+		}
+	}
+}
+
+func TestJoinClassroom_UnknownCodeAndAlreadyJoined(t *testing.T) {
+	cs := &mockClassroomService{
+		joinFn: func(user *model.User, joinCode string) (*dto.Classroom, error) {
+			if joinCode == "JOINED00" {
+				return nil, apperr.ErrConflict
+			}
+			return nil, apperr.ErrNotFound
+		},
+	}
+	r := newClassroomEngine(t, cs, &mockMypageService{})
+
+	for code, want := range map[string]int{"UNKNOWN0": http.StatusNotFound, "JOINED00": http.StatusConflict} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/classes/join", bytes.NewBufferString(`{"joinCode":"`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		addUserSub(req, "student")
+		r.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("code %s: expected %d, got %d", code, want, w.Code)
+		}
+	}
+}
+
+func TestGetStudentMypage_UsesStudentUser(t *testing.T) {
+	cs := &mockClassroomService{
+		studentUserFn: func(actorSub string, classID uint64, studentSub string) (*model.User, error) {
+			if actorSub != "teacher" || classID != 1 {
+				return nil, apperr.ErrForbidden
+			}
+			return &model.User{Sub: studentSub, UserName: "生徒"}, nil
+		},
+	}
+	var gotSub string
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			gotSub = u.Sub
+			return &dto.User{UserName: u.UserName, TestSessDtos: []dto.TestSession{}}, nil
+		},
+	}
+	r := newClassroomEngine(t, cs, ms)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/classes/1/students/student-1/mypage", nil)
+	addUserSub(req, "teacher")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if gotSub != "student-1" {
+		t.Errorf("expected mypage of student-1, got %q", gotSub)
+	}
+	var resp dto.User
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.UserName != "生徒" {
+		t.Errorf("expected student name, got %q", resp.UserName)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/classes/1/students/student-1/mypage", nil)
+	addUserSub(req, "student-2")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for non-teacher, got %d", w.Code)
+	}
+}
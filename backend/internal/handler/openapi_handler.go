@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/openapi"
+)
+
+// GET /openapi.json (ログイン不要)
+// API の OpenAPI 文書を返す。
+func OpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openapi.Spec)
+}
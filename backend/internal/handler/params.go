@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+)
+
+// badRequest はパラメータが不正なことを 400 (invalid_parameter) で返す。param は不正だったパラメータ名。
+func badRequest(c *gin.Context, param, message string) {
+	c.Error(apperr.InvalidParameter(param, message))
+}
+
+// uintParam はパスの name を ID として返す。不正なら 400 を返して false。
+func uintParam(c *gin.Context, name string) (uint64, bool) {
+	s := c.Param(name)
+	if s == "" {
+		badRequest(c, name, name+" を指定してください")
+		return 0, false
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		badRequest(c, name, name+" は数値で指定してください")
+		return 0, false
+	}
+	return id, true
+}
+
+// sessionIDParam はパスの sessionId を返す。不正なら 400 を返して false。
+func sessionIDParam(c *gin.Context) (uint64, bool) {
+	return uintParam(c, "sessionId")
+}
+
+// classIDParam はパスの classId を返す。不正なら 400 を返して false。
+func classIDParam(c *gin.Context) (uint64, bool) {
+	return uintParam(c, "classId")
+}
+
+// assignmentIDParam はパスの assignmentId を返す。不正なら 400 を返して false。
+func assignmentIDParam(c *gin.Context) (uint64, bool) {
+	return uintParam(c, "assignmentId")
+}
+
+// bindJSON はリクエストの JSON を req に読み込む。不正なら 400 を返して false。
+func bindJSON(c *gin.Context, req any) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		badRequest(c, "body", "リクエストの JSON が不正です")
+		return false
+	}
+	return true
+}
+
+// idxParam はパスの idx (0始まり) を返す。不正なら 400 を返して false。
+func idxParam(c *gin.Context) (int, bool) {
+	idx, err := strconv.Atoi(c.Param("idx"))
+	if err != nil || idx < 0 {
+		badRequest(c, "idx", "idx は0以上の整数で指定してください")
+		return 0, false
+	}
+	return idx, true
+}
+
+// intQuery はクエリの整数を返す。省略時は 0、整数でなければ 400 を返して false。
+func intQuery(c *gin.Context, name string) (int, bool) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		badRequest(c, name, name+" は整数で指定してください")
+		return 0, false
+	}
+	return n, true
+}
+
+// optBoolQuery はクエリの真偽値を返す。省略時は nil、true/false 以外なら 400 を返して false。
+func optBoolQuery(c *gin.Context, name string) (*bool, bool) {
+	s, ok := c.GetQuery(name)
+	if !ok {
+		return nil, true
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		badRequest(c, name, name+" は true か false で指定してください")
+		return nil, false
+	}
+	return &v, true
+}
+
+// boolQuery はクエリの真偽値を返す。省略時は def、true/false 以外なら 400 を返して false。
+func boolQuery(c *gin.Context, name string, def bool) (bool, bool) {
+	v, ok := optBoolQuery(c, name)
+	if !ok || v == nil {
+		return def, ok
+	}
+	return *v, true
+}
+
+// LegacySessionID は旧ルート (/session/current/...?sessionId=) 用に、クエリの sessionId をパスパラメータとして渡す。
+// フロントエンドが /v1 に移行するまでの互換のために使う。
+func LegacySessionID(c *gin.Context) {
+	c.Params = append(c.Params, gin.Param{Key: "sessionId", Value: c.Query("sessionId")})
+	c.Next()
+}
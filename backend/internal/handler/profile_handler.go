@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type ProfileHandler struct {
+	profileService service.ProfileServicer
+}
+
+func NewProfileHandler(s service.ProfileServicer) *ProfileHandler {
+	return &ProfileHandler{profileService: s}
+}
+
+// GET /me
+// 未保存の場合も既定値のプロフィールを返す。
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.profileService.GetProfile(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// PUT /me
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	var req dto.UpdateProfileRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.profileService.UpdateProfile(actor, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
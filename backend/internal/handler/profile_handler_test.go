@@ -0,0 +1,137 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// mockProfileService は sub ごとに保存されたプロフィールを持つ。未保存ならトークンの名前を使う。
+type mockProfileService map[string]*dto.Profile
+
+func (m mockProfileService) GetProfile(actor *identity.Principal) (*dto.Profile, error) {
+	if p, ok := m[actor.Sub]; ok {
+		return p, nil
+	}
+	return &dto.Profile{UserSub: actor.Sub, DisplayName: actor.Name, PreferredCategories: []int{}, Timezone: "Asia/Tokyo"}, nil
+}
+
+func (m mockProfileService) UpdateProfile(actor *identity.Principal, req dto.UpdateProfileRequest) (*dto.Profile, error) {
+	if strings.TrimSpace(req.DisplayName) == "" {
+		return nil, apperr.ErrInvalidArgument
+	}
+	p := &dto.Profile{
+		UserSub:             actor.Sub,
+		DisplayName:         req.DisplayName,
+		Grade:               req.Grade,
+		TargetExam:          req.TargetExam,
+		PreferredCategories: req.PreferredCategories,
+		Timezone:            req.Timezone,
+		SessionDefaults:     req.SessionDefaults,
+	}
+	m[actor.Sub] = p
+	return p, nil
+}
+
+func (m mockProfileService) User(actor *identity.Principal) (*model.User, error) {
+	p, _ := m.GetProfile(actor)
+	u := actor.User()
+	u.UserName = p.DisplayName
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	u.Location = loc
+	return u, nil
+}
+
+func newProfileEngine(ps mockProfileService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), testAuth())
+
+	h := handler.NewProfileHandler(ps)
+	r.GET("/me", h.GetProfile)
+	r.PUT("/me", h.UpdateProfile)
+	return r
+}
+
+func TestGetProfile_Unauthorized(t *testing.T) {
+	r := newProfileEngine(mockProfileService{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestGetProfile_DefaultsWhenUnsaved(t *testing.T) {
+	r := newProfileEngine(mockProfileService{})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	addUserSub(req, "sub-1")
+	req.Header.Set(testUserNameHeader, "Alice")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.Profile
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.UserSub != "sub-1" || resp.DisplayName != "Alice" {
+		t.Errorf("unexpected profile: %+v", resp)
+	}
+}
+
+func TestUpdateProfile_Success(t *testing.T) {
+	ps := mockProfileService{}
+	r := newProfileEngine(ps)
+
+	body := `{"displayName":"Alice","grade":"高2","preferredCategories":[1,3],"timezone":"Asia/Tokyo","sessionDefaults":{"examMode":true}}`
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/me", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if p := ps["sub-1"]; p == nil || p.Grade != "高2" || !p.SessionDefaults.ExamMode {
+		t.Errorf("profile not saved as requested: %+v", p)
+	}
+}
+
+func TestUpdateProfile_BadRequest(t *testing.T) {
+	r := newProfileEngine(mockProfileService{})
+
+	for name, body := range map[string]string{
+		"malformed json": `{`,
+		"invalid value":  `{"displayName":" "}`,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/me", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+}
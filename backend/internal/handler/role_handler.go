@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type RoleHandler struct {
+	roleService service.RoleServicer
+}
+
+func NewRoleHandler(s service.RoleServicer) *RoleHandler {
+	return &RoleHandler{roleService: s}
+}
+
+// GET /me/roles
+// ログイン中の利用者の役割 (教師・管理者) を返す。フロントエンドが表示するメニューの切り替えに使う。
+func (h *RoleHandler) GetMyRoles(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.roleService.GetRoles(actor, actor.Sub)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /admin/users/:userSub/roles (管理者のみ)
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.roleService.GetRoles(actor, c.Param("userSub"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// PUT /admin/users/:userSub/roles/:role (管理者のみ)
+// 既に持っている役割でも 200 を返す。
+func (h *RoleHandler) GrantRole(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.roleService.GrantRole(actor, c.Param("userSub"), c.Param("role"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DELETE /admin/users/:userSub/roles/:role (管理者のみ)
+// 自分の管理者の役割は剥奪できない (409)。
+func (h *RoleHandler) RevokeRole(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.roleService.RevokeRole(actor, c.Param("userSub"), c.Param("role"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
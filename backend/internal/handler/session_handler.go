@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,139 +8,417 @@ import (
 
 	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
-	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/report"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
 
 type SessionHandler struct {
 	testSessService service.TestSessionServicer
 	mypageService   service.MypageServicer
+	profileService  service.ProfileServicer
 }
 
-func NewSessionHandler(ts service.TestSessionServicer, ms service.MypageServicer) *SessionHandler {
-	return &SessionHandler{testSessService: ts, mypageService: ms}
+func NewSessionHandler(ts service.TestSessionServicer, ms service.MypageServicer, ps service.ProfileServicer) *SessionHandler {
+	return &SessionHandler{testSessService: ts, mypageService: ms, profileService: ps}
 }
 
-// POST /session/test
+// POST /v1/sessions?includeIntegers=&examMode= (旧: POST /session/test)
+// 省略したクエリはプロフィールの既定値 (sessionDefaults) を使う。
 func (h *SessionHandler) CreateTestSess(c *gin.Context) {
-	userSub := c.GetHeader("X-User-Sub")
-	if userSub == "" {
-		c.Status(http.StatusUnauthorized)
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
-	includeIntegers, _ := strconv.ParseBool(c.DefaultQuery("includeIntegers", "false"))
+	includeIntegers, ok := optBoolQuery(c, "includeIntegers")
+	if !ok {
+		return
+	}
+	examMode, ok := optBoolQuery(c, "examMode")
+	if !ok {
+		return
+	}
+	// 省略されたものはプロフィールの既定値を使う
+	if includeIntegers == nil || examMode == nil {
+		profile, err := h.profileService.GetProfile(actor)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if includeIntegers == nil {
+			includeIntegers = &profile.SessionDefaults.IncludeIntegers
+		}
+		if examMode == nil {
+			examMode = &profile.SessionDefaults.ExamMode
+		}
+	}
 
-	testSess, err := h.testSessService.CreateTestSess(userSub, includeIntegers)
+	testSess, err := h.testSessService.CreateTestSess(actor, *includeIntegers, *examMode)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"sessionId": strconv.FormatUint(testSess.ID, 10)})
+	id := strconv.FormatUint(testSess.ID, 10)
+	c.Header("Location", "/v1/sessions/"+id)
+	c.JSON(http.StatusCreated, dto.CreatedSession{SessionID: id})
 }
 
-// GET /session/current/problems/:idx
+// GET /v1/sessions/:sessionId/problems/:idx (旧: GET /session/current/problems/:idx?sessionId=)
 func (h *SessionHandler) ViewOneProblem(c *gin.Context) {
-	userSub := c.GetHeader("X-User-Sub")
-	if userSub == "" {
-		c.Status(http.StatusUnauthorized)
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
-	sessionID, ok := getSessionIDFromQuery(c)
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+	idx, ok := idxParam(c)
 	if !ok {
-		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	idx, _ := strconv.Atoi(c.Param("idx"))
-
-	problem, err := h.testSessService.GetProblem(sessionID, userSub, idx)
+	problem, err := h.testSessService.GetProblem(sessionID, actor, idx)
 	if err != nil {
-		switch {
-		case errors.Is(err, apperr.ErrForbidden):
-			c.Status(http.StatusForbidden)
-		case errors.Is(err, apperr.ErrOutOfRange):
-			c.Status(http.StatusBadRequest)
-		case errors.Is(err, apperr.ErrNotFound):
-			c.Status(http.StatusNotFound)
-		default:
-			c.Status(http.StatusInternalServerError)
-		}
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, problem)
 }
 
-// POST /session/current/problems/:idx/answer
-func (h *SessionHandler) SubmitAnswer(c *gin.Context) {
-	userSub := c.GetHeader("X-User-Sub")
-	if userSub == "" {
-		c.Status(http.StatusUnauthorized)
+// POST /v1/sessions/:sessionId/problems/:idx/hint (旧: POST /session/current/problems/:idx/hint?sessionId=)
+// ヒントを返し、表示したことを記録する。試験モードのセッションでは 403。
+func (h *SessionHandler) RevealHint(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
 		return
 	}
 
-	sessionID, ok := getSessionIDFromQuery(c)
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+	idx, ok := idxParam(c)
 	if !ok {
-		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	idx, _ := strconv.Atoi(c.Param("idx"))
+	hint, err := h.testSessService.RevealHint(sessionID, actor, idx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hint)
+}
+
+// PUT /v1/sessions/:sessionId/problems/:idx/answer (旧: POST /session/current/problems/:idx/answer?sessionId=)
+func (h *SessionHandler) SubmitAnswer(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+	idx, ok := idxParam(c)
+	if !ok {
+		return
+	}
 
 	var req dto.AnswerRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Status(http.StatusBadRequest)
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	if err := h.testSessService.SubmitAnswer(sessionID, userSub, idx, req.SelectedChoiceID); err != nil {
-		switch {
-		case errors.Is(err, apperr.ErrForbidden):
-			c.Status(http.StatusForbidden)
-		case errors.Is(err, apperr.ErrOutOfRange), errors.Is(err, apperr.ErrNotFound):
-			c.Status(http.StatusBadRequest)
-		default:
-			c.Status(http.StatusInternalServerError)
-		}
+	if err := h.testSessService.SubmitAnswer(sessionID, actor, idx, req.SelectedChoiceID, req.Version); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-// GET /session/mypage
+// POST /v1/sessions/:sessionId/finish (旧: POST /session/current/finish?sessionId=)
+// セッションを終了する。終了済みでも 204 を返す。未回答の問題と並行して回答された場合は 409。
+func (h *SessionHandler) FinishSession(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.testSessService.FinishSession(sessionID, actor); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /v1/sessions/:sessionId/history (旧: GET /session/current/history?sessionId=)
+func (h *SessionHandler) GetAnswerHistory(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.testSessService.GetAnswerHistory(sessionID, actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GET /v1/me/mypage?limit=&cursor=&from=&to=&includeDetails= (旧: GET /session/mypage)
 func (h *SessionHandler) GetMypage(c *gin.Context) {
-	userSub := c.GetHeader("X-User-Sub")
-	if userSub == "" {
-		c.String(http.StatusUnauthorized, "NOT_LOGIN")
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	q, ok := getMypageQuery(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result, err := h.mypageService.GetUserData(user, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /v1/me/mypage/summary (旧: GET /session/mypage/summary)
+func (h *SessionHandler) GetMypageSummary(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result, err := h.mypageService.GetSummary(user)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	user := &model.User{
-		Sub:      userSub,
-		UserName: c.GetHeader("X-User-Name"),
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /v1/me/mypage/trends?sessions=&window= (旧: GET /session/mypage/trends)
+func (h *SessionHandler) GetMypageTrends(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
 	}
 
-	result, err := h.mypageService.GetUserData(user)
+	var q dto.TrendQuery
+	var ok bool
+	if q.Sessions, ok = intQuery(c, "sessions"); !ok {
+		return
+	}
+	if q.Window, ok = intQuery(c, "window"); !ok {
+		return
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result, err := h.mypageService.GetTrends(user, q)
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
-func getSessionIDFromQuery(c *gin.Context) (uint64, bool) {
-	s := c.Query("sessionId")
-	if s == "" {
-		return 0, false
+// GET /v1/me/mypage/categories (旧: GET /session/mypage/categories)
+func (h *SessionHandler) GetMypageCategories(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
 	}
-	id, err := strconv.ParseUint(s, 10, 64)
+
+	user, err := h.profileService.User(actor)
 	if err != nil {
-		return 0, false
+		c.Error(err)
+		return
+	}
+	result, err := h.mypageService.GetCategoryStats(user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /v1/me/export?format=json|csv|html&sessionId= (旧: GET /session/export)
+// sessionId を省略した場合は全セッションを書き出す。
+func (h *SessionHandler) Export(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "html" {
+		badRequest(c, "format", "format は json / csv / html のいずれかを指定してください")
+		return
+	}
+	var q dto.ExportQuery
+	if s := c.Query("sessionId"); s != "" {
+		sessionID, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			badRequest(c, "sessionId", "sessionId は数値で指定してください")
+			return
+		}
+		q.SessionID = sessionID
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result, err := h.mypageService.Export(user, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="mathovercome_export.csv"`)
+		c.Status(http.StatusOK)
+		if err := report.WriteExportCSV(c.Writer, result); err != nil {
+			c.Error(err)
+		}
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := report.WriteExportHTML(c.Writer, result); err != nil {
+			c.Error(err)
+		}
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GET /v1/sessions?status=&limit=&cursor=
+// 自分のセッションを新しい順に返す。status は in_progress / finished / expired。
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	q := dto.SessionListQuery{Cursor: c.Query("cursor"), Status: c.Query("status")}
+	var ok bool
+	if q.Limit, ok = intQuery(c, "limit"); !ok {
+		return
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	list, err := h.testSessService.ListSessions(user, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /v1/sessions/:sessionId
+// セッションを再開するための状態を返す。nextIdx が最初の未回答の問題。
+func (h *SessionHandler) GetSession(c *gin.Context) {
+	actor := middleware.Principal(c)
+	if actor == nil {
+		c.Error(apperr.ErrUnauthenticated)
+		return
+	}
+
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.profileService.User(actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	state, err := h.testSessService.GetSession(sessionID, user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// getMypageQuery はマイページの取得条件を返す。不正なら 400 を返して false。
+func getMypageQuery(c *gin.Context) (dto.MypageQuery, bool) {
+	q := dto.MypageQuery{
+		Cursor: c.Query("cursor"),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+	}
+	var ok bool
+	if q.Limit, ok = intQuery(c, "limit"); !ok {
+		return q, false
+	}
+	if q.IncludeDetails, ok = boolQuery(c, "includeDetails", true); !ok {
+		return q, false
 	}
-	return id, true
+	return q, true
 }
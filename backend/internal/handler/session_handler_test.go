@@ -2,10 +2,12 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +15,8 @@ import (
 	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
@@ -20,51 +24,165 @@ import (
 // --- モック実装 ---
 
 type mockTestSessionService struct {
-	createTestSessFn func(userSub string, includeIntegers bool) (*model.TestSession, error)
+	createTestSessFn func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error)
 	getProblemFn     func(sessionID uint64, userSub string, idx int) (*dto.SessionProblem, error)
-	submitAnswerFn   func(sessionID uint64, userSub string, idx int, choiceID *int64) error
+	revealHintFn     func(sessionID uint64, userSub string, idx int) (*dto.Hint, error)
+	submitAnswerFn   func(sessionID uint64, userSub string, idx int, choiceID *int64, version *int64) error
+	getHistoryFn     func(sessionID uint64, userSub string) (*dto.AnswerHistory, error)
+	finishSessionFn  func(sessionID uint64, userSub string) error
+	listSessionsFn   func(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error)
+	getSessionFn     func(sessionID uint64, user *model.User) (*dto.SessionState, error)
 }
 
-func (m *mockTestSessionService) CreateTestSess(userSub string, includeIntegers bool) (*model.TestSession, error) {
-	return m.createTestSessFn(userSub, includeIntegers)
+func (m *mockTestSessionService) CreateTestSess(actor *identity.Principal, includeIntegers, examMode bool) (*model.TestSession, error) {
+	return m.createTestSessFn(actor.Sub, includeIntegers, examMode)
 }
 
-func (m *mockTestSessionService) GetProblem(sessionID uint64, userSub string, idx int) (*dto.SessionProblem, error) {
-	return m.getProblemFn(sessionID, userSub, idx)
+func (m *mockTestSessionService) GetProblem(sessionID uint64, actor *identity.Principal, idx int) (*dto.SessionProblem, error) {
+	return m.getProblemFn(sessionID, actor.Sub, idx)
 }
 
-func (m *mockTestSessionService) SubmitAnswer(sessionID uint64, userSub string, idx int, choiceID *int64) error {
-	return m.submitAnswerFn(sessionID, userSub, idx, choiceID)
+func (m *mockTestSessionService) RevealHint(sessionID uint64, actor *identity.Principal, idx int) (*dto.Hint, error) {
+	return m.revealHintFn(sessionID, actor.Sub, idx)
+}
+
+func (m *mockTestSessionService) SubmitAnswer(sessionID uint64, actor *identity.Principal, idx int, choiceID *int64, version *int64) error {
+	return m.submitAnswerFn(sessionID, actor.Sub, idx, choiceID, version)
+}
+
+func (m *mockTestSessionService) GetAnswerHistory(sessionID uint64, actor *identity.Principal) (*dto.AnswerHistory, error) {
+	return m.getHistoryFn(sessionID, actor.Sub)
+}
+
+func (m *mockTestSessionService) FinishSession(sessionID uint64, actor *identity.Principal) error {
+	return m.finishSessionFn(sessionID, actor.Sub)
+}
+
+func (m *mockTestSessionService) ListSessions(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error) {
+	return m.listSessionsFn(user, q)
+}
+
+func (m *mockTestSessionService) GetSession(sessionID uint64, user *model.User) (*dto.SessionState, error) {
+	return m.getSessionFn(sessionID, user)
 }
 
 type mockMypageService struct {
-	getUserDataFn func(user *model.User) (*dto.User, error)
+	getUserDataFn func(user *model.User, q dto.MypageQuery) (*dto.User, error)
+	getSummaryFn  func(user *model.User) (*dto.MypageSummary, error)
+	getTrendsFn   func(user *model.User, q dto.TrendQuery) (*dto.Trends, error)
+	getCatStatsFn func(user *model.User) (*dto.CategoryStats, error)
+	exportFn      func(user *model.User, q dto.ExportQuery) (*dto.Export, error)
+}
+
+func (m *mockMypageService) GetUserData(user *model.User, q dto.MypageQuery) (*dto.User, error) {
+	return m.getUserDataFn(user, q)
+}
+
+func (m *mockMypageService) GetSummary(user *model.User) (*dto.MypageSummary, error) {
+	return m.getSummaryFn(user)
+}
+
+func (m *mockMypageService) GetTrends(user *model.User, q dto.TrendQuery) (*dto.Trends, error) {
+	return m.getTrendsFn(user, q)
 }
 
-func (m *mockMypageService) GetUserData(user *model.User) (*dto.User, error) {
-	return m.getUserDataFn(user)
+func (m *mockMypageService) GetCategoryStats(user *model.User) (*dto.CategoryStats, error) {
+	return m.getCatStatsFn(user)
 }
 
-// newSessionEngine はテスト用エンジンを作成する。
-// userSub が空でない場合、X-User-Sub ヘッダーをリクエストにセットするミドルウェアを追加する。
+func (m *mockMypageService) RebuildCategoryStats(userSub string) (*dto.CategoryStats, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockMypageService) Export(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+	return m.exportFn(user, q)
+}
+
+// newSessionEngine はテスト用エンジンを作成する。プロフィールは誰も保存していない状態。
+// ログイン中のユーザーは addUserSub でリクエストごとに指定する。
 func newSessionEngine(
 	ts service.TestSessionServicer,
 	ms service.MypageServicer,
 	userSub string,
+) *gin.Engine {
+	return newSessionEngineWithProfiles(ts, ms, mockProfileService{})
+}
+
+func newSessionEngineWithProfiles(
+	ts service.TestSessionServicer,
+	ms service.MypageServicer,
+	ps service.ProfileServicer,
 ) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
+	r.Use(middleware.Errors(), testAuth())
 
-	h := handler.NewSessionHandler(ts, ms)
+	h := handler.NewSessionHandler(ts, ms, ps)
 	r.POST("/session/test", h.CreateTestSess)
-	r.GET("/session/current/problems/:idx", h.ViewOneProblem)
-	r.POST("/session/current/problems/:idx/answer", h.SubmitAnswer)
+	current := r.Group("/session/current", handler.LegacySessionID)
+	current.GET("/problems/:idx", h.ViewOneProblem)
+	current.POST("/problems/:idx/hint", h.RevealHint)
+	current.POST("/problems/:idx/answer", h.SubmitAnswer)
+	current.POST("/finish", h.FinishSession)
+	current.GET("/history", h.GetAnswerHistory)
 	r.GET("/session/mypage", h.GetMypage)
+	r.GET("/session/mypage/summary", h.GetMypageSummary)
+	r.GET("/session/mypage/trends", h.GetMypageTrends)
+	r.GET("/session/mypage/categories", h.GetMypageCategories)
+	r.GET("/session/export", h.Export)
+	r.GET("/sessions", h.ListSessions)
+	r.GET("/sessions/:sessionId", h.GetSession)
+
+	v1 := r.Group("/v1/sessions")
+	v1.POST("", h.CreateTestSess)
+	v1.GET("/:sessionId/problems/:idx", h.ViewOneProblem)
+	v1.POST("/:sessionId/problems/:idx/hint", h.RevealHint)
+	v1.PUT("/:sessionId/problems/:idx/answer", h.SubmitAnswer)
+	v1.POST("/:sessionId/finish", h.FinishSession)
+	v1.GET("/:sessionId/history", h.GetAnswerHistory)
 	return r
 }
 
+// テストでログイン中のユーザーを指定するヘッダー。本番では利用者は検証済みの資格情報からのみ設定する。
+const (
+	testUserHeader     = "X-Test-User-Sub"
+	testUserNameHeader = "X-Test-User-Name"
+)
+
+// testAuth はテスト用ヘッダーの値を認証済みユーザーとしてコンテキストに入れる。
+func testAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sub := c.GetHeader(testUserHeader); sub != "" {
+			middleware.SetPrincipal(c, &identity.Principal{Sub: sub, Name: c.GetHeader(testUserNameHeader), Method: identity.MethodJWT})
+		}
+		c.Next()
+	}
+}
+
+// testRoles はテスト用の RoleChecker。sub ごとに保存された役割を持つ。
+type testRoles map[string][]string
+
+func (r testRoles) HasRole(_ context.Context, p *identity.Principal, role string) (bool, error) {
+	return p.HasRole(role) || identity.RolesInclude(r[p.Sub], role), nil
+}
+
 func addUserSub(req *http.Request, sub string) {
-	req.Header.Set("X-User-Sub", sub)
+	req.Header.Set(testUserHeader, sub)
+}
+
+// problemCode は problem+json のレスポンスの code を返す。
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, middleware.ProblemContentType) {
+		t.Errorf("expected %s, got %q", middleware.ProblemContentType, ct)
+	}
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return resp.Code
 }
 
 // --- CreateTestSess ---
@@ -81,7 +199,7 @@ func TestCreateTestSess_Unauthorized(t *testing.T) {
 
 func TestCreateTestSess_Success(t *testing.T) {
 	ts := &mockTestSessionService{
-		createTestSessFn: func(userSub string, includeIntegers bool) (*model.TestSession, error) {
+		createTestSessFn: func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error) {
 			return &model.TestSession{ID: 42, UserID: userSub}, nil
 		},
 	}
@@ -98,7 +216,7 @@ func TestCreateTestSess_Success(t *testing.T) {
 
 func TestCreateTestSess_ServiceError(t *testing.T) {
 	ts := &mockTestSessionService{
-		createTestSessFn: func(userSub string, includeIntegers bool) (*model.TestSession, error) {
+		createTestSessFn: func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error) {
 			return nil, errors.New("db error")
 		},
 	}
@@ -159,9 +277,9 @@ func TestViewOneProblem_Success(t *testing.T) {
 	ts := &mockTestSessionService{
 		getProblemFn: func(sID uint64, userSub string, idx int) (*dto.SessionProblem, error) {
 			return &dto.SessionProblem{
-				ID:       1,
-				Question: "1+1=?",
-				Hint:     "2です",
+				ID:            1,
+				Question:      "1+1=?",
+				HintAvailable: true,
 				Choices: []dto.Choice{
 					{ID: 5, ChoiceText: "2"},
 					{ID: 6, ChoiceText: "3"},
@@ -199,6 +317,110 @@ func TestViewOneProblem_Success(t *testing.T) {
 	}
 }
 
+func TestCreateTestSess_ExamMode(t *testing.T) {
+	var gotExam bool
+	ts := &mockTestSessionService{
+		createTestSessFn: func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error) {
+			gotExam = examMode
+			return &model.TestSession{ID: 42, UserID: userSub}, nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/test?examMode=true", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if !gotExam {
+		t.Error("expected examMode to be passed to service")
+	}
+}
+
+func TestCreateTestSess_ProfileDefaults(t *testing.T) {
+	var gotIntegers, gotExam bool
+	ts := &mockTestSessionService{
+		createTestSessFn: func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error) {
+			gotIntegers, gotExam = includeIntegers, examMode
+			return &model.TestSession{ID: 42, UserID: userSub}, nil
+		},
+	}
+	ps := mockProfileService{"sub-1": {
+		UserSub:         "sub-1",
+		DisplayName:     "Alice",
+		SessionDefaults: dto.SessionDefaults{IncludeIntegers: true, ExamMode: true},
+	}}
+	r := newSessionEngineWithProfiles(ts, nil, ps)
+
+	// クエリを省略するとプロフィールの既定値
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/test", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if !gotIntegers || !gotExam {
+		t.Errorf("expected profile defaults, got includeIntegers=%v examMode=%v", gotIntegers, gotExam)
+	}
+
+	// クエリを指定すればそちらが優先
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/session/test?includeIntegers=false&examMode=false", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if gotIntegers || gotExam {
+		t.Errorf("expected query to override defaults, got includeIntegers=%v examMode=%v", gotIntegers, gotExam)
+	}
+}
+
+// --- RevealHint ---
+
+func TestRevealHint_Success(t *testing.T) {
+	ts := &mockTestSessionService{
+		revealHintFn: func(sID uint64, userSub string, idx int) (*dto.Hint, error) {
+			return &dto.Hint{Idx: idx, Hint: "2です"}, nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/problems/1/hint?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.Hint
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Idx != 1 || resp.Hint != "2です" {
+		t.Errorf("unexpected hint: %+v", resp)
+	}
+}
+
+func TestRevealHint_ExamModeForbidden(t *testing.T) {
+	ts := &mockTestSessionService{
+		revealHintFn: func(sID uint64, userSub string, idx int) (*dto.Hint, error) {
+			return nil, apperr.ErrForbidden
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/problems/0/hint?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
 // --- SubmitAnswer ---
 
 func TestSubmitAnswer_Unauthorized(t *testing.T) {
@@ -213,7 +435,7 @@ func TestSubmitAnswer_Unauthorized(t *testing.T) {
 
 func TestSubmitAnswer_NullAnswer(t *testing.T) {
 	ts := &mockTestSessionService{
-		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64) error {
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
 			return nil
 		},
 	}
@@ -233,7 +455,7 @@ func TestSubmitAnswer_NullAnswer(t *testing.T) {
 
 func TestSubmitAnswer_WithChoice(t *testing.T) {
 	ts := &mockTestSessionService{
-		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64) error {
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
 			return nil
 		},
 	}
@@ -254,7 +476,7 @@ func TestSubmitAnswer_WithChoice(t *testing.T) {
 
 func TestSubmitAnswer_OutOfRange(t *testing.T) {
 	ts := &mockTestSessionService{
-		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64) error {
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
 			return apperr.ErrOutOfRange
 		},
 	}
@@ -272,6 +494,159 @@ func TestSubmitAnswer_OutOfRange(t *testing.T) {
 	}
 }
 
+func TestSubmitAnswer_Conflict(t *testing.T) {
+	var gotVersion *int64
+	ts := &mockTestSessionService{
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
+			gotVersion = version
+			current := int64(6)
+			return &apperr.ConflictError{Current: dto.AnswerState{Idx: idx, SelectedID: &current, Version: 2}}
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	choiceID, version := int64(5), int64(1)
+	body, _ := json.Marshal(dto.AnswerRequest{SelectedChoiceID: &choiceID, Version: &version})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/problems/0/answer?sessionId=10", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+	if gotVersion == nil || *gotVersion != 1 {
+		t.Errorf("expected version 1 to be passed, got %v", gotVersion)
+	}
+	var resp struct {
+		Code    string          `json:"code"`
+		Current dto.AnswerState `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Code != "version_conflict" {
+		t.Errorf("expected version_conflict, got %q", resp.Code)
+	}
+	if cur := resp.Current; cur.Version != 2 || cur.SelectedID == nil || *cur.SelectedID != 6 {
+		t.Errorf("unexpected current state: %+v", cur)
+	}
+}
+
+func TestSubmitAnswer_FinishedSession(t *testing.T) {
+	ts := &mockTestSessionService{
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
+			return apperr.ErrSessionFinished
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	choiceID := int64(5)
+	body, _ := json.Marshal(dto.AnswerRequest{SelectedChoiceID: &choiceID})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/problems/0/answer?sessionId=10", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+}
+
+// --- FinishSession ---
+
+func TestFinishSession_Success(t *testing.T) {
+	var gotID uint64
+	ts := &mockTestSessionService{
+		finishSessionFn: func(sID uint64, userSub string) error {
+			gotID = sID
+			return nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/finish?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if gotID != 10 {
+		t.Errorf("expected session 10, got %d", gotID)
+	}
+}
+
+func TestFinishSession_ConcurrentAnswerConflict(t *testing.T) {
+	ts := &mockTestSessionService{
+		finishSessionFn: func(sID uint64, userSub string) error {
+			return apperr.ErrConflict
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/session/current/finish?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+}
+
+// --- GetAnswerHistory ---
+
+func TestGetAnswerHistory_Success(t *testing.T) {
+	ts := &mockTestSessionService{
+		getHistoryFn: func(sID uint64, userSub string) (*dto.AnswerHistory, error) {
+			return &dto.AnswerHistory{
+				SessionID:    int64(sID),
+				Timeline:     []dto.AnswerEvent{{Idx: 0, ChoiceID: 1, IsCorrect: true}},
+				RightToWrong: 1,
+			}, nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/current/history?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.AnswerHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.SessionID != 10 || len(resp.Timeline) != 1 || resp.RightToWrong != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGetAnswerHistory_Forbidden(t *testing.T) {
+	ts := &mockTestSessionService{
+		getHistoryFn: func(sID uint64, userSub string) (*dto.AnswerHistory, error) {
+			return nil, apperr.ErrForbidden
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/current/history?sessionId=10", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
 // --- GetMypage ---
 
 func TestGetMypage_Unauthorized(t *testing.T) {
@@ -286,7 +661,7 @@ func TestGetMypage_Unauthorized(t *testing.T) {
 
 func TestGetMypage_Success(t *testing.T) {
 	ms := &mockMypageService{
-		getUserDataFn: func(u *model.User) (*dto.User, error) {
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
 			return &dto.User{UserName: u.UserName, TestSessDtos: []dto.TestSession{}}, nil
 		},
 	}
@@ -294,8 +669,8 @@ func TestGetMypage_Success(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/session/mypage", nil)
-	req.Header.Set("X-User-Sub", "sub-1")
-	req.Header.Set("X-User-Name", "TestUser")
+	addUserSub(req, "sub-1")
+	req.Header.Set(testUserNameHeader, "TestUser")
 	r.ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
@@ -310,9 +685,55 @@ func TestGetMypage_Success(t *testing.T) {
 	}
 }
 
+func TestGetMypage_UsesProfile(t *testing.T) {
+	var got *model.User
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			got = u
+			return &dto.User{UserName: u.UserName, TestSessDtos: []dto.TestSession{}}, nil
+		},
+	}
+	ps := mockProfileService{"sub-1": {UserSub: "sub-1", DisplayName: "Alice", Timezone: "America/New_York"}}
+	r := newSessionEngineWithProfiles(nil, ms, ps)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage", nil)
+	addUserSub(req, "sub-1")
+	req.Header.Set(testUserNameHeader, "TokenName")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if got.UserName != "Alice" || got.Location == nil || got.Location.String() != "America/New_York" {
+		t.Errorf("expected profile name and timezone, got %q %v", got.UserName, got.Location)
+	}
+}
+
+func TestGetMypage_IgnoresUserHeaders(t *testing.T) {
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			t.Errorf("service must not be called for unauthenticated request, got user %q", u.Sub)
+			return nil, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "")
+
+	// 認証を通っていないリクエストがヘッダーでユーザーを名乗っても未ログインとして扱う
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage", nil)
+	req.Header.Set("X-User-Sub", "sub-1")
+	req.Header.Set("X-User-Name", "TestUser")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
 func TestGetMypage_ServiceError(t *testing.T) {
 	ms := &mockMypageService{
-		getUserDataFn: func(u *model.User) (*dto.User, error) {
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
 			return nil, errors.New("db error")
 		},
 	}
@@ -326,3 +747,534 @@ func TestGetMypage_ServiceError(t *testing.T) {
 		t.Errorf("expected 500, got %d", w.Code)
 	}
 }
+
+func TestGetMypage_QueryParams(t *testing.T) {
+	var got dto.MypageQuery
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			got = q
+			return &dto.User{TestSessDtos: []dto.TestSession{}, NextCursor: "next"}, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage?limit=5&cursor=abc&from=2025-10-01&to=2025-10-31&includeDetails=false", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	want := dto.MypageQuery{Limit: 5, Cursor: "abc", From: "2025-10-01", To: "2025-10-31", IncludeDetails: false}
+	if got != want {
+		t.Errorf("expected query %+v, got %+v", want, got)
+	}
+	var resp dto.User
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.NextCursor != "next" {
+		t.Errorf("expected NextCursor = next, got %s", resp.NextCursor)
+	}
+}
+
+func TestGetMypage_DefaultIncludesDetails(t *testing.T) {
+	var got dto.MypageQuery
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			got = q
+			return &dto.User{TestSessDtos: []dto.TestSession{}}, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if !got.IncludeDetails {
+		t.Error("expected IncludeDetails = true by default")
+	}
+}
+
+func TestGetMypage_InvalidLimit(t *testing.T) {
+	r := newSessionEngine(nil, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage?limit=abc", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetMypage_InvalidArgument(t *testing.T) {
+	ms := &mockMypageService{
+		getUserDataFn: func(u *model.User, q dto.MypageQuery) (*dto.User, error) {
+			return nil, apperr.ErrInvalidArgument
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage?cursor=broken", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+// --- GetMypageSummary ---
+
+func TestGetMypageSummary_Unauthorized(t *testing.T) {
+	r := newSessionEngine(nil, nil, "")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/summary", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestGetMypageSummary_Success(t *testing.T) {
+	ms := &mockMypageService{
+		getSummaryFn: func(u *model.User) (*dto.MypageSummary, error) {
+			return &dto.MypageSummary{SessionCount: 3, Total: 36, CorrectCount: 18, Accuracy: 0.5}, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/summary", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.MypageSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.SessionCount != 3 || resp.CorrectCount != 18 {
+		t.Errorf("unexpected summary: %+v", resp)
+	}
+}
+
+// --- GetMypageTrends ---
+
+func TestGetMypageTrends_QueryParams(t *testing.T) {
+	var got dto.TrendQuery
+	ms := &mockMypageService{
+		getTrendsFn: func(user *model.User, q dto.TrendQuery) (*dto.Trends, error) {
+			got = q
+			return &dto.Trends{SessionCount: 2, Window: q.Window}, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/trends?sessions=5&window=2", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got.Sessions != 5 || got.Window != 2 {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	var resp dto.Trends
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.SessionCount != 2 || resp.Window != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGetMypageTrends_InvalidParam(t *testing.T) {
+	r := newSessionEngine(nil, &mockMypageService{}, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/trends?sessions=abc", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetMypageTrends_InvalidArgument(t *testing.T) {
+	ms := &mockMypageService{
+		getTrendsFn: func(user *model.User, q dto.TrendQuery) (*dto.Trends, error) {
+			return nil, apperr.ErrInvalidArgument
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/trends?sessions=1000", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+// --- GetMypageCategories ---
+
+func TestGetMypageCategories_Success(t *testing.T) {
+	ms := &mockMypageService{
+		getCatStatsFn: func(user *model.User) (*dto.CategoryStats, error) {
+			return &dto.CategoryStats{
+				Categories:     []dto.CategoryStat{{CategoryName: "確率", Attempts: 4, CorrectCount: 1, Accuracy: 0.25}},
+				WeakCategories: []string{"確率"},
+			}, nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/mypage/categories", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp dto.CategoryStats
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(resp.Categories) != 1 || resp.Categories[0].Attempts != 4 || len(resp.WeakCategories) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+// --- Export ---
+
+func exportResult() *dto.Export {
+	selected := int64(12)
+	return &dto.Export{
+		UserName:   "TestUser",
+		Categories: []dto.Category{{CategoryName: "確率", Total: 1}},
+		Sessions: []dto.ExportSession{{
+			SessionID: 1, StartTime: "2024-01-01 09:00:00", Total: 1,
+			Categories: []dto.Category{{CategoryName: "確率", Total: 1}},
+			Problems: []dto.ExportProblem{{
+				CategoryName: "確率", Question: "問題",
+				SelectedChoiceID: &selected, SelectedChoiceText: "誤答",
+				CorrectChoiceID: 11, CorrectChoiceText: "正解",
+			}},
+		}},
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	var gotQuery dto.ExportQuery
+	ms := &mockMypageService{
+		exportFn: func(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+			gotQuery = q
+			return exportResult(), nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/export?format=csv&sessionId=1", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if gotQuery.SessionID != 1 {
+		t.Errorf("expected SessionID = 1, got %d", gotQuery.SessionID)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %s", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "誤答") || !strings.Contains(lines[1], "正解") {
+		t.Errorf("unexpected csv: %q", w.Body.String())
+	}
+}
+
+func TestExport_HTML(t *testing.T) {
+	ms := &mockMypageService{
+		exportFn: func(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+			return exportResult(), nil
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/export?format=html", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "確率") {
+		t.Errorf("expected category in report")
+	}
+}
+
+func TestExport_BadRequest(t *testing.T) {
+	r := newSessionEngine(nil, &mockMypageService{}, "sub-1")
+
+	for _, path := range []string{"/session/export?format=xml", "/session/export?sessionId=abc"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestExport_Forbidden(t *testing.T) {
+	ms := &mockMypageService{
+		exportFn: func(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+			return nil, apperr.ErrForbidden
+		},
+	}
+	r := newSessionEngine(nil, ms, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/session/export?sessionId=2", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
+// --- ListSessions / GetSession ---
+
+func TestListSessions_PassesQuery(t *testing.T) {
+	var got dto.SessionListQuery
+	ts := &mockTestSessionService{
+		listSessionsFn: func(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error) {
+			if user.Sub != "sub-1" {
+				t.Errorf("unexpected user %q", user.Sub)
+			}
+			got = q
+			return &dto.SessionList{Sessions: []dto.SessionListItem{{SessionID: "3", Status: "in_progress", Answered: 2, Total: 12}}}, nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/sessions?status=in_progress&limit=5&cursor=abc", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got.Status != "in_progress" || got.Limit != 5 || got.Cursor != "abc" {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	if !strings.Contains(w.Body.String(), `"sessionId":"3"`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestListSessions_BadRequest(t *testing.T) {
+	ts := &mockTestSessionService{
+		listSessionsFn: func(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error) {
+			return nil, apperr.ErrInvalidArgument
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	for _, path := range []string{"/sessions?limit=x", "/sessions?status=paused"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestGetSession_Errors(t *testing.T) {
+	ts := &mockTestSessionService{
+		getSessionFn: func(sessionID uint64, user *model.User) (*dto.SessionState, error) {
+			switch sessionID {
+			case 1:
+				next := 3
+				return &dto.SessionState{NextIdx: &next}, nil
+			case 2:
+				return nil, apperr.ErrForbidden
+			}
+			return nil, apperr.ErrNotFound
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	for path, want := range map[string]int{
+		"/sessions/1":   http.StatusOK,
+		"/sessions/2":   http.StatusForbidden,
+		"/sessions/9":   http.StatusNotFound,
+		"/sessions/abc": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+		if want == http.StatusOK && !strings.Contains(w.Body.String(), `"nextIdx":3`) {
+			t.Errorf("unexpected body: %s", w.Body.String())
+		}
+	}
+}
+
+// --- /v1 ---
+
+func TestV1SubmitAnswer_PathParams(t *testing.T) {
+	var gotSession uint64
+	var gotIdx int
+	ts := &mockTestSessionService{
+		submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
+			gotSession, gotIdx = sID, idx
+			return nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	choiceID := int64(5)
+	body, _ := json.Marshal(dto.AnswerRequest{SelectedChoiceID: &choiceID})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/v1/sessions/10/problems/3/answer", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if gotSession != 10 || gotIdx != 3 {
+		t.Errorf("expected session 10 idx 3, got %d %d", gotSession, gotIdx)
+	}
+}
+
+func TestV1CreateTestSess_Location(t *testing.T) {
+	ts := &mockTestSessionService{
+		createTestSessFn: func(userSub string, includeIntegers, examMode bool) (*model.TestSession, error) {
+			return &model.TestSession{ID: 42, UserID: userSub}, nil
+		},
+	}
+	r := newSessionEngine(ts, nil, "sub-1")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/sessions?examMode=true", nil)
+	addUserSub(req, "sub-1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/v1/sessions/42" {
+		t.Errorf("unexpected Location: %q", loc)
+	}
+}
+
+func TestV1_InvalidParams(t *testing.T) {
+	r := newSessionEngine(&mockTestSessionService{}, nil, "sub-1")
+
+	for _, tc := range []struct{ method, path, param string }{
+		{http.MethodGet, "/v1/sessions/abc/problems/0", "sessionId"},
+		{http.MethodGet, "/v1/sessions/-1/problems/0", "sessionId"},
+		{http.MethodGet, "/v1/sessions/10/problems/abc", "idx"},
+		{http.MethodGet, "/v1/sessions/10/problems/-1", "idx"},
+		{http.MethodPut, "/v1/sessions/10/problems/0/answer", "body"},
+		{http.MethodPost, "/v1/sessions?includeIntegers=yes", "includeIntegers"},
+		{http.MethodGet, "/session/current/problems/0", "sessionId"},
+		{http.MethodPost, "/session/current/finish?sessionId=abc", "sessionId"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d", tc.method, tc.path, w.Code)
+			continue
+		}
+		var resp struct {
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+			Param  string `json:"param"`
+			Detail string `json:"detail"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if resp.Status != 400 || resp.Code != "invalid_parameter" || resp.Param != tc.param || resp.Detail == "" {
+			t.Errorf("%s %s: unexpected body %+v", tc.method, tc.path, resp)
+		}
+	}
+}
+
+func TestSubmitAnswer_DistinguishesNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{apperr.ErrSessionNotFound, http.StatusNotFound, "session_not_found"},
+		{apperr.ErrChoiceNotFound, http.StatusBadRequest, "choice_not_found"},
+		{apperr.ErrOutOfRange, http.StatusBadRequest, "problem_out_of_range"},
+		{apperr.ErrSessionFinished, http.StatusConflict, "session_finished"},
+		{errors.New("db error"), http.StatusInternalServerError, "internal"},
+	} {
+		ts := &mockTestSessionService{
+			submitAnswerFn: func(sID uint64, userSub string, idx int, choiceID *int64, version *int64) error {
+				return tc.err
+			},
+		}
+		r := newSessionEngine(ts, nil, "sub-1")
+
+		choiceID := int64(5)
+		body, _ := json.Marshal(dto.AnswerRequest{SelectedChoiceID: &choiceID})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/v1/sessions/10/problems/0/answer", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		addUserSub(req, "sub-1")
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.status, w.Code)
+		}
+		if code := problemCode(t, w); code != tc.code {
+			t.Errorf("%v: expected code %q, got %q", tc.err, tc.code, code)
+		}
+		if strings.Contains(w.Body.String(), "db error") {
+			t.Errorf("internal error leaked: %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package identity は認証済みの利用者 (Principal) と、入口ごとの認証方式を定義する。
+// HTTP・CLI・MCP のいずれも、入口で Authenticator が資格情報を検証して Principal を作り、context に入れて以降の処理に渡す。
+// クライアントが名乗ったユーザー ID をそのまま信用しないこと。
+package identity
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// 認証方式
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// API キーに付与できる権限。ログイン (JWT) した利用者はすべての権限を持つ。
+// 権限は操作の種類を絞るだけで、管理者・教師かどうかの判定は別に行う。
+const (
+	ScopeReadResults = "results:read" // 成績・マイページの閲覧
+	ScopeTakeTests   = "tests:write"  // 受験 (セッションの作成・回答・ヒント・終了、クラスへの参加、課題の受験) と自分のプロフィールの更新
+	ScopeAdmin       = "admin"        // 管理者・教師向けの操作 (問題分析、クラス・課題の管理)
+)
+
+// AllScopes はすべての権限を返す。
+func AllScopes() []string {
+	return []string{ScopeReadResults, ScopeTakeTests, ScopeAdmin}
+}
+
+// 役割。上位の役割は下位の役割の操作もできる (管理者 > 教師 > 生徒)。
+// ログインした利用者は全員が生徒。教師・管理者はトークンのクレーム (Cognito のグループ) か、保存された役割で決まる。
+// クラスごとの教師かどうかは別に、サービス側の AccessPolicy で判定する。
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
+var roleRank = map[string]int{RoleStudent: 1, RoleTeacher: 2, RoleAdmin: 3}
+
+// IsRole は role が既知の役割かを返す。
+func IsRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RolesInclude は roles に role 以上の役割が含まれるかを返す。生徒の役割は常に含まれる。
+func RolesInclude(roles []string, role string) bool {
+	if role == RoleStudent {
+		return true
+	}
+	if !IsRole(role) {
+		return false
+	}
+	for _, r := range roles {
+		if roleRank[r] >= roleRank[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleChecker は利用者が役割を持つかを、クレームと保存された役割の両方から判定する。
+type RoleChecker interface {
+	HasRole(ctx context.Context, p *Principal, role string) (bool, error)
+}
+
+// APIKeyPrefix は API キーの先頭に付ける文字列。JWT と見分けるのに使う。
+const APIKeyPrefix = "mok_"
+
+// ErrUnauthenticated は資格情報が無効 (署名不正・期限切れ・未登録など) であることを表す。
+// 認証基盤に到達できないなどの一時的な失敗はこのエラーをラップしない。
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal は認証済みの利用者。
+type Principal struct {
+	Sub    string   // Cognito sub
+	Name   string   // 表示名
+	Method string   // 認証方式
+	Scopes []string // 許可された操作
+	Roles  []string // トークンのクレームに含まれる役割。保存された役割は RoleChecker で確認する
+}
+
+// HasScope は scope の操作が許可されているかを返す。
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole はトークンのクレームだけで role 以上の役割を持つかを返す。
+func (p *Principal) HasRole(role string) bool {
+	return RolesInclude(p.Roles, role)
+}
+
+// User はサービスに渡すユーザーを返す。
+func (p *Principal) User() *model.User {
+	return &model.User{Sub: p.Sub, UserName: p.Name}
+}
+
+// Authenticator は資格情報 (トークンや API キー) を検証して Principal を返す。
+// 資格情報が無効な場合は ErrUnauthenticated をラップしたエラーを返す。
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*Principal, error)
+}
+
+// Select は API キーの形式の資格情報を keys で、それ以外 (JWT) を tokens で検証する Authenticator を返す。
+func Select(keys, tokens Authenticator) Authenticator {
+	return selector{keys: keys, tokens: tokens}
+}
+
+type selector struct {
+	keys, tokens Authenticator
+}
+
+func (s selector) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	if strings.HasPrefix(credential, APIKeyPrefix) {
+		return s.keys.Authenticate(ctx, credential)
+	}
+	return s.tokens.Authenticate(ctx, credential)
+}
+
+type contextKey struct{}
+
+// NewContext は p を入れた context を返す。
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext は context の Principal を返す。未認証なら false。
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,104 @@
+package idgen_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/idgen"
+)
+
+func TestSnowflake_Monotonic(t *testing.T) {
+	g := idgen.NewSnowflake(1)
+
+	prev := g.NextID()
+	for i := 0; i < 10000; i++ {
+		id := g.NextID()
+		if id <= prev {
+			t.Fatalf("expected increasing IDs, got %d after %d", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSnowflake_UniqueAcrossGoroutines(t *testing.T) {
+	g := idgen.NewSnowflake(1)
+
+	const workers, perWorker = 8, 2000
+	ids := make(chan uint64, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				ids <- g.NextID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[uint64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate ID: %d", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSnowflake_DifferentNodesDoNotCollide(t *testing.T) {
+	a := idgen.NewSnowflake(1)
+	b := idgen.NewSnowflake(2)
+
+	seen := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		for _, id := range []uint64{a.NextID(), b.NextID()} {
+			if seen[id] {
+				t.Fatalf("duplicate ID across nodes: %d", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestSnowflake_KeepsNineteenDigits(t *testing.T) {
+	// GSI1 の gsi1sk は文字列ソートのため、既存 ID と桁数が揃っている必要がある
+	id := idgen.NewSnowflake(0).NextID()
+	if n := len(strconv.FormatUint(id, 10)); n != 19 {
+		t.Errorf("expected 19 digits, got %d (%d)", n, id)
+	}
+}
+
+func TestFloorID_BoundsNextID(t *testing.T) {
+	before := time.Now()
+	id := idgen.NewSnowflake(5).NextID()
+	after := time.Now().Add(time.Millisecond)
+
+	if id < idgen.FloorID(before) || id >= idgen.FloorID(after) {
+		t.Errorf("expected %d in [%d, %d)", id, idgen.FloorID(before), idgen.FloorID(after))
+	}
+	// UnixNano 採番の旧 ID とは値の大きさで見分けられる
+	if id < idgen.LegacyIDLimit || uint64(before.UnixNano()) >= idgen.LegacyIDLimit {
+		t.Errorf("expected legacy IDs below %d and Snowflake IDs above", idgen.LegacyIDLimit)
+	}
+}
+
+func TestNodeIDFromEnv(t *testing.T) {
+	t.Setenv("ID_NODE", "42")
+	if got := idgen.NodeIDFromEnv(); got != 42 {
+		t.Errorf("expected node 42, got %d", got)
+	}
+
+	t.Setenv("ID_NODE", "")
+	t.Setenv("AWS_LAMBDA_LOG_STREAM_NAME", "2025/10/01/[$LATEST]abc")
+	first := idgen.NodeIDFromEnv()
+	if first != idgen.NodeIDFromEnv() {
+		t.Error("expected node ID derived from log stream to be stable")
+	}
+	if first > 1023 {
+		t.Errorf("expected node ID within 10 bits, got %d", first)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+var (
+	errInvalidCredential = apperr.New(apperr.ErrUnauthenticated, apperr.CodeUnauthenticated, "資格情報が無効です。ログインし直してください")
+	errAuthUnavailable   = apperr.New(apperr.ErrUnavailable, apperr.CodeServiceUnavailable, "認証基盤に接続できません。しばらくしてから再度お試しください")
+)
+
+// Authenticate は Authorization: Bearer の資格情報を a で検証し、Principal をコンテキストに入れる。
+// 資格情報が無いリクエストはそのまま通し (ハンドラーが未ログインとして 401 を返す)、無効な資格情報は 401 で止める。
+// 認証基盤 (JWKS など) に到達できない場合は 503。
+func Authenticate(a identity.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		credential, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			abort(c, errInvalidCredential)
+			return
+		}
+
+		p, err := a.Authenticate(c.Request.Context(), strings.TrimSpace(credential))
+		if errors.Is(err, identity.ErrUnauthenticated) {
+			c.Error(err)
+			abort(c, errInvalidCredential)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			abort(c, errAuthUnavailable)
+			return
+		}
+
+		SetPrincipal(c, p)
+		c.Next()
+	}
+}
@@ -0,0 +1,108 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/auth/authtest"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+)
+
+func newEngine(a identity.Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), middleware.Authenticate(a))
+	r.GET("/me", func(c *gin.Context) {
+		p := middleware.Principal(c)
+		if p == nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.String(http.StatusOK, p.Sub+"/"+p.Name+"/"+p.Method)
+	})
+	return r
+}
+
+func TestAuthenticate_JWT(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	v := auth.NewVerifier(auth.Config{
+		Issuer:    authtest.Issuer,
+		JWKSURL:   srv.JWKSURL(),
+		Audiences: []string{authtest.Audience},
+		TokenUse:  "id",
+	})
+	r := newEngine(v)
+
+	claims := func(name string) map[string]any {
+		c := map[string]any{
+			"sub":              "user-1",
+			"cognito:username": "taro",
+			"iss":              authtest.Issuer,
+			"aud":              authtest.Audience,
+			"token_use":        "id",
+			"exp":              time.Now().Add(time.Hour).Unix(),
+		}
+		if name != "" {
+			c["name"] = name
+		}
+		return c
+	}
+	expired := claims("太郎")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{"valid token", map[string]string{"Authorization": "Bearer " + srv.Sign(t, "k1", claims("太郎"))}, http.StatusOK, "user-1/太郎/jwt"},
+		{"falls back to username", map[string]string{"Authorization": "Bearer " + srv.Sign(t, "k1", claims(""))}, http.StatusOK, "user-1/taro/jwt"},
+		{"no token", nil, http.StatusUnauthorized, ""},
+		{"spoofed headers", map[string]string{"X-User-Sub": "admin", "X-User-Name": "admin"}, http.StatusUnauthorized, ""},
+		{"expired token", map[string]string{"Authorization": "Bearer " + srv.Sign(t, "k1", expired)}, http.StatusUnauthorized, ""},
+		{"not bearer", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.wantCode, w.Code)
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected body %q, got %q", tt.name, tt.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestAuthenticate_JWKSUnavailable(t *testing.T) {
+	srv := authtest.NewServer(t, "k1")
+	token := srv.Sign(t, "k1", map[string]any{"sub": "user-1"})
+	v := auth.NewVerifier(auth.Config{
+		Issuer:    authtest.Issuer,
+		JWKSURL:   "http://127.0.0.1:0/jwks.json",
+		Audiences: []string{authtest.Audience},
+		TokenUse:  "id",
+	})
+	r := newEngine(v)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+)
+
+// ProblemContentType は RFC 7807 のエラーレスポンスの Content-Type。
+const ProblemContentType = "application/problem+json"
+
+// Errors はハンドラーやミドルウェアが c.Error で渡した最後のエラーを RFC 7807 の problem+json で返す。
+// code (apperr.Code) と details の各項目は拡張メンバーとして入れる。内部エラーの原因はレスポンスに含めずログにだけ残す。
+// レスポンスを書き始めた後のエラー (CSV の書き出し中など) もログにだけ残す。
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		e := apperr.From(c.Errors.Last().Err)
+		status := problemStatus(e)
+		body := gin.H{
+			"type":     "about:blank",
+			"title":    http.StatusText(status),
+			"status":   status,
+			"detail":   e.Message,
+			"instance": c.Request.URL.Path,
+			"code":     e.Code,
+		}
+		for k, v := range e.Details {
+			if _, ok := body[k]; !ok {
+				body[k] = v
+			}
+		}
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(status, body)
+	}
+}
+
+// problemStatus はエラーの種類に対応する HTTP のステータスを返す。
+func problemStatus(e *apperr.Error) int {
+	switch {
+	case errors.Is(e, apperr.ErrInvalidArgument), errors.Is(e, apperr.ErrOutOfRange):
+		return http.StatusBadRequest
+	case errors.Is(e, apperr.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(e, apperr.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(e, apperr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(e, apperr.ErrConflict), errors.Is(e, apperr.ErrSessionFinished), errors.Is(e, apperr.ErrClosed):
+		return http.StatusConflict
+	case errors.Is(e, apperr.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// abort は以降のハンドラーを実行せず、err を Errors で返させる。
+func abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+// SetPrincipal は認証済みの利用者をリクエストの context に入れる。認証ミドルウェア (とテスト) からのみ呼ぶ。
+func SetPrincipal(c *gin.Context, p *identity.Principal) {
+	c.Request = c.Request.WithContext(identity.NewContext(c.Request.Context(), p))
+}
+
+// Principal は認証済みの利用者を返す。未ログインなら nil。
+// クライアントが送ったヘッダーは信用せず、検証済みの資格情報から作った値だけを使う。
+func Principal(c *gin.Context) *identity.Principal {
+	p, _ := identity.FromContext(c.Request.Context())
+	return p
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+// RequireLogin は認証済みの利用者のみ通す。未ログインは 401。
+// ルートのグループごとに付け、未ログインの応答をハンドラーによらず揃える。
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Principal(c) == nil {
+			abort(c, apperr.ErrUnauthenticated)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole は role 以上の役割を持つ利用者のみ通す。未ログインは 401、役割が無ければ 403。
+// 役割の確認に失敗した場合 (テーブルに到達できないなど) は 500。
+// クラスごとの教師かどうかはサービス側の AccessPolicy で判定する。
+func RequireRole(roles identity.RoleChecker, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := Principal(c)
+		if p == nil {
+			abort(c, apperr.ErrUnauthenticated)
+			return
+		}
+		ok, err := roles.HasRole(c.Request.Context(), p, role)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		if !ok {
+			abort(c, apperr.RoleRequired(role))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+)
+
+// RequireScope は認証済みの利用者が scope の操作を許可されている場合のみ通す。
+// 未ログインは 401、権限の無い API キーは 403。ログイン (JWT) した利用者はすべての権限を持つ。
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := Principal(c)
+		if p == nil {
+			abort(c, apperr.ErrUnauthenticated)
+			return
+		}
+		if !p.HasScope(scope) {
+			abort(c, apperr.ScopeRequired(scope))
+			return
+		}
+		c.Next()
+	}
+}
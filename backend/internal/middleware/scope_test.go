@@ -0,0 +1,62 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
+)
+
+// fixedAuth は特定の資格情報だけを受け付ける Authenticator。
+type fixedAuth map[string]*identity.Principal
+
+func (f fixedAuth) Authenticate(_ context.Context, credential string) (*identity.Principal, error) {
+	if p, ok := f[credential]; ok {
+		return p, nil
+	}
+	return nil, identity.ErrUnauthenticated
+}
+
+func TestRequireScope(t *testing.T) {
+	keys := fixedAuth{"mok_read": {Sub: "user-1", Method: identity.MethodAPIKey, Scopes: []string{identity.ScopeReadResults}}}
+	tokens := fixedAuth{"jwt": {Sub: "user-1", Method: identity.MethodJWT, Scopes: identity.AllScopes()}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors(), middleware.Authenticate(identity.Select(keys, tokens)))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/read", middleware.RequireScope(identity.ScopeReadResults), ok)
+	r.POST("/take", middleware.RequireScope(identity.ScopeTakeTests), ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		credential string
+		wantCode   int
+	}{
+		{"key with scope", http.MethodGet, "/read", "mok_read", http.StatusOK},
+		{"key without scope", http.MethodPost, "/take", "mok_read", http.StatusForbidden},
+		{"jwt has all scopes", http.MethodPost, "/take", "jwt", http.StatusOK},
+		{"jwt is not checked as key", http.MethodGet, "/read", "mok_jwt", http.StatusUnauthorized},
+		{"key is not checked as jwt", http.MethodGet, "/read", "read", http.StatusUnauthorized},
+		{"anonymous", http.MethodGet, "/read", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.credential != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.wantCode, w.Code)
+		}
+	}
+}
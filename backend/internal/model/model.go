@@ -5,6 +5,7 @@ import "time"
 type User struct {
 	Sub      string // Cognito sub
 	UserName string
+	Location *time.Location // 日時の表示に使うタイムゾーン。nil は JST
 }
 
 type Category struct {
@@ -27,12 +28,32 @@ type Choice struct {
 	IsCorrect  bool
 }
 
+// セッションの作成状態。ready になるまでは問題の取得やマイページの対象外。
+const (
+	SessionStatusPending = "pending"
+	SessionStatusReady   = "ready"
+)
+
 type TestSession struct {
 	ID              uint64
 	UserID          string // Cognito sub
 	IncludeIntegers bool
+	ExamMode        bool // true の場合ヒントを表示できない
 	StartTime       time.Time
+	Status          string
+	FinishedAt      *time.Time       // 終了した時刻。終了後は回答できない
 	SessionProblems []SessionProblem `json:",omitempty"`
+
+	// 課題から生成したセッションのみ。AssignmentID が 0 なら自習のセッション
+	AssignmentID uint64
+	Attempt      int           // 何回目の受験か (1始まり)
+	ClosesAt     *time.Time    // 課題の締切。これ以降は回答できない
+	TimeLimit    time.Duration // 最初の問題を表示してからの制限時間。0 なら無制限
+}
+
+// IsReady は作成が完了しているかを返す。status を持たない旧データは ready とみなす。
+func (s *TestSession) IsReady() bool {
+	return s.Status == "" || s.Status == SessionStatusReady
 }
 
 type SessionProblem struct {
@@ -44,4 +65,207 @@ type SessionProblem struct {
 	IsCorrect        *bool
 	CategoryName     string
 	CategoryID       int
+	Version          int64      // 回答のたびに増える。楽観ロックに使う
+	FirstViewedAt    *time.Time // 初めて問題を表示した時刻
+	AnsweredAt       *time.Time // 初めて回答した時刻。回答を変更しても更新しない
+	HintRevealedAt   *time.Time // 初めてヒントを表示した時刻
+	AnsweredWithHint bool       // 最後の回答がヒント表示後に行われたか
+}
+
+// TimeSpent は初回表示から初回回答までの所要時間を返す。
+// どちらかが記録されていない (旧データ・未回答) 場合は ok=false。
+func (sp *SessionProblem) TimeSpent() (d time.Duration, ok bool) {
+	if sp.FirstViewedAt == nil || sp.AnsweredAt == nil {
+		return 0, false
+	}
+	d = sp.AnsweredAt.Sub(*sp.FirstViewedAt)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// AnswerEvent は回答1回分の記録。回答を変更しても上書きされず追記される。
+type AnswerEvent struct {
+	ID               uint64
+	SessionID        uint64
+	SessionProblemID uint64
+	ProblemID        uint64
+	CategoryName     string
+	ChoiceID         uint64
+	IsCorrect        bool
+	WithHint         bool
+	AnsweredAt       time.Time
+}
+
+// CategoryStat はユーザーのカテゴリ別の累計成績。回答・セッション終了のたびに差分で更新する。
+// Attempts は回答した問題数と、終了したセッションで未回答のまま残った問題数の合計。
+type CategoryStat struct {
+	CategoryID      int
+	CategoryName    string
+	Attempts        int
+	CorrectCount    int
+	LastAttemptedAt *time.Time
+}
+
+// CategoryStatDelta は1回の書き込みで CategoryStat に加える差分。
+type CategoryStatDelta struct {
+	UserSub      string
+	CategoryID   int
+	CategoryName string
+	Attempts     int
+	CorrectCount int
+	At           time.Time
+}
+
+// SessionSummaryDelta は1回の回答でセッションの集計 (summary) に加える差分。
+// CategoryIdx は集計内でのカテゴリの位置 (出題順の初出順)。
+// Timed は初回回答で所要時間を計測できた場合のみ true で、ProblemIdx と TimeSpent はその問題の値。
+type SessionSummaryDelta struct {
+	CategoryIdx   int
+	CategoryName  string
+	Answered      int
+	Correct       int
+	Hinted        int
+	HintedCorrect int
+	Timed         bool
+	ProblemIdx    int
+	TimeSpent     time.Duration
+}
+
+// クラスでの役割
+const (
+	ClassRoleTeacher = "teacher"
+	ClassRoleStudent = "student"
+)
+
+// Classroom は教師が作成し、生徒が参加コードで参加するクラス。
+type Classroom struct {
+	ID        uint64
+	Name      string
+	JoinCode  string
+	OwnerSub  string // 作成した教師の Cognito sub
+	CreatedAt time.Time
+}
+
+// ClassMember はクラスへの所属。教師も role=teacher のメンバーとして持つ。
+type ClassMember struct {
+	ClassID  uint64
+	UserSub  string
+	UserName string
+	Role     string
+	JoinedAt time.Time
+}
+
+// AssignmentRule は課題の出題ルール。カテゴリごとに Count 問をランダムに出題する。
+type AssignmentRule struct {
+	CategoryID int
+	Count      int
+}
+
+// Assignment は教師がクラスに出す課題。ProblemIDs が空でなければ固定の問題セットを、空なら Rules に従って出題する。
+// DueAt を過ぎた提出は遅延として扱い、ClosesAt を過ぎると回答できなくなる。
+type Assignment struct {
+	ID          uint64
+	ClassID     uint64
+	Title       string
+	ProblemIDs  []uint64
+	Rules       []AssignmentRule
+	DueAt       time.Time
+	ClosesAt    time.Time
+	TimeLimit   time.Duration
+	MaxAttempts int
+	ExamMode    bool
+	CreatedBy   string
+	CreatedAt   time.Time
+}
+
+// IsClosed は課題が締め切られているかを返す。
+func (a *Assignment) IsClosed(now time.Time) bool {
+	return !now.Before(a.ClosesAt)
+}
+
+// AssignmentAttempt は生徒1人の1回分の受験。FinishedAt / Total / CorrectCount はセッションから読み込む。
+type AssignmentAttempt struct {
+	AssignmentID uint64
+	UserSub      string
+	Attempt      int
+	SessionID    uint64 // 0 はセッション作成中 (または作成に失敗した)
+	CreatedAt    time.Time
+	FinishedAt   *time.Time
+	Total        int
+	CorrectCount int
+}
+
+// ClassAnalyticsCache はクラス分析の計算結果のキャッシュ。
+// Version は生徒の回答・セッションの終了・所属の変更のたびに増えるクラスの版で、
+// 計算時の版 (ComputedVersion) や集計方法 (Params) と一致しなければ Payload は古い。
+type ClassAnalyticsCache struct {
+	ClassID         uint64
+	Version         int64
+	ComputedVersion int64
+	Params          string
+	Payload         []byte
+	GeneratedAt     time.Time
+}
+
+// IsFresh は Payload が版 Version と集計方法 params で計算したものかを返す。
+func (c *ClassAnalyticsCache) IsFresh(params string) bool {
+	return len(c.Payload) > 0 && c.ComputedVersion == c.Version && c.Params == params
+}
+
+// ItemAnalysisSnapshot は保存済みの問題分析。Payload は計算結果 (dto.ItemAnalysis) の JSON。
+type ItemAnalysisSnapshot struct {
+	Payload     []byte
+	GeneratedAt time.Time
+}
+
+// APIKey はスクリプトや MCP から使う利用者ごとの API キー。キーそのものは保存せず、ハッシュだけを持つ。
+type APIKey struct {
+	ID         string // キーに含まれる公開の識別子
+	UserSub    string
+	UserName   string
+	Name       string // 用途のメモ
+	Hash       string // キー全体の SHA-256 (16進)
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time // 失効した時刻。失効したキーでは認証できない
+}
+
+// UserProfile は利用者が設定するプロフィール。未設定の項目はゼロ値。
+type UserProfile struct {
+	Sub                    string
+	DisplayName            string
+	Grade                  string // 学年 (例: 高2)
+	TargetExam             string // 目標とする試験
+	PreferredCategories    []int  // 重点的に取り組むカテゴリ
+	Timezone               string // IANA のタイムゾーン名
+	DefaultIncludeIntegers bool   // セッション作成時に整数問題を含めるかの既定値
+	DefaultExamMode        bool   // セッション作成時に試験モードにするかの既定値
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+}
+
+// 退会 (アカウントデータの削除) の状態
+const (
+	DeletionStatusRunning   = "running"
+	DeletionStatusCompleted = "completed"
+)
+
+// DeletedUserSub は削除した利用者の sub の代わりに残す値。他の利用者も使うクラス・課題の作成者に使う。
+const DeletedUserSub = "deleted-user"
+
+// AccountDeletion は利用者のデータ削除の進捗。削除は段階 (Phase) ごとに少しずつ進め、途中から再開できる。
+// 完了後も誰がいつ削除したかの記録として残す (利用者のデータは含まない)。
+type AccountDeletion struct {
+	UserSub     string
+	RequestedBy string // 削除を実行した管理者の Cognito sub
+	Status      string
+	Phase       string         // 実行中の段階。完了後は空
+	Deleted     map[string]int // 段階ごとの削除したアイテム数
+	Anonymized  int            // 削除せずに利用者の情報だけを消したアイテム数 (他の利用者も使うクラス・課題)
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
 }
@@ -0,0 +1,336 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 生成するクライアントが読む OpenAPI 文書の項目
+type document struct {
+	Paths      map[string]map[string]docOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]map[string]any `json:"schemas"`
+	} `json:"components"`
+}
+
+type docOperation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Deprecated  bool   `json:"deprecated"`
+	Parameters  []struct {
+		Name        string         `json:"name"`
+		In          string         `json:"in"`
+		Description string         `json:"description"`
+		Schema      map[string]any `json:"schema"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]docMedia `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]docMedia `json:"content"`
+	} `json:"responses"`
+}
+
+type docMedia struct {
+	Schema map[string]any `json:"schema"`
+}
+
+var methodOrder = map[string]int{"get": 0, "post": 1, "put": 2, "delete": 3}
+
+// GenerateClient は OpenAPI 文書から apiclient パッケージの型付きメソッド (client_gen.go) を生成する。
+// deprecated の操作 (旧ルート) は生成しない。スキーマの Go の型は x-go-type (dto.X) を使う。
+// JSON 以外の形式も返す操作は JSON だけを受け取るので、形式を選ぶ format クエリは生成しない。
+func GenerateClient(spec []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("OpenAPI 文書の読み込みに失敗: %w", err)
+	}
+
+	type entry struct {
+		method, path string
+		op           docOperation
+	}
+	var entries []entry
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			if !op.Deprecated {
+				entries = append(entries, entry{method, path, op})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].path != entries[j].path {
+			return entries[i].path < entries[j].path
+		}
+		return methodOrder[entries[i].method] < methodOrder[entries[j].method]
+	})
+
+	g := &generator{schemas: doc.Components.Schemas, imports: map[string]bool{"context": true}}
+	for _, e := range entries {
+		if err := g.method(strings.ToUpper(e.method), e.path, e.op); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", e.method, e.path, err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by go generate ./internal/openapi; DO NOT EDIT.\n\npackage apiclient\n\nimport (\n")
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		if !strings.Contains(imp, ".") {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+	}
+	out.WriteString("\n")
+	for _, imp := range imports {
+		if strings.Contains(imp, ".") {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+type generator struct {
+	schemas map[string]map[string]any
+	imports map[string]bool
+	buf     bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// method は1つの操作のメソッド (とクエリパラメータの構造体) を書く。
+func (g *generator) method(method, path string, op docOperation) error {
+	name := exported(op.OperationID)
+
+	// 返す型。JSON を返す最初の 2xx のレスポンスで決める (無ければ 204)
+	var result string
+	var formats bool
+	var codes []string
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		content := op.Responses[code].Content
+		if media, ok := content["application/json"]; ok && result == "" {
+			t, err := g.goType(media.Schema)
+			if err != nil {
+				return err
+			}
+			result = t
+			formats = len(content) > 1
+		}
+	}
+
+	var args []string
+	paramTypes := make(map[string]string)
+	type queryField struct{ name, field, typ, desc string }
+	var query []queryField
+	for _, p := range op.Parameters {
+		t, err := paramType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+		switch p.In {
+		case "path":
+			arg := unexported(p.Name)
+			args = append(args, arg+" "+t)
+			paramTypes[p.Name] = t
+		case "query":
+			if formats && p.Name == "format" {
+				continue
+			}
+			if t == "bool" {
+				t = "*bool"
+			}
+			query = append(query, queryField{p.Name, exported(p.Name), t, p.Description})
+		}
+	}
+	if op.RequestBody != nil {
+		t, err := g.goType(op.RequestBody.Content["application/json"].Schema)
+		if err != nil {
+			return err
+		}
+		args = append(args, "body "+t)
+	}
+	if len(query) > 0 {
+		params := name + "Params"
+		g.printf("\n// %s は %s のクエリパラメータ。ゼロ値 (nil) の項目は送らない。\n", params, name)
+		g.printf("type %s struct {\n", params)
+		for _, q := range query {
+			if q.desc != "" {
+				g.printf("// %s\n", q.desc)
+			}
+			g.printf("%s %s\n", q.field, q.typ)
+		}
+		g.printf("}\n")
+		args = append(args, "params *"+params)
+	}
+
+	summary := op.Summary
+	if summary != "" && summary[0] < utf8.RuneSelf {
+		summary = " " + summary
+	}
+	g.printf("\n// %s は%s。\n// %s %s\n", name, summary, method, path)
+	returns := "error"
+	if result != "" {
+		returns = "(" + resultType(result) + ", error)"
+	}
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "), returns)
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		g.imports["net/url"] = true
+		queryArg = "q"
+		g.printf("q := url.Values{}\nif params != nil {\n")
+		for _, f := range query {
+			switch f.typ {
+			case "*bool":
+				g.imports["strconv"] = true
+				g.printf("if params.%s != nil {\nq.Set(%q, strconv.FormatBool(*params.%[1]s))\n}\n", f.field, f.name)
+			case "int":
+				g.imports["strconv"] = true
+				g.printf("if params.%s != 0 {\nq.Set(%q, strconv.Itoa(params.%[1]s))\n}\n", f.field, f.name)
+			case "uint64":
+				g.imports["strconv"] = true
+				g.printf("if params.%s != 0 {\nq.Set(%q, strconv.FormatUint(params.%[1]s, 10))\n}\n", f.field, f.name)
+			default:
+				g.printf("if params.%s != \"\" {\nq.Set(%q, params.%[1]s)\n}\n", f.field, f.name)
+			}
+		}
+		g.printf("}\n")
+	}
+
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		bodyArg = "body"
+	}
+	urlPath := g.pathExpr(path, paramTypes)
+	if result == "" {
+		g.printf("return c.do(ctx, %q, %s, %s, %s, nil)\n}\n", method, urlPath, queryArg, bodyArg)
+		return nil
+	}
+	g.printf("var out %s\n", result)
+	if resultType(result) == result {
+		g.printf("err := c.do(ctx, %q, %s, %s, %s, &out)\nreturn out, err\n}\n", method, urlPath, queryArg, bodyArg)
+		return nil
+	}
+	g.printf("if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n", method, urlPath, queryArg, bodyArg)
+	return nil
+}
+
+// pathExpr は OpenAPI のパス (/sessions/{sessionId}) をパスパラメータから組み立てる式にする。
+func (g *generator) pathExpr(path string, types map[string]string) string {
+	var parts []string
+	lit := ""
+	for _, seg := range strings.Split(path, "/")[1:] {
+		lit += "/"
+		name, ok := strings.CutPrefix(seg, "{")
+		if !ok {
+			lit += seg
+			continue
+		}
+		name = strings.TrimSuffix(name, "}")
+		parts = append(parts, fmt.Sprintf("%q", lit))
+		lit = ""
+		arg := unexported(name)
+		switch types[name] {
+		case "uint64":
+			g.imports["strconv"] = true
+			parts = append(parts, "strconv.FormatUint("+arg+", 10)")
+		case "int":
+			g.imports["strconv"] = true
+			parts = append(parts, "strconv.Itoa("+arg+")")
+		default:
+			g.imports["net/url"] = true
+			parts = append(parts, "url.PathEscape("+arg+")")
+		}
+	}
+	if lit != "" {
+		parts = append(parts, fmt.Sprintf("%q", lit))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// goType はスキーマの Go の型を返す。構造体は x-go-type、型の無いオブジェクトは json.RawMessage。
+func (g *generator) goType(schema map[string]any) (string, error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		t, ok := g.schemas[name]["x-go-type"].(string)
+		if !ok {
+			return "", fmt.Errorf("%s に x-go-type がありません", name)
+		}
+		if strings.HasPrefix(t, "dto.") {
+			g.imports["github.com/Kyouheip/MathOvercome_serverless/internal/dto"] = true
+		}
+		return t, nil
+	}
+	switch schema["type"] {
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		t, err := g.goType(items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + t, nil
+	case "object":
+		if _, ok := schema["properties"]; !ok {
+			g.imports["encoding/json"] = true
+			return "json.RawMessage", nil
+		}
+	}
+	return "", fmt.Errorf("生成できないスキーマです: %v", schema)
+}
+
+// resultType はメソッドが返す型。構造体はポインタで返す。
+func resultType(t string) string {
+	if strings.HasPrefix(t, "[]") || t == "json.RawMessage" {
+		return t
+	}
+	return "*" + t
+}
+
+// paramType はパラメータの Go の型を返す。
+func paramType(schema map[string]any) (string, error) {
+	switch schema["type"] {
+	case "integer":
+		if schema["format"] == "uint64" {
+			return "uint64", nil
+		}
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "string":
+		return "string", nil
+	}
+	return "", fmt.Errorf("生成できないパラメータの型です: %v", schema)
+}
+
+// exported は operationId やパラメータ名を Go の公開名にする (sessionId → SessionID)。
+func exported(name string) string {
+	name = unexported(name)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// unexported はパラメータ名を Go の非公開名にする (sessionId → sessionID)。
+func unexported(name string) string {
+	if base, ok := strings.CutSuffix(name, "Id"); ok {
+		return base + "ID"
+	}
+	return name
+}
@@ -0,0 +1,28 @@
+// gen は OpenAPI 文書 (openapi.json) と apiclient のメソッド (client_gen.go) を生成する。
+// internal/openapi で go generate から実行する。
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/openapi"
+)
+
+func main() {
+	spec, err := openapi.Build()
+	if err != nil {
+		log.Fatalf("OpenAPI 文書の生成に失敗: %v", err)
+	}
+	if err := os.WriteFile("openapi.json", spec, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := openapi.GenerateClient(spec)
+	if err != nil {
+		log.Fatalf("クライアントの生成に失敗: %v", err)
+	}
+	if err := os.WriteFile("../apiclient/client_gen.go", client, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package openapi は HTTP API の OpenAPI 3.1 文書を Operations と dto の型から作る。
+// 文書 (openapi.json) とクライアント (internal/apiclient/client_gen.go) は go generate で生成してコミットする。
+// 生成したものが古ければテストが失敗する。
+package openapi
+
+//go:generate go run ./gen
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+// Spec は生成済みの OpenAPI 文書。GET /openapi.json で返す。
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Build は Operations から OpenAPI 文書を作る。
+func Build() ([]byte, error) {
+	s := newSchemas()
+	paths := make(map[string]map[string]any)
+	add := func(method, path string, op map[string]any) {
+		p := pathTemplate(path)
+		if paths[p] == nil {
+			paths[p] = make(map[string]any)
+		}
+		paths[p][strings.ToLower(method)] = op
+	}
+
+	for _, op := range Operations {
+		add(op.Method, op.Path, operation(s, op, op.Path))
+		if op.Legacy == "" {
+			continue
+		}
+		method, path, _ := strings.Cut(op.Legacy, " ")
+		legacy := operation(s, op, path)
+		legacy["operationId"] = op.ID + "Legacy"
+		legacy["deprecated"] = true
+		legacy["description"] = fmt.Sprintf("旧ルート。%s %s を使ってください。", op.Method, pathTemplate(op.Path))
+		add(method, path, legacy)
+	}
+
+	s.components["Problem"] = problemSchema(s)
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "MathOvercome API",
+			"version":     "1.0.0",
+			"description": "エラーは RFC 7807 の problem+json で返し、code で種類を判別する。/v1 の付かないルートは移行のために残している旧ルート。",
+		},
+		"servers":  []any{map[string]any{"url": "/"}},
+		"security": []any{map[string]any{"bearer": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": s.components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Cognito の ID トークンまたは API キー (mok_...)",
+				},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// operation は path で受け付ける op の Operation Object を作る。
+// op のパスパラメータのうち path に無いもの (旧ルートの sessionId) はクエリで受け付ける。
+func operation(s *schemas, op Operation, path string) map[string]any {
+	o := map[string]any{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+	}
+	if op.Public {
+		o["security"] = []any{}
+	}
+	if op.Scope != "" {
+		o["x-scope"] = op.Scope
+	}
+	if op.Role != "" {
+		o["x-role"] = op.Role
+	}
+
+	var params []any
+	inPath := make(map[string]bool)
+	for _, name := range pathParamNames(path) {
+		inPath[name] = true
+		params = append(params, parameter(pathParams[name], "path", true))
+	}
+	for _, name := range pathParamNames(op.Path) {
+		if !inPath[name] {
+			params = append(params, parameter(pathParams[name], "query", true))
+		}
+	}
+	for _, p := range op.Query {
+		params = append(params, parameter(p, "query", false))
+	}
+	if len(params) > 0 {
+		o["parameters"] = params
+	}
+
+	if op.Body != nil {
+		o["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": s.of(op.Body)}},
+		}
+	}
+
+	responses := map[string]any{
+		"default": map[string]any{
+			"description": "エラー",
+			"content":     map[string]any{"application/problem+json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Problem"}}},
+		},
+	}
+	if op.Response == nil {
+		responses["204"] = map[string]any{"description": http.StatusText(http.StatusNoContent)}
+	} else {
+		content := map[string]any{"application/json": map[string]any{"schema": s.of(op.Response)}}
+		for _, ct := range op.Formats {
+			content[ct] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		status := op.Status
+		if len(status) == 0 {
+			status = []int{http.StatusOK}
+		}
+		for _, code := range status {
+			responses[fmt.Sprint(code)] = map[string]any{"description": http.StatusText(code), "content": content}
+		}
+	}
+	o["responses"] = responses
+	return o
+}
+
+func parameter(p Param, in string, required bool) map[string]any {
+	if p.Name == "" {
+		panic("openapi: unknown path parameter")
+	}
+	param := map[string]any{
+		"name":   p.Name,
+		"in":     in,
+		"schema": p.Schema,
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	if required {
+		param["required"] = true
+	}
+	return param
+}
+
+// pathTemplate は gin のパス (/sessions/:sessionId) を OpenAPI の形式 (/sessions/{sessionId}) にする。
+func pathTemplate(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			segs[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// pathParamNames はパスパラメータの名前を出現順に返す。
+func pathParamNames(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// problemSchema は middleware.Errors が返す problem+json。details の各項目は拡張メンバーとして入る。
+func problemSchema(s *schemas) map[string]any {
+	current := s.of(dto.AnswerState{})
+	current["description"] = "version_conflict の場合の現在の回答"
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"type":     map[string]any{"type": "string"},
+			"title":    map[string]any{"type": "string"},
+			"status":   map[string]any{"type": "integer"},
+			"detail":   map[string]any{"type": "string"},
+			"instance": map[string]any{"type": "string"},
+			"code":     map[string]any{"type": "string", "description": "エラーの種類 (apperr.Code)"},
+			"param":    map[string]any{"type": "string", "description": "invalid_parameter の場合の不正だったパラメータ"},
+			"scope":    map[string]any{"type": "string", "description": "scope_required の場合の足りない権限"},
+			"role":     map[string]any{"type": "string", "description": "role_required の場合の足りない役割"},
+			"current":  current,
+		},
+		"required": []string{"type", "title", "status", "detail", "instance", "code"},
+	}
+}
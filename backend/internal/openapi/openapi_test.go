@@ -0,0 +1,95 @@
+package openapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/openapi"
+)
+
+// 生成した文書とクライアントが Operations・dto と一致していること
+func TestSpec_UpToDate(t *testing.T) {
+	spec, err := openapi.Build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !bytes.Equal(spec, openapi.Spec) {
+		t.Errorf("openapi.json is out of date; run go generate ./internal/openapi")
+	}
+
+	client, err := openapi.GenerateClient(spec)
+	if err != nil {
+		t.Fatalf("generate client: %v", err)
+	}
+	current, err := os.ReadFile("../apiclient/client_gen.go")
+	if err != nil {
+		t.Fatalf("read client: %v", err)
+	}
+	if !bytes.Equal(client, current) {
+		t.Errorf("apiclient/client_gen.go is out of date; run go generate ./internal/openapi")
+	}
+}
+
+func TestBuild_OperationIDsUnique(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	seen := make(map[string]string)
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			if op.OperationID == "" {
+				t.Errorf("%s %s: missing operationId", method, path)
+			}
+			if prev, ok := seen[op.OperationID]; ok {
+				t.Errorf("operationId %s is used by %s and %s %s", op.OperationID, prev, method, path)
+			}
+			seen[op.OperationID] = method + " " + path
+		}
+	}
+}
+
+// 埋め込んだ構造体は展開し、omitempty の無いフィールドだけを required にする
+func TestBuild_Schemas(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+				GoType     string                     `json:"x-go-type"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	state := doc.Components.Schemas["SessionState"]
+	if state.GoType != "dto.SessionState" {
+		t.Errorf("unexpected x-go-type %q", state.GoType)
+	}
+	for _, name := range []string{"sessionId", "answered", "nextIdx", "problems", "deadline"} {
+		if _, ok := state.Properties[name]; !ok {
+			t.Errorf("SessionState: missing property %s", name)
+		}
+	}
+	required := make(map[string]bool)
+	for _, name := range state.Required {
+		required[name] = true
+	}
+	if !required["sessionId"] || !required["nextIdx"] || required["deadline"] || required["finishedAt"] {
+		t.Errorf("SessionState: unexpected required %v", state.Required)
+	}
+	var nextIdx struct {
+		Type []string `json:"type"`
+	}
+	if err := json.Unmarshal(state.Properties["nextIdx"], &nextIdx); err != nil || len(nextIdx.Type) != 2 || nextIdx.Type[1] != "null" {
+		t.Errorf("SessionState.nextIdx: expected nullable integer, got %s", state.Properties["nextIdx"])
+	}
+}
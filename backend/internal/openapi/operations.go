@@ -0,0 +1,374 @@
+package openapi
+
+import (
+	"encoding/json"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+)
+
+// Operation は API の1つの操作。パスは gin の形式 (/v1/sessions/:sessionId) で書く。
+// パスパラメータの型と説明は pathParams から決まる。
+type Operation struct {
+	Method  string
+	Path    string
+	ID      string // operationId。生成するクライアントのメソッド名になる
+	Summary string // "〜する" の形で書く
+	Tag     string
+	Scope   string // 必要な API キーの権限 (空なら不要)
+	Role    string // 必要な役割 (空なら生徒を含む全員)
+	Public  bool   // ログイン不要
+	Query   []Param
+	Body    any   // リクエストの JSON (dto の値)
+	Status  []int // 成功時のステータス。省略時は 200 (Response が nil なら 204)
+	// Response は成功時の JSON (dto の値)
+	Response any
+	// Formats は format クエリで選べる JSON 以外の Content-Type
+	Formats []string
+	// Legacy は同じハンドラーで受け付ける旧ルート ("POST /session/test")。deprecated として文書に載せる
+	Legacy string
+}
+
+// Param はクエリパラメータ。
+type Param struct {
+	Name        string
+	Description string
+	Schema      map[string]any
+}
+
+func intQuery(name, desc string) Param {
+	return Param{name, desc, map[string]any{"type": "integer"}}
+}
+
+func boolQuery(name, desc string) Param {
+	return Param{name, desc, map[string]any{"type": "boolean"}}
+}
+
+func stringQuery(name, desc string) Param {
+	return Param{name, desc, map[string]any{"type": "string"}}
+}
+
+func enumQuery(name, desc string, values ...string) Param {
+	return Param{name, desc, map[string]any{"type": "string", "enum": values, "default": values[0]}}
+}
+
+// pathParams はパスパラメータの型と説明。ID はハンドラーが数値として検証する。
+var pathParams = map[string]Param{
+	"sessionId":    {"sessionId", "セッション ID", map[string]any{"type": "integer", "format": "uint64", "minimum": 0}},
+	"idx":          {"idx", "問題の番号 (0始まり)", map[string]any{"type": "integer", "minimum": 0}},
+	"classId":      {"classId", "クラス ID", map[string]any{"type": "integer", "format": "uint64", "minimum": 0}},
+	"assignmentId": {"assignmentId", "課題 ID", map[string]any{"type": "integer", "format": "uint64", "minimum": 0}},
+	"studentSub":   {"studentSub", "生徒の Cognito sub", map[string]any{"type": "string"}},
+	"userSub":      {"userSub", "利用者の Cognito sub", map[string]any{"type": "string"}},
+	"keyId":        {"keyId", "API キーの ID", map[string]any{"type": "string"}},
+	"role":         {"role", "役割", map[string]any{"type": "string", "enum": []string{identity.RoleTeacher, identity.RoleAdmin}}},
+}
+
+// マイページの取得条件 (本人と教師の閲覧で共通)
+var mypageQuery = []Param{
+	intQuery("limit", "1ページのセッション数"),
+	stringQuery("cursor", "前のページの nextCursor"),
+	stringQuery("from", "この日以降のセッション (YYYY-MM-DD)"),
+	stringQuery("to", "この日以前のセッション (YYYY-MM-DD)"),
+	boolQuery("includeDetails", "分野別の成績と時間配分を含める (省略時は true)"),
+}
+
+// Operations はすべての操作。ルートを追加したらここにも追加する (router のテストが差分を検出する)。
+var Operations = []Operation{
+	// セッション
+	{
+		Method: "POST", Path: "/v1/sessions", ID: "createSession", Tag: "sessions",
+		Summary: "セッションを作成する",
+		Scope:   identity.ScopeTakeTests,
+		Query: []Param{
+			boolQuery("includeIntegers", "整数の問題を含める (省略時はプロフィールの既定値)"),
+			boolQuery("examMode", "試験モードにする (省略時はプロフィールの既定値)"),
+		},
+		Status: []int{201}, Response: dto.CreatedSession{},
+		Legacy: "POST /session/test",
+	},
+	{
+		Method: "GET", Path: "/v1/sessions", ID: "listSessions", Tag: "sessions",
+		Summary: "自分のセッションを新しい順に一覧する",
+		Scope:   identity.ScopeReadResults,
+		Query: []Param{
+			enumQuery("status", "状態で絞り込む", "in_progress", "finished", "expired"),
+			intQuery("limit", "1ページの件数"),
+			stringQuery("cursor", "前のページの nextCursor"),
+		},
+		Response: dto.SessionList{},
+	},
+	{
+		Method: "GET", Path: "/v1/sessions/:sessionId", ID: "getSession", Tag: "sessions",
+		Summary:  "セッションを再開するための状態を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.SessionState{},
+	},
+	{
+		Method: "GET", Path: "/v1/sessions/:sessionId/problems/:idx", ID: "getProblem", Tag: "sessions",
+		Summary:  "問題を取得する",
+		Scope:    identity.ScopeTakeTests,
+		Response: dto.SessionProblem{},
+		Legacy:   "GET /session/current/problems/:idx",
+	},
+	{
+		Method: "POST", Path: "/v1/sessions/:sessionId/problems/:idx/hint", ID: "revealHint", Tag: "sessions",
+		Summary:  "ヒントを表示する (試験モードでは 403)",
+		Scope:    identity.ScopeTakeTests,
+		Response: dto.Hint{},
+		Legacy:   "POST /session/current/problems/:idx/hint",
+	},
+	{
+		Method: "PUT", Path: "/v1/sessions/:sessionId/problems/:idx/answer", ID: "submitAnswer", Tag: "sessions",
+		Summary: "回答を送信する (version が古ければ 409)",
+		Scope:   identity.ScopeTakeTests,
+		Body:    dto.AnswerRequest{},
+		Legacy:  "POST /session/current/problems/:idx/answer",
+	},
+	{
+		Method: "POST", Path: "/v1/sessions/:sessionId/finish", ID: "finishSession", Tag: "sessions",
+		Summary: "セッションを終了する",
+		Scope:   identity.ScopeTakeTests,
+		Legacy:  "POST /session/current/finish",
+	},
+	{
+		Method: "GET", Path: "/v1/sessions/:sessionId/history", ID: "getAnswerHistory", Tag: "sessions",
+		Summary:  "回答の履歴を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.AnswerHistory{},
+		Legacy:   "GET /session/current/history",
+	},
+
+	// 自分の成績
+	{
+		Method: "GET", Path: "/v1/me/mypage", ID: "getMypage", Tag: "mypage",
+		Summary:  "セッションごとの成績を取得する",
+		Scope:    identity.ScopeReadResults,
+		Query:    mypageQuery,
+		Response: dto.User{},
+		Legacy:   "GET /session/mypage",
+	},
+	{
+		Method: "GET", Path: "/v1/me/mypage/summary", ID: "getMypageSummary", Tag: "mypage",
+		Summary:  "成績の概要を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.MypageSummary{},
+		Legacy:   "GET /session/mypage/summary",
+	},
+	{
+		Method: "GET", Path: "/v1/me/mypage/trends", ID: "getMypageTrends", Tag: "mypage",
+		Summary: "分野別の正答率の推移を取得する",
+		Scope:   identity.ScopeReadResults,
+		Query: []Param{
+			intQuery("sessions", "対象にする直近のセッション数"),
+			intQuery("window", "移動平均のセッション数"),
+		},
+		Response: dto.Trends{},
+		Legacy:   "GET /session/mypage/trends",
+	},
+	{
+		Method: "GET", Path: "/v1/me/mypage/categories", ID: "getMypageCategories", Tag: "mypage",
+		Summary:  "分野別の累計の成績を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.CategoryStats{},
+		Legacy:   "GET /session/mypage/categories",
+	},
+	{
+		Method: "GET", Path: "/v1/me/export", ID: "export", Tag: "mypage",
+		Summary: "成績を書き出す",
+		Scope:   identity.ScopeReadResults,
+		Query: []Param{
+			enumQuery("format", "書き出す形式", "json", "csv", "html"),
+			{"sessionId", "このセッションだけを書き出す (省略時は全セッション)", map[string]any{"type": "integer", "format": "uint64", "minimum": 0}},
+		},
+		Response: dto.Export{},
+		Formats:  []string{"text/csv", "text/html"},
+		Legacy:   "GET /session/export",
+	},
+
+	// クラス
+	{
+		Method: "POST", Path: "/v1/classes", ID: "createClassroom", Tag: "classes",
+		Summary: "クラスを作成する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleTeacher,
+		Body:   dto.CreateClassroomRequest{},
+		Status: []int{201}, Response: dto.Classroom{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes", ID: "listClassrooms", Tag: "classes",
+		Summary:  "所属するクラスを一覧する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.ClassroomList{},
+	},
+	{
+		Method: "POST", Path: "/v1/classes/join", ID: "joinClassroom", Tag: "classes",
+		Summary: "参加コードでクラスに参加する",
+		Scope:   identity.ScopeTakeTests,
+		Body:    dto.JoinClassroomRequest{},
+		Status:  []int{201}, Response: dto.Classroom{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId", ID: "getClassroom", Tag: "classes",
+		Summary:  "クラスと名簿を取得する (クラスの教師のみ)",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.ClassroomDetail{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/analytics", ID: "getClassAnalytics", Tag: "classes",
+		Summary:  "クラスの成績の集計を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.ClassAnalytics{},
+	},
+	{
+		Method: "DELETE", Path: "/v1/classes/:classId/students/:studentSub", ID: "removeStudent", Tag: "classes",
+		Summary: "生徒をクラスから外す",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleTeacher,
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/students/:studentSub/mypage", ID: "getStudentMypage", Tag: "classes",
+		Summary:  "生徒のセッションごとの成績を取得する (クラスの教師のみ)",
+		Scope:    identity.ScopeReadResults,
+		Query:    mypageQuery,
+		Response: dto.User{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/students/:studentSub/summary", ID: "getStudentSummary", Tag: "classes",
+		Summary:  "生徒の成績の概要を取得する (クラスの教師のみ)",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.MypageSummary{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/students/:studentSub/categories", ID: "getStudentCategories", Tag: "classes",
+		Summary:  "生徒の分野別の累計の成績を取得する (クラスの教師のみ)",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.CategoryStats{},
+	},
+
+	// 課題
+	{
+		Method: "POST", Path: "/v1/classes/:classId/assignments", ID: "createAssignment", Tag: "assignments",
+		Summary: "課題を作成する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleTeacher,
+		Body:   dto.CreateAssignmentRequest{},
+		Status: []int{201}, Response: dto.Assignment{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/assignments", ID: "listAssignments", Tag: "assignments",
+		Summary:  "クラスの課題を一覧する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.AssignmentList{},
+	},
+	{
+		Method: "GET", Path: "/v1/classes/:classId/assignments/:assignmentId/status", ID: "getAssignmentStatus", Tag: "assignments",
+		Summary:  "課題の提出状況を取得する (クラスの教師のみ)",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.AssignmentStatus{},
+	},
+	{
+		Method: "POST", Path: "/v1/classes/:classId/assignments/:assignmentId/attempts", ID: "startAttempt", Tag: "assignments",
+		Summary: "課題を受験する (続きから受験できれば 200、新しく作成すれば 201)",
+		Scope:   identity.ScopeTakeTests,
+		Status:  []int{200, 201}, Response: dto.AssignmentAttempt{},
+	},
+
+	// プロフィール・アカウント
+	{
+		Method: "GET", Path: "/v1/me", ID: "getProfile", Tag: "me",
+		Summary:  "プロフィールを取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.Profile{},
+	},
+	{
+		Method: "PUT", Path: "/v1/me", ID: "updateProfile", Tag: "me",
+		Summary:  "プロフィールを更新する",
+		Scope:    identity.ScopeTakeTests,
+		Body:     dto.UpdateProfileRequest{},
+		Response: dto.Profile{},
+	},
+	{
+		Method: "GET", Path: "/v1/me/roles", ID: "getMyRoles", Tag: "me",
+		Summary:  "自分の役割を取得する",
+		Response: dto.UserRoles{},
+	},
+	{
+		Method: "GET", Path: "/v1/me/archive", ID: "getMyArchive", Tag: "me",
+		Summary:  "自分のデータ一式を取得する",
+		Scope:    identity.ScopeReadResults,
+		Response: dto.AccountArchive{},
+	},
+
+	// API キー (ログインした利用者のみ。API キーでの呼び出しは 403)
+	{
+		Method: "POST", Path: "/v1/api-keys", ID: "createAPIKey", Tag: "api-keys",
+		Summary: "API キーを発行する",
+		Body:    dto.CreateAPIKeyRequest{},
+		Status:  []int{201}, Response: dto.CreatedAPIKey{},
+	},
+	{
+		Method: "GET", Path: "/v1/api-keys", ID: "listAPIKeys", Tag: "api-keys",
+		Summary:  "API キーを一覧する",
+		Response: []dto.APIKey{},
+	},
+	{
+		Method: "DELETE", Path: "/v1/api-keys/:keyId", ID: "revokeAPIKey", Tag: "api-keys",
+		Summary: "API キーを失効させる",
+	},
+
+	// 管理者
+	{
+		Method: "GET", Path: "/v1/admin/item-analysis", ID: "getItemAnalysis", Tag: "admin",
+		Summary: "保存済みの問題ごとの分析を取得する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Query: []Param{
+			enumQuery("format", "書き出す形式", "json", "csv"),
+		},
+		Response: dto.ItemAnalysis{},
+		Formats:  []string{"text/csv"},
+	},
+	{
+		Method: "GET", Path: "/v1/admin/users/:userSub/roles", ID: "getUserRoles", Tag: "admin",
+		Summary: "利用者の役割を取得する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Response: dto.UserRoles{},
+	},
+	{
+		Method: "PUT", Path: "/v1/admin/users/:userSub/roles/:role", ID: "grantRole", Tag: "admin",
+		Summary: "利用者に役割を付与する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Response: dto.UserRoles{},
+	},
+	{
+		Method: "DELETE", Path: "/v1/admin/users/:userSub/roles/:role", ID: "revokeRole", Tag: "admin",
+		Summary: "利用者の役割を剥奪する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Response: dto.UserRoles{},
+	},
+	{
+		Method: "GET", Path: "/v1/admin/users/:userSub/archive", ID: "getUserArchive", Tag: "admin",
+		Summary: "利用者のデータ一式を取得する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Response: dto.AccountArchive{},
+	},
+	{
+		Method: "POST", Path: "/v1/admin/users/:userSub/deletion", ID: "deleteUserData", Tag: "admin",
+		Summary: "利用者のデータ削除を1回分進める (status が completed になるまで繰り返し呼ぶ)",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Query: []Param{
+			intQuery("batchSize", "1回で削除するアイテム数の目安"),
+		},
+		Response: dto.AccountDeletion{},
+	},
+	{
+		Method: "GET", Path: "/v1/admin/users/:userSub/deletion", ID: "getUserDeletion", Tag: "admin",
+		Summary: "利用者のデータ削除の進捗を取得する",
+		Scope:   identity.ScopeAdmin, Role: identity.RoleAdmin,
+		Response: dto.AccountDeletion{},
+	},
+
+	{
+		Method: "GET", Path: "/openapi.json", ID: "getOpenAPI", Tag: "meta",
+		Summary:  "この API の OpenAPI 文書を取得する",
+		Public:   true,
+		Response: json.RawMessage{},
+	},
+}
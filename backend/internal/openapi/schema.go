@@ -0,0 +1,119 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// schemas は dto の型から JSON Schema を作り、構造体を components/schemas に登録する。
+// 型の名前をそのままコンポーネント名にし、x-go-type に Go の型 (dto.X) を入れる (クライアントの生成に使う)。
+type schemas struct {
+	components map[string]any
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: make(map[string]any)}
+}
+
+// of は v の型のスキーマを返す。v が nil ならスキーマは無い。
+func (s *schemas) of(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) map[string]any {
+	if t == rawMessageType {
+		return map[string]any{"type": "object"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.schema(t.Elem()))
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.ref(t)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "uint64", "minimum": 0}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// ref は構造体をコンポーネントに登録し、参照を返す。
+func (s *schemas) ref(t reflect.Type) map[string]any {
+	name := t.Name()
+	if _, ok := s.components[name]; !ok {
+		// 自己参照で無限に再帰しないよう先に登録する
+		s.components[name] = nil
+		props := make(map[string]any)
+		var required []string
+		s.fields(t, props, &required)
+		obj := map[string]any{
+			"type":       "object",
+			"properties": props,
+			"x-go-type":  t.String(),
+		}
+		if len(required) > 0 {
+			obj["required"] = required
+		}
+		s.components[name] = obj
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// fields は encoding/json と同じ規則で t のフィールドを props に加える。
+// omitempty の無いフィールドは常に出力されるので required にする。埋め込んだ構造体のフィールドは展開する。
+func (s *schemas) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			s.fields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable は null も取る schema を返す。
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok {
+		cp := make(map[string]any, len(schema))
+		for k, v := range schema {
+			cp[k] = v
+		}
+		cp["type"] = []string{t, "null"}
+		return cp
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
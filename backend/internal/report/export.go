@@ -0,0 +1,70 @@
+package report
+
+import (
+	"embed"
+	"encoding/csv"
+	"html/template"
+	"io"
+	"strconv"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+//go:embed templates/export.html
+var templateFS embed.FS
+
+var exportTemplate = template.Must(template.New("export.html").Funcs(template.FuncMap{
+	"percent": func(correct, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(correct) / float64(total) * 100
+	},
+	"inc": func(i int) int { return i + 1 },
+}).ParseFS(templateFS, "templates/export.html"))
+
+var exportHeader = []string{
+	"session_id", "start_time", "idx", "category_name", "question",
+	"selected_choice_id", "selected_choice_text", "correct_choice_id", "correct_choice_text",
+	"is_correct", "with_hint", "time_spent_sec",
+}
+
+// WriteExportCSV はエクスポートを1問1行の CSV で書き出す。未回答の問題は選択肢の列が空欄。
+func WriteExportCSV(w io.Writer, e *dto.Export) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, s := range e.Sessions {
+		for _, p := range s.Problems {
+			selected := ""
+			if p.SelectedChoiceID != nil {
+				selected = strconv.FormatInt(*p.SelectedChoiceID, 10)
+			}
+			row := []string{
+				strconv.FormatInt(s.SessionID, 10),
+				s.StartTime,
+				strconv.Itoa(p.Idx),
+				p.CategoryName,
+				p.Question,
+				selected,
+				p.SelectedChoiceText,
+				strconv.FormatInt(p.CorrectChoiceID, 10),
+				p.CorrectChoiceText,
+				strconv.FormatBool(p.IsCorrect),
+				strconv.FormatBool(p.WithHint),
+				formatOptFloat(p.TimeSpentSec),
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteExportHTML は印刷用の HTML レポートを書き出す。カテゴリ別の正答率を棒グラフで表示する。
+func WriteExportHTML(w io.Writer, e *dto.Export) error {
+	return exportTemplate.Execute(w, e)
+}
@@ -0,0 +1,65 @@
+// Package report は分析結果やエクスポートをファイル形式 (CSV など) に書き出す。
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+)
+
+var itemAnalysisHeader = []string{
+	"problem_id", "category_name", "question", "attempts", "correct_count", "p_value",
+	"discrimination", "timed_count", "avg_time_sec", "flags",
+	"choice_id", "choice_text", "is_correct", "choice_count", "choice_rate",
+}
+
+// WriteItemAnalysisCSV は問題分析を1選択肢1行の CSV で書き出す。
+// 問題単位の列は選択肢の行ごとに繰り返す。識別力・平均時間が求まらない場合は空欄。
+func WriteItemAnalysisCSV(w io.Writer, a *dto.ItemAnalysis) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(itemAnalysisHeader); err != nil {
+		return err
+	}
+	for _, it := range a.Items {
+		base := []string{
+			strconv.FormatInt(it.ProblemID, 10),
+			it.CategoryName,
+			it.Question,
+			strconv.Itoa(it.Attempts),
+			strconv.Itoa(it.CorrectCount),
+			formatFloat(it.PValue),
+			formatOptFloat(it.Discrimination),
+			strconv.Itoa(it.TimedCount),
+			formatOptFloat(it.AvgTimeSec),
+			strings.Join(it.Flags, ";"),
+		}
+		for _, c := range it.Choices {
+			row := append(append([]string{}, base...),
+				strconv.FormatInt(c.ChoiceID, 10),
+				c.ChoiceText,
+				strconv.FormatBool(c.IsCorrect),
+				strconv.Itoa(c.Count),
+				formatFloat(c.Rate),
+			)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func formatOptFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
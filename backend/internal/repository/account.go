@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// BatchWriteItem 1回あたりの最大アイテム数
+const maxBatchWriteItems = 25
+
+// ItemKey はテーブルのアイテムのキー。利用者のデータ削除で、種類の違うアイテムをまとめて消すのに使う。
+type ItemKey struct {
+	PK string
+	SK string
+}
+
+func (k ItemKey) attributeValues() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: k.PK},
+		"sk": &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+func itemKeyOf(key map[string]types.AttributeValue) ItemKey {
+	var k ItemKey
+	if v, ok := key["pk"].(*types.AttributeValueMemberS); ok {
+		k.PK = v.Value
+	}
+	if v, ok := key["sk"].(*types.AttributeValueMemberS); ok {
+		k.SK = v.Value
+	}
+	return k
+}
+
+// APIKeyItemKey などは各アイテムのキーを返す。
+func APIKeyItemKey(id string) ItemKey { return itemKeyOf(apiKeyKey(id)) }
+
+func ClassMemberItemKey(classID uint64, userSub string) ItemKey {
+	return itemKeyOf(classMemberKey(classID, userSub))
+}
+
+func AttemptItemKey(assignmentID uint64, userSub string, attempt int) ItemKey {
+	return itemKeyOf(attemptKey(assignmentID, userSub, attempt))
+}
+
+// dynamoAccountDeletion はデータ削除の進捗 (pk=DELETION#<sub>, sk=#METADATA)。
+// 削除対象の USER#<sub> とは別のパーティションに置き、削除後も記録として残す。
+type dynamoAccountDeletion struct {
+	PK          string         `dynamodbav:"pk"`
+	SK          string         `dynamodbav:"sk"`
+	UserSub     string         `dynamodbav:"user_sub"`
+	RequestedBy string         `dynamodbav:"requested_by"`
+	Status      string         `dynamodbav:"status"`
+	Phase       string         `dynamodbav:"phase,omitempty"`
+	Deleted     map[string]int `dynamodbav:"deleted"`
+	Anonymized  int            `dynamodbav:"anonymized"`
+	StartedAt   string         `dynamodbav:"started_at"`             // stampLayout
+	UpdatedAt   string         `dynamodbav:"updated_at"`             // stampLayout
+	CompletedAt string         `dynamodbav:"completed_at,omitempty"` // stampLayout
+}
+
+func accountDeletionKey(userSub string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("DELETION#%s", userSub)},
+		"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+	}
+}
+
+// FindUserSessions は利用者のセッションを作成途中のものも含めてすべて返す (新しい順)。
+func (r *Repository) FindUserSessions(userSub string) ([]model.TestSession, error) {
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("gsi1pk = :gsi1pk"),
+		FilterExpression:       aws.String("sk = :meta AND begins_with(pk, :session)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":gsi1pk":  &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
+			":meta":    &types.AttributeValueMemberS{Value: "#METADATA"},
+			":session": &types.AttributeValueMemberS{Value: "SESSION#"},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	var sessions []model.TestSession
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var ds dynamoSession
+			if err := attributevalue.UnmarshalMap(item, &ds); err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, toModelSession(ds))
+		}
+	}
+	return sessions, nil
+}
+
+// PurgeSession は pk=SESSION#<id> 配下の全アイテムを削除し、削除した件数を返す。
+// 途中で失敗してもセッションを GSI1 から引き直して再開できるよう、#METADATA は最後に消す。
+func (r *Repository) PurgeSession(sessionID uint64) (int, error) {
+	keys, err := r.queryItemKeys(fmt.Sprintf("SESSION#%d", sessionID))
+	if err != nil {
+		return 0, err
+	}
+	var children, meta []ItemKey
+	for _, k := range keys {
+		if k.SK == "#METADATA" {
+			meta = append(meta, k)
+		} else {
+			children = append(children, k)
+		}
+	}
+	if err := r.DeleteItems(children); err != nil {
+		return 0, err
+	}
+	if err := r.DeleteItems(meta); err != nil {
+		return len(children), err
+	}
+	return len(keys), nil
+}
+
+// PurgeClassMember は役割を問わずクラスへの所属を削除する。
+// クラス分析の計算結果には生徒ごとの成績が入るため、同じトランザクションで版を進めて計算結果を消す。
+func (r *Repository) PurgeClassMember(classID uint64, userSub string) error {
+	_, err := r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{
+				TableName: aws.String(tableName()),
+				Key:       classMemberKey(classID, userSub),
+			}},
+			classAnalyticsReset(classID),
+		},
+	})
+	return err
+}
+
+// FindUserItemKeys は pk=USER#<sub> 配下 (プロフィール・役割・分野別累計) の全アイテムのキーを返す。
+func (r *Repository) FindUserItemKeys(userSub string) ([]ItemKey, error) {
+	return r.queryItemKeys(fmt.Sprintf("USER#%s", userSub))
+}
+
+func (r *Repository) queryItemKeys(pk string) ([]ItemKey, error) {
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: pk},
+		},
+		ProjectionExpression: aws.String("pk, sk"),
+	})
+	var keys []ItemKey
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			keys = append(keys, itemKeyOf(item))
+		}
+	}
+	return keys, nil
+}
+
+// DeleteItems は keys のアイテムを BatchWriteItem で順に削除する。既に無いアイテムはエラーにしない。
+func (r *Repository) DeleteItems(keys []ItemKey) error {
+	for i := 0; i < len(keys); i += maxBatchWriteItems {
+		end := min(i+maxBatchWriteItems, len(keys))
+		requests := make([]types.WriteRequest, 0, end-i)
+		for _, k := range keys[i:end] {
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: k.attributeValues()}})
+		}
+		if err := r.batchWrite("delete items", requests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchWrite は最大 maxBatchWriteItems 件の requests を BatchWriteItem で書き込む。
+// 未処理のアイテムは間隔を倍にしながら maxBatchAttempts 回まで再送する。
+func (r *Repository) batchWrite(op string, requests []types.WriteRequest) error {
+	pending := map[string][]types.WriteRequest{tableName(): requests}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == maxBatchAttempts {
+			return fmt.Errorf("%s: unprocessed items remain after %d attempts", op, maxBatchAttempts)
+		}
+		if attempt > 0 {
+			time.Sleep(batchRetryBase << (attempt - 1))
+		}
+		out, err := r.client.BatchWriteItem(bg(), &dynamodb.BatchWriteItemInput{RequestItems: pending})
+		if err != nil {
+			return err
+		}
+		pending = out.UnprocessedItems
+	}
+	return nil
+}
+
+// AnonymizeClassroomOwner はクラスの作成者が userSub なら model.DeletedUserSub に置き換える。
+// 置き換えた場合は true を返す。
+func (r *Repository) AnonymizeClassroomOwner(classID uint64, userSub string) (bool, error) {
+	return r.anonymize(classKey(classID), "owner_sub", userSub)
+}
+
+// AnonymizeAssignmentCreator は課題の作成者が userSub なら model.DeletedUserSub に置き換える。
+func (r *Repository) AnonymizeAssignmentCreator(classID, assignmentID uint64, userSub string) (bool, error) {
+	return r.anonymize(map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+		"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ASSIGN#%d", assignmentID)},
+	}, "created_by", userSub)
+}
+
+func (r *Repository) anonymize(key map[string]types.AttributeValue, attr, userSub string) (bool, error) {
+	_, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName()),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #attr = :deleted"),
+		ConditionExpression: aws.String("#attr = :sub"),
+		ExpressionAttributeNames: map[string]string{
+			"#attr": attr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deleted": &types.AttributeValueMemberS{Value: model.DeletedUserSub},
+			":sub":     &types.AttributeValueMemberS{Value: userSub},
+		},
+	})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// FindAccountDeletion はデータ削除の進捗を返す。削除を始めていなければ apperr.ErrNotFound。
+func (r *Repository) FindAccountDeletion(userSub string) (*model.AccountDeletion, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName()),
+		Key:            accountDeletionKey(userSub),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var d dynamoAccountDeletion
+	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+		return nil, err
+	}
+	del := &model.AccountDeletion{
+		UserSub:     d.UserSub,
+		RequestedBy: d.RequestedBy,
+		Status:      d.Status,
+		Phase:       d.Phase,
+		Deleted:     d.Deleted,
+		Anonymized:  d.Anonymized,
+		CompletedAt: parseStamp(d.CompletedAt),
+	}
+	if del.Deleted == nil {
+		del.Deleted = make(map[string]int)
+	}
+	if t := parseStamp(d.StartedAt); t != nil {
+		del.StartedAt = *t
+	}
+	if t := parseStamp(d.UpdatedAt); t != nil {
+		del.UpdatedAt = *t
+	}
+	return del, nil
+}
+
+// SaveAccountDeletion はデータ削除の進捗を上書き保存する。
+func (r *Repository) SaveAccountDeletion(d *model.AccountDeletion) error {
+	key := itemKeyOf(accountDeletionKey(d.UserSub))
+	item, err := attributevalue.MarshalMap(dynamoAccountDeletion{
+		PK:          key.PK,
+		SK:          key.SK,
+		UserSub:     d.UserSub,
+		RequestedBy: d.RequestedBy,
+		Status:      d.Status,
+		Phase:       d.Phase,
+		Deleted:     d.Deleted,
+		Anonymized:  d.Anonymized,
+		StartedAt:   formatStamp(d.StartedAt),
+		UpdatedAt:   formatStamp(d.UpdatedAt),
+		CompletedAt: optStamp(d.CompletedAt),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+		TableName: aws.String(tableName()),
+		Item:      item,
+	})
+	return err
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoAnswerEvent は回答1回分の追記専用アイテム。
+// pk=SESSION#<session_id>, sk=EVENT#<id>。ID は採番順なので SK 昇順が時系列順になる。
+type dynamoAnswerEvent struct {
+	PK               string `dynamodbav:"pk"`
+	SK               string `dynamodbav:"sk"`
+	ID               uint64 `dynamodbav:"id"`
+	SessionID        uint64 `dynamodbav:"session_id"`
+	SessionProblemID uint64 `dynamodbav:"sp_id"`
+	ProblemID        uint64 `dynamodbav:"problem_id"`
+	CategoryName     string `dynamodbav:"category_name"`
+	ChoiceID         uint64 `dynamodbav:"choice_id"`
+	IsCorrect        bool   `dynamodbav:"is_correct"`
+	WithHint         bool   `dynamodbav:"with_hint,omitempty"`
+	AnsweredAt       string `dynamodbav:"answered_at"` // stampLayout
+}
+
+func newDynamoAnswerEvent(e model.AnswerEvent) dynamoAnswerEvent {
+	return dynamoAnswerEvent{
+		PK:               fmt.Sprintf("SESSION#%d", e.SessionID),
+		SK:               fmt.Sprintf("EVENT#%d", e.ID),
+		ID:               e.ID,
+		SessionID:        e.SessionID,
+		SessionProblemID: e.SessionProblemID,
+		ProblemID:        e.ProblemID,
+		CategoryName:     e.CategoryName,
+		ChoiceID:         e.ChoiceID,
+		IsCorrect:        e.IsCorrect,
+		WithHint:         e.WithHint,
+		AnsweredAt:       formatStamp(e.AnsweredAt),
+	}
+}
+
+// FindAnswerEvents はセッション内の回答イベントを時系列順に返す。
+func (r *Repository) FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error) {
+	out, err := r.client.Query(bg(), &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sessionID)},
+			":prefix": &types.AttributeValueMemberS{Value: "EVENT#"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]model.AnswerEvent, 0, len(out.Items))
+	for _, item := range out.Items {
+		var de dynamoAnswerEvent
+		if err := attributevalue.UnmarshalMap(item, &de); err != nil {
+			return nil, err
+		}
+		var answeredAt time.Time
+		if t := parseStamp(de.AnsweredAt); t != nil {
+			answeredAt = *t
+		}
+		events = append(events, model.AnswerEvent{
+			ID:               de.ID,
+			SessionID:        de.SessionID,
+			SessionProblemID: de.SessionProblemID,
+			ProblemID:        de.ProblemID,
+			CategoryName:     de.CategoryName,
+			ChoiceID:         de.ChoiceID,
+			IsCorrect:        de.IsCorrect,
+			WithHint:         de.WithHint,
+			AnsweredAt:       answeredAt,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoAPIKey は API キー (pk=APIKEY#<id>, sk=#METADATA)。
+// GSI1 (gsi1pk=APIKEYOWNER#<sub>) で利用者のキーを作成順に引く (プレフィックスの理由は tableName を参照)。
+type dynamoAPIKey struct {
+	PK         string   `dynamodbav:"pk"`
+	SK         string   `dynamodbav:"sk"`
+	GSI1PK     string   `dynamodbav:"gsi1pk"`
+	GSI1SK     string   `dynamodbav:"gsi1sk"`
+	ID         string   `dynamodbav:"id"`
+	UserSub    string   `dynamodbav:"user_sub"`
+	UserName   string   `dynamodbav:"user_name,omitempty"`
+	Name       string   `dynamodbav:"name"`
+	Hash       string   `dynamodbav:"key_hash"`
+	Scopes     []string `dynamodbav:"scopes,stringset"`
+	CreatedAt  string   `dynamodbav:"created_at"`             // stampLayout
+	LastUsedAt string   `dynamodbav:"last_used_at,omitempty"` // stampLayout
+	RevokedAt  string   `dynamodbav:"revoked_at,omitempty"`   // stampLayout
+}
+
+func apiKeyKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("APIKEY#%s", id)},
+		"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+	}
+}
+
+func toModelAPIKey(dk dynamoAPIKey) model.APIKey {
+	k := model.APIKey{
+		ID:         dk.ID,
+		UserSub:    dk.UserSub,
+		UserName:   dk.UserName,
+		Name:       dk.Name,
+		Hash:       dk.Hash,
+		Scopes:     dk.Scopes,
+		LastUsedAt: parseStamp(dk.LastUsedAt),
+		RevokedAt:  parseStamp(dk.RevokedAt),
+	}
+	if t := parseStamp(dk.CreatedAt); t != nil {
+		k.CreatedAt = *t
+	}
+	return k
+}
+
+// CreateAPIKey は API キーを保存する。ID が既存のキーと重複した場合は apperr.ErrConflict。
+func (r *Repository) CreateAPIKey(k *model.APIKey) error {
+	k.CreatedAt = time.Now()
+	item, err := attributevalue.MarshalMap(dynamoAPIKey{
+		PK:        fmt.Sprintf("APIKEY#%s", k.ID),
+		SK:        "#METADATA",
+		GSI1PK:    fmt.Sprintf("APIKEYOWNER#%s", k.UserSub),
+		GSI1SK:    formatStamp(k.CreatedAt),
+		ID:        k.ID,
+		UserSub:   k.UserSub,
+		UserName:  k.UserName,
+		Name:      k.Name,
+		Hash:      k.Hash,
+		Scopes:    k.Scopes,
+		CreatedAt: formatStamp(k.CreatedAt),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if isConditionFailed(err) {
+		return apperr.ErrConflict
+	}
+	return err
+}
+
+// FindAPIKey は API キーを返す。存在しなければ apperr.ErrNotFound。
+func (r *Repository) FindAPIKey(id string) (*model.APIKey, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       apiKeyKey(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var dk dynamoAPIKey
+	if err := attributevalue.UnmarshalMap(out.Item, &dk); err != nil {
+		return nil, err
+	}
+	k := toModelAPIKey(dk)
+	return &k, nil
+}
+
+// FindAPIKeys は利用者の API キー (失効したものを含む) を新しい順に返す。
+func (r *Repository) FindAPIKeys(userSub string) ([]model.APIKey, error) {
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("gsi1pk = :gsi1pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":gsi1pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("APIKEYOWNER#%s", userSub)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	var keys []model.APIKey
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var dk dynamoAPIKey
+			if err := attributevalue.UnmarshalMap(item, &dk); err != nil {
+				return nil, err
+			}
+			keys = append(keys, toModelAPIKey(dk))
+		}
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey は利用者のキーを失効させる。既に失効していれば失効した時刻を変えない。
+// キーが無いか他の利用者のものなら apperr.ErrNotFound。
+func (r *Repository) RevokeAPIKey(id, userSub string, at time.Time) error {
+	_, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName()),
+		Key:                 apiKeyKey(id),
+		UpdateExpression:    aws.String("SET revoked_at = if_not_exists(revoked_at, :at)"),
+		ConditionExpression: aws.String("attribute_exists(pk) AND user_sub = :sub"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at":  &types.AttributeValueMemberS{Value: formatStamp(at)},
+			":sub": &types.AttributeValueMemberS{Value: userSub},
+		},
+	})
+	if isConditionFailed(err) {
+		return apperr.ErrNotFound
+	}
+	return err
+}
+
+// TouchAPIKey はキーの最終使用日時を更新する。
+func (r *Repository) TouchAPIKey(id string, at time.Time) error {
+	_, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName()),
+		Key:                 apiKeyKey(id),
+		UpdateExpression:    aws.String("SET last_used_at = :at"),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at": &types.AttributeValueMemberS{Value: formatStamp(at)},
+		},
+	})
+	if isConditionFailed(err) {
+		return apperr.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// BatchGetItem 1回あたりの最大キー数
+const maxBatchGetKeys = 100
+
+// dynamoAssignment はクラスの課題 (pk=CLASS#<class_id>, sk=ASSIGN#<id>)。
+type dynamoAssignment struct {
+	PK           string                 `dynamodbav:"pk"`
+	SK           string                 `dynamodbav:"sk"`
+	ID           uint64                 `dynamodbav:"id"`
+	ClassID      uint64                 `dynamodbav:"class_id"`
+	Title        string                 `dynamodbav:"title"`
+	ProblemIDs   []uint64               `dynamodbav:"problem_ids,omitempty"`
+	Rules        []dynamoAssignmentRule `dynamodbav:"rules,omitempty"`
+	DueAt        string                 `dynamodbav:"due_at"`    // stampLayout
+	ClosesAt     string                 `dynamodbav:"closes_at"` // stampLayout
+	TimeLimitSec int64                  `dynamodbav:"time_limit_sec,omitempty"`
+	MaxAttempts  int                    `dynamodbav:"max_attempts"`
+	ExamMode     bool                   `dynamodbav:"exam_mode,omitempty"`
+	CreatedBy    string                 `dynamodbav:"created_by"`
+	CreatedAt    string                 `dynamodbav:"created_at"` // stampLayout
+}
+
+type dynamoAssignmentRule struct {
+	CategoryID int `dynamodbav:"category_id"`
+	Count      int `dynamodbav:"count"`
+}
+
+// dynamoAttempt は生徒の受験枠 (pk=ASSIGN#<assignment_id>, sk=ATTEMPT#<sub>#<attempt>)。
+// セッションより先に attribute_not_exists で書き込み、受験回数の上限を並行リクエストでも守る。
+type dynamoAttempt struct {
+	PK           string `dynamodbav:"pk"`
+	SK           string `dynamodbav:"sk"`
+	AssignmentID uint64 `dynamodbav:"assignment_id"`
+	UserSub      string `dynamodbav:"user_sub"`
+	Attempt      int    `dynamodbav:"attempt"`
+	SessionID    uint64 `dynamodbav:"session_id,omitempty"`
+	CreatedAt    string `dynamodbav:"created_at"` // stampLayout
+}
+
+func attemptKey(assignmentID uint64, userSub string, attempt int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ASSIGN#%d", assignmentID)},
+		// 辞書順が受験順になるようゼロ埋めする
+		"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ATTEMPT#%s#%03d", userSub, attempt)},
+	}
+}
+
+// CreateAssignment は課題を保存し、ID を採番する。
+func (r *Repository) CreateAssignment(a *model.Assignment) error {
+	a.CreatedAt = time.Now()
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		a.ID = r.ids.NextID()
+
+		rules := make([]dynamoAssignmentRule, len(a.Rules))
+		for i, rule := range a.Rules {
+			rules[i] = dynamoAssignmentRule{CategoryID: rule.CategoryID, Count: rule.Count}
+		}
+		item, err := attributevalue.MarshalMap(dynamoAssignment{
+			PK:           fmt.Sprintf("CLASS#%d", a.ClassID),
+			SK:           fmt.Sprintf("ASSIGN#%d", a.ID),
+			ID:           a.ID,
+			ClassID:      a.ClassID,
+			Title:        a.Title,
+			ProblemIDs:   a.ProblemIDs,
+			Rules:        rules,
+			DueAt:        formatStamp(a.DueAt),
+			ClosesAt:     formatStamp(a.ClosesAt),
+			TimeLimitSec: int64(a.TimeLimit / time.Second),
+			MaxAttempts:  a.MaxAttempts,
+			ExamMode:     a.ExamMode,
+			CreatedBy:    a.CreatedBy,
+			CreatedAt:    formatStamp(a.CreatedAt),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+			TableName:           aws.String(tableName()),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		})
+		if isConditionFailed(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("assignment id collision: gave up after %d attempts", maxIDAttempts)
+}
+
+// FindAssignment はクラスの課題を返す。存在しなければ apperr.ErrNotFound。
+func (r *Repository) FindAssignment(classID, assignmentID uint64) (*model.Assignment, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+			"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ASSIGN#%d", assignmentID)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var da dynamoAssignment
+	if err := attributevalue.UnmarshalMap(out.Item, &da); err != nil {
+		return nil, err
+	}
+	a := toModelAssignment(da)
+	return &a, nil
+}
+
+// FindAssignments はクラスの課題を作成順 (ID 順) に返す。
+func (r *Repository) FindAssignments(classID uint64) ([]model.Assignment, error) {
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+			":prefix": &types.AttributeValueMemberS{Value: "ASSIGN#"},
+		},
+	})
+
+	var assignments []model.Assignment
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var da dynamoAssignment
+			if err := attributevalue.UnmarshalMap(item, &da); err != nil {
+				return nil, err
+			}
+			assignments = append(assignments, toModelAssignment(da))
+		}
+	}
+	// sk は文字列なので桁数の違う ID の順序を保証しない
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].ID < assignments[j].ID
+	})
+	return assignments, nil
+}
+
+func toModelAssignment(da dynamoAssignment) model.Assignment {
+	rules := make([]model.AssignmentRule, len(da.Rules))
+	for i, rule := range da.Rules {
+		rules[i] = model.AssignmentRule{CategoryID: rule.CategoryID, Count: rule.Count}
+	}
+	a := model.Assignment{
+		ID:          da.ID,
+		ClassID:     da.ClassID,
+		Title:       da.Title,
+		ProblemIDs:  da.ProblemIDs,
+		Rules:       rules,
+		TimeLimit:   time.Duration(da.TimeLimitSec) * time.Second,
+		MaxAttempts: da.MaxAttempts,
+		ExamMode:    da.ExamMode,
+		CreatedBy:   da.CreatedBy,
+	}
+	if t := parseStamp(da.DueAt); t != nil {
+		a.DueAt = *t
+	}
+	if t := parseStamp(da.ClosesAt); t != nil {
+		a.ClosesAt = *t
+	}
+	if t := parseStamp(da.CreatedAt); t != nil {
+		a.CreatedAt = *t
+	}
+	return a
+}
+
+// CreateAssignmentSession は受験枠を確保してから課題のセッションを作成し、枠にセッション ID を記録する。
+// 同じ受験枠が既にあれば apperr.ErrConflict。セッションの作成に失敗した場合は枠を解放する。
+func (r *Repository) CreateAssignmentSession(attempt *model.AssignmentAttempt, session *model.TestSession, sps []model.SessionProblem) error {
+	attempt.CreatedAt = time.Now()
+	key := attemptKey(attempt.AssignmentID, attempt.UserSub, attempt.Attempt)
+	item, err := attributevalue.MarshalMap(dynamoAttempt{
+		AssignmentID: attempt.AssignmentID,
+		UserSub:      attempt.UserSub,
+		Attempt:      attempt.Attempt,
+		CreatedAt:    formatStamp(attempt.CreatedAt),
+	})
+	if err != nil {
+		return err
+	}
+	item["pk"], item["sk"] = key["pk"], key["sk"]
+
+	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if isConditionFailed(err) {
+		return apperr.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	session.AssignmentID = attempt.AssignmentID
+	session.Attempt = attempt.Attempt
+	if err := r.CreateTestSession(session, sps); err != nil {
+		r.client.DeleteItem(bg(), &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName()),
+			Key:       key,
+		})
+		return err
+	}
+
+	_, err = r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName()),
+		Key:              key,
+		UpdateExpression: aws.String("SET session_id = :sid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sid": &types.AttributeValueMemberN{Value: strconv.FormatUint(session.ID, 10)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	attempt.SessionID = session.ID
+	return nil
+}
+
+// FindAssignmentAttempts は課題の受験を生徒・受験順に返す。userSub が空でなければその生徒の分だけを返す。
+// 各受験のセッションを BatchGetItem で読み、終了時刻と得点を埋める。
+func (r *Repository) FindAssignmentAttempts(assignmentID uint64, userSub string) ([]model.AssignmentAttempt, error) {
+	prefix := "ATTEMPT#"
+	if userSub != "" {
+		prefix = fmt.Sprintf("ATTEMPT#%s#", userSub)
+	}
+	p := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: fmt.Sprintf("ASSIGN#%d", assignmentID)},
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+	})
+
+	var attempts []model.AssignmentAttempt
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var da dynamoAttempt
+			if err := attributevalue.UnmarshalMap(item, &da); err != nil {
+				return nil, err
+			}
+			a := model.AssignmentAttempt{
+				AssignmentID: da.AssignmentID,
+				UserSub:      da.UserSub,
+				Attempt:      da.Attempt,
+				SessionID:    da.SessionID,
+			}
+			if t := parseStamp(da.CreatedAt); t != nil {
+				a.CreatedAt = *t
+			}
+			attempts = append(attempts, a)
+		}
+	}
+
+	sessions, err := r.batchGetSessions(attempts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attempts {
+		ds, ok := sessions[attempts[i].SessionID]
+		if !ok {
+			continue
+		}
+		attempts[i].FinishedAt = parseStamp(ds.FinishedAt)
+		if ds.Summary != nil {
+			attempts[i].Total = ds.Summary.Total
+			attempts[i].CorrectCount = ds.Summary.CorrectCount
+		}
+	}
+	return attempts, nil
+}
+
+// batchGetSessions は受験のセッションアイテムをまとめて読む。未処理のキーは maxBatchAttempts 回まで再送する。
+func (r *Repository) batchGetSessions(attempts []model.AssignmentAttempt) (map[uint64]dynamoSession, error) {
+	var keys []map[string]types.AttributeValue
+	for _, a := range attempts {
+		if a.SessionID == 0 {
+			continue
+		}
+		keys = append(keys, map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", a.SessionID)},
+			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+		})
+	}
+
+	sessions := make(map[uint64]dynamoSession, len(keys))
+	for i := 0; i < len(keys); i += maxBatchGetKeys {
+		end := i + maxBatchGetKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		request := map[string]types.KeysAndAttributes{
+			tableName(): {Keys: keys[i:end]},
+		}
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return nil, fmt.Errorf("batch get sessions: unprocessed keys remain after %d attempts", maxBatchAttempts)
+			}
+			out, err := r.client.BatchGetItem(bg(), &dynamodb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range out.Responses[tableName()] {
+				var ds dynamoSession
+				if err := attributevalue.UnmarshalMap(item, &ds); err != nil {
+					return nil, err
+				}
+				sessions[ds.ID] = ds
+			}
+			request = out.UnprocessedKeys
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoCategoryStat はユーザーのカテゴリ別累計成績 (pk=USER#<sub>, sk=CATSTAT#<category_id>)。
+// 回答・セッション終了のトランザクション内で ADD により差分更新する。
+type dynamoCategoryStat struct {
+	PK              string `dynamodbav:"pk"`
+	SK              string `dynamodbav:"sk"`
+	CategoryID      int    `dynamodbav:"category_id"`
+	CategoryName    string `dynamodbav:"category_name"`
+	Attempts        int    `dynamodbav:"attempts"`
+	CorrectCount    int    `dynamodbav:"correct_count"`
+	LastAttemptedAt string `dynamodbav:"last_attempted_at,omitempty"` // stampLayout
+}
+
+func categoryStatKey(userSub string, categoryID int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
+		"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CATSTAT#%d", categoryID)},
+	}
+}
+
+// categoryStatUpdate は累計に差分を加える Update を作る。アイテムが無ければ作成される。
+func categoryStatUpdate(d model.CategoryStatDelta) types.TransactWriteItem {
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:        aws.String(tableName()),
+		Key:              categoryStatKey(d.UserSub, d.CategoryID),
+		UpdateExpression: aws.String("SET category_id = :id, category_name = :name, last_attempted_at = :at ADD attempts :attempts, correct_count :correct"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":       &types.AttributeValueMemberN{Value: strconv.Itoa(d.CategoryID)},
+			":name":     &types.AttributeValueMemberS{Value: d.CategoryName},
+			":at":       &types.AttributeValueMemberS{Value: formatStamp(d.At)},
+			":attempts": &types.AttributeValueMemberN{Value: strconv.Itoa(d.Attempts)},
+			":correct":  &types.AttributeValueMemberN{Value: strconv.Itoa(d.CorrectCount)},
+		},
+	}}
+}
+
+// FindCategoryStats はユーザーのカテゴリ別累計をカテゴリID順に返す。
+func (r *Repository) FindCategoryStats(userSub string) ([]model.CategoryStat, error) {
+	items, err := r.queryCategoryStats(userSub)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]model.CategoryStat, len(items))
+	for i, ds := range items {
+		stats[i] = model.CategoryStat{
+			CategoryID:      ds.CategoryID,
+			CategoryName:    ds.CategoryName,
+			Attempts:        ds.Attempts,
+			CorrectCount:    ds.CorrectCount,
+			LastAttemptedAt: parseStamp(ds.LastAttemptedAt),
+		}
+	}
+	return stats, nil
+}
+
+func (r *Repository) queryCategoryStats(userSub string) ([]dynamoCategoryStat, error) {
+	out, err := r.client.Query(bg(), &dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
+			":prefix": &types.AttributeValueMemberS{Value: "CATSTAT#"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []dynamoCategoryStat
+	for _, item := range out.Items {
+		var ds dynamoCategoryStat
+		if err := attributevalue.UnmarshalMap(item, &ds); err != nil {
+			return nil, err
+		}
+		items = append(items, ds)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CategoryID < items[j].CategoryID
+	})
+	return items, nil
+}
+
+// ReplaceCategoryStats はユーザーのカテゴリ別累計を stats で置き換える。
+// stats に含まれないカテゴリの累計は削除する。再集計 (rebuild) 用で、回答と並行して実行すると差分が失われる。
+// 置き換えた後に所属クラスの分析の版を進める。
+func (r *Repository) ReplaceCategoryStats(userSub string, stats []model.CategoryStat) error {
+	existing, err := r.queryCategoryStats(userSub)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[int]bool, len(stats))
+	var requests []types.WriteRequest
+	for _, st := range stats {
+		keep[st.CategoryID] = true
+		item, err := attributevalue.MarshalMap(dynamoCategoryStat{
+			PK:              fmt.Sprintf("USER#%s", userSub),
+			SK:              fmt.Sprintf("CATSTAT#%d", st.CategoryID),
+			CategoryID:      st.CategoryID,
+			CategoryName:    st.CategoryName,
+			Attempts:        st.Attempts,
+			CorrectCount:    st.CorrectCount,
+			LastAttemptedAt: optStamp(st.LastAttemptedAt),
+		})
+		if err != nil {
+			return err
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	for _, ds := range existing {
+		if !keep[ds.CategoryID] {
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: categoryStatKey(userSub, ds.CategoryID),
+			}})
+		}
+	}
+
+	// DynamoDB は BatchWriteItem 1回あたり最大 25 件
+	for i := 0; i < len(requests); i += 25 {
+		end := i + 25
+		if end > len(requests) {
+			end = len(requests)
+		}
+		if err := r.batchWrite("replace category stats", requests[i:end]); err != nil {
+			return err
+		}
+	}
+
+	bumps, err := r.classAnalyticsBumps(userSub)
+	if err != nil || len(bumps) == 0 {
+		return err
+	}
+	_, err = r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{TransactItems: bumps})
+	return err
+}
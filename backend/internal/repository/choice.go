@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 )
 
@@ -33,7 +34,7 @@ func (r *Repository) FindChoiceByProblemAndChoiceID(problemID, choiceID uint64)
 		return nil, err
 	}
 	if out.Item == nil {
-		return nil, fmt.Errorf("choice not found: problem=%d choice=%d", problemID, choiceID)
+		return nil, fmt.Errorf("problem %d choice %d: %w", problemID, choiceID, apperr.ErrNotFound)
 	}
 
 	var dc dynamoChoice
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoClassAnalytics はクラス分析のキャッシュ (pk=CLASS#<id>, sk=ANALYTICS)。
+// analytics_version は生徒の回答・終了・所属の変更と同じ書き込みで ADD するクラスの版で、
+// キャッシュの有効性はこのアイテム1件を読むだけで判定できる。
+// 保存は計算時の版 (computed_version) が新しい場合のみ行うため、遅れて終わった再計算で上書きされない。
+type dynamoClassAnalytics struct {
+	PK              string `dynamodbav:"pk"`
+	SK              string `dynamodbav:"sk"`
+	ClassID         uint64 `dynamodbav:"class_id"`
+	Version         int64  `dynamodbav:"analytics_version"`
+	ComputedVersion int64  `dynamodbav:"computed_version"`
+	Params          string `dynamodbav:"params"`
+	Payload         []byte `dynamodbav:"payload"`
+	GeneratedAt     string `dynamodbav:"generated_at"` // stampLayout
+}
+
+func classAnalyticsKey(classID uint64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+		"sk": &types.AttributeValueMemberS{Value: "ANALYTICS"},
+	}
+}
+
+// classAnalyticsBump はクラスの分析の版を1進める更新。アイテムが無ければ作る。
+func classAnalyticsBump(classID uint64) types.TransactWriteItem {
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:                 aws.String(tableName()),
+		Key:                       classAnalyticsKey(classID),
+		UpdateExpression:          aws.String("ADD analytics_version :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": &types.AttributeValueMemberN{Value: "1"}},
+	}}
+}
+
+// classAnalyticsReset は版を1進めると同時に計算結果を消す更新。
+// 版は 0 に戻さないため、消す前の版で始まった再計算の結果が後から新しいものとして扱われることはない。
+func classAnalyticsReset(classID uint64) types.TransactWriteItem {
+	item := classAnalyticsBump(classID)
+	item.Update.UpdateExpression = aws.String("ADD analytics_version :one REMOVE payload, computed_version, params")
+	return item
+}
+
+// classAnalyticsBumps は生徒として所属する全クラスの分析の版を進める更新を返す。
+// 生徒の成績を変える書き込みのトランザクションに加える。
+func (r *Repository) classAnalyticsBumps(userSub string) ([]types.TransactWriteItem, error) {
+	memberships, err := r.FindMemberships(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find memberships: %w", err)
+	}
+	var items []types.TransactWriteItem
+	for _, m := range memberships {
+		if m.Role == model.ClassRoleStudent {
+			items = append(items, classAnalyticsBump(m.ClassID))
+		}
+	}
+	return items, nil
+}
+
+// FindClassAnalytics はクラス分析のキャッシュを返す。版も計算結果も無ければ apperr.ErrNotFound。
+func (r *Repository) FindClassAnalytics(classID uint64) (*model.ClassAnalyticsCache, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       classAnalyticsKey(classID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var da dynamoClassAnalytics
+	if err := attributevalue.UnmarshalMap(out.Item, &da); err != nil {
+		return nil, err
+	}
+	c := &model.ClassAnalyticsCache{
+		ClassID:         classID,
+		Version:         da.Version,
+		ComputedVersion: da.ComputedVersion,
+		Params:          da.Params,
+		Payload:         da.Payload,
+	}
+	if t := parseStamp(da.GeneratedAt); t != nil {
+		c.GeneratedAt = *t
+	}
+	return c, nil
+}
+
+// SaveClassAnalytics は c.ComputedVersion の版で計算したクラス分析を保存する。
+// 版 (analytics_version) は変えない。より新しい版で計算済みの場合は何もしない。
+func (r *Repository) SaveClassAnalytics(c *model.ClassAnalyticsCache) error {
+	_, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName()),
+		Key:                 classAnalyticsKey(c.ClassID),
+		UpdateExpression:    aws.String("SET class_id = :class, computed_version = :v, params = :params, payload = :payload, generated_at = :at"),
+		ConditionExpression: aws.String("attribute_not_exists(computed_version) OR computed_version <= :v"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":class":   &types.AttributeValueMemberN{Value: strconv.FormatUint(c.ClassID, 10)},
+			":v":       &types.AttributeValueMemberN{Value: strconv.FormatInt(c.ComputedVersion, 10)},
+			":params":  &types.AttributeValueMemberS{Value: c.Params},
+			":payload": &types.AttributeValueMemberB{Value: c.Payload},
+			":at":      &types.AttributeValueMemberS{Value: formatStamp(c.GeneratedAt)},
+		},
+	})
+	if isConditionFailed(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoClassroom はクラス本体 (pk=CLASS#<id>, sk=#METADATA)。
+type dynamoClassroom struct {
+	PK        string `dynamodbav:"pk"`
+	SK        string `dynamodbav:"sk"`
+	ID        uint64 `dynamodbav:"id"`
+	Name      string `dynamodbav:"name"`
+	JoinCode  string `dynamodbav:"join_code"`
+	OwnerSub  string `dynamodbav:"owner_sub"`
+	CreatedAt string `dynamodbav:"created_at"` // stampLayout
+}
+
+// dynamoJoinCode は参加コードからクラスを引くためのアイテム (pk=JOINCODE#<code>, sk=#METADATA)。
+// 作成時に attribute_not_exists で書き込み、コードの重複を防ぐ。
+type dynamoJoinCode struct {
+	PK      string `dynamodbav:"pk"`
+	SK      string `dynamodbav:"sk"`
+	ClassID uint64 `dynamodbav:"class_id"`
+}
+
+// dynamoClassMember はクラスへの所属 (pk=CLASS#<id>, sk=MEMBER#<sub>)。
+// GSI1 (gsi1pk=MEMBER#<sub>) でユーザーの所属クラスを引く (プレフィックスの理由は tableName を参照)。
+type dynamoClassMember struct {
+	PK       string `dynamodbav:"pk"`
+	SK       string `dynamodbav:"sk"`
+	GSI1PK   string `dynamodbav:"gsi1pk"`
+	GSI1SK   string `dynamodbav:"gsi1sk"`
+	ClassID  uint64 `dynamodbav:"class_id"`
+	UserSub  string `dynamodbav:"user_sub"`
+	UserName string `dynamodbav:"user_name,omitempty"`
+	Role     string `dynamodbav:"role"`
+	JoinedAt string `dynamodbav:"joined_at"` // stampLayout
+}
+
+func classKey(classID uint64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+		"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+	}
+}
+
+func classMemberKey(classID uint64, userSub string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+		"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("MEMBER#%s", userSub)},
+	}
+}
+
+func newDynamoClassMember(m model.ClassMember) dynamoClassMember {
+	return dynamoClassMember{
+		PK:       fmt.Sprintf("CLASS#%d", m.ClassID),
+		SK:       fmt.Sprintf("MEMBER#%s", m.UserSub),
+		GSI1PK:   fmt.Sprintf("MEMBER#%s", m.UserSub),
+		GSI1SK:   fmt.Sprintf("CLASS#%d", m.ClassID),
+		ClassID:  m.ClassID,
+		UserSub:  m.UserSub,
+		UserName: m.UserName,
+		Role:     m.Role,
+		JoinedAt: formatStamp(m.JoinedAt),
+	}
+}
+
+func toModelClassMember(dm dynamoClassMember) model.ClassMember {
+	m := model.ClassMember{
+		ClassID:  dm.ClassID,
+		UserSub:  dm.UserSub,
+		UserName: dm.UserName,
+		Role:     dm.Role,
+	}
+	if t := parseStamp(dm.JoinedAt); t != nil {
+		m.JoinedAt = *t
+	}
+	return m
+}
+
+// CreateClassroom はクラス・参加コード・作成した教師の所属を1トランザクションで作成し、ID を採番する。
+// 参加コードが既存のクラスと重複した場合は apperr.ErrConflict を返す (呼び出し側でコードを作り直す)。
+func (r *Repository) CreateClassroom(class *model.Classroom, teacher *model.ClassMember) error {
+	now := time.Now()
+	class.CreatedAt = now
+	teacher.JoinedAt = now
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		class.ID = r.ids.NextID()
+		teacher.ClassID = class.ID
+
+		items := make([]types.TransactWriteItem, 0, 3)
+		for _, v := range []any{
+			dynamoClassroom{
+				PK:        fmt.Sprintf("CLASS#%d", class.ID),
+				SK:        "#METADATA",
+				ID:        class.ID,
+				Name:      class.Name,
+				JoinCode:  class.JoinCode,
+				OwnerSub:  class.OwnerSub,
+				CreatedAt: formatStamp(class.CreatedAt),
+			},
+			dynamoJoinCode{
+				PK:      fmt.Sprintf("JOINCODE#%s", class.JoinCode),
+				SK:      "#METADATA",
+				ClassID: class.ID,
+			},
+			newDynamoClassMember(*teacher),
+		} {
+			put, err := conditionalPut(v)
+			if err != nil {
+				return err
+			}
+			items = append(items, put)
+		}
+
+		_, err := r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) == 3 {
+			// [1] が参加コードの重複。それ以外はクラス ID の衝突なので再採番する
+			if reason := tce.CancellationReasons[1]; reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return apperr.ErrConflict
+			}
+			if isTxConditionFailed(err) {
+				continue
+			}
+		}
+		return err
+	}
+	return fmt.Errorf("class id collision: gave up after %d attempts", maxIDAttempts)
+}
+
+// FindClassroom はクラスを返す。存在しなければ apperr.ErrNotFound。
+func (r *Repository) FindClassroom(classID uint64) (*model.Classroom, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       classKey(classID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var dc dynamoClassroom
+	if err := attributevalue.UnmarshalMap(out.Item, &dc); err != nil {
+		return nil, err
+	}
+	class := &model.Classroom{
+		ID:       dc.ID,
+		Name:     dc.Name,
+		JoinCode: dc.JoinCode,
+		OwnerSub: dc.OwnerSub,
+	}
+	if t := parseStamp(dc.CreatedAt); t != nil {
+		class.CreatedAt = *t
+	}
+	return class, nil
+}
+
+// FindClassroomByJoinCode は参加コードに対応するクラスを返す。存在しなければ apperr.ErrNotFound。
+func (r *Repository) FindClassroomByJoinCode(code string) (*model.Classroom, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("JOINCODE#%s", code)},
+			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var dj dynamoJoinCode
+	if err := attributevalue.UnmarshalMap(out.Item, &dj); err != nil {
+		return nil, err
+	}
+	return r.FindClassroom(dj.ClassID)
+}
+
+// AddClassMember はクラスに所属を追加する。既に所属している場合は apperr.ErrConflict。
+func (r *Repository) AddClassMember(m *model.ClassMember) error {
+	m.JoinedAt = time.Now()
+	put, err := conditionalPut(newDynamoClassMember(*m))
+	if err != nil {
+		return err
+	}
+	// 生徒の顔ぶれは分析の結果を変えるため、同じトランザクションで分析の版を進める
+	_, err = r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{put, classAnalyticsBump(m.ClassID)},
+	})
+	if isTxConditionFailed(err) {
+		return apperr.ErrConflict
+	}
+	return err
+}
+
+// RemoveClassMember は生徒の所属を削除する。教師の所属は削除しない (該当しなければ apperr.ErrNotFound)。
+func (r *Repository) RemoveClassMember(classID uint64, userSub string) error {
+	_, err := r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Delete: &types.Delete{
+				TableName:                 aws.String(tableName()),
+				Key:                       classMemberKey(classID, userSub),
+				ConditionExpression:       aws.String("#role = :student"),
+				ExpressionAttributeNames:  map[string]string{"#role": "role"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":student": &types.AttributeValueMemberS{Value: model.ClassRoleStudent}},
+			}},
+			classAnalyticsBump(classID),
+		},
+	})
+	if isTxConditionFailed(err) {
+		return apperr.ErrNotFound
+	}
+	return err
+}
+
+// FindClassMember はクラスでのユーザーの所属を返す。所属していなければ apperr.ErrNotFound。
+func (r *Repository) FindClassMember(classID uint64, userSub string) (*model.ClassMember, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       classMemberKey(classID, userSub),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var dm dynamoClassMember
+	if err := attributevalue.UnmarshalMap(out.Item, &dm); err != nil {
+		return nil, err
+	}
+	m := toModelClassMember(dm)
+	return &m, nil
+}
+
+// FindClassMembers はクラスの全所属 (教師を含む) を返す。
+func (r *Repository) FindClassMembers(classID uint64) ([]model.ClassMember, error) {
+	return r.queryClassMembers(&dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: fmt.Sprintf("CLASS#%d", classID)},
+			":prefix": &types.AttributeValueMemberS{Value: "MEMBER#"},
+		},
+	})
+}
+
+// FindMemberships はユーザーが所属する全クラスの所属を返す。
+func (r *Repository) FindMemberships(userSub string) ([]model.ClassMember, error) {
+	return r.queryClassMembers(&dynamodb.QueryInput{
+		TableName:              aws.String(tableName()),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("gsi1pk = :gsi1pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":gsi1pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("MEMBER#%s", userSub)},
+		},
+	})
+}
+
+func (r *Repository) queryClassMembers(input *dynamodb.QueryInput) ([]model.ClassMember, error) {
+	p := dynamodb.NewQueryPaginator(r.client, input)
+	var members []model.ClassMember
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var dm dynamoClassMember
+			if err := attributevalue.UnmarshalMap(item, &dm); err != nil {
+				return nil, err
+			}
+			members = append(members, toModelClassMember(dm))
+		}
+	}
+	return members, nil
+}
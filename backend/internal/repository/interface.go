@@ -1,21 +1,131 @@
 package repository
 
-import "github.com/Kyouheip/MathOvercome_serverless/internal/model"
+import (
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
 
 // TestSessionRepo は TestSessionService が使うリポジトリ操作を定義する。
 type TestSessionRepo interface {
-	SaveTestSession(session *model.TestSession) error
+	CreateTestSession(session *model.TestSession, sps []model.SessionProblem) error
 	FindTestSession(sessionID uint64) (*model.TestSession, error)
 	FindProblemsPerCategory(categoryIDs []int, countPerCategory int) ([]model.Problem, error)
-	SaveSessionProblems(sps []model.SessionProblem) error
 	CountSessionProblems(sessionID uint64) (int64, error)
 	FindSessionProblemByIdx(sessionID uint64, idx int) (*model.SessionProblem, error)
 	FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error)
 	FindChoiceByProblemAndChoiceID(problemID, choiceID uint64) (*model.Choice, error)
-	SaveSessionProblem(sp *model.SessionProblem) error
+	UpdateSessionProblemAnswer(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error
+	FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error)
+	MarkSessionProblemViewed(sp *model.SessionProblem, at time.Time) error
+	MarkHintRevealed(sp *model.SessionProblem, at time.Time) error
+	FinishTestSession(session *model.TestSession, unanswered []model.SessionProblem, deltas []model.CategoryStatDelta, at time.Time) error
+	FindSessionSummaries(userSub string, q SessionQuery) (*SessionPage, error)
 }
 
 // MypageRepo は MypageService が使うリポジトリ操作を定義する。
 type MypageRepo interface {
-	GetSessionProblemsRaw(userSub string) ([]SessionProblemRow, error)
+	FindSessionSummaries(userSub string, q SessionQuery) (*SessionPage, error)
+	FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error)
+	FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error)
+	FindCategoryStats(userSub string) ([]model.CategoryStat, error)
+	ReplaceCategoryStats(userSub string, stats []model.CategoryStat) error
+	FindTestSession(sessionID uint64) (*model.TestSession, error)
+	FindProblemWithChoices(problemID uint64) (*model.Problem, error)
+}
+
+// ItemAnalysisRepo は ItemAnalysisService が使うリポジトリ操作を定義する。
+type ItemAnalysisRepo interface {
+	ScanSessionProblems() ([]model.SessionProblem, error)
+	FindProblemWithChoices(problemID uint64) (*model.Problem, error)
+	FindItemAnalysis() (*model.ItemAnalysisSnapshot, error)
+	SaveItemAnalysis(a *model.ItemAnalysisSnapshot) error
+}
+
+// ClassMemberReader はアクセス判定に使うクラス所属の参照操作を定義する。
+type ClassMemberReader interface {
+	FindClassMember(classID uint64, userSub string) (*model.ClassMember, error)
+	FindMemberships(userSub string) ([]model.ClassMember, error)
+}
+
+// ClassroomRepo は ClassroomService が使うリポジトリ操作を定義する。
+type ClassroomRepo interface {
+	ClassMemberReader
+	CreateClassroom(class *model.Classroom, teacher *model.ClassMember) error
+	FindClassroom(classID uint64) (*model.Classroom, error)
+	FindClassroomByJoinCode(code string) (*model.Classroom, error)
+	AddClassMember(m *model.ClassMember) error
+	RemoveClassMember(classID uint64, userSub string) error
+	FindClassMembers(classID uint64) ([]model.ClassMember, error)
+}
+
+// AssignmentRepo は AssignmentService が使うリポジトリ操作を定義する。
+type AssignmentRepo interface {
+	ClassMemberReader
+	FindClassMembers(classID uint64) ([]model.ClassMember, error)
+	CreateAssignment(a *model.Assignment) error
+	FindAssignment(classID, assignmentID uint64) (*model.Assignment, error)
+	FindAssignments(classID uint64) ([]model.Assignment, error)
+	FindProblemsPerCategory(categoryIDs []int, countPerCategory int) ([]model.Problem, error)
+	FindProblemWithChoices(problemID uint64) (*model.Problem, error)
+	CreateAssignmentSession(attempt *model.AssignmentAttempt, session *model.TestSession, sps []model.SessionProblem) error
+	FindAssignmentAttempts(assignmentID uint64, userSub string) ([]model.AssignmentAttempt, error)
+}
+
+// ClassAnalyticsRepo は ClassAnalyticsService が使うリポジトリ操作を定義する。
+type ClassAnalyticsRepo interface {
+	ClassMemberReader
+	FindClassMembers(classID uint64) ([]model.ClassMember, error)
+	FindCategoryStats(userSub string) ([]model.CategoryStat, error)
+	FindSessionSummaries(userSub string, q SessionQuery) (*SessionPage, error)
+	FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error)
+	FindProblemWithChoices(problemID uint64) (*model.Problem, error)
+	FindClassAnalytics(classID uint64) (*model.ClassAnalyticsCache, error)
+	SaveClassAnalytics(c *model.ClassAnalyticsCache) error
+}
+
+// APIKeyRepo は APIKeyService が使うリポジトリ操作を定義する。
+type APIKeyRepo interface {
+	CreateAPIKey(k *model.APIKey) error
+	FindAPIKey(id string) (*model.APIKey, error)
+	FindAPIKeys(userSub string) ([]model.APIKey, error)
+	RevokeAPIKey(id, userSub string, at time.Time) error
+	TouchAPIKey(id string, at time.Time) error
+}
+
+// RoleRepo は RoleService が使うリポジトリ操作を定義する。
+type RoleRepo interface {
+	FindUserRoles(userSub string) ([]string, error)
+	AddUserRole(userSub, role string, at time.Time) error
+	RemoveUserRole(userSub, role string, at time.Time) error
+}
+
+// ProfileRepo は ProfileService が使うリポジトリ操作を定義する。
+type ProfileRepo interface {
+	FindUserProfile(userSub string) (*model.UserProfile, error)
+	SaveUserProfile(p *model.UserProfile, at time.Time) error
+}
+
+// AccountRepo は AccountService (利用者データのエクスポートと削除) が使うリポジトリ操作を定義する。
+type AccountRepo interface {
+	FindUserProfile(userSub string) (*model.UserProfile, error)
+	FindUserRoles(userSub string) ([]string, error)
+	FindCategoryStats(userSub string) ([]model.CategoryStat, error)
+	FindUserSessions(userSub string) ([]model.TestSession, error)
+	FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error)
+	FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error)
+	FindMemberships(userSub string) ([]model.ClassMember, error)
+	FindClassroom(classID uint64) (*model.Classroom, error)
+	FindAssignments(classID uint64) ([]model.Assignment, error)
+	FindAssignmentAttempts(assignmentID uint64, userSub string) ([]model.AssignmentAttempt, error)
+	FindAPIKeys(userSub string) ([]model.APIKey, error)
+
+	PurgeSession(sessionID uint64) (int, error)
+	PurgeClassMember(classID uint64, userSub string) error
+	FindUserItemKeys(userSub string) ([]ItemKey, error)
+	DeleteItems(keys []ItemKey) error
+	AnonymizeClassroomOwner(classID uint64, userSub string) (bool, error)
+	AnonymizeAssignmentCreator(classID, assignmentID uint64, userSub string) (bool, error)
+	FindAccountDeletion(userSub string) (*model.AccountDeletion, error)
+	SaveAccountDeletion(d *model.AccountDeletion) error
 }
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoItemAnalysis は最後に計算した問題分析 (pk=ITEMANALYSIS, sk=#METADATA)。
+// 計算は全 SP の Scan を伴うため CLI (mathovercome analysis rebuild) で行い、API は保存済みの結果を返す。
+type dynamoItemAnalysis struct {
+	PK          string `dynamodbav:"pk"`
+	SK          string `dynamodbav:"sk"`
+	Payload     []byte `dynamodbav:"payload"`
+	GeneratedAt string `dynamodbav:"generated_at"` // stampLayout
+}
+
+func itemAnalysisKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "ITEMANALYSIS"},
+		"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+	}
+}
+
+// FindItemAnalysis は保存済みの問題分析を返す。まだ計算していなければ apperr.ErrNotFound。
+func (r *Repository) FindItemAnalysis() (*model.ItemAnalysisSnapshot, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       itemAnalysisKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var da dynamoItemAnalysis
+	if err := attributevalue.UnmarshalMap(out.Item, &da); err != nil {
+		return nil, err
+	}
+	a := &model.ItemAnalysisSnapshot{Payload: da.Payload}
+	if t := parseStamp(da.GeneratedAt); t != nil {
+		a.GeneratedAt = *t
+	}
+	return a, nil
+}
+
+// SaveItemAnalysis は計算した問題分析を上書き保存する。
+func (r *Repository) SaveItemAnalysis(a *model.ItemAnalysisSnapshot) error {
+	key := itemKeyOf(itemAnalysisKey())
+	item, err := attributevalue.MarshalMap(dynamoItemAnalysis{
+		PK:          key.PK,
+		SK:          key.SK,
+		Payload:     a.Payload,
+		GeneratedAt: formatStamp(a.GeneratedAt),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+		TableName: aws.String(tableName()),
+		Item:      item,
+	})
+	return err
+}
+
+// ScanSessionProblems はテーブル全体を Scan して全セッションの SP を返す。
+// 問題分析の再計算などのバッチ処理用で、テーブルの大きさに比例して読み込み容量を消費する。リクエストの処理中には呼ばない。
+func (r *Repository) ScanSessionProblems() ([]model.SessionProblem, error) {
+	p := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName:        aws.String(tableName()),
+		FilterExpression: aws.String("begins_with(pk, :pk) AND begins_with(sk, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "SESSION#"},
+			":sk": &types.AttributeValueMemberS{Value: "SP#"},
+		},
+	})
+
+	var sps []model.SessionProblem
+	for p.HasMorePages() {
+		out, err := p.NextPage(bg())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			var dsp dynamoSP
+			if err := attributevalue.UnmarshalMap(item, &dsp); err != nil {
+				return nil, err
+			}
+			sps = append(sps, toModelSP(dsp))
+		}
+	}
+	return sps, nil
+}
+
+// FindProblemWithChoices は問題と選択肢をまとめて返す。
+func (r *Repository) FindProblemWithChoices(problemID uint64) (*model.Problem, error) {
+	problem, choices, err := r.fetchProblemWithChoices(problemID)
+	if err != nil {
+		return nil, err
+	}
+	problem.Choices = choices
+	return problem, nil
+}
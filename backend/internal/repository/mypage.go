@@ -1,75 +1,279 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"sort"
+	"maps"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/idgen"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 )
 
+// CategoryStats はカテゴリ単位の集計結果。
+// HintedCount はヒント表示後に回答した問題数で、CorrectCount のうち HintedCorrectCount がヒントありの正答。
+// TimeSpent は所要時間を計測できた TimedCount 問分の合計。
 type CategoryStats struct {
-	Name         string
-	TotalCount   int
-	CorrectCount int
+	Name               string
+	TotalCount         int
+	CorrectCount       int
+	HintedCount        int
+	HintedCorrectCount int
+	TimedCount         int
+	TimeSpent          time.Duration
 }
 
-type SessionProblemRow struct {
-	SessionID    uint64
-	StartTime    time.Time
-	IsCorrect    bool
+// ProblemTime は1問分の所要時間 (初回表示から初回回答まで)。
+type ProblemTime struct {
+	Idx          int
 	CategoryName string
+	TimeSpent    time.Duration
 }
 
-// GetSessionProblemsRaw はユーザーの全セッション×全SPを結合して返す。
-// セッションは降順（新しい順）、SP は昇順。
-func (r *Repository) GetSessionProblemsRaw(userSub string) ([]SessionProblemRow, error) {
-	// GSI1: gsi1pk = USER#<sub> → セッション一覧を降順で取得
-	sessOut, err := r.client.Query(bg(), &dynamodb.QueryInput{
-		TableName:              aws.String(tableName()),
-		IndexName:              aws.String("GSI1"),
-		KeyConditionExpression: aws.String("gsi1pk = :gsi1pk"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":gsi1pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
-		},
-		ScanIndexForward: aws.Bool(false), // 降順
-	})
-	if err != nil {
-		return nil, err
+// SessionQuery はマイページ履歴の取得条件。
+// From / To がゼロ値の場合はその方向の絞り込みを行わない。To は排他的上限。
+// Finished が nil なら終了したかどうかで絞り込まない。
+type SessionQuery struct {
+	Limit    int32
+	Cursor   string
+	From     time.Time
+	To       time.Time
+	Finished *bool
+}
+
+// SessionSummary はセッション単位の集計結果。
+type SessionSummary struct {
+	SessionID          uint64
+	StartTime          time.Time
+	FinishedAt         *time.Time
+	ExamMode           bool
+	IncludeIntegers    bool
+	AssignmentID       uint64
+	Attempt            int
+	ClosesAt           *time.Time
+	Total              int
+	Answered           int // 回答済みの問題数
+	CorrectCount       int
+	HintedCount        int
+	HintedCorrectCount int
+	TimedCount         int
+	TimeSpent          time.Duration
+	Categories         []CategoryStats
+	Problems           []ProblemTime // 所要時間を計測できた問題のみ
+}
+
+// SessionPage は FindSessionSummaries の1ページ分の結果。
+// NextCursor が空なら最終ページ。NextCursor があれば次のページには1件以上ある。
+type SessionPage struct {
+	Sessions   []SessionSummary
+	NextCursor string
+}
+
+// sessionIDSlack は start_time を記録してからセッションの ID を採番するまでの猶予。
+// ID の範囲は上限をこの分だけ広げ、正確な絞り込みは start_time で行う。
+const sessionIDSlack = time.Minute
+
+// sessionKeyRange は gsi1sk の範囲 (両端を含む)。
+type sessionKeyRange struct{ lo, hi string }
+
+func (kr sessionKeyRange) contains(sk string) bool { return kr.lo <= sk && sk <= kr.hi }
+
+// sessionKeyRanges は開始時刻が [from, to) のセッションを含む gsi1sk の範囲を新しい順に返す。
+// セッション ID は時系列順の Snowflake だが、UnixNano 採番の旧データは Snowflake より小さい値に並ぶため、
+// Snowflake と旧データの2つの範囲に分けて読む。
+// gsi1sk は ID を0埋めせずに書くため、文字列の順序が数値の順序と一致するのは ID が19桁の場合のみ。
+// Snowflake (LegacyIDLimit 以上) と 2001年9月以降の UnixNano はどちらも19桁で、シードデータもこれに合わせている。
+// 19桁に満たない ID のセッションは期間を指定すると範囲外になる (期間を指定しなければ読める)。
+func sessionKeyRanges(from, to time.Time) []sessionKeyRange {
+	sk := func(id uint64) string { return fmt.Sprintf("SESSION#%019d", id) }
+
+	newLo, newHi := idgen.LegacyIDLimit, uint64(math.MaxInt64)
+	oldLo, oldHi := uint64(0), idgen.LegacyIDLimit-1
+	if !from.IsZero() {
+		newLo = max(newLo, idgen.FloorID(from))
+		oldLo = uint64(max(from.UnixNano(), 0))
+	}
+	if !to.IsZero() {
+		newHi = idgen.FloorID(to.Add(sessionIDSlack)) - 1
+		oldHi = min(oldHi, uint64(max(to.Add(sessionIDSlack).UnixNano(), 0)))
+	}
+
+	var ranges []sessionKeyRange
+	for _, r := range [][2]uint64{{newLo, newHi}, {oldLo, oldHi}} {
+		if r[0] <= r[1] {
+			ranges = append(ranges, sessionKeyRange{sk(r[0]), sk(r[1])})
+		}
 	}
+	return ranges
+}
 
-	var rows []SessionProblemRow
+// FindSessionSummaries はユーザーのセッションを新しい順に1ページ分 (最大 Limit 件) 取得する。Limit が0なら全件。
+// 期間は gsi1sk (時系列順の ID) の範囲として KeyCondition で読み、
+// 作成途中・終了状態の絞り込みで件数が減った分は Limit 件そろうか最後に達するまで続けて読む。
+// セッションに保存済みの集計 (summary) があればそれを使い、
+// 無い (または answered_count を持たない) 古いセッションのみ SP を Query して集計する。
+func (r *Repository) FindSessionSummaries(userSub string, q SessionQuery) (*SessionPage, error) {
+	gsi1pk := fmt.Sprintf("USER#%s", userSub)
+	ranges := sessionKeyRanges(q.From, q.To)
 
-	for _, sessItem := range sessOut.Items {
-		var ds dynamoSession
-		if err := attributevalue.UnmarshalMap(sessItem, &ds); err != nil {
+	var startKey map[string]types.AttributeValue
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor, gsi1pk)
+		if err != nil {
 			return nil, err
 		}
-		startTime, _ := time.Parse("2006-01-02 15:04:05", ds.StartTime)
+		// 前のページの最後のセッションを含む範囲から再開する。範囲外なら条件を変えたカーソル
+		sk, _ := key["gsi1sk"].(*types.AttributeValueMemberS)
+		for len(ranges) > 0 && (sk == nil || !ranges[0].contains(sk.Value)) {
+			ranges = ranges[1:]
+		}
+		if len(ranges) == 0 {
+			return nil, apperr.ErrInvalidArgument
+		}
+		startKey = key
+	}
+
+	// 作成途中 (pending) のセッションは除外する
+	filters := []string{"(attribute_not_exists(#status) OR #status = :ready)"}
+	values := map[string]types.AttributeValue{
+		":gsi1pk": &types.AttributeValueMemberS{Value: gsi1pk},
+		":ready":  &types.AttributeValueMemberS{Value: model.SessionStatusReady},
+	}
+	if !q.From.IsZero() {
+		filters = append(filters, "start_time >= :from")
+		values[":from"] = &types.AttributeValueMemberS{Value: q.From.UTC().Format(timeLayout)}
+	}
+	if !q.To.IsZero() {
+		filters = append(filters, "start_time < :to")
+		values[":to"] = &types.AttributeValueMemberS{Value: q.To.UTC().Format(timeLayout)}
+	}
+	if q.Finished != nil {
+		if *q.Finished {
+			filters = append(filters, "attribute_exists(finished_at)")
+		} else {
+			filters = append(filters, "attribute_not_exists(finished_at)")
+		}
+	}
 
-		sps, err := r.querySessionProblems(ds.ID)
+	// 次のページがあるかを知るため Limit より1件多く集める
+	limit := int(q.Limit)
+	full := func(n int) bool { return limit > 0 && n > limit }
+	var sessions []dynamoSession
+	for _, kr := range ranges {
+		if full(len(sessions)) {
+			break
+		}
+		rangeValues := maps.Clone(values)
+		rangeValues[":lo"] = &types.AttributeValueMemberS{Value: kr.lo}
+		rangeValues[":hi"] = &types.AttributeValueMemberS{Value: kr.hi}
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName()),
+			IndexName:                 aws.String("GSI1"),
+			KeyConditionExpression:    aws.String("gsi1pk = :gsi1pk AND gsi1sk BETWEEN :lo AND :hi"),
+			FilterExpression:          aws.String(strings.Join(filters, " AND ")),
+			ExpressionAttributeNames:  map[string]string{"#status": "status"},
+			ExpressionAttributeValues: rangeValues,
+			ExclusiveStartKey:         startKey,
+			ScanIndexForward:          aws.Bool(false), // 降順
+		}
+		if limit > 0 {
+			input.Limit = aws.Int32(int32(limit + 1))
+		}
+		startKey = nil
+
+		p := dynamodb.NewQueryPaginator(r.client, input)
+		for p.HasMorePages() && !full(len(sessions)) {
+			out, err := p.NextPage(bg())
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range out.Items {
+				var ds dynamoSession
+				if err := attributevalue.UnmarshalMap(item, &ds); err != nil {
+					return nil, err
+				}
+				sessions = append(sessions, ds)
+			}
+		}
+	}
+
+	page := &SessionPage{}
+	if full(len(sessions)) {
+		sessions = sessions[:limit]
+		last := sessions[limit-1]
+		key, err := attributevalue.MarshalMap(map[string]string{
+			"pk": last.PK, "sk": last.SK, "gsi1pk": last.GSI1PK, "gsi1sk": last.GSI1SK,
+		})
 		if err != nil {
 			return nil, err
 		}
+		if page.NextCursor, err = encodeCursor(key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ds := range sessions {
+		startTime, _ := time.Parse(timeLayout, ds.StartTime)
 
-		for _, dsp := range sps {
-			isCorrect := false
-			if dsp.IsCorrect != nil {
-				isCorrect = *dsp.IsCorrect
+		summary := ds.Summary
+		if summary == nil || summary.AnsweredCount == nil {
+			sps, err := r.querySessionProblems(ds.ID)
+			if err != nil {
+				return nil, err
 			}
-			rows = append(rows, SessionProblemRow{
-				SessionID:    ds.ID,
-				StartTime:    startTime,
-				IsCorrect:    isCorrect,
-				CategoryName: dsp.CategoryName,
-			})
+			s := summarize(toModelSPs(sps))
+			summary = &s
 		}
+
+		sum := summary.toSessionSummary(ds.ID, startTime)
+		sum.ExamMode = ds.ExamMode
+		sum.IncludeIntegers = ds.IncludeIntegers
+		sum.AssignmentID = ds.AssignmentID
+		sum.Attempt = ds.Attempt
+		sum.ClosesAt = parseStamp(ds.ClosesAt)
+		sum.FinishedAt = parseStamp(ds.FinishedAt)
+		page.Sessions = append(page.Sessions, sum)
 	}
+	return page, nil
+}
 
-	return rows, nil
+// encodeCursor はアイテムのキーをクライアントに返せる文字列に変換する。
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	var m map[string]string
+	if err := attributevalue.UnmarshalMap(key, &m); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor は encodeCursor の逆変換。
+// 他ユーザーのパーティションを指すカーソルは不正として扱う。
+func decodeCursor(cursor, gsi1pk string) (map[string]types.AttributeValue, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, apperr.ErrInvalidArgument
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if m["gsi1pk"] != gsi1pk {
+		return nil, apperr.ErrInvalidArgument
+	}
+	return attributevalue.MarshalMap(m)
 }
 
 // GetCategoryStats はセッション内のSPをカテゴリ別に集計して返す。
@@ -99,55 +303,3 @@ func (r *Repository) GetCategoryStats(sessionID uint64) ([]CategoryStats, error)
 	}
 	return result, nil
 }
-
-// GetWeakCategories は正答率 < 0.5 のカテゴリを昇順で最大2件返す。
-func (r *Repository) GetWeakCategories(sessionID uint64) ([]string, error) {
-	sps, err := r.querySessionProblems(sessionID)
-	if err != nil {
-		return nil, err
-	}
-
-	type catStat struct {
-		total   int
-		correct int
-	}
-	statsMap := make(map[string]*catStat)
-	var order []string
-
-	for _, dsp := range sps {
-		if _, exists := statsMap[dsp.CategoryName]; !exists {
-			statsMap[dsp.CategoryName] = &catStat{}
-			order = append(order, dsp.CategoryName)
-		}
-		statsMap[dsp.CategoryName].total++
-		if dsp.IsCorrect != nil && *dsp.IsCorrect {
-			statsMap[dsp.CategoryName].correct++
-		}
-	}
-
-	type weakCat struct {
-		name string
-		rate float64
-	}
-	var weaks []weakCat
-	for _, name := range order {
-		st := statsMap[name]
-		if st.total == 0 {
-			continue
-		}
-		rate := float64(st.correct) / float64(st.total)
-		if rate < 0.5 {
-			weaks = append(weaks, weakCat{name: name, rate: rate})
-		}
-	}
-
-	sort.Slice(weaks, func(i, j int) bool {
-		return weaks[i].rate < weaks[j].rate
-	})
-
-	names := []string{}
-	for i := 0; i < len(weaks) && i < 2; i++ {
-		names = append(names, weaks[i].name)
-	}
-	return names, nil
-}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/idgen"
+)
+
+func TestSessionKeyRanges(t *testing.T) {
+	sk := func(id uint64) string { return fmt.Sprintf("SESSION#%019d", id) }
+	maxID := uint64(math.MaxInt64)
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 10, 8, 0, 0, 0, 0, time.UTC)
+	// LegacyIDLimit は 2004年11月のミリ秒に当たる
+	beforeLimit := time.Date(2004, 6, 1, 0, 0, 0, 0, time.UTC)
+	afterLimit := time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []sessionKeyRange
+	}{
+		{"no bounds", time.Time{}, time.Time{}, []sessionKeyRange{
+			{sk(idgen.LegacyIDLimit), sk(maxID)},
+			{sk(0), sk(idgen.LegacyIDLimit - 1)},
+		}},
+		{"from only", from, time.Time{}, []sessionKeyRange{
+			{sk(idgen.FloorID(from)), sk(maxID)},
+			{sk(uint64(from.UnixNano())), sk(idgen.LegacyIDLimit - 1)},
+		}},
+		{"to only", time.Time{}, to, []sessionKeyRange{
+			{sk(idgen.LegacyIDLimit), sk(idgen.FloorID(to.Add(sessionIDSlack)) - 1)},
+			{sk(0), sk(uint64(to.Add(sessionIDSlack).UnixNano()))},
+		}},
+		{"window", from, to, []sessionKeyRange{
+			{sk(idgen.FloorID(from)), sk(idgen.FloorID(to.Add(sessionIDSlack)) - 1)},
+			{sk(uint64(from.UnixNano())), sk(uint64(to.Add(sessionIDSlack).UnixNano()))},
+		}},
+		{"spans legacy limit", beforeLimit, afterLimit, []sessionKeyRange{
+			{sk(idgen.LegacyIDLimit), sk(idgen.FloorID(afterLimit.Add(sessionIDSlack)) - 1)},
+			{sk(uint64(beforeLimit.UnixNano())), sk(uint64(afterLimit.Add(sessionIDSlack).UnixNano()))},
+		}},
+		{"before legacy limit", time.Time{}, beforeLimit, []sessionKeyRange{
+			{sk(0), sk(uint64(beforeLimit.Add(sessionIDSlack).UnixNano()))},
+		}},
+	}
+	for _, tt := range tests {
+		got := sessionKeyRanges(tt.from, tt.to)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSessionKeyRanges_ContainsSessionsInWindow(t *testing.T) {
+	from := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 10, 2, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC)
+	ranges := sessionKeyRanges(from, to)
+
+	contains := func(sk string) bool {
+		return slices.ContainsFunc(ranges, func(kr sessionKeyRange) bool { return kr.contains(sk) })
+	}
+	// gsi1sk は0埋めせずに書く
+	tests := []struct {
+		name string
+		sk   string
+		want bool
+	}{
+		{"snowflake", fmt.Sprintf("SESSION#%d", idgen.FloorID(start)|1), true},
+		{"legacy", fmt.Sprintf("SESSION#%d", start.UnixNano()), true},
+		{"next day", fmt.Sprintf("SESSION#%d", idgen.FloorID(to.Add(time.Hour))), false},
+		{"short id", "SESSION#1", false},
+	}
+	for _, tt := range tests {
+		if got := contains(tt.sk); got != tt.want {
+			t.Errorf("%s: expected contains(%s) = %v, got %v", tt.name, tt.sk, tt.want, got)
+		}
+	}
+}
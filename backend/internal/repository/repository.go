@@ -2,11 +2,45 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"os"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/idgen"
 )
 
+// start_time 属性の書式
+const timeLayout = "2006-01-02 15:04:05"
+
+// answered_at / first_viewed_at など秒未満が必要な時刻属性の書式 (RFC3339, UTC, ミリ秒)
+const stampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func formatStamp(t time.Time) string {
+	return t.UTC().Format(stampLayout)
+}
+
+// parseStamp は stampLayout の文字列を解釈する。空や不正な値は nil を返す。
+func parseStamp(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(stampLayout, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// tableName は全エンティティを入れる単一テーブルの名前を返す。キーの設計は db/dynamodb/README.md を参照。
+//
+// GSI1 の gsi1pk はエンティティごとにプレフィックスを分ける。利用者のセッションは USER#<sub> を使うため、
+// 利用者単位で引く他のエンティティ (所属クラスの MEMBER#、API キーの APIKEYOWNER#) は別のプレフィックスにして、
+// マイページのセッション一覧の Query に混ざらないようにしている。
 func tableName() string {
 	if t := os.Getenv("DYNAMODB_TABLE"); t != "" {
 		return t
@@ -14,12 +48,33 @@ func tableName() string {
 	return "MathOvercome"
 }
 
+const (
+	// 条件付き書き込みが ID 衝突で失敗した場合の最大試行回数
+	maxIDAttempts = 3
+	// TransactWriteItems 1回あたりの最大アイテム数
+	maxTransactItems = 100
+	// BatchWriteItem の未処理アイテムを再送する最大回数と、最初の再送までの間隔
+	maxBatchAttempts = 3
+	batchRetryBase   = 50 * time.Millisecond
+)
+
 type Repository struct {
 	client *dynamodb.Client
+	ids    idgen.Generator
 }
 
+// NewRepository は Snowflake 採番を使うリポジトリを返す。
 func NewRepository(client *dynamodb.Client) *Repository {
-	return &Repository{client: client}
+	return &Repository{
+		client: client,
+		ids:    idgen.NewSnowflake(idgen.NodeIDFromEnv()),
+	}
+}
+
+// WithIDGenerator は採番方式を差し替える。
+func (r *Repository) WithIDGenerator(g idgen.Generator) *Repository {
+	r.ids = g
+	return r
 }
 
 func bg() context.Context {
@@ -36,3 +91,36 @@ var catNames = map[int]string{
 	6: "図形の性質",
 	7: "整数",
 }
+
+// conditionalPut は既存アイテムを上書きしない条件付きの Put を作る。
+func conditionalPut(v any) (types.TransactWriteItem, error) {
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:           aws.String(tableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	}}, nil
+}
+
+// isConditionFailed は PutItem / UpdateItem の条件式が満たされなかったかを判定する。
+func isConditionFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
+
+// isTxConditionFailed は TransactWriteItems がいずれかの条件式で取り消されたかを判定する。
+func isTxConditionFailed(err error) bool {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return false
+	}
+	for _, reason := range tce.CancellationReasons {
+		if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
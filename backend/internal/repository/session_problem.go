@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 )
 
@@ -24,9 +27,15 @@ type dynamoSP struct {
 	CategoryName     string  `dynamodbav:"category_name"`
 	SelectedChoiceID *uint64 `dynamodbav:"selected_choice_id,omitempty"`
 	IsCorrect        *bool   `dynamodbav:"is_correct,omitempty"`
+	Version          int64   `dynamodbav:"version,omitempty"`
+	FirstViewedAt    string  `dynamodbav:"first_viewed_at,omitempty"`  // stampLayout
+	AnsweredAt       string  `dynamodbav:"answered_at,omitempty"`      // stampLayout
+	HintRevealedAt   string  `dynamodbav:"hint_revealed_at,omitempty"` // stampLayout
+	AnsweredWithHint bool    `dynamodbav:"answered_with_hint,omitempty"`
 }
 
-// querySessionProblems は pk=SESSION#<id>, sk begins_with SP# で全SPを取得し SK 昇順で返す。
+// querySessionProblems は pk=SESSION#<id>, sk begins_with SP# で全SPを取得し ID 昇順で返す。
+// SK は文字列比較のため桁数の異なる ID (SP#2 と SP#10 など) が逆転しないよう数値で並べ直す。
 func (r *Repository) querySessionProblems(sessionID uint64) ([]dynamoSP, error) {
 	out, err := r.client.Query(bg(), &dynamodb.QueryInput{
 		TableName:              aws.String(tableName()),
@@ -49,7 +58,7 @@ func (r *Repository) querySessionProblems(sessionID uint64) ([]dynamoSP, error)
 		sps = append(sps, dsp)
 	}
 	sort.Slice(sps, func(i, j int) bool {
-		return sps[i].SK < sps[j].SK
+		return sps[i].ID < sps[j].ID
 	})
 	return sps, nil
 }
@@ -105,11 +114,37 @@ func (r *Repository) fetchProblemWithChoices(problemID uint64) (*model.Problem,
 	}
 
 	if problem == nil {
-		return nil, nil, fmt.Errorf("problem not found: %d", problemID)
+		return nil, nil, fmt.Errorf("problem %d: %w", problemID, apperr.ErrNotFound)
 	}
 	return problem, choices, nil
 }
 
+func newDynamoSP(sp model.SessionProblem) dynamoSP {
+	return dynamoSP{
+		PK:               fmt.Sprintf("SESSION#%d", sp.TestSessionID),
+		SK:               fmt.Sprintf("SP#%d", sp.ID),
+		ID:               sp.ID,
+		SessionID:        sp.TestSessionID,
+		ProblemID:        sp.ProblemID,
+		CategoryID:       sp.CategoryID,
+		CategoryName:     sp.CategoryName,
+		SelectedChoiceID: sp.SelectedChoiceID,
+		IsCorrect:        sp.IsCorrect,
+		Version:          sp.Version,
+		FirstViewedAt:    optStamp(sp.FirstViewedAt),
+		AnsweredAt:       optStamp(sp.AnsweredAt),
+		HintRevealedAt:   optStamp(sp.HintRevealedAt),
+		AnsweredWithHint: sp.AnsweredWithHint,
+	}
+}
+
+func optStamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatStamp(*t)
+}
+
 func toModelSP(dsp dynamoSP) model.SessionProblem {
 	return model.SessionProblem{
 		ID:               dsp.ID,
@@ -119,9 +154,22 @@ func toModelSP(dsp dynamoSP) model.SessionProblem {
 		CategoryName:     dsp.CategoryName,
 		SelectedChoiceID: dsp.SelectedChoiceID,
 		IsCorrect:        dsp.IsCorrect,
+		Version:          dsp.Version,
+		FirstViewedAt:    parseStamp(dsp.FirstViewedAt),
+		AnsweredAt:       parseStamp(dsp.AnsweredAt),
+		HintRevealedAt:   parseStamp(dsp.HintRevealedAt),
+		AnsweredWithHint: dsp.AnsweredWithHint,
 	}
 }
 
+func toModelSPs(dsps []dynamoSP) []model.SessionProblem {
+	result := make([]model.SessionProblem, len(dsps))
+	for i, dsp := range dsps {
+		result[i] = toModelSP(dsp)
+	}
+	return result
+}
+
 func (r *Repository) FindSessionProblemByIdx(sessionID uint64, idx int) (*model.SessionProblem, error) {
 	sps, err := r.querySessionProblems(sessionID)
 	if err != nil {
@@ -164,104 +212,188 @@ func (r *Repository) FindSessionProblemsBySessionID(sessionID uint64) ([]model.S
 	if err != nil {
 		return nil, err
 	}
-	result := make([]model.SessionProblem, len(sps))
-	for i, dsp := range sps {
-		result[i] = toModelSP(dsp)
-	}
-	return result, nil
+	return toModelSPs(sps), nil
 }
 
-func (r *Repository) SaveSessionProblem(sp *model.SessionProblem) error {
-	dsp := dynamoSP{
-		PK:               fmt.Sprintf("SESSION#%d", sp.TestSessionID),
-		SK:               fmt.Sprintf("SP#%d", sp.ID),
-		ID:               sp.ID,
-		SessionID:        sp.TestSessionID,
-		ProblemID:        sp.ProblemID,
-		CategoryID:       sp.CategoryID,
-		CategoryName:     sp.CategoryName,
-		SelectedChoiceID: sp.SelectedChoiceID,
-		IsCorrect:        sp.IsCorrect,
+// UpdateSessionProblemAnswer は回答 (selected_choice_id / is_correct) だけを更新し version を1進める。
+// answered_at は初回回答時のみ記録し、回答を変更しても上書きしない。
+// 同じトランザクションで回答イベント (EVENT#) を追記し、カテゴリ別累計に delta を、セッションの集計に summary を加え、
+// 所属クラスの分析の版を進めるため、
+// 履歴・累計・集計と最新の回答は常に一致する。version の条件により同じ回答の再送で累計が二重に加算されることはない。
+// 保存済みの version が expectedVersion と異なる場合は何も書き込まず、
+// 現在の SP を Current に持つ *apperr.ConflictError を返す。セッションが終了済みの場合は apperr.ErrSessionFinished。
+func (r *Repository) UpdateSessionProblemAnswer(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+	values := map[string]types.AttributeValue{
+		":next": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion+1, 10)},
+		":at":   &types.AttributeValueMemberS{Value: formatStamp(event.AnsweredAt)},
 	}
-	item, err := attributevalue.MarshalMap(dsp)
-	if err != nil {
-		return err
+	for name, v := range map[string]any{":choice": sp.SelectedChoiceID, ":correct": sp.IsCorrect, ":hint": sp.AnsweredWithHint} {
+		av, err := attributevalue.Marshal(v)
+		if err != nil {
+			return err
+		}
+		values[name] = av
 	}
-	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
-		TableName: aws.String(tableName()),
-		Item:      item,
-	})
-	return err
-}
 
-func (r *Repository) SaveSessionProblems(sps []model.SessionProblem) error {
-	if len(sps) == 0 {
-		return nil
+	// version を持たない旧データは version 0 とみなす
+	condition := "attribute_exists(pk) AND attribute_not_exists(version)"
+	if expectedVersion > 0 {
+		condition = "version = :expected"
+		values[":expected"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)}
 	}
 
-	// BatchGetItem で問題のカテゴリ情報を一括取得
-	keys := make([]map[string]types.AttributeValue, len(sps))
-	for i, sp := range sps {
-		keys[i] = map[string]types.AttributeValue{
-			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("PROBLEM#%d", sp.ProblemID)},
+	update := types.TransactWriteItem{Update: &types.Update{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sp.TestSessionID)},
+			"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SP#%d", sp.ID)},
+		},
+		UpdateExpression: aws.String("SET selected_choice_id = :choice, is_correct = :correct, answered_with_hint = :hint, " +
+			"version = :next, answered_at = if_not_exists(answered_at, :at)"),
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}}
+
+	// セッションの終了の確認は集計の更新の条件で兼ねる。集計を持たない旧データは終了の確認だけを行う
+	session := sessionSummaryUpdate(sp.TestSessionID, summary)
+	notFinished := types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sp.TestSessionID)},
 			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
-		}
-	}
-	batchOut, err := r.client.BatchGetItem(bg(), &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]types.KeysAndAttributes{
-			tableName(): {Keys: keys},
 		},
-	})
+		ConditionExpression:                 aws.String("attribute_not_exists(finished_at)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}}
+	stat := categoryStatUpdate(delta)
+	bumps, err := r.classAnalyticsBumps(delta.UserSub)
 	if err != nil {
 		return err
 	}
 
-	// problem_id → category_id のマップを構築
-	catIDMap := make(map[uint64]int)
-	for _, item := range batchOut.Responses[tableName()] {
-		var dp dynamoProblem
-		if err := attributevalue.UnmarshalMap(item, &dp); err != nil {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		event.ID = r.ids.NextID()
+		put, err := conditionalPut(newDynamoAnswerEvent(*event))
+		if err != nil {
 			return err
 		}
-		catIDMap[dp.ID] = dp.CategoryID
-	}
 
-	// ID を採番して書き込みリクエストを生成
-	base := uint64(time.Now().UnixNano())
-	requests := make([]types.WriteRequest, len(sps))
-	for i := range sps {
-		sps[i].ID = base + uint64(i)
-		catID := catIDMap[sps[i].ProblemID]
-		dsp := dynamoSP{
-			PK:           fmt.Sprintf("SESSION#%d", sps[i].TestSessionID),
-			SK:           fmt.Sprintf("SP#%d", sps[i].ID),
-			ID:           sps[i].ID,
-			SessionID:    sps[i].TestSessionID,
-			ProblemID:    sps[i].ProblemID,
-			CategoryID:   catID,
-			CategoryName: catNames[catID],
+		items := append([]types.TransactWriteItem{update, put, session, stat}, bumps...)
+		_, err = r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) == len(items) {
+			// [0] が SP の version 条件、[1] がイベント ID の重複、[2] がセッションの終了 (または旧データの集計)
+			if reason := tce.CancellationReasons[0]; reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				if reason.Item == nil {
+					return apperr.ErrNotFound
+				}
+				var current dynamoSP
+				if err := attributevalue.UnmarshalMap(reason.Item, &current); err != nil {
+					return err
+				}
+				return &apperr.ConflictError{Current: toModelSP(current)}
+			}
+			if reason := tce.CancellationReasons[2]; reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				if _, ok := reason.Item["finished_at"]; ok || reason.Item == nil {
+					return apperr.ErrSessionFinished
+				}
+				session = notFinished
+				continue
+			}
+			if isTxConditionFailed(err) {
+				continue
+			}
 		}
-		item, err := attributevalue.MarshalMap(dsp)
 		if err != nil {
 			return err
 		}
-		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+
+		sp.Version = expectedVersion + 1
+		if sp.AnsweredAt == nil {
+			at := event.AnsweredAt
+			sp.AnsweredAt = &at
+		}
+		return nil
 	}
+	return fmt.Errorf("answer event id collision: gave up after %d attempts", maxIDAttempts)
+}
 
-	// DynamoDB は BatchWriteItem 1回あたり最大 25 件
-	for i := 0; i < len(requests); i += 25 {
-		end := i + 25
-		if end > len(requests) {
-			end = len(requests)
+// putSessionProblemChunk は chunk に ID を採番し、既存アイテムを上書きしない条件付きで書き込む。
+// ID が衝突した場合は chunk 全体を再採番して再試行する。採番は単調増加なので出題順は保たれる。
+func (r *Repository) putSessionProblemChunk(chunk []model.SessionProblem) error {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		items := make([]types.TransactWriteItem, len(chunk))
+		for i := range chunk {
+			chunk[i].ID = r.ids.NextID()
+			put, err := conditionalPut(newDynamoSP(chunk[i]))
+			if err != nil {
+				return err
+			}
+			items[i] = put
 		}
-		_, err := r.client.BatchWriteItem(bg(), &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]types.WriteRequest{
-				tableName(): requests[i:end],
-			},
+
+		_, err := r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
 		})
-		if err != nil {
-			return err
+		if isTxConditionFailed(err) {
+			continue
 		}
+		return err
 	}
+	return fmt.Errorf("session problem id collision: gave up after %d attempts", maxIDAttempts)
+}
+
+// MarkSessionProblemViewed は初回表示時刻 (first_viewed_at) を記録する。
+// 既に記録済みの場合は上書きせず、sp には保存されている時刻を反映する。
+func (r *Repository) MarkSessionProblemViewed(sp *model.SessionProblem, at time.Time) error {
+	stamp, err := r.stampOnce(sp, "first_viewed_at", at)
+	if err != nil {
+		return err
+	}
+	sp.FirstViewedAt = stamp
 	return nil
 }
+
+// MarkHintRevealed はヒントの初回表示時刻 (hint_revealed_at) を記録する。
+func (r *Repository) MarkHintRevealed(sp *model.SessionProblem, at time.Time) error {
+	stamp, err := r.stampOnce(sp, "hint_revealed_at", at)
+	if err != nil {
+		return err
+	}
+	sp.HintRevealedAt = stamp
+	return nil
+}
+
+// stampOnce は SP の時刻属性 attr が未設定の場合のみ at を書き込み、保存されている時刻を返す。
+func (r *Repository) stampOnce(sp *model.SessionProblem, attr string, at time.Time) (*time.Time, error) {
+	out, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sp.TestSessionID)},
+			"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SP#%d", sp.ID)},
+		},
+		UpdateExpression:         aws.String("SET #attr = if_not_exists(#attr, :at)"),
+		ConditionExpression:      aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames: map[string]string{"#attr": attr},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at": &types.AttributeValueMemberS{Value: formatStamp(at)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if isConditionFailed(err) {
+		return nil, apperr.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var updated map[string]string
+	if err := attributevalue.UnmarshalMap(out.Attributes, &updated); err != nil {
+		return nil, err
+	}
+	return parseStamp(updated[attr]), nil
+}
@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,14 +18,137 @@ import (
 )
 
 type dynamoSession struct {
-	PK              string `dynamodbav:"pk"`
-	SK              string `dynamodbav:"sk"`
-	GSI1PK          string `dynamodbav:"gsi1pk"`
-	GSI1SK          string `dynamodbav:"gsi1sk"`
-	ID              uint64 `dynamodbav:"id"`
-	OwnerID         string `dynamodbav:"owner_id"` // Cognito sub
-	IncludeIntegers bool   `dynamodbav:"include_integers"`
-	StartTime       string `dynamodbav:"start_time"`
+	PK              string         `dynamodbav:"pk"`
+	SK              string         `dynamodbav:"sk"`
+	GSI1PK          string         `dynamodbav:"gsi1pk"`
+	GSI1SK          string         `dynamodbav:"gsi1sk"`
+	ID              uint64         `dynamodbav:"id"`
+	OwnerID         string         `dynamodbav:"owner_id"` // Cognito sub
+	IncludeIntegers bool           `dynamodbav:"include_integers"`
+	ExamMode        bool           `dynamodbav:"exam_mode,omitempty"`
+	StartTime       string         `dynamodbav:"start_time"`
+	Status          string         `dynamodbav:"status,omitempty"`      // 旧データは属性なし (= ready)
+	FinishedAt      string         `dynamodbav:"finished_at,omitempty"` // stampLayout
+	Summary         *dynamoSummary `dynamodbav:"summary,omitempty"`
+	AssignmentID    uint64         `dynamodbav:"assignment_id,omitempty"`
+	Attempt         int            `dynamodbav:"attempt,omitempty"`
+	ClosesAt        string         `dynamodbav:"closes_at,omitempty"` // stampLayout
+	TimeLimitSec    int64          `dynamodbav:"time_limit_sec,omitempty"`
+}
+
+// dynamoSummary はセッションアイテムに保持する集計値。
+// マイページで SP を都度 Query しないために回答のたびに更新する。
+// timed_count / time_spent_ms は所要時間を計測できた (初回表示と初回回答が揃った) SP のみを数える。
+// hinted_count / hinted_correct_count はヒント表示後に回答した SP の数とそのうちの正答数。
+// answered_count の無い旧データは SP から数え直す。
+type dynamoSummary struct {
+	Total              int                     `dynamodbav:"total"`
+	AnsweredCount      *int                    `dynamodbav:"answered_count"`
+	CorrectCount       int                     `dynamodbav:"correct_count"`
+	HintedCount        int                     `dynamodbav:"hinted_count,omitempty"`
+	HintedCorrectCount int                     `dynamodbav:"hinted_correct_count,omitempty"`
+	TimedCount         int                     `dynamodbav:"timed_count,omitempty"`
+	TimeSpentMs        int64                   `dynamodbav:"time_spent_ms,omitempty"`
+	Categories         []dynamoCategorySummary `dynamodbav:"categories"`
+	Problems           []dynamoProblemTime     `dynamodbav:"problems,omitempty"`
+}
+
+type dynamoCategorySummary struct {
+	Name               string `dynamodbav:"name"`
+	Total              int    `dynamodbav:"total"`
+	CorrectCount       int    `dynamodbav:"correct_count"`
+	HintedCount        int    `dynamodbav:"hinted_count,omitempty"`
+	HintedCorrectCount int    `dynamodbav:"hinted_correct_count,omitempty"`
+	TimedCount         int    `dynamodbav:"timed_count,omitempty"`
+	TimeSpentMs        int64  `dynamodbav:"time_spent_ms,omitempty"`
+}
+
+// dynamoProblemTime は計測できた1問分の所要時間。idx は出題順 (0始まり)。
+type dynamoProblemTime struct {
+	Idx          int    `dynamodbav:"idx"`
+	CategoryName string `dynamodbav:"category_name"`
+	TimeSpentMs  int64  `dynamodbav:"time_spent_ms"`
+}
+
+// summarize は SP 一覧からセッションの集計値を作る。カテゴリは初出順。
+func summarize(sps []model.SessionProblem) dynamoSummary {
+	var s dynamoSummary
+	answered := 0
+	s.AnsweredCount = &answered
+	idx := make(map[string]int)
+	for n, sp := range sps {
+		i, exists := idx[sp.CategoryName]
+		if !exists {
+			i = len(s.Categories)
+			idx[sp.CategoryName] = i
+			s.Categories = append(s.Categories, dynamoCategorySummary{Name: sp.CategoryName})
+		}
+		s.Total++
+		s.Categories[i].Total++
+		if sp.SelectedChoiceID != nil {
+			answered++
+		}
+		correct := sp.IsCorrect != nil && *sp.IsCorrect
+		if correct {
+			s.CorrectCount++
+			s.Categories[i].CorrectCount++
+		}
+		if sp.SelectedChoiceID != nil && sp.AnsweredWithHint {
+			s.HintedCount++
+			s.Categories[i].HintedCount++
+			if correct {
+				s.HintedCorrectCount++
+				s.Categories[i].HintedCorrectCount++
+			}
+		}
+		if d, ok := sp.TimeSpent(); ok {
+			ms := d.Milliseconds()
+			s.TimedCount++
+			s.TimeSpentMs += ms
+			s.Categories[i].TimedCount++
+			s.Categories[i].TimeSpentMs += ms
+			s.Problems = append(s.Problems, dynamoProblemTime{Idx: n, CategoryName: sp.CategoryName, TimeSpentMs: ms})
+		}
+	}
+	return s
+}
+
+func (s dynamoSummary) toSessionSummary(sessionID uint64, startTime time.Time) SessionSummary {
+	categories := make([]CategoryStats, len(s.Categories))
+	for i, c := range s.Categories {
+		categories[i] = CategoryStats{
+			Name:               c.Name,
+			TotalCount:         c.Total,
+			CorrectCount:       c.CorrectCount,
+			HintedCount:        c.HintedCount,
+			HintedCorrectCount: c.HintedCorrectCount,
+			TimedCount:         c.TimedCount,
+			TimeSpent:          time.Duration(c.TimeSpentMs) * time.Millisecond,
+		}
+	}
+	problems := make([]ProblemTime, len(s.Problems))
+	for i, p := range s.Problems {
+		problems[i] = ProblemTime{
+			Idx:          p.Idx,
+			CategoryName: p.CategoryName,
+			TimeSpent:    time.Duration(p.TimeSpentMs) * time.Millisecond,
+		}
+	}
+	// 回答のたびに追記するため回答順になっている
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Idx < problems[j].Idx })
+	return SessionSummary{
+		SessionID:          sessionID,
+		StartTime:          startTime,
+		Total:              s.Total,
+		Answered:           *s.AnsweredCount,
+		CorrectCount:       s.CorrectCount,
+		HintedCount:        s.HintedCount,
+		HintedCorrectCount: s.HintedCorrectCount,
+		TimedCount:         s.TimedCount,
+		TimeSpent:          time.Duration(s.TimeSpentMs) * time.Millisecond,
+		Categories:         categories,
+		Problems:           problems,
+	}
 }
 
 func (r *Repository) FindTestSession(sessionID uint64) (*model.TestSession, error) {
@@ -36,23 +163,35 @@ func (r *Repository) FindTestSession(sessionID uint64) (*model.TestSession, erro
 		return nil, err
 	}
 	if out.Item == nil {
-		return nil, apperr.ErrNotFound
+		return nil, apperr.ErrSessionNotFound
 	}
 	var ds dynamoSession
 	if err := attributevalue.UnmarshalMap(out.Item, &ds); err != nil {
 		return nil, err
 	}
-	return &model.TestSession{
-		ID:     ds.ID,
-		UserID: ds.OwnerID,
-	}, nil
+	session := toModelSession(ds)
+	return &session, nil
 }
 
-func (r *Repository) SaveTestSession(session *model.TestSession) error {
-	session.ID = uint64(time.Now().UnixNano())
-	session.StartTime = time.Now()
+func toModelSession(ds dynamoSession) model.TestSession {
+	startTime, _ := time.Parse(timeLayout, ds.StartTime)
+	return model.TestSession{
+		ID:              ds.ID,
+		UserID:          ds.OwnerID,
+		IncludeIntegers: ds.IncludeIntegers,
+		ExamMode:        ds.ExamMode,
+		StartTime:       startTime,
+		Status:          ds.Status,
+		FinishedAt:      parseStamp(ds.FinishedAt),
+		AssignmentID:    ds.AssignmentID,
+		Attempt:         ds.Attempt,
+		ClosesAt:        parseStamp(ds.ClosesAt),
+		TimeLimit:       time.Duration(ds.TimeLimitSec) * time.Second,
+	}
+}
 
-	ds := dynamoSession{
+func newDynamoSession(session *model.TestSession, summary *dynamoSummary) dynamoSession {
+	return dynamoSession{
 		PK:              fmt.Sprintf("SESSION#%d", session.ID),
 		SK:              "#METADATA",
 		GSI1PK:          fmt.Sprintf("USER#%s", session.UserID),
@@ -60,15 +199,261 @@ func (r *Repository) SaveTestSession(session *model.TestSession) error {
 		ID:              session.ID,
 		OwnerID:         session.UserID,
 		IncludeIntegers: session.IncludeIntegers,
-		StartTime:       session.StartTime.Format("2006-01-02 15:04:05"),
+		ExamMode:        session.ExamMode,
+		StartTime:       session.StartTime.Format(timeLayout),
+		Status:          session.Status,
+		FinishedAt:      optStamp(session.FinishedAt),
+		Summary:         summary,
+		AssignmentID:    session.AssignmentID,
+		Attempt:         session.Attempt,
+		ClosesAt:        optStamp(session.ClosesAt),
+		TimeLimitSec:    int64(session.TimeLimit / time.Second),
+	}
+}
+
+// CreateTestSession はセッションと SP をまとめて作成し、それぞれに ID を採番する。
+// 全アイテムが1トランザクションに収まる場合は ready として原子的に書き込む。
+// 収まらない場合は pending で作成して SP を書き込み、最後に ready へ更新する。
+// pending のまま残ったセッションは読み取り側で無視される。
+func (r *Repository) CreateTestSession(session *model.TestSession, sps []model.SessionProblem) error {
+	session.StartTime = time.Now()
+	for i := range sps {
+		sps[i].CategoryName = catNames[sps[i].CategoryID]
 	}
-	item, err := attributevalue.MarshalMap(ds)
+
+	if len(sps)+1 <= maxTransactItems {
+		return r.createTestSessionTx(session, sps)
+	}
+	return r.createTestSessionStaged(session, sps)
+}
+
+// createTestSessionTx はセッションと全 SP を1回の TransactWriteItems で書き込む。
+// ID が衝突した場合は全体を再採番して再試行する。
+func (r *Repository) createTestSessionTx(session *model.TestSession, sps []model.SessionProblem) error {
+	session.Status = model.SessionStatusReady
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		session.ID = r.ids.NextID()
+		for i := range sps {
+			sps[i].TestSessionID = session.ID
+			sps[i].ID = r.ids.NextID()
+		}
+		summary := summarize(sps)
+
+		items := make([]types.TransactWriteItem, 0, len(sps)+1)
+		put, err := conditionalPut(newDynamoSession(session, &summary))
+		if err != nil {
+			return err
+		}
+		items = append(items, put)
+		for _, sp := range sps {
+			put, err := conditionalPut(newDynamoSP(sp))
+			if err != nil {
+				return err
+			}
+			items = append(items, put)
+		}
+
+		_, err = r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if isTxConditionFailed(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("session id collision: gave up after %d attempts", maxIDAttempts)
+}
+
+// createTestSessionStaged は pending → SP 書き込み → ready の順で作成する。
+// 途中で失敗した場合は書き込み済みのアイテムを削除する。
+func (r *Repository) createTestSessionStaged(session *model.TestSession, sps []model.SessionProblem) error {
+	session.Status = model.SessionStatusPending
+	if err := r.putTestSession(session); err != nil {
+		return err
+	}
+
+	for i := range sps {
+		sps[i].TestSessionID = session.ID
+	}
+	for i := 0; i < len(sps); i += maxTransactItems {
+		end := i + maxTransactItems
+		if end > len(sps) {
+			end = len(sps)
+		}
+		if err := r.putSessionProblemChunk(sps[i:end]); err != nil {
+			return r.deleteSessionItems(session.ID, err)
+		}
+	}
+
+	summary, err := attributevalue.Marshal(summarize(sps))
 	if err != nil {
+		return r.deleteSessionItems(session.ID, err)
+	}
+	_, err = r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", session.ID)},
+			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+		},
+		UpdateExpression:         aws.String("SET #status = :ready, summary = :summary"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ready":   &types.AttributeValueMemberS{Value: model.SessionStatusReady},
+			":summary": summary,
+		},
+	})
+	if err != nil {
+		return r.deleteSessionItems(session.ID, err)
+	}
+	session.Status = model.SessionStatusReady
+	return nil
+}
+
+// putTestSession は ID を採番してセッションを保存する。
+// 他の実行環境と ID が衝突した場合は上書きせず、再採番して再試行する。
+func (r *Repository) putTestSession(session *model.TestSession) error {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		session.ID = r.ids.NextID()
+
+		item, err := attributevalue.MarshalMap(newDynamoSession(session, nil))
+		if err != nil {
+			return err
+		}
+		_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+			TableName:           aws.String(tableName()),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		})
+		if isConditionFailed(err) {
+			continue
+		}
 		return err
 	}
-	_, err = r.client.PutItem(bg(), &dynamodb.PutItemInput{
+	return fmt.Errorf("session id collision: gave up after %d attempts", maxIDAttempts)
+}
+
+// deleteSessionItems は作成に失敗したセッションの書き込み済みアイテムを削除する。
+// 削除にも失敗した場合は元のエラー cause と合わせて返す。残ったアイテムは pending のため読み取り側で無視される。
+func (r *Repository) deleteSessionItems(sessionID uint64, cause error) error {
+	if _, err := r.PurgeSession(sessionID); err != nil {
+		return errors.Join(cause, fmt.Errorf("clean up session %d: %w", sessionID, err))
+	}
+	return cause
+}
+
+// sessionSummaryUpdate は回答と同じトランザクションでセッションの summary に d を加える更新を作る。
+// 読み取った集計で上書きせず ADD で加算するため、並行する回答の更新を失わない。
+// answered_count を持たない旧データは条件を満たさない (集計は読み取り時に SP から数え直す)。
+func sessionSummaryUpdate(sessionID uint64, d model.SessionSummaryDelta) types.TransactWriteItem {
+	cat := fmt.Sprintf("summary.categories[%d]", d.CategoryIdx)
+	adds := []string{
+		"summary.answered_count :answered",
+		"summary.correct_count :correct",
+		"summary.hinted_count :hinted",
+		"summary.hinted_correct_count :hinted_correct",
+		cat + ".correct_count :correct",
+		cat + ".hinted_count :hinted",
+		cat + ".hinted_correct_count :hinted_correct",
+	}
+	values := map[string]types.AttributeValue{
+		":answered":       &types.AttributeValueMemberN{Value: strconv.Itoa(d.Answered)},
+		":correct":        &types.AttributeValueMemberN{Value: strconv.Itoa(d.Correct)},
+		":hinted":         &types.AttributeValueMemberN{Value: strconv.Itoa(d.Hinted)},
+		":hinted_correct": &types.AttributeValueMemberN{Value: strconv.Itoa(d.HintedCorrect)},
+	}
+	expr := ""
+	if d.Timed {
+		ms := d.TimeSpent.Milliseconds()
+		adds = append(adds,
+			"summary.timed_count :one",
+			"summary.time_spent_ms :ms",
+			cat+".timed_count :one",
+			cat+".time_spent_ms :ms",
+		)
+		values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+		values[":ms"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(ms, 10)}
+		values[":empty"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+		values[":problem"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"idx":           &types.AttributeValueMemberN{Value: strconv.Itoa(d.ProblemIdx)},
+				"category_name": &types.AttributeValueMemberS{Value: d.CategoryName},
+				"time_spent_ms": &types.AttributeValueMemberN{Value: strconv.FormatInt(ms, 10)},
+			}},
+		}}
+		expr = "SET summary.problems = list_append(if_not_exists(summary.problems, :empty), :problem) "
+	}
+
+	return types.TransactWriteItem{Update: &types.Update{
 		TableName: aws.String(tableName()),
-		Item:      item,
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sessionID)},
+			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+		},
+		UpdateExpression:                    aws.String(expr + "ADD " + strings.Join(adds, ", ")),
+		ConditionExpression:                 aws.String("attribute_not_exists(finished_at) AND attribute_exists(summary.answered_count)"),
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}}
+}
+
+// FinishTestSession はセッションに finished_at を記録し、未回答の SP の分だけカテゴリ別累計を更新する。
+// 終了は分析の得点に入るため、所属クラスの分析の版も同じトランザクションで進める。
+// 未回答だった SP が並行して回答された場合は何も書き込まず apperr.ErrConflict を、
+// 既に終了済みの場合は apperr.ErrSessionFinished を返す。
+func (r *Repository) FinishTestSession(session *model.TestSession, unanswered []model.SessionProblem, deltas []model.CategoryStatDelta, at time.Time) error {
+	bumps, err := r.classAnalyticsBumps(session.UserID)
+	if err != nil {
+		return err
+	}
+	if 1+len(unanswered)+len(deltas)+len(bumps) > maxTransactItems {
+		return fmt.Errorf("finish session: too many items (%d unanswered)", len(unanswered))
+	}
+
+	items := make([]types.TransactWriteItem, 0, 1+len(unanswered)+len(deltas)+len(bumps))
+	items = append(items, types.TransactWriteItem{Update: &types.Update{
+		TableName: aws.String(tableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", session.ID)},
+			"sk": &types.AttributeValueMemberS{Value: "#METADATA"},
+		},
+		UpdateExpression:    aws.String("SET finished_at = :at"),
+		ConditionExpression: aws.String("attribute_exists(pk) AND attribute_not_exists(finished_at)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at": &types.AttributeValueMemberS{Value: formatStamp(at)},
+		},
+	}})
+	for _, sp := range unanswered {
+		items = append(items, types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+			TableName: aws.String(tableName()),
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SESSION#%d", sp.TestSessionID)},
+				"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("SP#%d", sp.ID)},
+			},
+			ConditionExpression: aws.String("attribute_exists(pk) AND attribute_not_exists(selected_choice_id)"),
+		}})
+	}
+	for _, d := range deltas {
+		items = append(items, categoryStatUpdate(d))
+	}
+	items = append(items, bumps...)
+
+	_, err = r.client.TransactWriteItems(bg(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
 	})
-	return err
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 {
+		if reason := tce.CancellationReasons[0]; reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+			return apperr.ErrSessionFinished
+		}
+		if isTxConditionFailed(err) {
+			return apperr.ErrConflict
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	session.FinishedAt = &at
+	return nil
 }
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// dynamoUserProfile はプロフィール (pk=USER#<sub>, sk=PROFILE)。
+type dynamoUserProfile struct {
+	PK                     string `dynamodbav:"pk"`
+	SK                     string `dynamodbav:"sk"`
+	UserSub                string `dynamodbav:"user_sub"`
+	DisplayName            string `dynamodbav:"display_name"`
+	Grade                  string `dynamodbav:"grade,omitempty"`
+	TargetExam             string `dynamodbav:"target_exam,omitempty"`
+	PreferredCategories    []int  `dynamodbav:"preferred_categories,omitempty,numberset"`
+	Timezone               string `dynamodbav:"timezone"`
+	DefaultIncludeIntegers bool   `dynamodbav:"default_include_integers"`
+	DefaultExamMode        bool   `dynamodbav:"default_exam_mode"`
+	CreatedAt              string `dynamodbav:"created_at"` // stampLayout
+	UpdatedAt              string `dynamodbav:"updated_at"` // stampLayout
+}
+
+func userProfileKey(userSub string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
+		"sk": &types.AttributeValueMemberS{Value: "PROFILE"},
+	}
+}
+
+// FindUserProfile はプロフィールを返す。保存されていなければ apperr.ErrNotFound。
+func (r *Repository) FindUserProfile(userSub string) (*model.UserProfile, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       userProfileKey(userSub),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, apperr.ErrNotFound
+	}
+	var d dynamoUserProfile
+	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+		return nil, err
+	}
+	p := &model.UserProfile{
+		Sub:                    d.UserSub,
+		DisplayName:            d.DisplayName,
+		Grade:                  d.Grade,
+		TargetExam:             d.TargetExam,
+		PreferredCategories:    d.PreferredCategories,
+		Timezone:               d.Timezone,
+		DefaultIncludeIntegers: d.DefaultIncludeIntegers,
+		DefaultExamMode:        d.DefaultExamMode,
+	}
+	if t := parseStamp(d.CreatedAt); t != nil {
+		p.CreatedAt = *t
+	}
+	if t := parseStamp(d.UpdatedAt); t != nil {
+		p.UpdatedAt = *t
+	}
+	return p, nil
+}
+
+// SaveUserProfile はプロフィールを保存する。作成日時は最初に保存したときの値を保ち、p.CreatedAt に入れて返す。
+func (r *Repository) SaveUserProfile(p *model.UserProfile, at time.Time) error {
+	values := map[string]types.AttributeValue{
+		":sub":      &types.AttributeValueMemberS{Value: p.Sub},
+		":name":     &types.AttributeValueMemberS{Value: p.DisplayName},
+		":grade":    &types.AttributeValueMemberS{Value: p.Grade},
+		":exam":     &types.AttributeValueMemberS{Value: p.TargetExam},
+		":tz":       &types.AttributeValueMemberS{Value: p.Timezone},
+		":integers": &types.AttributeValueMemberBOOL{Value: p.DefaultIncludeIntegers},
+		":exammode": &types.AttributeValueMemberBOOL{Value: p.DefaultExamMode},
+		":at":       &types.AttributeValueMemberS{Value: formatStamp(at)},
+	}
+	update := "SET user_sub = :sub, display_name = :name, grade = :grade, target_exam = :exam, timezone = :tz, " +
+		"default_include_integers = :integers, default_exam_mode = :exammode, updated_at = :at, created_at = if_not_exists(created_at, :at)"
+	// 空の Number Set は保存できないため、カテゴリが無い場合は属性を消す
+	if len(p.PreferredCategories) > 0 {
+		ns := &types.AttributeValueMemberNS{}
+		for _, id := range p.PreferredCategories {
+			ns.Value = append(ns.Value, strconv.Itoa(id))
+		}
+		values[":cats"] = ns
+		update += ", preferred_categories = :cats"
+	} else {
+		update += " REMOVE preferred_categories"
+	}
+
+	out, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName()),
+		Key:                       userProfileKey(p.Sub),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		return err
+	}
+	var d dynamoUserProfile
+	if err := attributevalue.UnmarshalMap(out.Attributes, &d); err != nil {
+		return err
+	}
+	if t := parseStamp(d.CreatedAt); t != nil {
+		p.CreatedAt = *t
+	}
+	p.UpdatedAt = at
+	return nil
+}
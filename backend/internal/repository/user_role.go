@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoUserRoles は管理者が付与した役割 (pk=USER#<sub>, sk=ROLES)。生徒の役割は保存しない。
+type dynamoUserRoles struct {
+	Roles     []string `dynamodbav:"roles,stringset"`
+	UpdatedAt string   `dynamodbav:"updated_at"` // stampLayout
+}
+
+func userRolesKey(userSub string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("USER#%s", userSub)},
+		"sk": &types.AttributeValueMemberS{Value: "ROLES"},
+	}
+}
+
+// FindUserRoles は保存された役割を返す。無ければ空。
+func (r *Repository) FindUserRoles(userSub string) ([]string, error) {
+	out, err := r.client.GetItem(bg(), &dynamodb.GetItemInput{
+		TableName: aws.String(tableName()),
+		Key:       userRolesKey(userSub),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+	var d dynamoUserRoles
+	if err := attributevalue.UnmarshalMap(out.Item, &d); err != nil {
+		return nil, err
+	}
+	return d.Roles, nil
+}
+
+// AddUserRole は役割を追加する。既に持っていても成功する。
+func (r *Repository) AddUserRole(userSub, role string, at time.Time) error {
+	return r.updateUserRoles(userSub, "ADD", role, at)
+}
+
+// RemoveUserRole は役割を取り除く。持っていなくても成功する。
+func (r *Repository) RemoveUserRole(userSub, role string, at time.Time) error {
+	return r.updateUserRoles(userSub, "DELETE", role, at)
+}
+
+// updateUserRoles は String Set に ADD / DELETE する。並行した付与・剥奪が互いを上書きしない。
+func (r *Repository) updateUserRoles(userSub, action, role string, at time.Time) error {
+	_, err := r.client.UpdateItem(bg(), &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName()),
+		Key:              userRolesKey(userSub),
+		UpdateExpression: aws.String(fmt.Sprintf("SET updated_at = :at %s roles :role", action)),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at":   &types.AttributeValueMemberS{Value: formatStamp(at)},
+			":role": &types.AttributeValueMemberSS{Value: []string{role}},
+		},
+	})
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/Kyouheip/MathOvercome_serverless/internal/handler"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/middleware"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
@@ -15,34 +16,140 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
-func New(client *dynamodb.Client) *gin.Engine {
+// New はルーターを作る。利用者は検証した資格情報からのみ特定する。
+// Authorization: Bearer の値が API キー (mok_...) ならテーブルのキーで、それ以外は tokens (JWT) で検証する。
+// ADMIN_USER_SUBS / TEACHER_USER_SUBS (カンマ区切りの Cognito sub) には管理者・教師の役割を固定で割り当てる。
+func New(client *dynamodb.Client, tokens identity.Authenticator) *gin.Engine {
 	repo := repository.NewRepository(client)
-	testSessSvc := service.NewTestSessionService(repo)
-	mypageSvc := service.NewMypageService(repo)
-	sessionHandler := handler.NewSessionHandler(testSessSvc, mypageSvc)
+	roles := service.NewRoleService(repo).WithBootstrapFromEnv()
+	return newEngine(repo, identity.Select(service.NewAPIKeyService(repo), tokens), roles)
+}
+
+func newEngine(repo *repository.Repository, authn identity.Authenticator, roles service.RoleServicer) *gin.Engine {
+	policy := service.NewAccessPolicy(repo)
+	testSessSvc := service.NewTestSessionService(repo).WithAccessPolicy(policy)
+	mypageSvc := service.NewMypageService(repo).WithAccessPolicy(policy)
+	profileSvc := service.NewProfileService(repo)
+	sessionHandler := handler.NewSessionHandler(testSessSvc, mypageSvc, profileSvc)
+	classroomHandler := handler.NewClassroomHandler(service.NewClassroomService(repo), mypageSvc)
+	assignmentHandler := handler.NewAssignmentHandler(service.NewAssignmentService(repo))
+	analyticsHandler := handler.NewClassAnalyticsHandler(service.NewClassAnalyticsService(repo))
+	adminHandler := handler.NewAdminHandler(service.NewItemAnalysisService(repo))
+	apiKeyHandler := handler.NewAPIKeyHandler(service.NewAPIKeyService(repo))
+	roleHandler := handler.NewRoleHandler(roles)
+	profileHandler := handler.NewProfileHandler(profileSvc)
+	accountHandler := handler.NewAccountHandler(service.NewAccountService(repo, roles))
 
 	r := gin.Default()
 
 	if os.Getenv("APP_ENV") == "local" {
 		allowOrigin := os.Getenv("ALLOW_ORIGIN")
-		r.Use(middleware.LocalAuthMiddleware())
 		r.Use(cors.New(cors.Config{
 			AllowOrigins:     []string{allowOrigin},
-			AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 			AllowCredentials: true,
 			MaxAge:           12 * time.Hour,
 		}))
 	}
+	// エラーは c.Error で渡し、ここでまとめて problem+json にする
+	r.Use(middleware.Errors())
+	// API の文書は資格情報を検証せずに返す
+	r.GET("/openapi.json", handler.OpenAPISpec)
+	r.Use(middleware.Authenticate(authn))
+
+	// 未ログインは 401、役割や API キーの権限 (scope) が足りなければ 403。
+	// ログイン (JWT) した利用者はすべての権限を持ち、役割はトークンのクレームと保存された役割で決まる
+	login := middleware.RequireLogin()
+	teacher := middleware.RequireRole(roles, identity.RoleTeacher)
+	admin := middleware.RequireRole(roles, identity.RoleAdmin)
+	read := middleware.RequireScope(identity.ScopeReadResults)
+	take := middleware.RequireScope(identity.ScopeTakeTests)
+	manage := middleware.RequireScope(identity.ScopeAdmin)
+
+	v1 := r.Group("/v1")
+	{
+		sessions := v1.Group("/sessions", login)
+		{
+			sessions.POST("", take, sessionHandler.CreateTestSess)
+			sessions.GET("", read, sessionHandler.ListSessions)
+			sessions.GET("/:sessionId", read, sessionHandler.GetSession)
+			sessions.GET("/:sessionId/problems/:idx", take, sessionHandler.ViewOneProblem)
+			sessions.POST("/:sessionId/problems/:idx/hint", take, sessionHandler.RevealHint)
+			sessions.PUT("/:sessionId/problems/:idx/answer", take, sessionHandler.SubmitAnswer)
+			sessions.POST("/:sessionId/finish", take, sessionHandler.FinishSession)
+			sessions.GET("/:sessionId/history", read, sessionHandler.GetAnswerHistory)
+		}
+
+		// クラスの閲覧・参加は全員。作成と管理は教師の役割が必要で、どのクラスの教師かはサービス側で判定する
+		classes := v1.Group("/classes", login)
+		{
+			classes.POST("", manage, teacher, classroomHandler.CreateClassroom)
+			classes.GET("", read, classroomHandler.ListClassrooms)
+			classes.POST("/join", take, classroomHandler.JoinClassroom)
+			classes.GET("/:classId", read, classroomHandler.GetClassroom)
+			classes.GET("/:classId/analytics", read, analyticsHandler.GetClassAnalytics)
+			classes.DELETE("/:classId/students/:studentSub", manage, teacher, classroomHandler.RemoveStudent)
+			classes.GET("/:classId/students/:studentSub/mypage", read, classroomHandler.GetStudentMypage)
+			classes.GET("/:classId/students/:studentSub/summary", read, classroomHandler.GetStudentSummary)
+			classes.GET("/:classId/students/:studentSub/categories", read, classroomHandler.GetStudentCategories)
+			classes.POST("/:classId/assignments", manage, teacher, assignmentHandler.CreateAssignment)
+			classes.GET("/:classId/assignments", read, assignmentHandler.ListAssignments)
+			classes.GET("/:classId/assignments/:assignmentId/status", read, assignmentHandler.GetAssignmentStatus)
+			classes.POST("/:classId/assignments/:assignmentId/attempts", take, assignmentHandler.StartAttempt)
+		}
 
+		me := v1.Group("/me", login)
+		{
+			me.GET("", read, profileHandler.GetProfile)
+			me.PUT("", take, profileHandler.UpdateProfile)
+			me.GET("/roles", roleHandler.GetMyRoles)
+			me.GET("/archive", read, accountHandler.GetMyArchive)
+			me.GET("/mypage", read, sessionHandler.GetMypage)
+			me.GET("/mypage/summary", read, sessionHandler.GetMypageSummary)
+			me.GET("/mypage/trends", read, sessionHandler.GetMypageTrends)
+			me.GET("/mypage/categories", read, sessionHandler.GetMypageCategories)
+			me.GET("/export", read, sessionHandler.Export)
+		}
 
+		// キーの管理はログインした利用者のみ (API キーでの呼び出しはサービスが 403 にする)
+		keys := v1.Group("/api-keys", login)
+		{
+			keys.POST("", apiKeyHandler.CreateAPIKey)
+			keys.GET("", apiKeyHandler.ListAPIKeys)
+			keys.DELETE("/:keyId", apiKeyHandler.RevokeAPIKey)
+		}
+
+		adminGroup := v1.Group("/admin", login, manage, admin)
+		{
+			adminGroup.GET("/item-analysis", adminHandler.GetItemAnalysis)
+			adminGroup.GET("/users/:userSub/roles", roleHandler.GetUserRoles)
+			adminGroup.PUT("/users/:userSub/roles/:role", roleHandler.GrantRole)
+			adminGroup.DELETE("/users/:userSub/roles/:role", roleHandler.RevokeRole)
+			adminGroup.GET("/users/:userSub/archive", accountHandler.GetUserArchive)
+			adminGroup.POST("/users/:userSub/deletion", accountHandler.DeleteUserData)
+			adminGroup.GET("/users/:userSub/deletion", accountHandler.GetUserDeletion)
+		}
+	}
 
-	sess := r.Group("/session")
+	// 旧ルート (/session/...)。フロントエンドが /v1 に移行するまで同じハンドラーで受け付ける。
+	// /session/current/... はクエリの sessionId をパスパラメータに読み替える
+	sess := r.Group("/session", login)
 	{
-		sess.POST("/test", sessionHandler.CreateTestSess)
-		sess.GET("/current/problems/:idx", sessionHandler.ViewOneProblem)
-		sess.POST("/current/problems/:idx/answer", sessionHandler.SubmitAnswer)
-		sess.GET("/mypage", sessionHandler.GetMypage)
+		sess.POST("/test", take, sessionHandler.CreateTestSess)
+		current := sess.Group("/current", handler.LegacySessionID)
+		{
+			current.GET("/problems/:idx", take, sessionHandler.ViewOneProblem)
+			current.POST("/problems/:idx/hint", take, sessionHandler.RevealHint)
+			current.POST("/problems/:idx/answer", take, sessionHandler.SubmitAnswer)
+			current.POST("/finish", take, sessionHandler.FinishSession)
+			current.GET("/history", read, sessionHandler.GetAnswerHistory)
+		}
+		sess.GET("/mypage", read, sessionHandler.GetMypage)
+		sess.GET("/mypage/summary", read, sessionHandler.GetMypageSummary)
+		sess.GET("/mypage/trends", read, sessionHandler.GetMypageTrends)
+		sess.GET("/mypage/categories", read, sessionHandler.GetMypageCategories)
+		sess.GET("/export", read, sessionHandler.Export)
 	}
 
 	return r
@@ -0,0 +1,371 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/gin-gonic/gin"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/openapi"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// fakeAuth は Bearer の値をそのまま利用者の種類として扱う。
+var fakeAuth = authFunc(func(_ context.Context, credential string) (*identity.Principal, error) {
+	switch credential {
+	case "student", "teacher", "admin":
+		return &identity.Principal{Sub: credential, Method: identity.MethodJWT, Scopes: identity.AllScopes()}, nil
+	case "mok_noscope":
+		// 権限の無い API キー。管理者の役割を持っていても scope で弾かれる
+		return &identity.Principal{Sub: "admin", Method: identity.MethodAPIKey}, nil
+	}
+	return nil, identity.ErrUnauthenticated
+})
+
+type authFunc func(ctx context.Context, credential string) (*identity.Principal, error)
+
+func (f authFunc) Authenticate(ctx context.Context, credential string) (*identity.Principal, error) {
+	return f(ctx, credential)
+}
+
+// fakeRoles は sub と同じ名前の役割を持つとみなす。
+type fakeRoles struct{}
+
+func (fakeRoles) HasRole(_ context.Context, p *identity.Principal, role string) (bool, error) {
+	return identity.RolesInclude([]string{p.Sub}, role), nil
+}
+
+func (fakeRoles) GetRoles(actor *identity.Principal, userSub string) (*dto.UserRoles, error) {
+	return &dto.UserRoles{UserSub: userSub, Roles: []string{}}, nil
+}
+
+func (fakeRoles) GrantRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	return &dto.UserRoles{UserSub: userSub, Roles: []string{role}}, nil
+}
+
+func (fakeRoles) RevokeRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	return &dto.UserRoles{UserSub: userSub, Roles: []string{}}, nil
+}
+
+// newTestEngine は到達できない DynamoDB を使うルーターを作る。
+// 認可を通ったリクエストはハンドラーまで届き、テーブルの読み書きで失敗する (401/403 以外になる)。
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "ap-northeast-1",
+		BaseEndpoint: aws.String("http://127.0.0.1:1"),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		Retryer:      aws.NopRetryer{},
+	})
+	return newEngine(repository.NewRepository(client), fakeAuth, fakeRoles{})
+}
+
+// 必要な役割
+const (
+	anyone  = identity.RoleStudent
+	teacher = identity.RoleTeacher
+	admin   = identity.RoleAdmin
+)
+
+type route struct {
+	method, route, path string
+	role, scope         string
+}
+
+// v1Routes は /v1 のルート。
+var v1Routes = []route{
+	{"POST", "/v1/sessions", "/v1/sessions", anyone, identity.ScopeTakeTests},
+	{"GET", "/v1/sessions", "/v1/sessions", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/sessions/:sessionId", "/v1/sessions/1", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/sessions/:sessionId/problems/:idx", "/v1/sessions/1/problems/0", anyone, identity.ScopeTakeTests},
+	{"POST", "/v1/sessions/:sessionId/problems/:idx/hint", "/v1/sessions/1/problems/0/hint", anyone, identity.ScopeTakeTests},
+	{"PUT", "/v1/sessions/:sessionId/problems/:idx/answer", "/v1/sessions/1/problems/0/answer", anyone, identity.ScopeTakeTests},
+	{"POST", "/v1/sessions/:sessionId/finish", "/v1/sessions/1/finish", anyone, identity.ScopeTakeTests},
+	{"GET", "/v1/sessions/:sessionId/history", "/v1/sessions/1/history", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/me/mypage", "/v1/me/mypage", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/me/mypage/summary", "/v1/me/mypage/summary", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/me/mypage/trends", "/v1/me/mypage/trends", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/me/mypage/categories", "/v1/me/mypage/categories", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/me/export", "/v1/me/export", anyone, identity.ScopeReadResults},
+	{"POST", "/v1/classes", "/v1/classes", teacher, identity.ScopeAdmin},
+	{"GET", "/v1/classes", "/v1/classes", anyone, identity.ScopeReadResults},
+	{"POST", "/v1/classes/join", "/v1/classes/join", anyone, identity.ScopeTakeTests},
+	{"GET", "/v1/classes/:classId", "/v1/classes/1", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/classes/:classId/analytics", "/v1/classes/1/analytics", anyone, identity.ScopeReadResults},
+	{"DELETE", "/v1/classes/:classId/students/:studentSub", "/v1/classes/1/students/s1", teacher, identity.ScopeAdmin},
+	{"GET", "/v1/classes/:classId/students/:studentSub/mypage", "/v1/classes/1/students/s1/mypage", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/classes/:classId/students/:studentSub/summary", "/v1/classes/1/students/s1/summary", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/classes/:classId/students/:studentSub/categories", "/v1/classes/1/students/s1/categories", anyone, identity.ScopeReadResults},
+	{"POST", "/v1/classes/:classId/assignments", "/v1/classes/1/assignments", teacher, identity.ScopeAdmin},
+	{"GET", "/v1/classes/:classId/assignments", "/v1/classes/1/assignments", anyone, identity.ScopeReadResults},
+	{"GET", "/v1/classes/:classId/assignments/:assignmentId/status", "/v1/classes/1/assignments/1/status", anyone, identity.ScopeReadResults},
+	{"POST", "/v1/classes/:classId/assignments/:assignmentId/attempts", "/v1/classes/1/assignments/1/attempts", anyone, identity.ScopeTakeTests},
+	{"GET", "/v1/me", "/v1/me", anyone, identity.ScopeReadResults},
+	{"PUT", "/v1/me", "/v1/me", anyone, identity.ScopeTakeTests},
+	{"GET", "/v1/me/roles", "/v1/me/roles", anyone, ""},
+	{"GET", "/v1/me/archive", "/v1/me/archive", anyone, identity.ScopeReadResults},
+	{"POST", "/v1/api-keys", "/v1/api-keys", anyone, ""},
+	{"GET", "/v1/api-keys", "/v1/api-keys", anyone, ""},
+	{"DELETE", "/v1/api-keys/:keyId", "/v1/api-keys/k1", anyone, ""},
+	{"GET", "/v1/admin/item-analysis", "/v1/admin/item-analysis", admin, identity.ScopeAdmin},
+	{"GET", "/v1/admin/users/:userSub/roles", "/v1/admin/users/s1/roles", admin, identity.ScopeAdmin},
+	{"PUT", "/v1/admin/users/:userSub/roles/:role", "/v1/admin/users/s1/roles/teacher", admin, identity.ScopeAdmin},
+	{"DELETE", "/v1/admin/users/:userSub/roles/:role", "/v1/admin/users/s1/roles/teacher", admin, identity.ScopeAdmin},
+	{"GET", "/v1/admin/users/:userSub/archive", "/v1/admin/users/s1/archive", admin, identity.ScopeAdmin},
+	{"POST", "/v1/admin/users/:userSub/deletion", "/v1/admin/users/s1/deletion", admin, identity.ScopeAdmin},
+	{"GET", "/v1/admin/users/:userSub/deletion", "/v1/admin/users/s1/deletion", admin, identity.ScopeAdmin},
+}
+
+// legacyRoutes は移行のために残している旧ルート (/session/...)。
+var legacyRoutes = []route{
+	{"POST", "/session/test", "/session/test", anyone, identity.ScopeTakeTests},
+	{"GET", "/session/current/problems/:idx", "/session/current/problems/0?sessionId=1", anyone, identity.ScopeTakeTests},
+	{"POST", "/session/current/problems/:idx/hint", "/session/current/problems/0/hint?sessionId=1", anyone, identity.ScopeTakeTests},
+	{"POST", "/session/current/problems/:idx/answer", "/session/current/problems/0/answer?sessionId=1", anyone, identity.ScopeTakeTests},
+	{"POST", "/session/current/finish", "/session/current/finish?sessionId=1", anyone, identity.ScopeTakeTests},
+	{"GET", "/session/current/history", "/session/current/history?sessionId=1", anyone, identity.ScopeReadResults},
+	{"GET", "/session/mypage", "/session/mypage", anyone, identity.ScopeReadResults},
+	{"GET", "/session/mypage/summary", "/session/mypage/summary", anyone, identity.ScopeReadResults},
+	{"GET", "/session/mypage/trends", "/session/mypage/trends", anyone, identity.ScopeReadResults},
+	{"GET", "/session/mypage/categories", "/session/mypage/categories", anyone, identity.ScopeReadResults},
+	{"GET", "/session/export", "/session/export", anyone, identity.ScopeReadResults},
+}
+
+// publicRoutes はログイン不要のルート。
+var publicRoutes = []route{
+	{"GET", "/openapi.json", "/openapi.json", "", ""},
+}
+
+// routes はすべてのルートと、必要な役割・API キーの権限。ルートを追加したら上の表と openapi.Operations にも追加する。
+var routes = append(append([]route{}, v1Routes...), legacyRoutes...)
+
+func TestRoutes_AllCovered(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, info := range newTestEngine().Routes() {
+		registered[info.Method+" "+info.Path] = true
+	}
+	covered := make(map[string]bool, len(routes))
+	for _, rt := range publicRoutes {
+		covered[rt.method+" "+rt.route] = true
+	}
+	for _, rt := range routes {
+		key := rt.method + " " + rt.route
+		if !registered[key] {
+			t.Errorf("route %s is in the table but not registered", key)
+		}
+		covered[key] = true
+	}
+	for key := range registered {
+		if !covered[key] {
+			t.Errorf("route %s is not covered by the authorization table", key)
+		}
+	}
+}
+
+// OpenAPI 文書の操作が登録したルートと一致し、必要な権限・役割も表と同じであること
+func TestRoutes_OpenAPI(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Security   []any  `json:"security"`
+			Scope      string `json:"x-scope"`
+			Role       string `json:"x-role"`
+			Deprecated bool   `json:"deprecated"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	type specOp struct {
+		scope, role    string
+		public, legacy bool
+	}
+	spec := make(map[string]specOp)
+	for path, ops := range doc.Paths {
+		path = strings.NewReplacer("{", ":", "}", "").Replace(path)
+		for method, op := range ops {
+			role := op.Role
+			if role == "" {
+				role = anyone
+			}
+			spec[strings.ToUpper(method)+" "+path] = specOp{op.Scope, role, op.Security != nil && len(op.Security) == 0, op.Deprecated}
+		}
+	}
+
+	registered := make(map[string]bool)
+	for _, info := range newTestEngine().Routes() {
+		key := info.Method + " " + info.Path
+		registered[key] = true
+		if _, ok := spec[key]; !ok {
+			t.Errorf("route %s is not in openapi.json", key)
+		}
+	}
+	for key := range spec {
+		if !registered[key] {
+			t.Errorf("operation %s is in openapi.json but not registered", key)
+		}
+	}
+
+	for _, rt := range routes {
+		key := rt.method + " " + rt.route
+		op, ok := spec[key]
+		if !ok {
+			continue
+		}
+		if op.scope != rt.scope || op.role != rt.role || op.public {
+			t.Errorf("%s: openapi.json has scope %q role %q public %v, expected scope %q role %q", key, op.scope, op.role, op.public, rt.scope, rt.role)
+		}
+		if deprecated := !strings.HasPrefix(rt.route, "/v1/"); op.legacy != deprecated {
+			t.Errorf("%s: expected deprecated %v", key, deprecated)
+		}
+	}
+	for _, rt := range publicRoutes {
+		if op := spec[rt.method+" "+rt.route]; !op.public {
+			t.Errorf("%s %s: expected public operation", rt.method, rt.route)
+		}
+	}
+}
+
+// /openapi.json は資格情報が無くても無効でも返す
+func TestRoutes_OpenAPIPublic(t *testing.T) {
+	r := newTestEngine()
+
+	for _, caller := range []string{"", "invalid", "student"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/openapi.json", nil)
+		if caller != "" {
+			req.Header.Set("Authorization", "Bearer "+caller)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), openapi.Spec) {
+			t.Errorf("as %q: expected the spec, got %d", caller, w.Code)
+		}
+	}
+}
+
+func TestRoutes_Authorization(t *testing.T) {
+	r := newTestEngine()
+
+	for _, rt := range routes {
+		for _, caller := range []string{"", "invalid", "student", "teacher", "admin", "mok_noscope"} {
+			var want func(code int) bool
+			var wantDesc string
+			switch {
+			case caller == "" || caller == "invalid":
+				want, wantDesc = is(http.StatusUnauthorized), "401"
+			case caller == "mok_noscope" && rt.scope != "":
+				want, wantDesc = is(http.StatusForbidden), "403"
+			case caller == "mok_noscope":
+				// スコープの無いルートは役割で判定される (API キーの管理はサービスが 403 にする)
+				continue
+			case !identity.RolesInclude([]string{caller}, rt.role):
+				want, wantDesc = is(http.StatusForbidden), "403"
+			default:
+				want, wantDesc = func(code int) bool { return code != http.StatusUnauthorized && code != http.StatusForbidden }, "not 401/403"
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(rt.method, rt.path, nil)
+			if caller != "" {
+				req.Header.Set("Authorization", "Bearer "+caller)
+			}
+			r.ServeHTTP(w, req)
+
+			if !want(w.Code) {
+				t.Errorf("%s %s as %q: expected %s, got %d", rt.method, rt.path, caller, wantDesc, w.Code)
+			}
+		}
+	}
+}
+
+func is(want int) func(int) bool {
+	return func(code int) bool { return code == want }
+}
+
+// 旧ルートも /v1 と同じハンドラーでパラメータを検証する
+func TestRoutes_InvalidParams(t *testing.T) {
+	r := newTestEngine()
+
+	for _, rt := range []struct{ method, path string }{
+		{"GET", "/v1/sessions/abc/problems/0"},
+		{"GET", "/v1/sessions/1/problems/abc"},
+		{"GET", "/v1/sessions/1/problems/-1"},
+		{"POST", "/v1/sessions/1/problems/x/hint"},
+		{"PUT", "/v1/sessions/1/problems/0/answer"},
+		{"POST", "/v1/sessions/abc/finish"},
+		{"POST", "/v1/sessions?examMode=maybe"},
+		{"GET", "/v1/me/mypage?limit=ten"},
+		{"GET", "/session/current/problems/abc?sessionId=1"},
+		{"GET", "/session/current/problems/0"},
+		{"POST", "/session/current/finish?sessionId=abc"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(rt.method, rt.path, nil)
+		req.Header.Set("Authorization", "Bearer student")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected 400, got %d", rt.method, rt.path, w.Code)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), `"code":"invalid_parameter"`) {
+			t.Errorf("%s %s: expected error body, got %s", rt.method, rt.path, w.Body.String())
+		}
+	}
+}
+
+// 認証・認可の失敗と内部エラーも problem+json で返し、内部エラーの原因は含めない
+func TestRoutes_ProblemJSON(t *testing.T) {
+	r := newTestEngine()
+
+	for _, tc := range []struct {
+		caller, method, path string
+		status               int
+		code                 string
+	}{
+		{"", "GET", "/v1/sessions", http.StatusUnauthorized, "unauthenticated"},
+		{"invalid", "GET", "/v1/sessions", http.StatusUnauthorized, "unauthenticated"},
+		{"student", "GET", "/v1/admin/item-analysis", http.StatusForbidden, "role_required"},
+		{"mok_noscope", "GET", "/v1/sessions", http.StatusForbidden, "scope_required"},
+		{"student", "GET", "/v1/sessions", http.StatusInternalServerError, "internal"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.caller != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.caller)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s as %q: expected %d, got %d", tc.method, tc.path, tc.caller, tc.status, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+			t.Errorf("%s %s as %q: unexpected Content-Type %q", tc.method, tc.path, tc.caller, ct)
+		}
+		var resp struct {
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+			Detail string `json:"detail"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if resp.Status != tc.status || resp.Code != tc.code || resp.Detail == "" {
+			t.Errorf("%s %s as %q: unexpected body %s", tc.method, tc.path, tc.caller, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "127.0.0.1") {
+			t.Errorf("%s %s: internal error leaked: %s", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
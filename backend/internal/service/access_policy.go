@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// AccessPolicy はユーザーの学習データへのアクセス可否を判定する。
+// 回答・ヒント・終了などの書き込みは本人のみ。閲覧は本人と、本人が生徒として所属するクラスの教師に許可する。
+// 許可しない場合は apperr.ErrForbidden を返す。
+type AccessPolicy struct {
+	classes repository.ClassMemberReader
+}
+
+// NewAccessPolicy はクラス所属を参照するポリシーを返す。r が nil の場合は本人のみ許可する。
+func NewAccessPolicy(r repository.ClassMemberReader) *AccessPolicy {
+	return &AccessPolicy{classes: r}
+}
+
+// AuthorizeSessionWrite はセッションへの回答などを本人に限る。
+func (p *AccessPolicy) AuthorizeSessionWrite(actorSub string, sess *model.TestSession) error {
+	if sess.UserID != actorSub {
+		return apperr.ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeSessionRead はセッションの閲覧を本人と担当の教師に許可する。
+func (p *AccessPolicy) AuthorizeSessionRead(actorSub string, sess *model.TestSession) error {
+	return p.AuthorizeUserRead(actorSub, sess.UserID)
+}
+
+// AuthorizeUserRead は ownerSub の学習データの閲覧を本人と担当の教師に許可する。
+func (p *AccessPolicy) AuthorizeUserRead(actorSub, ownerSub string) error {
+	if actorSub == ownerSub {
+		return nil
+	}
+	if p.classes == nil {
+		return apperr.ErrForbidden
+	}
+
+	memberships, err := p.classes.FindMemberships(ownerSub)
+	if err != nil {
+		return fmt.Errorf("find memberships: %w", err)
+	}
+	for _, m := range memberships {
+		if m.Role != model.ClassRoleStudent {
+			continue
+		}
+		if ok, err := p.isTeacher(m.ClassID, actorSub); err != nil || ok {
+			return err
+		}
+	}
+	return apperr.ErrForbidden
+}
+
+// AuthorizeStudentRead はクラスを指定した閲覧を判定する。
+// actorSub がクラスの教師で、studentSub が同じクラスの生徒である場合のみ生徒の所属を返す。
+// 生徒が所属していない場合も、クラスの存在を明かさないよう教師でなければ ErrForbidden を優先する。
+func (p *AccessPolicy) AuthorizeStudentRead(actorSub string, classID uint64, studentSub string) (*model.ClassMember, error) {
+	if p.classes == nil {
+		return nil, apperr.ErrForbidden
+	}
+	ok, err := p.isTeacher(classID, actorSub)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apperr.ErrForbidden
+	}
+
+	m, err := p.classes.FindClassMember(classID, studentSub)
+	if err != nil {
+		return nil, err
+	}
+	if m.Role != model.ClassRoleStudent {
+		return nil, apperr.ErrNotFound
+	}
+	return m, nil
+}
+
+// AuthorizeClassTeacher はクラスの管理操作を教師に限る。
+func (p *AccessPolicy) AuthorizeClassTeacher(actorSub string, classID uint64) error {
+	if p.classes == nil {
+		return apperr.ErrForbidden
+	}
+	ok, err := p.isTeacher(classID, actorSub)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperr.ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeClassMember はクラスの閲覧を所属するユーザー (教師・生徒) に限り、その所属を返す。
+func (p *AccessPolicy) AuthorizeClassMember(actorSub string, classID uint64) (*model.ClassMember, error) {
+	if p.classes == nil {
+		return nil, apperr.ErrForbidden
+	}
+	m, err := p.classes.FindClassMember(classID, actorSub)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, apperr.ErrForbidden
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find class member: %w", err)
+	}
+	return m, nil
+}
+
+func (p *AccessPolicy) isTeacher(classID uint64, userSub string) (bool, error) {
+	m, err := p.classes.FindClassMember(classID, userSub)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find class member: %w", err)
+	}
+	return m.Role == model.ClassRoleTeacher, nil
+}
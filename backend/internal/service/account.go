@@ -0,0 +1,486 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// データ削除の段階。この順に進める。
+const (
+	// API キーを最初に消し、スクリプトや MCP からの書き込みを止める
+	deletionPhaseAPIKeys = "apiKeys"
+	// セッション (問題・回答履歴) と、課題から生成したセッションの受験枠
+	deletionPhaseSessions = "sessions"
+	// セッションの無い受験枠 (作成に失敗したもの)。所属クラスから引くため classes より前に行う
+	deletionPhaseAttempts = "attempts"
+	// クラスの所属。クラス分析の計算結果は版を進めて消す。自分が作成したクラス・課題は削除せず作成者を匿名化する
+	deletionPhaseClasses = "classes"
+	// プロフィール・役割・分野別累計 (pk=USER#<sub>)
+	deletionPhaseUser = "user"
+)
+
+var deletionPhases = []string{
+	deletionPhaseAPIKeys,
+	deletionPhaseSessions,
+	deletionPhaseAttempts,
+	deletionPhaseClasses,
+	deletionPhaseUser,
+}
+
+const (
+	// DeleteAccount 1回で削除するアイテム数の目安。セッション単位で区切るため多少超えることがある
+	defaultDeletionBatchSize = 500
+	maxDeletionBatchSize     = 5000
+)
+
+// AccountService は利用者のデータ一式のエクスポートと、管理者によるデータ削除を行う。
+type AccountService struct {
+	repo  repository.AccountRepo
+	roles identity.RoleChecker
+}
+
+func NewAccountService(r repository.AccountRepo, roles identity.RoleChecker) *AccountService {
+	return &AccountService{repo: r, roles: roles}
+}
+
+// ExportAccount は userSub のデータ一式を返す。本人か管理者のみ。
+func (s *AccountService) ExportAccount(actor *identity.Principal, userSub string) (*dto.AccountArchive, error) {
+	if actor.Sub != userSub {
+		if err := requireAdmin(s.roles, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := s.repo.FindUserProfile(userSub)
+	if errors.Is(err, apperr.ErrNotFound) {
+		profile = &model.UserProfile{Sub: userSub, Timezone: defaultTimezone}
+		if actor.Sub == userSub {
+			profile.DisplayName = actor.Name
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("find profile: %w", err)
+	}
+	loc, err := time.LoadLocation(profile.Timezone)
+	if err != nil {
+		loc = jst
+	}
+
+	roles, err := s.repo.FindUserRoles(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find roles: %w", err)
+	}
+	archive := &dto.AccountArchive{
+		UserSub:    userSub,
+		ExportedAt: archiveStamp(time.Now(), loc),
+		Profile:    *toProfileDto(profile),
+		Roles:      normalizeRoles(roles),
+	}
+
+	stats, err := s.repo.FindCategoryStats(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find category stats: %w", err)
+	}
+	archive.CategoryStats = make([]dto.CategoryStat, 0, len(stats))
+	for _, st := range stats {
+		c := dto.CategoryStat{CategoryName: st.CategoryName, Attempts: st.Attempts, CorrectCount: st.CorrectCount}
+		if st.Attempts > 0 {
+			c.Accuracy = float64(st.CorrectCount) / float64(st.Attempts)
+		}
+		if st.LastAttemptedAt != nil {
+			c.LastAttemptedAt = archiveStamp(*st.LastAttemptedAt, loc)
+		}
+		archive.CategoryStats = append(archive.CategoryStats, c)
+	}
+
+	if archive.Sessions, err = s.archiveSessions(userSub, loc); err != nil {
+		return nil, err
+	}
+	if archive.Classes, archive.Attempts, err = s.archiveClasses(userSub, loc); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.repo.FindAPIKeys(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find api keys: %w", err)
+	}
+	archive.APIKeys = make([]dto.APIKey, 0, len(keys))
+	for _, k := range keys {
+		archive.APIKeys = append(archive.APIKeys, toAPIKeyDto(k))
+	}
+	return archive, nil
+}
+
+// archiveSessions はセッションを古い順に、問題と回答履歴付きで返す。
+func (s *AccountService) archiveSessions(userSub string, loc *time.Location) ([]dto.ArchivedSession, error) {
+	sessions, err := s.repo.FindUserSessions(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find sessions: %w", err)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+
+	result := make([]dto.ArchivedSession, 0, len(sessions))
+	for _, sess := range sessions {
+		as := dto.ArchivedSession{
+			SessionID:       strconv.FormatUint(sess.ID, 10),
+			Status:          model.SessionStatusReady,
+			IncludeIntegers: sess.IncludeIntegers,
+			ExamMode:        sess.ExamMode,
+			StartTime:       archiveStamp(sess.StartTime, loc),
+			Attempt:         sess.Attempt,
+		}
+		if sess.Status != "" {
+			as.Status = sess.Status
+		}
+		if sess.FinishedAt != nil {
+			as.FinishedAt = archiveStamp(*sess.FinishedAt, loc)
+		}
+		if sess.AssignmentID != 0 {
+			as.AssignmentID = strconv.FormatUint(sess.AssignmentID, 10)
+		}
+
+		sps, err := s.repo.FindSessionProblemsBySessionID(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("find session problems: %w", err)
+		}
+		as.Problems = make([]dto.ArchivedSessionProblem, 0, len(sps))
+		for i, sp := range sps {
+			p := dto.ArchivedSessionProblem{
+				Idx:              i,
+				ProblemID:        strconv.FormatUint(sp.ProblemID, 10),
+				CategoryName:     sp.CategoryName,
+				IsCorrect:        sp.IsCorrect,
+				AnsweredWithHint: sp.AnsweredWithHint,
+				FirstViewedAt:    optArchiveStamp(sp.FirstViewedAt, loc),
+				AnsweredAt:       optArchiveStamp(sp.AnsweredAt, loc),
+				HintRevealedAt:   optArchiveStamp(sp.HintRevealedAt, loc),
+			}
+			if sp.SelectedChoiceID != nil {
+				p.SelectedChoiceID = strconv.FormatUint(*sp.SelectedChoiceID, 10)
+			}
+			as.Problems = append(as.Problems, p)
+		}
+
+		events, err := s.repo.FindAnswerEvents(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("find answer events: %w", err)
+		}
+		as.AnswerEvents = make([]dto.ArchivedAnswerEvent, 0, len(events))
+		for _, e := range events {
+			as.AnswerEvents = append(as.AnswerEvents, dto.ArchivedAnswerEvent{
+				ProblemID:    strconv.FormatUint(e.ProblemID, 10),
+				CategoryName: e.CategoryName,
+				ChoiceID:     strconv.FormatUint(e.ChoiceID, 10),
+				IsCorrect:    e.IsCorrect,
+				WithHint:     e.WithHint,
+				AnsweredAt:   archiveStamp(e.AnsweredAt, loc),
+			})
+		}
+		result = append(result, as)
+	}
+	return result, nil
+}
+
+// archiveClasses は所属クラスと課題の受験枠を返す。
+func (s *AccountService) archiveClasses(userSub string, loc *time.Location) ([]dto.ArchivedClass, []dto.ArchivedAttempt, error) {
+	memberships, err := s.repo.FindMemberships(userSub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find memberships: %w", err)
+	}
+	classes := make([]dto.ArchivedClass, 0, len(memberships))
+	attempts := []dto.ArchivedAttempt{}
+	for _, m := range memberships {
+		c := dto.ArchivedClass{
+			ClassID:  strconv.FormatUint(m.ClassID, 10),
+			Role:     m.Role,
+			JoinedAt: archiveStamp(m.JoinedAt, loc),
+		}
+		class, err := s.repo.FindClassroom(m.ClassID)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			return nil, nil, fmt.Errorf("find classroom: %w", err)
+		}
+		if class != nil {
+			c.Name = class.Name
+		}
+		classes = append(classes, c)
+
+		assignments, err := s.repo.FindAssignments(m.ClassID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("find assignments: %w", err)
+		}
+		for _, a := range assignments {
+			as, err := s.repo.FindAssignmentAttempts(a.ID, userSub)
+			if err != nil {
+				return nil, nil, fmt.Errorf("find attempts: %w", err)
+			}
+			for _, at := range as {
+				aa := dto.ArchivedAttempt{
+					ClassID:      c.ClassID,
+					AssignmentID: strconv.FormatUint(a.ID, 10),
+					Title:        a.Title,
+					Attempt:      at.Attempt,
+					CreatedAt:    archiveStamp(at.CreatedAt, loc),
+				}
+				if at.SessionID != 0 {
+					aa.SessionID = strconv.FormatUint(at.SessionID, 10)
+				}
+				attempts = append(attempts, aa)
+			}
+		}
+	}
+	return classes, attempts, nil
+}
+
+// DeleteAccount は userSub のデータを最大でおよそ batchSize 件削除し、進捗を返す。管理者のみ。
+// Status が completed になるまで繰り返し呼ぶ。途中で失敗しても、もう一度呼べば続きから再開する。
+// 完了後に呼ぶと最初からやり直す (削除後に作られたデータも消える)。
+// 自分自身のデータは削除できない (ErrConflict)。batchSize が 0 以下なら既定値を使う。
+func (s *AccountService) DeleteAccount(actor *identity.Principal, userSub string, batchSize int) (*dto.AccountDeletion, error) {
+	if err := requireAdmin(s.roles, actor); err != nil {
+		return nil, err
+	}
+	if userSub == "" {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if userSub == actor.Sub {
+		return nil, apperr.ErrConflict
+	}
+	if batchSize <= 0 {
+		batchSize = defaultDeletionBatchSize
+	}
+	batchSize = min(batchSize, maxDeletionBatchSize)
+
+	now := time.Now()
+	d, err := s.repo.FindAccountDeletion(userSub)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, fmt.Errorf("find deletion: %w", err)
+	}
+	if d == nil || d.Status == model.DeletionStatusCompleted {
+		d = &model.AccountDeletion{
+			UserSub:   userSub,
+			Status:    model.DeletionStatusRunning,
+			Phase:     deletionPhases[0],
+			Deleted:   make(map[string]int),
+			StartedAt: now,
+		}
+	}
+	d.RequestedBy = actor.Sub
+
+	budget := batchSize
+	for budget > 0 && d.Status == model.DeletionStatusRunning {
+		deleted, done, err := s.runDeletionPhase(d, budget)
+		d.Deleted[d.Phase] += deleted
+		budget -= deleted
+		if err != nil {
+			// 途中までの進捗は保存し、次の呼び出しで続きから再開する
+			d.UpdatedAt = time.Now()
+			if saveErr := s.repo.SaveAccountDeletion(d); saveErr != nil {
+				return nil, fmt.Errorf("save deletion: %w", saveErr)
+			}
+			return nil, fmt.Errorf("delete %s: %w", d.Phase, err)
+		}
+		if !done {
+			break
+		}
+		d.Phase = nextDeletionPhase(d.Phase)
+		if d.Phase == "" {
+			d.Status = model.DeletionStatusCompleted
+			completed := time.Now()
+			d.CompletedAt = &completed
+		}
+	}
+
+	d.UpdatedAt = time.Now()
+	if err := s.repo.SaveAccountDeletion(d); err != nil {
+		return nil, fmt.Errorf("save deletion: %w", err)
+	}
+	return toAccountDeletionDto(d), nil
+}
+
+// GetAccountDeletion は userSub のデータ削除の進捗を返す。管理者のみ。始めていなければ ErrNotFound。
+func (s *AccountService) GetAccountDeletion(actor *identity.Principal, userSub string) (*dto.AccountDeletion, error) {
+	if err := requireAdmin(s.roles, actor); err != nil {
+		return nil, err
+	}
+	d, err := s.repo.FindAccountDeletion(userSub)
+	if err != nil {
+		return nil, err
+	}
+	return toAccountDeletionDto(d), nil
+}
+
+// runDeletionPhase は d.Phase の削除を budget 件程度まで進める。
+// 削除した件数と、その段階が終わったかを返す。匿名化した件数は d.Anonymized に加える。
+func (s *AccountService) runDeletionPhase(d *model.AccountDeletion, budget int) (deleted int, done bool, err error) {
+	sub := d.UserSub
+	switch d.Phase {
+	case deletionPhaseAPIKeys:
+		keys, err := s.repo.FindAPIKeys(sub)
+		if err != nil {
+			return 0, false, err
+		}
+		items := make([]repository.ItemKey, 0, len(keys))
+		for _, k := range keys {
+			items = append(items, repository.APIKeyItemKey(k.ID))
+		}
+		if err := s.repo.DeleteItems(items); err != nil {
+			return 0, false, err
+		}
+		return len(items), true, nil
+
+	case deletionPhaseSessions:
+		sessions, err := s.repo.FindUserSessions(sub)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, sess := range sessions {
+			if deleted >= budget {
+				return deleted, false, nil
+			}
+			// 受験枠を先に消す。セッションを先に消すと受験枠を引けなくなるため
+			if sess.AssignmentID != 0 {
+				if err := s.repo.DeleteItems([]repository.ItemKey{repository.AttemptItemKey(sess.AssignmentID, sub, sess.Attempt)}); err != nil {
+					return deleted, false, err
+				}
+				deleted++
+			}
+			n, err := s.repo.PurgeSession(sess.ID)
+			deleted += n
+			if err != nil {
+				return deleted, false, err
+			}
+		}
+		return deleted, true, nil
+
+	case deletionPhaseAttempts:
+		memberships, err := s.repo.FindMemberships(sub)
+		if err != nil {
+			return 0, false, err
+		}
+		var items []repository.ItemKey
+		for _, m := range memberships {
+			assignments, err := s.repo.FindAssignments(m.ClassID)
+			if err != nil {
+				return 0, false, err
+			}
+			for _, a := range assignments {
+				attempts, err := s.repo.FindAssignmentAttempts(a.ID, sub)
+				if err != nil {
+					return 0, false, err
+				}
+				for _, at := range attempts {
+					items = append(items, repository.AttemptItemKey(a.ID, sub, at.Attempt))
+				}
+			}
+		}
+		if err := s.repo.DeleteItems(items); err != nil {
+			return 0, false, err
+		}
+		return len(items), true, nil
+
+	case deletionPhaseClasses:
+		memberships, err := s.repo.FindMemberships(sub)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, m := range memberships {
+			if m.Role == model.ClassRoleTeacher {
+				if err := s.anonymizeClass(d, m.ClassID); err != nil {
+					return 0, false, err
+				}
+			}
+			// 分析の計算結果もクラスの版を進めて消す (版を残すためアイテムは削除しない)
+			if err := s.repo.PurgeClassMember(m.ClassID, sub); err != nil {
+				return 0, false, err
+			}
+		}
+		return len(memberships), true, nil
+
+	case deletionPhaseUser:
+		items, err := s.repo.FindUserItemKeys(sub)
+		if err != nil {
+			return 0, false, err
+		}
+		if err := s.repo.DeleteItems(items); err != nil {
+			return 0, false, err
+		}
+		return len(items), true, nil
+	}
+	return 0, false, fmt.Errorf("unknown deletion phase %q", d.Phase)
+}
+
+// anonymizeClass は利用者が作成したクラスと課題の作成者を匿名化する。
+// クラスと課題は他の生徒の成績から参照されるため削除しない。
+func (s *AccountService) anonymizeClass(d *model.AccountDeletion, classID uint64) error {
+	ok, err := s.repo.AnonymizeClassroomOwner(classID, d.UserSub)
+	if err != nil {
+		return err
+	}
+	if ok {
+		d.Anonymized++
+	}
+	assignments, err := s.repo.FindAssignments(classID)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		if a.CreatedBy != d.UserSub {
+			continue
+		}
+		ok, err := s.repo.AnonymizeAssignmentCreator(classID, a.ID, d.UserSub)
+		if err != nil {
+			return err
+		}
+		if ok {
+			d.Anonymized++
+		}
+	}
+	return nil
+}
+
+func nextDeletionPhase(phase string) string {
+	for i, p := range deletionPhases {
+		if p == phase && i+1 < len(deletionPhases) {
+			return deletionPhases[i+1]
+		}
+	}
+	return ""
+}
+
+func toAccountDeletionDto(d *model.AccountDeletion) *dto.AccountDeletion {
+	result := &dto.AccountDeletion{
+		UserSub:     d.UserSub,
+		RequestedBy: d.RequestedBy,
+		Status:      d.Status,
+		Phase:       d.Phase,
+		Deleted:     d.Deleted,
+		Anonymized:  d.Anonymized,
+		StartedAt:   d.StartedAt.In(jst).Format("2006-01-02 15:04:05"),
+		UpdatedAt:   d.UpdatedAt.In(jst).Format("2006-01-02 15:04:05"),
+	}
+	if d.CompletedAt != nil {
+		result.CompletedAt = d.CompletedAt.In(jst).Format("2006-01-02 15:04:05")
+	}
+	return result
+}
+
+func archiveStamp(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
+}
+
+func optArchiveStamp(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return archiveStamp(*t, loc)
+}
@@ -0,0 +1,371 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockAccountRepo は利用者1人分のデータをメモリ上に持つ。削除したアイテムは各 Find の結果から除く。
+type mockAccountRepo struct {
+	profile     *model.UserProfile
+	roles       []string
+	stats       []model.CategoryStat
+	sessions    []model.TestSession
+	sps         map[uint64][]model.SessionProblem
+	events      map[uint64][]model.AnswerEvent
+	members     []model.ClassMember
+	classes     map[uint64]*model.Classroom
+	assignments map[uint64][]model.Assignment
+	attempts    []model.AssignmentAttempt
+	apiKeys     []model.APIKey
+	userItems   []repository.ItemKey
+
+	deleted map[repository.ItemKey]bool
+	purged  map[uint64]bool
+	// analytics はクラス分析のアイテムの版と計算結果
+	analytics map[uint64]*model.ClassAnalyticsCache
+	deletion  *model.AccountDeletion
+	// failPurge のセッションは1回だけ削除に失敗する
+	failPurge uint64
+}
+
+// newAccountFixture は user-1 が教師として作成したクラスに所属し、3回分のセッションを持つ状態を作る。
+func newAccountFixture() *mockAccountRepo {
+	base := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	correct := true
+	choice := uint64(11)
+	m := &mockAccountRepo{
+		roles:  []string{identity.RoleTeacher},
+		stats:  []model.CategoryStat{{CategoryID: 1, CategoryName: "数と式", Attempts: 4, CorrectCount: 3}},
+		sps:    make(map[uint64][]model.SessionProblem),
+		events: make(map[uint64][]model.AnswerEvent),
+		members: []model.ClassMember{
+			{ClassID: 50, UserSub: "user-1", Role: model.ClassRoleTeacher, JoinedAt: base},
+		},
+		classes:     map[uint64]*model.Classroom{50: {ID: 50, Name: "1組", OwnerSub: "user-1"}},
+		assignments: map[uint64][]model.Assignment{50: {{ID: 60, ClassID: 50, Title: "小テスト", CreatedBy: "user-1"}}},
+		attempts:    []model.AssignmentAttempt{{AssignmentID: 60, UserSub: "user-1", Attempt: 1, SessionID: 3}},
+		apiKeys:     []model.APIKey{{ID: "k1", UserSub: "user-1", Name: "script", CreatedAt: base}},
+		userItems: []repository.ItemKey{
+			{PK: "USER#user-1", SK: "PROFILE"},
+			{PK: "USER#user-1", SK: "ROLES"},
+			{PK: "USER#user-1", SK: "CATSTAT#1"},
+		},
+		deleted: make(map[repository.ItemKey]bool),
+		purged:  make(map[uint64]bool),
+		analytics: map[uint64]*model.ClassAnalyticsCache{
+			50: {ClassID: 50, Version: 7, ComputedVersion: 7, Params: "v1", Payload: []byte(`{}`)},
+		},
+	}
+	// 新しい順に返す (GSI1 の降順)
+	for id := uint64(3); id >= 1; id-- {
+		sess := model.TestSession{ID: id, UserID: "user-1", StartTime: base.Add(time.Duration(id) * time.Hour)}
+		if id == 3 {
+			sess.AssignmentID, sess.Attempt = 60, 1
+		}
+		m.sessions = append(m.sessions, sess)
+		for i := 0; i < 4; i++ {
+			m.sps[id] = append(m.sps[id], model.SessionProblem{ID: id*10 + uint64(i), TestSessionID: id, ProblemID: 100 + uint64(i), CategoryName: "数と式"})
+		}
+		m.sps[id][0].SelectedChoiceID, m.sps[id][0].IsCorrect = &choice, &correct
+		m.events[id] = []model.AnswerEvent{{SessionID: id, ProblemID: 100, ChoiceID: 11, IsCorrect: true, AnsweredAt: sess.StartTime}}
+	}
+	return m
+}
+
+func (m *mockAccountRepo) FindUserProfile(userSub string) (*model.UserProfile, error) {
+	if m.profile == nil || m.deleted[repository.ItemKey{PK: "USER#" + userSub, SK: "PROFILE"}] {
+		return nil, apperr.ErrNotFound
+	}
+	return m.profile, nil
+}
+
+func (m *mockAccountRepo) FindUserRoles(userSub string) ([]string, error) { return m.roles, nil }
+
+func (m *mockAccountRepo) FindCategoryStats(userSub string) ([]model.CategoryStat, error) {
+	return m.stats, nil
+}
+
+func (m *mockAccountRepo) FindUserSessions(userSub string) ([]model.TestSession, error) {
+	var result []model.TestSession
+	for _, s := range m.sessions {
+		if !m.purged[s.ID] {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountRepo) FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error) {
+	return m.sps[sessionID], nil
+}
+
+func (m *mockAccountRepo) FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error) {
+	return m.events[sessionID], nil
+}
+
+func (m *mockAccountRepo) FindMemberships(userSub string) ([]model.ClassMember, error) {
+	var result []model.ClassMember
+	for _, mem := range m.members {
+		if !m.deleted[repository.ClassMemberItemKey(mem.ClassID, mem.UserSub)] {
+			result = append(result, mem)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountRepo) FindClassroom(classID uint64) (*model.Classroom, error) {
+	c, ok := m.classes[classID]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *mockAccountRepo) FindAssignments(classID uint64) ([]model.Assignment, error) {
+	return m.assignments[classID], nil
+}
+
+func (m *mockAccountRepo) FindAssignmentAttempts(assignmentID uint64, userSub string) ([]model.AssignmentAttempt, error) {
+	var result []model.AssignmentAttempt
+	for _, a := range m.attempts {
+		if a.AssignmentID == assignmentID && a.UserSub == userSub && !m.deleted[repository.AttemptItemKey(a.AssignmentID, a.UserSub, a.Attempt)] {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountRepo) FindAPIKeys(userSub string) ([]model.APIKey, error) {
+	var result []model.APIKey
+	for _, k := range m.apiKeys {
+		if !m.deleted[repository.APIKeyItemKey(k.ID)] {
+			result = append(result, k)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountRepo) PurgeSession(sessionID uint64) (int, error) {
+	if m.failPurge == sessionID {
+		m.failPurge = 0
+		return 0, errors.New("throttled")
+	}
+	if m.purged[sessionID] {
+		return 0, nil
+	}
+	m.purged[sessionID] = true
+	return 1 + len(m.sps[sessionID]) + len(m.events[sessionID]), nil
+}
+
+func (m *mockAccountRepo) FindUserItemKeys(userSub string) ([]repository.ItemKey, error) {
+	var result []repository.ItemKey
+	for _, k := range m.userItems {
+		if !m.deleted[k] {
+			result = append(result, k)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAccountRepo) DeleteItems(keys []repository.ItemKey) error {
+	for _, k := range keys {
+		m.deleted[k] = true
+	}
+	return nil
+}
+
+func (m *mockAccountRepo) PurgeClassMember(classID uint64, userSub string) error {
+	m.deleted[repository.ClassMemberItemKey(classID, userSub)] = true
+	c, ok := m.analytics[classID]
+	if !ok {
+		c = &model.ClassAnalyticsCache{ClassID: classID}
+		m.analytics[classID] = c
+	}
+	c.Version++
+	c.ComputedVersion, c.Params, c.Payload = 0, "", nil
+	return nil
+}
+
+func (m *mockAccountRepo) AnonymizeClassroomOwner(classID uint64, userSub string) (bool, error) {
+	c, ok := m.classes[classID]
+	if !ok || c.OwnerSub != userSub {
+		return false, nil
+	}
+	c.OwnerSub = model.DeletedUserSub
+	return true, nil
+}
+
+func (m *mockAccountRepo) AnonymizeAssignmentCreator(classID, assignmentID uint64, userSub string) (bool, error) {
+	for i, a := range m.assignments[classID] {
+		if a.ID == assignmentID && a.CreatedBy == userSub {
+			m.assignments[classID][i].CreatedBy = model.DeletedUserSub
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockAccountRepo) FindAccountDeletion(userSub string) (*model.AccountDeletion, error) {
+	if m.deletion == nil {
+		return nil, apperr.ErrNotFound
+	}
+	d := *m.deletion
+	d.Deleted = make(map[string]int)
+	for k, v := range m.deletion.Deleted {
+		d.Deleted[k] = v
+	}
+	return &d, nil
+}
+
+func (m *mockAccountRepo) SaveAccountDeletion(d *model.AccountDeletion) error {
+	saved := *d
+	m.deletion = &saved
+	return nil
+}
+
+func newAccountService(repo *mockAccountRepo) *service.AccountService {
+	roles := service.NewRoleService(&mockRoleRepo{roles: map[string][]string{"admin-1": {identity.RoleAdmin}}})
+	return service.NewAccountService(repo, roles)
+}
+
+func TestAccount_ExportOwnData(t *testing.T) {
+	svc := newAccountService(newAccountFixture())
+	actor := &identity.Principal{Sub: "user-1", Name: "Alice", Method: identity.MethodJWT}
+
+	a, err := svc.ExportAccount(actor, "user-1")
+	if err != nil {
+		t.Fatalf("ExportAccount: %v", err)
+	}
+	if a.Profile.DisplayName != "Alice" || len(a.Roles) != 1 || len(a.CategoryStats) != 1 || len(a.APIKeys) != 1 {
+		t.Errorf("archive = %+v", a)
+	}
+	if len(a.Sessions) != 3 || a.Sessions[0].SessionID != "1" || a.Sessions[2].AssignmentID != "60" {
+		t.Fatalf("sessions = %+v, want 3 sessions oldest first", a.Sessions)
+	}
+	s := a.Sessions[0]
+	if len(s.Problems) != 4 || s.Problems[0].SelectedChoiceID != "11" || len(s.AnswerEvents) != 1 {
+		t.Errorf("session = %+v, want problems and answer events", s)
+	}
+	// プロフィール未保存なら Asia/Tokyo のオフセット付き
+	if s.StartTime != "2026-04-01T10:00:00+09:00" {
+		t.Errorf("startTime = %s", s.StartTime)
+	}
+	if len(a.Classes) != 1 || a.Classes[0].Name != "1組" || len(a.Attempts) != 1 || a.Attempts[0].SessionID != "3" {
+		t.Errorf("classes = %+v, attempts = %+v", a.Classes, a.Attempts)
+	}
+}
+
+func TestAccount_ExportOthersRequiresAdmin(t *testing.T) {
+	svc := newAccountService(newAccountFixture())
+
+	if _, err := svc.ExportAccount(principal("user-2"), "user-1"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("other user: err = %v, want ErrForbidden", err)
+	}
+	a, err := svc.ExportAccount(principal("admin-1"), "user-1")
+	if err != nil {
+		t.Fatalf("admin: %v", err)
+	}
+	if a.Profile.DisplayName != "" {
+		t.Errorf("displayName = %q, want empty (admin's name must not be used)", a.Profile.DisplayName)
+	}
+}
+
+func TestAccount_DeleteInBatches(t *testing.T) {
+	repo := newAccountFixture()
+	svc := newAccountService(repo)
+	admin := principal("admin-1")
+
+	calls := 0
+	for {
+		calls++
+		if calls > 20 {
+			t.Fatal("deletion did not complete")
+		}
+		d, err := svc.DeleteAccount(admin, "user-1", 5)
+		if err != nil {
+			t.Fatalf("DeleteAccount #%d: %v", calls, err)
+		}
+		if d.Status == model.DeletionStatusCompleted {
+			if d.Phase != "" || d.CompletedAt == "" || d.RequestedBy != "admin-1" {
+				t.Errorf("completed deletion = %+v", d)
+			}
+			// セッション 3回 × (本体 + 問題4 + 回答1) と、課題の受験枠
+			if d.Deleted["sessions"] != 19 || d.Deleted["apiKeys"] != 1 || d.Deleted["classes"] != 1 || d.Deleted["user"] != 3 {
+				t.Errorf("deleted = %v", d.Deleted)
+			}
+			if d.Anonymized != 2 {
+				t.Errorf("anonymized = %d, want class and assignment", d.Anonymized)
+			}
+			break
+		}
+	}
+	if calls < 3 {
+		t.Errorf("calls = %d, want the sessions to be split into batches", calls)
+	}
+
+	if sessions, _ := repo.FindUserSessions("user-1"); len(sessions) != 0 {
+		t.Errorf("sessions remain: %v", sessions)
+	}
+	if members, _ := repo.FindMemberships("user-1"); len(members) != 0 {
+		t.Errorf("memberships remain: %v", members)
+	}
+	if keys, _ := repo.FindUserItemKeys("user-1"); len(keys) != 0 {
+		t.Errorf("user items remain: %v", keys)
+	}
+	// 分析のアイテムは削除せず、版を進めて計算結果だけを消す
+	if c := repo.analytics[50]; c.Version != 8 || len(c.Payload) != 0 || c.IsFresh("v1") {
+		t.Errorf("class analytics = %+v, want version advanced past 7 and payload dropped", c)
+	}
+	if repo.classes[50].OwnerSub != model.DeletedUserSub || repo.assignments[50][0].CreatedBy != model.DeletedUserSub {
+		t.Error("class and assignment created by the user should be anonymized, not deleted")
+	}
+}
+
+func TestAccount_DeleteResumesAfterFailure(t *testing.T) {
+	repo := newAccountFixture()
+	repo.failPurge = 2
+	svc := newAccountService(repo)
+	admin := principal("admin-1")
+
+	if _, err := svc.DeleteAccount(admin, "user-1", 100); err == nil {
+		t.Fatal("expected error from failed purge")
+	}
+	if repo.deletion == nil || repo.deletion.Phase != "sessions" || repo.deletion.Deleted["apiKeys"] != 1 {
+		t.Fatalf("progress = %+v, want saved in sessions phase", repo.deletion)
+	}
+
+	d, err := svc.DeleteAccount(admin, "user-1", 100)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if d.Status != model.DeletionStatusCompleted || d.Deleted["sessions"] != 19 {
+		t.Errorf("deletion = %+v, want completed with every session", d)
+	}
+
+	got, err := svc.GetAccountDeletion(admin, "user-1")
+	if err != nil || got.Status != model.DeletionStatusCompleted {
+		t.Errorf("GetAccountDeletion = %+v, %v", got, err)
+	}
+}
+
+func TestAccount_DeleteRequiresAdmin(t *testing.T) {
+	svc := newAccountService(newAccountFixture())
+
+	if _, err := svc.DeleteAccount(principal("user-2"), "user-1", 0); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("non-admin: err = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.DeleteAccount(principal("admin-1"), "admin-1", 0); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("self: err = %v, want ErrConflict", err)
+	}
+	if _, err := svc.GetAccountDeletion(principal("admin-1"), "user-1"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("not started: err = %v, want ErrNotFound", err)
+	}
+}
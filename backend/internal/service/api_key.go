@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	// 1人が同時に持てる (失効していない) キーの数
+	maxAPIKeysPerUser   = 10
+	maxAPIKeyNameLength = 50
+
+	// 最終使用日時を書き込む間隔。リクエストごとに書き込まないように間引く
+	apiKeyTouchInterval = time.Minute
+
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+)
+
+// APIKeyService は利用者ごとの API キーの発行・失効と、キーによる認証を行う。
+// キーは mok_<ID>_<秘密部分> の形式で、保存するのはキー全体の SHA-256 だけ。
+type APIKeyService struct {
+	repo repository.APIKeyRepo
+}
+
+func NewAPIKeyService(r repository.APIKeyRepo) *APIKeyService {
+	return &APIKeyService{repo: r}
+}
+
+// CreateAPIKey はキーを発行する。キーそのものは戻り値でしか返さない。
+// キーの管理はログイン (JWT) した利用者だけができる。API キーでキーを増やせないようにするため。
+func (s *APIKeyService) CreateAPIKey(actor *identity.Principal, req dto.CreateAPIKeyRequest) (*dto.CreatedAPIKey, error) {
+	if actor.Method != identity.MethodJWT {
+		return nil, apperr.ErrForbidden
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return nil, apperr.ErrInvalidArgument
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.repo.FindAPIKeys(actor.Sub)
+	if err != nil {
+		return nil, err
+	}
+	active := 0
+	for _, k := range keys {
+		if k.RevokedAt == nil {
+			active++
+		}
+	}
+	if active >= maxAPIKeysPerUser {
+		return nil, apperr.ErrConflict
+	}
+
+	id, secret, err := newAPIKeyParts()
+	if err != nil {
+		return nil, err
+	}
+	key := identity.APIKeyPrefix + id + "_" + secret
+	k := model.APIKey{
+		ID:       id,
+		UserSub:  actor.Sub,
+		UserName: actor.Name,
+		Name:     name,
+		Hash:     hashAPIKey(key),
+		Scopes:   scopes,
+	}
+	if err := s.repo.CreateAPIKey(&k); err != nil {
+		return nil, fmt.Errorf("create api key: %w", err)
+	}
+	return &dto.CreatedAPIKey{APIKey: toAPIKeyDto(k), Key: key}, nil
+}
+
+// ListAPIKeys は利用者のキーを失効したものも含めて新しい順に返す。
+func (s *APIKeyService) ListAPIKeys(actor *identity.Principal) ([]dto.APIKey, error) {
+	if actor.Method != identity.MethodJWT {
+		return nil, apperr.ErrForbidden
+	}
+	keys, err := s.repo.FindAPIKeys(actor.Sub)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.APIKey, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, toAPIKeyDto(k))
+	}
+	return result, nil
+}
+
+// RevokeAPIKey は利用者のキーを失効させる。失効済みでもエラーにしない。
+// 他の利用者のキーは存在しないものとして ErrNotFound を返す。
+func (s *APIKeyService) RevokeAPIKey(actor *identity.Principal, keyID string) error {
+	if actor.Method != identity.MethodJWT {
+		return apperr.ErrForbidden
+	}
+	if keyID == "" {
+		return apperr.ErrNotFound
+	}
+	return s.repo.RevokeAPIKey(keyID, actor.Sub, time.Now())
+}
+
+// Authenticate はキーを検証して利用者を返す。identity.Authenticator を満たす。
+// 形式が不正・未登録・ハッシュ不一致・失効済みのキーは identity.ErrUnauthenticated。
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*identity.Principal, error) {
+	id, ok := parseAPIKeyID(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed api key", identity.ErrUnauthenticated)
+	}
+	k, err := s.repo.FindAPIKey(id)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown api key", identity.ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(k.Hash)) != 1 {
+		return nil, fmt.Errorf("%w: unknown api key", identity.ErrUnauthenticated)
+	}
+	if k.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: api key revoked", identity.ErrUnauthenticated)
+	}
+
+	now := time.Now()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		// 最終使用日時は目安なので、書き込みに失敗しても認証は通す
+		_ = s.repo.TouchAPIKey(k.ID, now)
+	}
+	return &identity.Principal{
+		Sub:    k.UserSub,
+		Name:   k.UserName,
+		Method: identity.MethodAPIKey,
+		Scopes: k.Scopes,
+	}, nil
+}
+
+// normalizeScopes は権限を重複なく既知のものだけにする。空や未知の権限は ErrInvalidArgument。
+func normalizeScopes(scopes []string) ([]string, error) {
+	var result []string
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if !slices.Contains(identity.AllScopes(), sc) {
+			return nil, apperr.ErrInvalidArgument
+		}
+		if !slices.Contains(result, sc) {
+			result = append(result, sc)
+		}
+	}
+	if len(result) == 0 {
+		return nil, apperr.ErrInvalidArgument
+	}
+	return result, nil
+}
+
+func newAPIKeyParts() (id, secret string, err error) {
+	b := make([]byte, apiKeyIDBytes+apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(b[:apiKeyIDBytes]), base64.RawURLEncoding.EncodeToString(b[apiKeyIDBytes:]), nil
+}
+
+// parseAPIKeyID は mok_<ID>_<秘密部分> から ID を取り出す。
+func parseAPIKeyID(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, identity.APIKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != hex.EncodedLen(apiKeyIDBytes) || secret == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKeyDto(k model.APIKey) dto.APIKey {
+	d := dto.APIKey{
+		KeyID:     k.ID,
+		Name:      k.Name,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.In(jst).Format("2006-01-02 15:04:05"),
+	}
+	if k.LastUsedAt != nil {
+		d.LastUsedAt = k.LastUsedAt.In(jst).Format("2006-01-02 15:04:05")
+	}
+	if k.RevokedAt != nil {
+		d.RevokedAt = k.RevokedAt.In(jst).Format("2006-01-02 15:04:05")
+	}
+	return d
+}
@@ -0,0 +1,219 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockAPIKeyRepo は API キーをメモリ上に持つ。
+type mockAPIKeyRepo struct {
+	keys    map[string]*model.APIKey
+	touched int
+}
+
+func newMockAPIKeyRepo() *mockAPIKeyRepo {
+	return &mockAPIKeyRepo{keys: make(map[string]*model.APIKey)}
+}
+
+func (m *mockAPIKeyRepo) CreateAPIKey(k *model.APIKey) error {
+	if _, ok := m.keys[k.ID]; ok {
+		return apperr.ErrConflict
+	}
+	k.CreatedAt = time.Now()
+	stored := *k
+	m.keys[k.ID] = &stored
+	return nil
+}
+
+func (m *mockAPIKeyRepo) FindAPIKey(id string) (*model.APIKey, error) {
+	k, ok := m.keys[id]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	found := *k
+	return &found, nil
+}
+
+func (m *mockAPIKeyRepo) FindAPIKeys(userSub string) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	for _, k := range m.keys {
+		if k.UserSub == userSub {
+			keys = append(keys, *k)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockAPIKeyRepo) RevokeAPIKey(id, userSub string, at time.Time) error {
+	k, ok := m.keys[id]
+	if !ok || k.UserSub != userSub {
+		return apperr.ErrNotFound
+	}
+	if k.RevokedAt == nil {
+		k.RevokedAt = &at
+	}
+	return nil
+}
+
+func (m *mockAPIKeyRepo) TouchAPIKey(id string, at time.Time) error {
+	k, ok := m.keys[id]
+	if !ok {
+		return apperr.ErrNotFound
+	}
+	k.LastUsedAt = &at
+	m.touched++
+	return nil
+}
+
+func TestAPIKey_CreateAndAuthenticate(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := service.NewAPIKeyService(repo)
+	actor := &identity.Principal{Sub: "user-1", Name: "Alice", Method: identity.MethodJWT}
+
+	created, err := svc.CreateAPIKey(actor, dto.CreateAPIKeyRequest{
+		Name:   " script ",
+		Scopes: []string{identity.ScopeReadResults, identity.ScopeReadResults},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, identity.APIKeyPrefix+created.KeyID+"_") {
+		t.Errorf("key = %q, want prefix mok_%s_", created.Key, created.KeyID)
+	}
+	if created.Name != "script" || len(created.Scopes) != 1 {
+		t.Errorf("created = %+v, want trimmed name and deduped scopes", created.APIKey)
+	}
+	if strings.Contains(repo.keys[created.KeyID].Hash, created.Key) {
+		t.Error("raw key must not be stored")
+	}
+
+	p, err := svc.Authenticate(context.Background(), created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if p.Sub != "user-1" || p.Name != "Alice" || p.Method != identity.MethodAPIKey {
+		t.Errorf("principal = %+v", p)
+	}
+	if !p.HasScope(identity.ScopeReadResults) || p.HasScope(identity.ScopeTakeTests) {
+		t.Errorf("scopes = %v, want only %s", p.Scopes, identity.ScopeReadResults)
+	}
+}
+
+func TestAPIKey_AuthenticateRejectsInvalidKeys(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := service.NewAPIKeyService(repo)
+	created, err := svc.CreateAPIKey(principal("user-1"), dto.CreateAPIKeyRequest{Name: "k", Scopes: []string{identity.ScopeTakeTests}})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	for name, key := range map[string]string{
+		"malformed":    "mok_nothex",
+		"no prefix":    strings.TrimPrefix(created.Key, identity.APIKeyPrefix),
+		"wrong secret": created.Key + "x",
+		"unknown id":   identity.APIKeyPrefix + "0000000000000000_secret",
+	} {
+		if _, err := svc.Authenticate(context.Background(), key); !errors.Is(err, identity.ErrUnauthenticated) {
+			t.Errorf("%s: err = %v, want ErrUnauthenticated", name, err)
+		}
+	}
+
+	if err := svc.RevokeAPIKey(principal("user-1"), created.KeyID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, err := svc.Authenticate(context.Background(), created.Key); !errors.Is(err, identity.ErrUnauthenticated) {
+		t.Errorf("revoked: err = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAPIKey_TouchIsThrottled(t *testing.T) {
+	repo := newMockAPIKeyRepo()
+	svc := service.NewAPIKeyService(repo)
+	created, _ := svc.CreateAPIKey(principal("user-1"), dto.CreateAPIKeyRequest{Name: "k", Scopes: []string{identity.ScopeReadResults}})
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Authenticate(context.Background(), created.Key); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+	}
+	if repo.touched != 1 {
+		t.Errorf("touched = %d, want 1", repo.touched)
+	}
+
+	old := time.Now().Add(-2 * time.Minute)
+	repo.keys[created.KeyID].LastUsedAt = &old
+	svc.Authenticate(context.Background(), created.Key)
+	if repo.touched != 2 {
+		t.Errorf("touched = %d, want 2 after interval", repo.touched)
+	}
+}
+
+func TestAPIKey_CreateValidation(t *testing.T) {
+	svc := service.NewAPIKeyService(newMockAPIKeyRepo())
+	for name, req := range map[string]dto.CreateAPIKeyRequest{
+		"empty name":    {Name: " ", Scopes: []string{identity.ScopeReadResults}},
+		"long name":     {Name: strings.Repeat("あ", 51), Scopes: []string{identity.ScopeReadResults}},
+		"no scopes":     {Name: "k"},
+		"unknown scope": {Name: "k", Scopes: []string{"results:write"}},
+	} {
+		if _, err := svc.CreateAPIKey(principal("user-1"), req); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestAPIKey_LimitCountsActiveKeysOnly(t *testing.T) {
+	svc := service.NewAPIKeyService(newMockAPIKeyRepo())
+	actor := principal("user-1")
+	req := dto.CreateAPIKeyRequest{Name: "k", Scopes: []string{identity.ScopeReadResults}}
+
+	var first string
+	for i := 0; i < 10; i++ {
+		created, err := svc.CreateAPIKey(actor, req)
+		if err != nil {
+			t.Fatalf("CreateAPIKey #%d: %v", i, err)
+		}
+		if i == 0 {
+			first = created.KeyID
+		}
+	}
+	if _, err := svc.CreateAPIKey(actor, req); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("11th key: err = %v, want ErrConflict", err)
+	}
+	if err := svc.RevokeAPIKey(actor, first); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, err := svc.CreateAPIKey(actor, req); err != nil {
+		t.Errorf("after revoke: %v", err)
+	}
+}
+
+func TestAPIKey_OwnerOnlyAndJWTOnly(t *testing.T) {
+	svc := service.NewAPIKeyService(newMockAPIKeyRepo())
+	created, _ := svc.CreateAPIKey(principal("user-1"), dto.CreateAPIKeyRequest{Name: "k", Scopes: []string{identity.ScopeAdmin}})
+
+	if err := svc.RevokeAPIKey(principal("user-2"), created.KeyID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("revoke other's key: err = %v, want ErrNotFound", err)
+	}
+	keys, err := svc.ListAPIKeys(principal("user-2"))
+	if err != nil || len(keys) != 0 {
+		t.Errorf("ListAPIKeys(user-2) = %v, %v; want empty", keys, err)
+	}
+
+	keyActor := &identity.Principal{Sub: "user-1", Method: identity.MethodAPIKey, Scopes: identity.AllScopes()}
+	if _, err := svc.CreateAPIKey(keyActor, dto.CreateAPIKeyRequest{Name: "k", Scopes: []string{identity.ScopeAdmin}}); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("create by api key: err = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.ListAPIKeys(keyActor); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("list by api key: err = %v, want ErrForbidden", err)
+	}
+}
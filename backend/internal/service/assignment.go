@@ -0,0 +1,424 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	maxAssignmentTitleLength = 100
+	// 固定の問題セット・出題ルールで出せる最大問題数 (セッション作成を1トランザクションに収める)
+	maxAssignmentProblems = 50
+	maxAssignmentAttempts = 10
+	maxTimeLimitMinutes   = 600
+	// 出題ルールで指定できるカテゴリ ID (1: 数と式 〜 7: 整数)
+	minCategoryID = 1
+	maxCategoryID = 7
+	// closesAt を省略した場合に期限後も提出を受け付ける時間
+	defaultLateWindow = 24 * time.Hour
+)
+
+// 課題の進み具合
+const (
+	AssignmentNotStarted = "not_started" // セッションが無い (課題の作成後に参加した生徒)
+	AssignmentInProgress = "in_progress"
+	AssignmentSubmitted  = "submitted"
+	AssignmentLate       = "late"   // 期限後・締切前に提出した
+	AssignmentMissed     = "missed" // 提出しないまま締め切られた
+)
+
+type AssignmentService struct {
+	repo   repository.AssignmentRepo
+	policy *AccessPolicy
+}
+
+func NewAssignmentService(r repository.AssignmentRepo) *AssignmentService {
+	return &AssignmentService{repo: r, policy: NewAccessPolicy(r)}
+}
+
+// CreateAssignment は課題を作成し、クラスの生徒全員に1回目のセッションを配る。
+// 配布の途中で失敗した場合、課題と配布済みのセッションは残る (残りの生徒は StartAttempt で受験できる)。
+func (s *AssignmentService) CreateAssignment(actor *identity.Principal, classID uint64, req dto.CreateAssignmentRequest) (*dto.Assignment, error) {
+	if err := s.policy.AuthorizeClassTeacher(actor.Sub, classID); err != nil {
+		return nil, err
+	}
+	a, err := s.toAssignment(classID, req)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedBy = actor.Sub
+
+	// 固定の問題セットは配布前にすべて存在することを確かめる
+	var fixed []model.SessionProblem
+	if len(a.ProblemIDs) > 0 {
+		if fixed, err = s.fixedProblems(a.ProblemIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.CreateAssignment(&a); err != nil {
+		return nil, fmt.Errorf("create assignment: %w", err)
+	}
+
+	members, err := s.repo.FindClassMembers(classID)
+	if err != nil {
+		return nil, fmt.Errorf("find class members: %w", err)
+	}
+	generated := 0
+	for _, m := range members {
+		if m.Role != model.ClassRoleStudent {
+			continue
+		}
+		if _, err := s.createAttempt(a, m.UserSub, 1, fixed); err != nil {
+			return nil, fmt.Errorf("generate session for %s: %w", m.UserSub, err)
+		}
+		generated++
+	}
+
+	result := toAssignmentDto(a, time.Now())
+	result.GeneratedSessions = generated
+	return &result, nil
+}
+
+// ListAssignments はクラスの課題を作成順に返す。生徒には自分の進み具合も返す。
+func (s *AssignmentService) ListAssignments(actor *identity.Principal, classID uint64) ([]dto.Assignment, error) {
+	member, err := s.policy.AuthorizeClassMember(actor.Sub, classID)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.repo.FindAssignments(classID)
+	if err != nil {
+		return nil, fmt.Errorf("find assignments: %w", err)
+	}
+
+	now := time.Now()
+	result := make([]dto.Assignment, len(assignments))
+	for i, a := range assignments {
+		result[i] = toAssignmentDto(a, now)
+		if member.Role != model.ClassRoleStudent {
+			continue
+		}
+		attempts, err := s.repo.FindAssignmentAttempts(a.ID, actor.Sub)
+		if err != nil {
+			return nil, fmt.Errorf("find attempts: %w", err)
+		}
+		st := studentStatus(a, attempts, now)
+		result[i].Status = st.Status
+		result[i].AttemptsUsed = len(attempts)
+		if open := openAttempt(a, attempts, now); open != nil {
+			result[i].CurrentSessionID = strconv.FormatUint(open.SessionID, 10)
+		}
+	}
+	return result, nil
+}
+
+// GetAssignmentStatus はクラスの生徒ごとの提出状況を教師に返す。生徒は名前順。
+func (s *AssignmentService) GetAssignmentStatus(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentStatus, error) {
+	if err := s.policy.AuthorizeClassTeacher(actor.Sub, classID); err != nil {
+		return nil, err
+	}
+	a, err := s.repo.FindAssignment(classID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repo.FindClassMembers(classID)
+	if err != nil {
+		return nil, fmt.Errorf("find class members: %w", err)
+	}
+	attempts, err := s.repo.FindAssignmentAttempts(assignmentID, "")
+	if err != nil {
+		return nil, fmt.Errorf("find attempts: %w", err)
+	}
+	byUser := make(map[string][]model.AssignmentAttempt)
+	for _, at := range attempts {
+		byUser[at.UserSub] = append(byUser[at.UserSub], at)
+	}
+
+	now := time.Now()
+	result := &dto.AssignmentStatus{
+		Assignment: toAssignmentDto(*a, now),
+		Students:   []dto.StudentAssignmentStatus{},
+	}
+	for _, m := range members {
+		if m.Role != model.ClassRoleStudent {
+			continue
+		}
+		st := studentStatus(*a, byUser[m.UserSub], now)
+		st.UserSub, st.UserName = m.UserSub, m.UserName
+		switch st.Status {
+		case AssignmentSubmitted:
+			result.SubmittedCount++
+		case AssignmentLate:
+			result.SubmittedCount++
+			result.LateCount++
+		}
+		result.Students = append(result.Students, st)
+	}
+	sort.SliceStable(result.Students, func(i, j int) bool {
+		return result.Students[i].UserName < result.Students[j].UserName
+	})
+	return result, nil
+}
+
+// StartAttempt は生徒が課題を受験するセッションを返す。
+// 終了していない受験があればそれを返し (created=false)、無ければ受験回数の上限まで新しいセッションを作る。
+// 締め切られていれば ErrAssignmentClosed (ErrClosed)、上限に達していれば ErrNoAttemptsLeft (ErrConflict)。
+func (s *AssignmentService) StartAttempt(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error) {
+	member, err := s.policy.AuthorizeClassMember(actor.Sub, classID)
+	if err != nil {
+		return nil, false, err
+	}
+	if member.Role != model.ClassRoleStudent {
+		return nil, false, apperr.ErrForbidden
+	}
+	a, err := s.repo.FindAssignment(classID, assignmentID)
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	if a.IsClosed(now) {
+		return nil, false, apperr.ErrAssignmentClosed
+	}
+
+	attempts, err := s.repo.FindAssignmentAttempts(assignmentID, actor.Sub)
+	if err != nil {
+		return nil, false, fmt.Errorf("find attempts: %w", err)
+	}
+	if open := openAttempt(*a, attempts, now); open != nil {
+		d := toAttemptDto(*a, *open, now)
+		return &d, false, nil
+	}
+	if len(attempts) >= a.MaxAttempts {
+		return nil, false, apperr.ErrNoAttemptsLeft
+	}
+
+	var fixed []model.SessionProblem
+	if len(a.ProblemIDs) > 0 {
+		if fixed, err = s.fixedProblems(a.ProblemIDs); err != nil {
+			return nil, false, err
+		}
+	}
+	// 並行して開始した場合は受験枠の条件付き書き込みで片方が ErrConflict になる
+	at, err := s.createAttempt(*a, actor.Sub, len(attempts)+1, fixed)
+	if err != nil {
+		return nil, false, err
+	}
+	d := toAttemptDto(*a, *at, now)
+	return &d, true, nil
+}
+
+// createAttempt は課題の設定でセッションを作成する。fixed が nil なら出題ルールで問題を選ぶ。
+func (s *AssignmentService) createAttempt(a model.Assignment, userSub string, n int, fixed []model.SessionProblem) (*model.AssignmentAttempt, error) {
+	sps := fixed
+	if sps == nil {
+		for _, rule := range a.Rules {
+			problems, err := s.repo.FindProblemsPerCategory([]int{rule.CategoryID}, rule.Count)
+			if err != nil {
+				return nil, fmt.Errorf("find problems: %w", err)
+			}
+			for _, p := range problems {
+				sps = append(sps, model.SessionProblem{ProblemID: p.ID, CategoryID: p.CategoryID})
+			}
+		}
+	}
+	// CreateTestSession が ID を書き込むため生徒ごとに複製する
+	sps = append([]model.SessionProblem(nil), sps...)
+
+	closesAt := a.ClosesAt
+	session := model.TestSession{
+		UserID:    userSub,
+		ExamMode:  a.ExamMode,
+		ClosesAt:  &closesAt,
+		TimeLimit: a.TimeLimit,
+	}
+	attempt := model.AssignmentAttempt{AssignmentID: a.ID, UserSub: userSub, Attempt: n}
+	if err := s.repo.CreateAssignmentSession(&attempt, &session, sps); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// fixedProblems は固定の問題セットを出題順の SP にする。存在しない問題があれば ErrInvalidArgument。
+func (s *AssignmentService) fixedProblems(ids []uint64) ([]model.SessionProblem, error) {
+	sps := make([]model.SessionProblem, 0, len(ids))
+	for _, id := range ids {
+		p, err := s.repo.FindProblemWithChoices(id)
+		if errors.Is(err, apperr.ErrNotFound) {
+			return nil, fmt.Errorf("problem %d: %w", id, apperr.ErrInvalidArgument)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("find problem %d: %w", id, err)
+		}
+		sps = append(sps, model.SessionProblem{ProblemID: p.ID, CategoryID: p.CategoryID})
+	}
+	return sps, nil
+}
+
+// toAssignment はリクエストを検証して課題にする。不正な値は ErrInvalidArgument。
+func (s *AssignmentService) toAssignment(classID uint64, req dto.CreateAssignmentRequest) (model.Assignment, error) {
+	a := model.Assignment{
+		ClassID:     classID,
+		Title:       strings.TrimSpace(req.Title),
+		TimeLimit:   time.Duration(req.TimeLimitMinutes) * time.Minute,
+		MaxAttempts: req.MaxAttempts,
+		ExamMode:    req.ExamMode,
+	}
+	if a.Title == "" || utf8.RuneCountInString(a.Title) > maxAssignmentTitleLength {
+		return a, apperr.ErrInvalidArgument
+	}
+	if a.MaxAttempts == 0 {
+		a.MaxAttempts = 1
+	}
+	if a.MaxAttempts < 1 || a.MaxAttempts > maxAssignmentAttempts {
+		return a, apperr.ErrInvalidArgument
+	}
+	if req.TimeLimitMinutes < 0 || req.TimeLimitMinutes > maxTimeLimitMinutes {
+		return a, apperr.ErrInvalidArgument
+	}
+
+	// 固定の問題セットと出題ルールはどちらか一方
+	if (len(req.ProblemIDs) == 0) == (len(req.Rules) == 0) {
+		return a, apperr.ErrInvalidArgument
+	}
+	total := 0
+	for _, id := range req.ProblemIDs {
+		if id <= 0 {
+			return a, apperr.ErrInvalidArgument
+		}
+		a.ProblemIDs = append(a.ProblemIDs, uint64(id))
+		total++
+	}
+	for _, rule := range req.Rules {
+		if rule.CategoryID < minCategoryID || rule.CategoryID > maxCategoryID || rule.Count < 1 {
+			return a, apperr.ErrInvalidArgument
+		}
+		a.Rules = append(a.Rules, model.AssignmentRule{CategoryID: rule.CategoryID, Count: rule.Count})
+		total += rule.Count
+	}
+	if total > maxAssignmentProblems {
+		return a, apperr.ErrInvalidArgument
+	}
+
+	dueAt, err := time.Parse(time.RFC3339, req.DueAt)
+	if err != nil || !dueAt.After(time.Now()) {
+		return a, apperr.ErrInvalidArgument
+	}
+	a.DueAt = dueAt
+	a.ClosesAt = dueAt.Add(defaultLateWindow)
+	if req.ClosesAt != "" {
+		closesAt, err := time.Parse(time.RFC3339, req.ClosesAt)
+		if err != nil || closesAt.Before(dueAt) {
+			return a, apperr.ErrInvalidArgument
+		}
+		a.ClosesAt = closesAt
+	}
+	return a, nil
+}
+
+// attemptStatus は1回分の受験の状態を返す。期限後の提出は Late。
+func attemptStatus(a model.Assignment, at model.AssignmentAttempt, now time.Time) string {
+	switch {
+	case at.FinishedAt != nil && at.FinishedAt.After(a.DueAt):
+		return AssignmentLate
+	case at.FinishedAt != nil:
+		return AssignmentSubmitted
+	case a.IsClosed(now):
+		return AssignmentMissed
+	default:
+		return AssignmentInProgress
+	}
+}
+
+// 生徒の状態として優先する順 (期限内の提出が最も良い)
+var assignmentStatusRank = map[string]int{
+	AssignmentSubmitted:  4,
+	AssignmentLate:       3,
+	AssignmentInProgress: 2,
+	AssignmentMissed:     1,
+	AssignmentNotStarted: 0,
+}
+
+// studentStatus は生徒の全受験から進み具合をまとめる。
+func studentStatus(a model.Assignment, attempts []model.AssignmentAttempt, now time.Time) dto.StudentAssignmentStatus {
+	st := dto.StudentAssignmentStatus{
+		Status:   AssignmentNotStarted,
+		Attempts: make([]dto.AssignmentAttempt, 0, len(attempts)),
+	}
+	if len(attempts) == 0 && a.IsClosed(now) {
+		st.Status = AssignmentMissed
+	}
+	for _, at := range attempts {
+		if at.SessionID == 0 {
+			continue
+		}
+		d := toAttemptDto(a, at, now)
+		if assignmentStatusRank[d.Status] > assignmentStatusRank[st.Status] {
+			st.Status = d.Status
+		}
+		st.Attempts = append(st.Attempts, d)
+	}
+	st.Late = st.Status == AssignmentLate
+	return st
+}
+
+// openAttempt は回答を続けられる受験 (未終了で締め切り前) を返す。
+func openAttempt(a model.Assignment, attempts []model.AssignmentAttempt, now time.Time) *model.AssignmentAttempt {
+	if a.IsClosed(now) {
+		return nil
+	}
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if attempts[i].SessionID != 0 && attempts[i].FinishedAt == nil {
+			return &attempts[i]
+		}
+	}
+	return nil
+}
+
+func toAssignmentDto(a model.Assignment, now time.Time) dto.Assignment {
+	d := dto.Assignment{
+		AssignmentID:     strconv.FormatUint(a.ID, 10),
+		ClassID:          strconv.FormatUint(a.ClassID, 10),
+		Title:            a.Title,
+		DueAt:            a.DueAt.In(jst).Format("2006-01-02 15:04:05"),
+		ClosesAt:         a.ClosesAt.In(jst).Format("2006-01-02 15:04:05"),
+		TimeLimitMinutes: int(a.TimeLimit / time.Minute),
+		MaxAttempts:      a.MaxAttempts,
+		ExamMode:         a.ExamMode,
+		Closed:           a.IsClosed(now),
+	}
+	for _, id := range a.ProblemIDs {
+		d.ProblemIDs = append(d.ProblemIDs, int64(id))
+	}
+	for _, rule := range a.Rules {
+		d.Rules = append(d.Rules, dto.AssignmentRule{CategoryID: rule.CategoryID, Count: rule.Count})
+	}
+	return d
+}
+
+func toAttemptDto(a model.Assignment, at model.AssignmentAttempt, now time.Time) dto.AssignmentAttempt {
+	status := attemptStatus(a, at, now)
+	d := dto.AssignmentAttempt{
+		Attempt:      at.Attempt,
+		SessionID:    strconv.FormatUint(at.SessionID, 10),
+		Status:       status,
+		Late:         status == AssignmentLate,
+		StartedAt:    at.CreatedAt.In(jst).Format("2006-01-02 15:04:05"),
+		Total:        at.Total,
+		CorrectCount: at.CorrectCount,
+	}
+	if at.FinishedAt != nil {
+		d.FinishedAt = at.FinishedAt.In(jst).Format("2006-01-02 15:04:05")
+	}
+	return d
+}
@@ -0,0 +1,290 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockAssignmentRepo はクラスの所属に加えて課題と受験をメモリ上に持つ。
+type mockAssignmentRepo struct {
+	*mockClassroomRepo
+	assignments map[uint64]*model.Assignment
+	attempts    []model.AssignmentAttempt
+	sessions    []model.TestSession
+	problems    map[uint64]model.Problem
+}
+
+func newMockAssignmentRepo() *mockAssignmentRepo {
+	return &mockAssignmentRepo{
+		mockClassroomRepo: newMockClassroomRepo(),
+		assignments:       make(map[uint64]*model.Assignment),
+		problems: map[uint64]model.Problem{
+			10: {ID: 10, CategoryID: 1},
+			11: {ID: 11, CategoryID: 2},
+		},
+	}
+}
+
+func (m *mockAssignmentRepo) CreateAssignment(a *model.Assignment) error {
+	a.ID = uint64(len(m.assignments) + 1)
+	a.CreatedAt = time.Now()
+	m.assignments[a.ID] = a
+	return nil
+}
+
+func (m *mockAssignmentRepo) FindAssignment(classID, assignmentID uint64) (*model.Assignment, error) {
+	a, ok := m.assignments[assignmentID]
+	if !ok || a.ClassID != classID {
+		return nil, apperr.ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *mockAssignmentRepo) FindAssignments(classID uint64) ([]model.Assignment, error) {
+	var result []model.Assignment
+	for id := uint64(1); id <= uint64(len(m.assignments)); id++ {
+		if a := m.assignments[id]; a.ClassID == classID {
+			result = append(result, *a)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAssignmentRepo) FindProblemsPerCategory(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
+	var result []model.Problem
+	for _, cat := range categoryIDs {
+		for i := 0; i < countPerCategory; i++ {
+			result = append(result, model.Problem{ID: uint64(cat*100 + i), CategoryID: cat})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAssignmentRepo) FindProblemWithChoices(problemID uint64) (*model.Problem, error) {
+	p, ok := m.problems[problemID]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (m *mockAssignmentRepo) CreateAssignmentSession(attempt *model.AssignmentAttempt, session *model.TestSession, sps []model.SessionProblem) error {
+	for _, at := range m.attempts {
+		if at.AssignmentID == attempt.AssignmentID && at.UserSub == attempt.UserSub && at.Attempt == attempt.Attempt {
+			return apperr.ErrConflict
+		}
+	}
+	session.ID = uint64(len(m.sessions) + 1)
+	session.AssignmentID = attempt.AssignmentID
+	session.Attempt = attempt.Attempt
+	session.SessionProblems = sps
+	m.sessions = append(m.sessions, *session)
+
+	attempt.SessionID = session.ID
+	attempt.CreatedAt = time.Now()
+	m.attempts = append(m.attempts, *attempt)
+	return nil
+}
+
+func (m *mockAssignmentRepo) FindAssignmentAttempts(assignmentID uint64, userSub string) ([]model.AssignmentAttempt, error) {
+	var result []model.AssignmentAttempt
+	for _, at := range m.attempts {
+		if at.AssignmentID == assignmentID && (userSub == "" || at.UserSub == userSub) {
+			result = append(result, at)
+		}
+	}
+	return result, nil
+}
+
+// finish はテスト用に受験を終了済みにする。
+func (m *mockAssignmentRepo) finish(userSub string, attempt int, at time.Time) {
+	for i := range m.attempts {
+		if m.attempts[i].UserSub == userSub && m.attempts[i].Attempt == attempt {
+			m.attempts[i].FinishedAt = &at
+		}
+	}
+}
+
+func newAssignmentClass() *mockAssignmentRepo {
+	repo := newMockAssignmentRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student-a", model.ClassRoleStudent)
+	repo.addMember(1, "student-b", model.ClassRoleStudent)
+	return repo
+}
+
+func assignmentRequest(due time.Time) dto.CreateAssignmentRequest {
+	return dto.CreateAssignmentRequest{
+		Title:            "第1回 小テスト",
+		ProblemIDs:       []int64{10, 11},
+		DueAt:            due.Format(time.RFC3339),
+		TimeLimitMinutes: 30,
+		MaxAttempts:      2,
+	}
+}
+
+func TestCreateAssignment_GeneratesSessionForEachStudent(t *testing.T) {
+	repo := newAssignmentClass()
+	svc := service.NewAssignmentService(repo)
+
+	a, err := svc.CreateAssignment(principal("teacher"), 1, assignmentRequest(time.Now().Add(48*time.Hour)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if a.GeneratedSessions != 2 {
+		t.Errorf("expected 2 generated sessions, got %d", a.GeneratedSessions)
+	}
+	if len(repo.sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(repo.sessions))
+	}
+	for _, sess := range repo.sessions {
+		if sess.UserID == "teacher" {
+			t.Errorf("teacher must not get a session")
+		}
+		if len(sess.SessionProblems) != 2 || sess.SessionProblems[0].ProblemID != 10 {
+			t.Errorf("expected fixed problem set, got %+v", sess.SessionProblems)
+		}
+		if sess.TimeLimit != 30*time.Minute || sess.ClosesAt == nil {
+			t.Errorf("expected time limit and close time on session, got %+v", sess)
+		}
+	}
+}
+
+func TestCreateAssignment_Validation(t *testing.T) {
+	svc := service.NewAssignmentService(newAssignmentClass())
+	due := time.Now().Add(48 * time.Hour)
+
+	tests := map[string]func(r *dto.CreateAssignmentRequest){
+		"empty title":       func(r *dto.CreateAssignmentRequest) { r.Title = " " },
+		"past due":          func(r *dto.CreateAssignmentRequest) { r.DueAt = time.Now().Add(-time.Hour).Format(time.RFC3339) },
+		"closes before due": func(r *dto.CreateAssignmentRequest) { r.ClosesAt = due.Add(-time.Hour).Format(time.RFC3339) },
+		"problems and rules": func(r *dto.CreateAssignmentRequest) {
+			r.Rules = []dto.AssignmentRule{{CategoryID: 1, Count: 2}}
+		},
+		"no problems":     func(r *dto.CreateAssignmentRequest) { r.ProblemIDs = nil },
+		"unknown problem": func(r *dto.CreateAssignmentRequest) { r.ProblemIDs = []int64{999} },
+		"too many attempts": func(r *dto.CreateAssignmentRequest) {
+			r.MaxAttempts = 11
+		},
+	}
+	for name, mutate := range tests {
+		req := assignmentRequest(due)
+		mutate(&req)
+		if _, err := svc.CreateAssignment(principal("teacher"), 1, req); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+
+	if _, err := svc.CreateAssignment(principal("student-a"), 1, assignmentRequest(due)); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for student, got %v", err)
+	}
+}
+
+func TestCreateAssignment_RulesPickProblemsPerStudent(t *testing.T) {
+	repo := newAssignmentClass()
+	svc := service.NewAssignmentService(repo)
+	req := assignmentRequest(time.Now().Add(48 * time.Hour))
+	req.ProblemIDs = nil
+	req.Rules = []dto.AssignmentRule{{CategoryID: 1, Count: 2}, {CategoryID: 3, Count: 1}}
+
+	if _, err := svc.CreateAssignment(principal("teacher"), 1, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, sess := range repo.sessions {
+		if len(sess.SessionProblems) != 3 {
+			t.Errorf("expected 3 problems from rules, got %d", len(sess.SessionProblems))
+		}
+	}
+}
+
+func TestStartAttempt(t *testing.T) {
+	repo := newAssignmentClass()
+	svc := service.NewAssignmentService(repo)
+	if _, err := svc.CreateAssignment(principal("teacher"), 1, assignmentRequest(time.Now().Add(48*time.Hour))); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	student := principal("student-a")
+
+	// 配布済みの未終了セッションを返す
+	at, created, err := svc.StartAttempt(student, 1, 1)
+	if err != nil || created || at.Attempt != 1 {
+		t.Fatalf("expected existing attempt 1, got %+v created=%v err=%v", at, created, err)
+	}
+
+	repo.finish("student-a", 1, time.Now())
+	at, created, err = svc.StartAttempt(student, 1, 1)
+	if err != nil || !created || at.Attempt != 2 {
+		t.Fatalf("expected new attempt 2, got %+v created=%v err=%v", at, created, err)
+	}
+
+	repo.finish("student-a", 2, time.Now())
+	if _, _, err := svc.StartAttempt(student, 1, 1); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("expected ErrConflict when no attempts left, got %v", err)
+	}
+	if _, _, err := svc.StartAttempt(principal("teacher"), 1, 1); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for teacher, got %v", err)
+	}
+}
+
+func TestStartAttempt_Closed(t *testing.T) {
+	repo := newAssignmentClass()
+	past := time.Now().Add(-time.Hour)
+	repo.assignments[1] = &model.Assignment{ID: 1, ClassID: 1, Title: "t", DueAt: past.Add(-time.Hour), ClosesAt: past, MaxAttempts: 1}
+	svc := service.NewAssignmentService(repo)
+
+	if _, _, err := svc.StartAttempt(principal("student-a"), 1, 1); !errors.Is(err, apperr.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestGetAssignmentStatus_FlagsLateAndMissed(t *testing.T) {
+	repo := newAssignmentClass()
+	repo.addMember(1, "student-c", model.ClassRoleStudent)
+	now := time.Now()
+	due := now.Add(-2 * time.Hour)
+	repo.assignments[1] = &model.Assignment{ID: 1, ClassID: 1, Title: "t", DueAt: due, ClosesAt: now.Add(-time.Hour), MaxAttempts: 1}
+	onTime, late := due.Add(-time.Minute), due.Add(time.Minute)
+	repo.attempts = []model.AssignmentAttempt{
+		{AssignmentID: 1, UserSub: "student-a", Attempt: 1, SessionID: 1, FinishedAt: &onTime},
+		{AssignmentID: 1, UserSub: "student-b", Attempt: 1, SessionID: 2, FinishedAt: &late},
+		{AssignmentID: 1, UserSub: "student-c", Attempt: 1, SessionID: 3},
+	}
+	svc := service.NewAssignmentService(repo)
+
+	st, err := svc.GetAssignmentStatus(principal("teacher"), 1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !st.Assignment.Closed {
+		t.Errorf("expected assignment to be closed")
+	}
+	want := map[string]string{
+		"student-a": service.AssignmentSubmitted,
+		"student-b": service.AssignmentLate,
+		"student-c": service.AssignmentMissed,
+	}
+	if len(st.Students) != len(want) {
+		t.Fatalf("expected %d students, got %d", len(want), len(st.Students))
+	}
+	for _, s := range st.Students {
+		if s.Status != want[s.UserSub] {
+			t.Errorf("%s: expected %s, got %s", s.UserSub, want[s.UserSub], s.Status)
+		}
+		if s.Late != (s.UserSub == "student-b") {
+			t.Errorf("%s: unexpected late flag %v", s.UserSub, s.Late)
+		}
+	}
+	if st.SubmittedCount != 2 || st.LateCount != 1 {
+		t.Errorf("expected submitted=2 late=1, got %d %d", st.SubmittedCount, st.LateCount)
+	}
+
+	if _, err := svc.GetAssignmentStatus(principal("student-a"), 1, 1); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for student, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// GetCategoryStats は回答時に更新しているカテゴリ別累計を返す。SP を読み直さないため件数によらず軽い。
+func (s *MypageService) GetCategoryStats(user *model.User) (*dto.CategoryStats, error) {
+	stats, err := s.repo.FindCategoryStats(user.Sub)
+	if err != nil {
+		return nil, fmt.Errorf("find category stats: %w", err)
+	}
+	return s.toCategoryStats(stats, userLocation(user)), nil
+}
+
+// RebuildCategoryStats は全セッションの SP と回答イベントからカテゴリ別累計を計算し直して保存する。
+// 累計が生データとずれた場合の修復用。回答と並行して実行すると、その回答の差分が失われることがある。
+func (s *MypageService) RebuildCategoryStats(userSub string) (*dto.CategoryStats, error) {
+	sessions, err := s.allSessions(userSub)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := make(map[int]int)
+	var stats []model.CategoryStat
+	for _, sum := range sessions {
+		sps, err := s.repo.FindSessionProblemsBySessionID(sum.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("find session problems: %w", err)
+		}
+		lastAnswered, err := s.lastAnsweredAt(sum.SessionID, sps)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sp := range sps {
+			var at *time.Time
+			switch {
+			case sp.SelectedChoiceID != nil:
+				at = sp.AnsweredAt
+				if t, ok := lastAnswered[sp.ID]; ok {
+					at = &t
+				}
+			case sum.FinishedAt != nil:
+				// 終了したセッションの未回答は不正解として数える
+				at = sum.FinishedAt
+			default:
+				continue
+			}
+
+			i, exists := idx[sp.CategoryID]
+			if !exists {
+				i = len(stats)
+				idx[sp.CategoryID] = i
+				stats = append(stats, model.CategoryStat{CategoryID: sp.CategoryID, CategoryName: sp.CategoryName})
+			}
+			st := &stats[i]
+			st.Attempts++
+			if sp.IsCorrect != nil && *sp.IsCorrect {
+				st.CorrectCount++
+			}
+			if at != nil && (st.LastAttemptedAt == nil || at.After(*st.LastAttemptedAt)) {
+				t := *at
+				st.LastAttemptedAt = &t
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].CategoryID < stats[j].CategoryID
+	})
+
+	if err := s.repo.ReplaceCategoryStats(userSub, stats); err != nil {
+		return nil, fmt.Errorf("replace category stats: %w", err)
+	}
+	return s.toCategoryStats(stats, jst), nil
+}
+
+// lastAnsweredAt は SP ごとの最後の回答時刻を回答イベントから求める。回答済みの SP が無ければ Query しない。
+func (s *MypageService) lastAnsweredAt(sessionID uint64, sps []model.SessionProblem) (map[uint64]time.Time, error) {
+	last := make(map[uint64]time.Time)
+	answered := false
+	for _, sp := range sps {
+		if sp.SelectedChoiceID != nil {
+			answered = true
+			break
+		}
+	}
+	if !answered {
+		return last, nil
+	}
+
+	events, err := s.repo.FindAnswerEvents(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("find answer events: %w", err)
+	}
+	for _, e := range events {
+		if e.AnsweredAt.After(last[e.SessionProblemID]) {
+			last[e.SessionProblemID] = e.AnsweredAt
+		}
+	}
+	return last, nil
+}
+
+func (s *MypageService) toCategoryStats(stats []model.CategoryStat, loc *time.Location) *dto.CategoryStats {
+	result := &dto.CategoryStats{Categories: make([]dto.CategoryStat, 0, len(stats))}
+	forWeak := make([]repository.CategoryStats, 0, len(stats))
+	for _, st := range stats {
+		c := dto.CategoryStat{
+			CategoryName: st.CategoryName,
+			Attempts:     st.Attempts,
+			CorrectCount: st.CorrectCount,
+		}
+		if st.Attempts > 0 {
+			c.Accuracy = float64(st.CorrectCount) / float64(st.Attempts)
+		}
+		if st.LastAttemptedAt != nil {
+			c.LastAttemptedAt = st.LastAttemptedAt.In(loc).Format("2006-01-02 15:04:05")
+		}
+		result.Categories = append(result.Categories, c)
+		forWeak = append(forWeak, repository.CategoryStats{
+			Name:         st.CategoryName,
+			TotalCount:   st.Attempts,
+			CorrectCount: st.CorrectCount,
+		})
+	}
+	result.WeakCategories = s.weak.Select(forWeak)
+	return result
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+func TestGetCategoryStats_AccuracyAndWeak(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &mockMypageRepo{
+		findCategoryStatsFn: func(userSub string) ([]model.CategoryStat, error) {
+			return []model.CategoryStat{
+				{CategoryID: 1, CategoryName: "数と式", Attempts: 4, CorrectCount: 3, LastAttemptedAt: &at},
+				{CategoryID: 5, CategoryName: "確率", Attempts: 4, CorrectCount: 1},
+			}, nil
+		},
+	}
+	svc := service.NewMypageService(repo).WithWeakPolicy(service.DefaultWeakPolicy())
+
+	result, err := svc.GetCategoryStats(&model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(result.Categories) != 2 || result.Categories[0].Accuracy != 0.75 {
+		t.Fatalf("unexpected categories: %+v", result.Categories)
+	}
+	if result.Categories[0].LastAttemptedAt != "2026-01-01 09:00:00" {
+		t.Errorf("expected LastAttemptedAt in JST, got %s", result.Categories[0].LastAttemptedAt)
+	}
+	if len(result.WeakCategories) != 1 || result.WeakCategories[0] != "確率" {
+		t.Errorf("expected weak = [確率], got %v", result.WeakCategories)
+	}
+}
+
+func TestRebuildCategoryStats_RecomputesFromRawData(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	finished := start.Add(time.Hour)
+	first, last := start.Add(time.Minute), start.Add(10*time.Minute)
+	choice, right, wrong := uint64(1), true, false
+
+	var saved []model.CategoryStat
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			repository.SessionSummary{SessionID: 1, StartTime: start, FinishedAt: &finished},
+			repository.SessionSummary{SessionID: 2, StartTime: start},
+		),
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			if sessionID == 1 {
+				return []model.SessionProblem{
+					{ID: 11, CategoryID: 1, CategoryName: "数と式", SelectedChoiceID: &choice, IsCorrect: &right, AnsweredAt: &first},
+					{ID: 12, CategoryID: 1, CategoryName: "数と式"},
+				}, nil
+			}
+			// 終了していないセッションの未回答は数えない
+			return []model.SessionProblem{
+				{ID: 21, CategoryID: 5, CategoryName: "確率", SelectedChoiceID: &choice, IsCorrect: &wrong, AnsweredAt: &first},
+				{ID: 22, CategoryID: 5, CategoryName: "確率"},
+			}, nil
+		},
+		findAnswerEventsFn: func(sessionID uint64) ([]model.AnswerEvent, error) {
+			if sessionID == 2 {
+				return []model.AnswerEvent{
+					{SessionProblemID: 21, AnsweredAt: first},
+					{SessionProblemID: 21, AnsweredAt: last},
+				}, nil
+			}
+			return nil, nil
+		},
+		replaceCategoryStatsFn: func(userSub string, stats []model.CategoryStat) error {
+			saved = stats
+			return nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	if _, err := svc.RebuildCategoryStats("sub-1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 categories, got %+v", saved)
+	}
+	if s := saved[0]; s.CategoryID != 1 || s.Attempts != 2 || s.CorrectCount != 1 || !s.LastAttemptedAt.Equal(finished) {
+		t.Errorf("unexpected 数と式 stat: %+v", s)
+	}
+	if s := saved[1]; s.CategoryID != 5 || s.Attempts != 1 || s.CorrectCount != 0 || !s.LastAttemptedAt.Equal(last) {
+		t.Errorf("unexpected 確率 stat: %+v", s)
+	}
+}
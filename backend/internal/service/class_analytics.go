@@ -0,0 +1,370 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	// 苦手が続いているかを判定する直近のセッション数
+	atRiskRecentSessions = 5
+	// よく間違えられた問題として返す件数
+	classMostMissedSize = 10
+	// 得点分布の区間の幅 (%)
+	scoreBucketWidth = 10
+	// 生徒に集計を返す最少人数。これ未満だと集計から個人の成績が推測できる
+	minAggregateStudents = 3
+	// 集計方法を変えたときに古いキャッシュを使わないための版
+	classAnalyticsVersion = 1
+)
+
+type ClassAnalyticsService struct {
+	repo   repository.ClassAnalyticsRepo
+	weak   WeakPolicy
+	policy *AccessPolicy
+}
+
+// NewClassAnalyticsService は環境変数で設定した苦手カテゴリの判定基準を使うサービスを返す。
+func NewClassAnalyticsService(r repository.ClassAnalyticsRepo) *ClassAnalyticsService {
+	return &ClassAnalyticsService{repo: r, weak: WeakPolicyFromEnv(), policy: NewAccessPolicy(r)}
+}
+
+// WithWeakPolicy は要注意の生徒の判定に使う苦手カテゴリの基準を差し替える。
+func (s *ClassAnalyticsService) WithWeakPolicy(p WeakPolicy) *ClassAnalyticsService {
+	s.weak = p
+	return s
+}
+
+// classStudent は集計に使う生徒1人分のデータ。sessions は新しい順。
+type classStudent struct {
+	member   model.ClassMember
+	stats    []model.CategoryStat
+	sessions []repository.SessionSummary
+}
+
+// GetClassAnalytics はクラス全体の集計を返す。教師には要注意の生徒も含め、生徒にはクラス全体の集計だけを返す。
+// SP を読む重い集計はクラスごとに保存し、クラスの版 (生徒の回答・終了・所属の変更で進む) が変わるまで使い回す。
+// 保存済みの集計が使えるかはキャッシュのアイテム1件を読むだけで判定し、生徒ごとのデータは読まない。
+func (s *ClassAnalyticsService) GetClassAnalytics(actor *identity.Principal, classID uint64) (*dto.ClassAnalytics, error) {
+	member, err := s.policy.AuthorizeClassMember(actor.Sub, classID)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.repo.FindClassAnalytics(classID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		c, err = &model.ClassAnalyticsCache{ClassID: classID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find class analytics: %w", err)
+	}
+	result := s.cached(c)
+	if result == nil {
+		students, err := s.students(classID)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = s.compute(classID, students); err != nil {
+			return nil, err
+		}
+		// 読み取った版で計算したものとして保存する。計算中に版が進んでいれば次の読み取りで再計算される
+		if err := s.save(classID, c.Version, result); err != nil {
+			return nil, err
+		}
+	}
+
+	if member.Role != model.ClassRoleTeacher {
+		return forStudent(result), nil
+	}
+	return result, nil
+}
+
+// students はクラスの生徒ごとにカテゴリ別累計と全セッションの集計を読む。
+func (s *ClassAnalyticsService) students(classID uint64) ([]classStudent, error) {
+	members, err := s.repo.FindClassMembers(classID)
+	if err != nil {
+		return nil, fmt.Errorf("find class members: %w", err)
+	}
+	var students []classStudent
+	for _, m := range members {
+		if m.Role != model.ClassRoleStudent {
+			continue
+		}
+		stats, err := s.repo.FindCategoryStats(m.UserSub)
+		if err != nil {
+			return nil, fmt.Errorf("find category stats: %w", err)
+		}
+		sessions, err := findAllSessions(s.repo, m.UserSub)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, classStudent{member: m, stats: stats, sessions: sessions})
+	}
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].member.UserSub < students[j].member.UserSub
+	})
+	return students, nil
+}
+
+// params は集計方法の版と苦手の判定基準。変えた場合は保存済みの集計を使わない。
+func (s *ClassAnalyticsService) params() string {
+	return fmt.Sprintf("v%d|%v", classAnalyticsVersion, s.weak)
+}
+
+// cached は最新の版で計算済みの集計を返す。無い・古い・読めない場合は nil。
+func (s *ClassAnalyticsService) cached(c *model.ClassAnalyticsCache) *dto.ClassAnalytics {
+	if !c.IsFresh(s.params()) {
+		return nil
+	}
+	var result dto.ClassAnalytics
+	if err := json.Unmarshal(c.Payload, &result); err != nil {
+		return nil
+	}
+	result.Cached = true
+	return &result
+}
+
+func (s *ClassAnalyticsService) save(classID uint64, version int64, result *dto.ClassAnalytics) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal class analytics: %w", err)
+	}
+	err = s.repo.SaveClassAnalytics(&model.ClassAnalyticsCache{
+		ClassID:         classID,
+		ComputedVersion: version,
+		Params:          s.params(),
+		Payload:         payload,
+		GeneratedAt:     time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("save class analytics: %w", err)
+	}
+	return nil
+}
+
+// compute は生徒全員の累計とセッションからクラスの集計を計算する。
+func (s *ClassAnalyticsService) compute(classID uint64, students []classStudent) (*dto.ClassAnalytics, error) {
+	result := &dto.ClassAnalytics{
+		ClassID:           strconv.FormatUint(classID, 10),
+		GeneratedAt:       time.Now().In(jst).Format("2006-01-02 15:04:05"),
+		StudentCount:      len(students),
+		Categories:        classCategories(students),
+		ScoreDistribution: make([]dto.ScoreBucket, 0, 100/scoreBucketWidth),
+		MostMissed:        []dto.MissedProblem{},
+		AtRiskStudents:    []dto.AtRiskStudent{},
+	}
+	for lo := 0; lo < 100; lo += scoreBucketWidth {
+		result.ScoreDistribution = append(result.ScoreDistribution, dto.ScoreBucket{Min: lo, Max: lo + scoreBucketWidth})
+	}
+
+	type missed struct {
+		categoryName      string
+		attempts, missCnt int
+		students          map[string]bool
+	}
+	problems := make(map[uint64]*missed)
+	var scoreSum float64
+	for _, st := range students {
+		active := false
+		for _, c := range st.stats {
+			if c.Attempts > 0 {
+				active = true
+			}
+		}
+		if active {
+			result.ActiveStudents++
+		}
+
+		for _, sum := range st.sessions {
+			if sum.FinishedAt != nil && sum.Total > 0 {
+				score := float64(sum.CorrectCount) / float64(sum.Total) * 100
+				result.ScoreDistribution[scoreBucket(score)].Count++
+				scoreSum += score
+				result.SessionCount++
+			}
+
+			sps, err := s.repo.FindSessionProblemsBySessionID(sum.SessionID)
+			if err != nil {
+				return nil, fmt.Errorf("find session problems: %w", err)
+			}
+			for _, sp := range sps {
+				// 終了したセッションの未回答は不正解として数える
+				if sp.SelectedChoiceID == nil && sum.FinishedAt == nil {
+					continue
+				}
+				p, ok := problems[sp.ProblemID]
+				if !ok {
+					p = &missed{categoryName: sp.CategoryName, students: make(map[string]bool)}
+					problems[sp.ProblemID] = p
+				}
+				p.attempts++
+				if sp.IsCorrect == nil || !*sp.IsCorrect {
+					p.missCnt++
+					p.students[st.member.UserSub] = true
+				}
+			}
+		}
+
+		if risk := s.atRisk(st); risk != nil {
+			result.AtRiskStudents = append(result.AtRiskStudents, *risk)
+		}
+	}
+	if result.SessionCount > 0 {
+		avg := scoreSum / float64(result.SessionCount)
+		result.AverageScore = &avg
+	}
+	sort.SliceStable(result.AtRiskStudents, func(i, j int) bool {
+		a, b := result.AtRiskStudents[i], result.AtRiskStudents[j]
+		if len(a.WeakCategories) != len(b.WeakCategories) {
+			return len(a.WeakCategories) > len(b.WeakCategories)
+		}
+		return a.Accuracy < b.Accuracy
+	})
+
+	ids := make([]uint64, 0, len(problems))
+	for id, p := range problems {
+		if p.missCnt > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := problems[ids[i]], problems[ids[j]]
+		if a.missCnt != b.missCnt {
+			return a.missCnt > b.missCnt
+		}
+		ra, rb := float64(a.missCnt)/float64(a.attempts), float64(b.missCnt)/float64(b.attempts)
+		if ra != rb {
+			return ra > rb
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > classMostMissedSize {
+		ids = ids[:classMostMissedSize]
+	}
+	for _, id := range ids {
+		p := problems[id]
+		mp := dto.MissedProblem{
+			ProblemID:    int64(id),
+			CategoryName: p.categoryName,
+			Attempts:     p.attempts,
+			MissCount:    p.missCnt,
+			MissRate:     float64(p.missCnt) / float64(p.attempts),
+			StudentCount: len(p.students),
+		}
+		// 削除された問題は問題文なしで返す
+		problem, err := s.repo.FindProblemWithChoices(id)
+		switch {
+		case err == nil:
+			mp.Question = problem.Question
+		case !errors.Is(err, apperr.ErrNotFound):
+			return nil, fmt.Errorf("find problem %d: %w", id, err)
+		}
+		result.MostMissed = append(result.MostMissed, mp)
+	}
+	return result, nil
+}
+
+// atRisk は累計と直近のセッションの両方で苦手と判定されたカテゴリがある生徒を返す。無ければ nil。
+// 累計だけだと克服済みの生徒が、直近だけだとたまたま1回失敗した生徒が混ざるため両方を求める。
+func (s *ClassAnalyticsService) atRisk(st classStudent) *dto.AtRiskStudent {
+	stats := make([]repository.CategoryStats, 0, len(st.stats))
+	var attempts, correct int
+	for _, c := range st.stats {
+		stats = append(stats, repository.CategoryStats{Name: c.CategoryName, TotalCount: c.Attempts, CorrectCount: c.CorrectCount})
+		attempts += c.Attempts
+		correct += c.CorrectCount
+	}
+	recent := st.sessions
+	if len(recent) > atRiskRecentSessions {
+		recent = recent[:atRiskRecentSessions]
+	}
+	recentWeak := make(map[string]bool)
+	for _, name := range s.weak.SelectFromHistory(recent) {
+		recentWeak[name] = true
+	}
+
+	var weak []string
+	for _, name := range s.weak.Select(stats) {
+		if recentWeak[name] {
+			weak = append(weak, name)
+		}
+	}
+	if len(weak) == 0 {
+		return nil
+	}
+	return &dto.AtRiskStudent{
+		UserSub:        st.member.UserSub,
+		UserName:       st.member.UserName,
+		WeakCategories: weak,
+		Accuracy:       float64(correct) / float64(attempts),
+	}
+}
+
+// classCategories はカテゴリ別累計からクラス平均を求める。カテゴリID順。
+func classCategories(students []classStudent) []dto.ClassCategoryStat {
+	type agg struct {
+		stat   dto.ClassCategoryStat
+		accSum float64
+	}
+	byID := make(map[int]*agg)
+	var ids []int
+	for _, st := range students {
+		for _, c := range st.stats {
+			if c.Attempts == 0 {
+				continue
+			}
+			a, ok := byID[c.CategoryID]
+			if !ok {
+				a = &agg{stat: dto.ClassCategoryStat{CategoryName: c.CategoryName}}
+				byID[c.CategoryID] = a
+				ids = append(ids, c.CategoryID)
+			}
+			a.stat.StudentCount++
+			a.stat.Attempts += c.Attempts
+			a.stat.CorrectCount += c.CorrectCount
+			a.accSum += float64(c.CorrectCount) / float64(c.Attempts)
+		}
+	}
+	sort.Ints(ids)
+
+	result := make([]dto.ClassCategoryStat, 0, len(ids))
+	for _, id := range ids {
+		a := byID[id]
+		a.stat.AverageAccuracy = a.accSum / float64(a.stat.StudentCount)
+		result = append(result, a.stat)
+	}
+	return result
+}
+
+// scoreBucket は得点率 (%) が入る区間の位置を返す。100% は最後の区間に入れる。
+func scoreBucket(score float64) int {
+	i := int(score) / scoreBucketWidth
+	if last := 100/scoreBucketWidth - 1; i > last {
+		i = last
+	}
+	return i
+}
+
+// forStudent は生徒向けに個人が特定できる情報を除いた集計を返す。
+func forStudent(a *dto.ClassAnalytics) *dto.ClassAnalytics {
+	result := *a
+	result.AtRiskStudents = nil
+	if result.ActiveStudents < minAggregateStudents {
+		result.Suppressed = true
+		result.AverageScore = nil
+		result.Categories = []dto.ClassCategoryStat{}
+		result.ScoreDistribution = []dto.ScoreBucket{}
+		result.MostMissed = []dto.MissedProblem{}
+	}
+	return &result
+}
@@ -0,0 +1,259 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockClassAnalyticsRepo はクラスの所属に加えて生徒ごとの累計・セッション・SP とキャッシュをメモリ上に持つ。
+type mockClassAnalyticsRepo struct {
+	*mockClassroomRepo
+	stats     map[string][]model.CategoryStat
+	sessions  map[string][]repository.SessionSummary
+	sps       map[uint64][]model.SessionProblem
+	cache     *model.ClassAnalyticsCache
+	version   int64 // 回答・終了・所属の変更で進むクラスの版
+	spReads   int
+	statReads int
+}
+
+func newMockClassAnalyticsRepo() *mockClassAnalyticsRepo {
+	return &mockClassAnalyticsRepo{
+		mockClassroomRepo: newMockClassroomRepo(),
+		stats:             make(map[string][]model.CategoryStat),
+		sessions:          make(map[string][]repository.SessionSummary),
+		sps:               make(map[uint64][]model.SessionProblem),
+	}
+}
+
+func (m *mockClassAnalyticsRepo) FindCategoryStats(userSub string) ([]model.CategoryStat, error) {
+	m.statReads++
+	return m.stats[userSub], nil
+}
+
+func (m *mockClassAnalyticsRepo) FindSessionSummaries(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+	return &repository.SessionPage{Sessions: m.sessions[userSub]}, nil
+}
+
+func (m *mockClassAnalyticsRepo) FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error) {
+	m.spReads++
+	return m.sps[sessionID], nil
+}
+
+func (m *mockClassAnalyticsRepo) FindProblemWithChoices(problemID uint64) (*model.Problem, error) {
+	if problemID == 99 {
+		return nil, apperr.ErrNotFound
+	}
+	return &model.Problem{ID: problemID, Question: "問題"}, nil
+}
+
+func (m *mockClassAnalyticsRepo) FindClassAnalytics(classID uint64) (*model.ClassAnalyticsCache, error) {
+	if m.cache == nil && m.version == 0 {
+		return nil, apperr.ErrNotFound
+	}
+	c := model.ClassAnalyticsCache{ClassID: classID}
+	if m.cache != nil {
+		c = *m.cache
+	}
+	c.Version = m.version
+	return &c, nil
+}
+
+func (m *mockClassAnalyticsRepo) SaveClassAnalytics(c *model.ClassAnalyticsCache) error {
+	if m.cache == nil || m.cache.ComputedVersion <= c.ComputedVersion {
+		m.cache = c
+	}
+	return nil
+}
+
+// addSession はテスト用に生徒の終了済みセッションを1件追加する。correct[i] が i 問目の正誤。
+// 回答のトランザクションと同じくクラスの版を進める。
+func (m *mockClassAnalyticsRepo) addSession(userSub string, sessionID uint64, categoryName string, correct ...bool) {
+	m.version++
+	finished := time.Date(2026, 4, 1, 10, 0, int(sessionID), 0, time.UTC)
+	sum := repository.SessionSummary{SessionID: sessionID, FinishedAt: &finished, Total: len(correct)}
+	cat := repository.CategoryStats{Name: categoryName, TotalCount: len(correct)}
+	for i, ok := range correct {
+		choice := uint64(1)
+		isCorrect := ok
+		m.sps[sessionID] = append(m.sps[sessionID], model.SessionProblem{
+			ID: uint64(i + 1), TestSessionID: sessionID, ProblemID: uint64(10 + i), CategoryName: categoryName,
+			SelectedChoiceID: &choice, IsCorrect: &isCorrect,
+		})
+		if ok {
+			sum.CorrectCount++
+			cat.CorrectCount++
+		}
+	}
+	sum.Categories = []repository.CategoryStats{cat}
+	// 新しい順
+	m.sessions[userSub] = append([]repository.SessionSummary{sum}, m.sessions[userSub]...)
+
+	var stat *model.CategoryStat
+	for i := range m.stats[userSub] {
+		if m.stats[userSub][i].CategoryName == categoryName {
+			stat = &m.stats[userSub][i]
+		}
+	}
+	if stat == nil {
+		m.stats[userSub] = append(m.stats[userSub], model.CategoryStat{CategoryID: 1, CategoryName: categoryName})
+		stat = &m.stats[userSub][len(m.stats[userSub])-1]
+	}
+	stat.Attempts += len(correct)
+	stat.CorrectCount += cat.CorrectCount
+	stat.LastAttemptedAt = &finished
+}
+
+func newAnalyticsClass() *mockClassAnalyticsRepo {
+	repo := newMockClassAnalyticsRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student-a", model.ClassRoleStudent)
+	repo.addMember(1, "student-b", model.ClassRoleStudent)
+	repo.addMember(1, "student-c", model.ClassRoleStudent)
+	repo.addSession("student-a", 1, "数と式", true, true, true, true)
+	repo.addSession("student-b", 2, "数と式", true, false, true, false)
+	// student-c は累計でも直近でも苦手
+	repo.addSession("student-c", 3, "数と式", false, false, true, false)
+	repo.addSession("student-c", 4, "数と式", false, false, false, true)
+	return repo
+}
+
+func TestGetClassAnalytics_Aggregates(t *testing.T) {
+	svc := service.NewClassAnalyticsService(newAnalyticsClass()).WithWeakPolicy(service.DefaultWeakPolicy())
+
+	a, err := svc.GetClassAnalytics(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if a.StudentCount != 3 || a.ActiveStudents != 3 || a.SessionCount != 4 {
+		t.Errorf("unexpected counts: %+v", a)
+	}
+	if len(a.Categories) != 1 || a.Categories[0].StudentCount != 3 {
+		t.Fatalf("expected one category over 3 students, got %+v", a.Categories)
+	}
+	// 生徒ごとの正答率 (1.0, 0.5, 0.25) の平均
+	if got := a.Categories[0].AverageAccuracy; got < 0.583 || got > 0.584 {
+		t.Errorf("expected average accuracy 0.5833, got %v", got)
+	}
+	// 得点率 100, 50, 25, 25
+	want := map[int]int{90: 1, 50: 1, 20: 2}
+	for _, b := range a.ScoreDistribution {
+		if b.Count != want[b.Min] {
+			t.Errorf("bucket %d-%d: expected %d, got %d", b.Min, b.Max, want[b.Min], b.Count)
+		}
+	}
+	if a.AverageScore == nil || *a.AverageScore != 50 {
+		t.Errorf("expected average score 50, got %v", a.AverageScore)
+	}
+
+	if len(a.AtRiskStudents) != 1 || a.AtRiskStudents[0].UserSub != "student-c" {
+		t.Fatalf("expected only student-c at risk, got %+v", a.AtRiskStudents)
+	}
+	if w := a.AtRiskStudents[0].WeakCategories; len(w) != 1 || w[0] != "数と式" {
+		t.Errorf("unexpected weak categories: %v", w)
+	}
+
+	if len(a.MostMissed) == 0 || a.MostMissed[0].ProblemID != 11 {
+		t.Fatalf("expected problem 11 to be most missed, got %+v", a.MostMissed)
+	}
+	if m := a.MostMissed[0]; m.MissCount != 3 || m.Attempts != 4 || m.StudentCount != 2 {
+		t.Errorf("unexpected most missed stat: %+v", m)
+	}
+}
+
+func TestGetClassAnalytics_CachedUntilNewAnswer(t *testing.T) {
+	repo := newAnalyticsClass()
+	svc := service.NewClassAnalyticsService(repo)
+
+	first, err := svc.GetClassAnalytics(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	reads, statReads := repo.spReads, repo.statReads
+
+	second, err := svc.GetClassAnalytics(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first.Cached || !second.Cached || repo.spReads != reads {
+		t.Errorf("expected second call to be served from cache (cached=%v, sp reads %d -> %d)", second.Cached, reads, repo.spReads)
+	}
+	// キャッシュの判定では生徒ごとのデータを読まない
+	if repo.statReads != statReads {
+		t.Errorf("expected no category stat reads on cache hit, got %d", repo.statReads-statReads)
+	}
+
+	// 新しい回答で累計が変わるとキャッシュは使われない
+	repo.addSession("student-a", 5, "数と式", false)
+	third, err := svc.GetClassAnalytics(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if third.Cached || third.SessionCount != 5 {
+		t.Errorf("expected recomputed analytics with 5 sessions, got cached=%v sessions=%d", third.Cached, third.SessionCount)
+	}
+}
+
+func TestGetClassAnalytics_FinishInvalidatesCache(t *testing.T) {
+	repo := newAnalyticsClass()
+	// 全問回答済みで未終了のセッション
+	repo.addSession("student-a", 5, "数と式", true)
+	repo.sessions["student-a"][0].FinishedAt = nil
+	svc := service.NewClassAnalyticsService(repo)
+
+	if _, err := svc.GetClassAnalytics(principal("teacher"), 1); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	finished := time.Now()
+	repo.sessions["student-a"][0].FinishedAt = &finished
+	repo.version++
+
+	a, err := svc.GetClassAnalytics(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if a.Cached || a.SessionCount != 5 {
+		t.Errorf("expected finished session to be counted, got cached=%v sessions=%d", a.Cached, a.SessionCount)
+	}
+}
+
+func TestGetClassAnalytics_StudentView(t *testing.T) {
+	repo := newAnalyticsClass()
+	svc := service.NewClassAnalyticsService(repo)
+
+	a, err := svc.GetClassAnalytics(principal("student-a"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if a.AtRiskStudents != nil {
+		t.Errorf("students must not see at-risk students, got %+v", a.AtRiskStudents)
+	}
+	if a.Suppressed || len(a.Categories) == 0 {
+		t.Errorf("expected class aggregates for 3 active students, got %+v", a)
+	}
+
+	// 回答した生徒が2人以下なら集計を返さない
+	small := newMockClassAnalyticsRepo()
+	small.addMember(1, "teacher", model.ClassRoleTeacher)
+	small.addMember(1, "student-a", model.ClassRoleStudent)
+	small.addMember(1, "student-b", model.ClassRoleStudent)
+	small.addSession("student-a", 1, "数と式", true)
+	small.addSession("student-b", 2, "数と式", false)
+	a, err = service.NewClassAnalyticsService(small).GetClassAnalytics(principal("student-a"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !a.Suppressed || len(a.Categories) != 0 || len(a.ScoreDistribution) != 0 || a.AverageScore != nil {
+		t.Errorf("expected suppressed aggregates, got %+v", a)
+	}
+
+	if _, err := svc.GetClassAnalytics(principal("stranger"), 1); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for non-member, got %v", err)
+	}
+}
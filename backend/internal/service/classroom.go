@@ -0,0 +1,192 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	maxClassNameLength = 50
+
+	// 参加コードの長さと文字種。読み間違えやすい 0/O・1/I/L は使わない
+	joinCodeLength   = 8
+	joinCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// 参加コードが既存のクラスと重複した場合に作り直す最大回数
+	maxJoinCodeAttempts = 5
+)
+
+type ClassroomService struct {
+	repo   repository.ClassroomRepo
+	policy *AccessPolicy
+}
+
+func NewClassroomService(r repository.ClassroomRepo) *ClassroomService {
+	return &ClassroomService{repo: r, policy: NewAccessPolicy(r)}
+}
+
+// CreateClassroom はクラスを作成し、作成したユーザーを教師として所属させる。
+// 教師の役割を持つかはルーター (middleware.RequireRole) 側で確認する。
+func (s *ClassroomService) CreateClassroom(actor *identity.Principal, name string) (*dto.Classroom, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxClassNameLength {
+		return nil, apperr.ErrInvalidArgument
+	}
+
+	member := model.ClassMember{UserSub: actor.Sub, UserName: actor.Name, Role: model.ClassRoleTeacher}
+	for attempt := 0; attempt < maxJoinCodeAttempts; attempt++ {
+		code, err := newJoinCode()
+		if err != nil {
+			return nil, err
+		}
+		class := model.Classroom{Name: name, JoinCode: code, OwnerSub: actor.Sub}
+		err = s.repo.CreateClassroom(&class, &member)
+		if errors.Is(err, apperr.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create classroom: %w", err)
+		}
+		c := toClassroomDto(class, model.ClassRoleTeacher)
+		return &c, nil
+	}
+	return nil, fmt.Errorf("join code collision: gave up after %d attempts", maxJoinCodeAttempts)
+}
+
+// JoinClassroom は参加コードのクラスに生徒として参加する。既に所属している場合は ErrConflict。
+func (s *ClassroomService) JoinClassroom(actor *identity.Principal, joinCode string) (*dto.Classroom, error) {
+	code := strings.ToUpper(strings.TrimSpace(joinCode))
+	if code == "" {
+		return nil, apperr.ErrInvalidArgument
+	}
+	class, err := s.repo.FindClassroomByJoinCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	member := model.ClassMember{ClassID: class.ID, UserSub: actor.Sub, UserName: actor.Name, Role: model.ClassRoleStudent}
+	if err := s.repo.AddClassMember(&member); err != nil {
+		return nil, err
+	}
+	c := toClassroomDto(*class, model.ClassRoleStudent)
+	return &c, nil
+}
+
+// ListClassrooms はユーザーが教師または生徒として所属するクラスを作成の古い順に返す。
+func (s *ClassroomService) ListClassrooms(actor *identity.Principal) ([]dto.Classroom, error) {
+	memberships, err := s.repo.FindMemberships(actor.Sub)
+	if err != nil {
+		return nil, fmt.Errorf("find memberships: %w", err)
+	}
+
+	classes := make([]model.Classroom, 0, len(memberships))
+	roles := make(map[uint64]string, len(memberships))
+	for _, m := range memberships {
+		class, err := s.repo.FindClassroom(m.ClassID)
+		if errors.Is(err, apperr.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("find classroom %d: %w", m.ClassID, err)
+		}
+		classes = append(classes, *class)
+		roles[class.ID] = m.Role
+	}
+	sort.SliceStable(classes, func(i, j int) bool {
+		return classes[i].CreatedAt.Before(classes[j].CreatedAt)
+	})
+
+	result := make([]dto.Classroom, len(classes))
+	for i, class := range classes {
+		result[i] = toClassroomDto(class, roles[class.ID])
+	}
+	return result, nil
+}
+
+// GetClassroom はクラスと生徒の名簿を教師に返す。
+func (s *ClassroomService) GetClassroom(actor *identity.Principal, classID uint64) (*dto.ClassroomDetail, error) {
+	if err := s.policy.AuthorizeClassTeacher(actor.Sub, classID); err != nil {
+		return nil, err
+	}
+	class, err := s.repo.FindClassroom(classID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repo.FindClassMembers(classID)
+	if err != nil {
+		return nil, fmt.Errorf("find class members: %w", err)
+	}
+
+	detail := &dto.ClassroomDetail{
+		Classroom: toClassroomDto(*class, model.ClassRoleTeacher),
+		Students:  make([]dto.ClassMember, 0, len(members)),
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].UserName < members[j].UserName
+	})
+	for _, m := range members {
+		if m.Role != model.ClassRoleStudent {
+			continue
+		}
+		detail.Students = append(detail.Students, dto.ClassMember{
+			UserSub:  m.UserSub,
+			UserName: m.UserName,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt.In(jst).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return detail, nil
+}
+
+// RemoveStudent は教師がクラスから生徒を外す。
+func (s *ClassroomService) RemoveStudent(actor *identity.Principal, classID uint64, studentSub string) error {
+	if err := s.policy.AuthorizeClassTeacher(actor.Sub, classID); err != nil {
+		return err
+	}
+	return s.repo.RemoveClassMember(classID, studentSub)
+}
+
+// StudentUser は教師がクラスの生徒のマイページ相当のデータを見るための生徒ユーザーを返す。
+// 教師でなければ ErrForbidden、クラスの生徒でなければ ErrNotFound。
+func (s *ClassroomService) StudentUser(actor *identity.Principal, classID uint64, studentSub string) (*model.User, error) {
+	m, err := s.policy.AuthorizeStudentRead(actor.Sub, classID, studentSub)
+	if err != nil {
+		return nil, err
+	}
+	return &model.User{Sub: m.UserSub, UserName: m.UserName}, nil
+}
+
+func toClassroomDto(class model.Classroom, role string) dto.Classroom {
+	c := dto.Classroom{
+		ClassID:   strconv.FormatUint(class.ID, 10),
+		Name:      class.Name,
+		Role:      role,
+		CreatedAt: class.CreatedAt.In(jst).Format("2006-01-02 15:04:05"),
+	}
+	if role == model.ClassRoleTeacher {
+		c.JoinCode = class.JoinCode
+	}
+	return c
+}
+
+// newJoinCode はランダムな参加コードを作る。
+func newJoinCode() (string, error) {
+	b := make([]byte, joinCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate join code: %w", err)
+	}
+	for i := range b {
+		b[i] = joinCodeAlphabet[int(b[i])%len(joinCodeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockClassroomRepo はクラスと所属をメモリ上に持つ。
+type mockClassroomRepo struct {
+	classes   map[uint64]*model.Classroom
+	members   map[string]model.ClassMember // key: "<class_id>/<sub>"
+	nextID    uint64
+	takenJoin map[string]bool
+}
+
+func newMockClassroomRepo() *mockClassroomRepo {
+	return &mockClassroomRepo{
+		classes:   make(map[uint64]*model.Classroom),
+		members:   make(map[string]model.ClassMember),
+		takenJoin: make(map[string]bool),
+	}
+}
+
+func memberKey(classID uint64, sub string) string {
+	return fmt.Sprintf("%d/%s", classID, sub)
+}
+
+// addMember はテスト用に所属を直接追加する。
+func (m *mockClassroomRepo) addMember(classID uint64, sub, role string) {
+	if _, ok := m.classes[classID]; !ok {
+		m.classes[classID] = &model.Classroom{ID: classID, Name: fmt.Sprintf("class-%d", classID)}
+	}
+	m.members[memberKey(classID, sub)] = model.ClassMember{ClassID: classID, UserSub: sub, UserName: sub, Role: role}
+}
+
+func (m *mockClassroomRepo) CreateClassroom(class *model.Classroom, teacher *model.ClassMember) error {
+	if m.takenJoin[class.JoinCode] {
+		return apperr.ErrConflict
+	}
+	m.nextID++
+	class.ID = m.nextID
+	teacher.ClassID = class.ID
+	m.classes[class.ID] = class
+	m.takenJoin[class.JoinCode] = true
+	m.members[memberKey(class.ID, teacher.UserSub)] = *teacher
+	return nil
+}
+
+func (m *mockClassroomRepo) FindClassroom(classID uint64) (*model.Classroom, error) {
+	c, ok := m.classes[classID]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *mockClassroomRepo) FindClassroomByJoinCode(code string) (*model.Classroom, error) {
+	for _, c := range m.classes {
+		if c.JoinCode == code {
+			return c, nil
+		}
+	}
+	return nil, apperr.ErrNotFound
+}
+
+func (m *mockClassroomRepo) AddClassMember(cm *model.ClassMember) error {
+	key := memberKey(cm.ClassID, cm.UserSub)
+	if _, ok := m.members[key]; ok {
+		return apperr.ErrConflict
+	}
+	m.members[key] = *cm
+	return nil
+}
+
+func (m *mockClassroomRepo) RemoveClassMember(classID uint64, userSub string) error {
+	key := memberKey(classID, userSub)
+	cm, ok := m.members[key]
+	if !ok || cm.Role != model.ClassRoleStudent {
+		return apperr.ErrNotFound
+	}
+	delete(m.members, key)
+	return nil
+}
+
+func (m *mockClassroomRepo) FindClassMember(classID uint64, userSub string) (*model.ClassMember, error) {
+	cm, ok := m.members[memberKey(classID, userSub)]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	return &cm, nil
+}
+
+func (m *mockClassroomRepo) FindClassMembers(classID uint64) ([]model.ClassMember, error) {
+	var result []model.ClassMember
+	for _, cm := range m.members {
+		if cm.ClassID == classID {
+			result = append(result, cm)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockClassroomRepo) FindMemberships(userSub string) ([]model.ClassMember, error) {
+	var result []model.ClassMember
+	for _, cm := range m.members {
+		if cm.UserSub == userSub {
+			result = append(result, cm)
+		}
+	}
+	return result, nil
+}
+
+// --- ClassroomService ---
+
+func TestCreateAndJoinClassroom(t *testing.T) {
+	repo := newMockClassroomRepo()
+	svc := service.NewClassroomService(repo)
+
+	created, err := svc.CreateClassroom(&identity.Principal{Sub: "teacher", Name: "先生"}, "  1年A組 ")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.Name != "1年A組" || created.Role != model.ClassRoleTeacher || len(created.JoinCode) != 8 {
+		t.Fatalf("unexpected classroom: %+v", created)
+	}
+
+	joined, err := svc.JoinClassroom(&identity.Principal{Sub: "student", Name: "生徒"}, " "+created.JoinCode+" ")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if joined.ClassID != created.ClassID || joined.Role != model.ClassRoleStudent {
+		t.Errorf("unexpected joined classroom: %+v", joined)
+	}
+	if joined.JoinCode != "" {
+		t.Errorf("join code must not be returned to students, got %q", joined.JoinCode)
+	}
+
+	if _, err := svc.JoinClassroom(principal("student"), created.JoinCode); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("expected ErrConflict on second join, got %v", err)
+	}
+}
+
+func TestCreateClassroom_InvalidName(t *testing.T) {
+	svc := service.NewClassroomService(newMockClassroomRepo())
+
+	for _, name := range []string{"", "   ", string(make([]rune, 51))} {
+		if _, err := svc.CreateClassroom(principal("teacher"), name); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("name %q: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
+
+func TestGetClassroom_OnlyTeacher(t *testing.T) {
+	repo := newMockClassroomRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student", model.ClassRoleStudent)
+	svc := service.NewClassroomService(repo)
+
+	detail, err := svc.GetClassroom(principal("teacher"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(detail.Students) != 1 || detail.Students[0].UserSub != "student" {
+		t.Errorf("expected roster with only the student, got %+v", detail.Students)
+	}
+
+	for _, actor := range []string{"student", "stranger"} {
+		if _, err := svc.GetClassroom(principal(actor), 1); !errors.Is(err, apperr.ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden, got %v", actor, err)
+		}
+	}
+}
+
+func TestRemoveStudent_CannotRemoveTeacher(t *testing.T) {
+	repo := newMockClassroomRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student", model.ClassRoleStudent)
+	svc := service.NewClassroomService(repo)
+
+	if err := svc.RemoveStudent(principal("student"), 1, "student"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for student, got %v", err)
+	}
+	if err := svc.RemoveStudent(principal("teacher"), 1, "teacher"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when removing teacher, got %v", err)
+	}
+	if err := svc.RemoveStudent(principal("teacher"), 1, "student"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestStudentUser(t *testing.T) {
+	repo := newMockClassroomRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student", model.ClassRoleStudent)
+	repo.addMember(2, "other-student", model.ClassRoleStudent)
+	svc := service.NewClassroomService(repo)
+
+	user, err := svc.StudentUser(principal("teacher"), 1, "student")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if user.Sub != "student" {
+		t.Errorf("expected student, got %+v", user)
+	}
+
+	if _, err := svc.StudentUser(principal("teacher"), 1, "other-student"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for student of another class, got %v", err)
+	}
+	if _, err := svc.StudentUser(principal("teacher"), 2, "other-student"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for another class, got %v", err)
+	}
+	if _, err := svc.StudentUser(principal("student"), 1, "teacher"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for student actor, got %v", err)
+	}
+}
+
+// --- AccessPolicy ---
+
+func TestAccessPolicy_AuthorizeUserRead(t *testing.T) {
+	repo := newMockClassroomRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student", model.ClassRoleStudent)
+	repo.addMember(2, "other-teacher", model.ClassRoleTeacher)
+	// 同じクラスの生徒同士は閲覧できない
+	repo.addMember(1, "classmate", model.ClassRoleStudent)
+	policy := service.NewAccessPolicy(repo)
+
+	tests := []struct {
+		actor string
+		owner string
+		want  error
+	}{
+		{"student", "student", nil},
+		{"teacher", "student", nil},
+		{"other-teacher", "student", apperr.ErrForbidden},
+		{"classmate", "student", apperr.ErrForbidden},
+		{"student", "teacher", apperr.ErrForbidden},
+	}
+	for _, tt := range tests {
+		err := policy.AuthorizeUserRead(tt.actor, tt.owner)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.actor, tt.owner, tt.want, err)
+		}
+	}
+}
+
+func TestAccessPolicy_SessionWriteIsOwnerOnly(t *testing.T) {
+	repo := newMockClassroomRepo()
+	repo.addMember(1, "teacher", model.ClassRoleTeacher)
+	repo.addMember(1, "student", model.ClassRoleStudent)
+	policy := service.NewAccessPolicy(repo)
+	sess := &model.TestSession{ID: 1, UserID: "student"}
+
+	if err := policy.AuthorizeSessionWrite("teacher", sess); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected teacher write to be forbidden, got %v", err)
+	}
+	if err := policy.AuthorizeSessionRead("teacher", sess); err != nil {
+		t.Errorf("expected teacher read to be allowed, got %v", err)
+	}
+}
+
+func TestAccessPolicy_NilRosterIsOwnerOnly(t *testing.T) {
+	policy := service.NewAccessPolicy(nil)
+
+	if err := policy.AuthorizeUserRead("teacher", "student"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+	}
+	if err := policy.AuthorizeUserRead("student", "student"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
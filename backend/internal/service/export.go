@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+)
+
+// Export はセッションの回答を問題文・選択肢付きで返す。q.SessionID が 0 なら全セッションを古い順に並べる。
+// 本人と担当の教師以外がセッションを指定した場合は ErrForbidden。
+func (s *MypageService) Export(user *model.User, q dto.ExportQuery) (*dto.Export, error) {
+	var targets []model.TestSession
+	if q.SessionID != 0 {
+		sess, err := s.repo.FindTestSession(q.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !sess.IsReady() {
+			return nil, apperr.ErrSessionNotFound
+		}
+		if err := s.policy.AuthorizeSessionRead(user.Sub, sess); err != nil {
+			return nil, err
+		}
+		targets = append(targets, *sess)
+	} else {
+		sessions, err := s.allSessions(user.Sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, sum := range sessions {
+			targets = append(targets, model.TestSession{ID: sum.SessionID, StartTime: sum.StartTime, ExamMode: sum.ExamMode})
+		}
+		sort.SliceStable(targets, func(i, j int) bool {
+			return targets[i].StartTime.Before(targets[j].StartTime)
+		})
+	}
+
+	result := &dto.Export{
+		UserName:    user.UserName,
+		GeneratedAt: time.Now().In(userLocation(user)).Format("2006-01-02 15:04:05"),
+		Sessions:    make([]dto.ExportSession, 0, len(targets)),
+	}
+	problems := make(map[uint64]*model.Problem)
+	var overall categoryCounter
+	for _, sess := range targets {
+		sps, err := s.repo.FindSessionProblemsBySessionID(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("find session problems: %w", err)
+		}
+
+		es := dto.ExportSession{
+			SessionID: int64(sess.ID),
+			StartTime: sess.StartTime.In(userLocation(user)).Format("2006-01-02 15:04:05"),
+			ExamMode:  sess.ExamMode,
+			Total:     len(sps),
+			Problems:  make([]dto.ExportProblem, 0, len(sps)),
+		}
+		var cats categoryCounter
+		for i, sp := range sps {
+			problem, ok := problems[sp.ProblemID]
+			if !ok {
+				problem, err = s.repo.FindProblemWithChoices(sp.ProblemID)
+				if err != nil {
+					return nil, fmt.Errorf("find problem %d: %w", sp.ProblemID, err)
+				}
+				problems[sp.ProblemID] = problem
+			}
+
+			p := toExportProblem(i, sp, problem)
+			if p.IsCorrect {
+				es.CorrectCount++
+			}
+			cats.add(sp.CategoryName, p.IsCorrect)
+			overall.add(sp.CategoryName, p.IsCorrect)
+			es.Problems = append(es.Problems, p)
+		}
+		es.Categories = cats.list()
+		result.Sessions = append(result.Sessions, es)
+	}
+	result.Categories = overall.list()
+	return result, nil
+}
+
+func toExportProblem(idx int, sp model.SessionProblem, problem *model.Problem) dto.ExportProblem {
+	p := dto.ExportProblem{
+		Idx:          idx,
+		CategoryName: sp.CategoryName,
+		Question:     problem.Question,
+		IsCorrect:    sp.IsCorrect != nil && *sp.IsCorrect,
+		WithHint:     sp.SelectedChoiceID != nil && sp.AnsweredWithHint,
+	}
+	for _, c := range problem.Choices {
+		if c.IsCorrect {
+			p.CorrectChoiceID = int64(c.ID)
+			p.CorrectChoiceText = c.ChoiceText
+		}
+		if sp.SelectedChoiceID != nil && *sp.SelectedChoiceID == c.ID {
+			p.SelectedChoiceText = c.ChoiceText
+		}
+	}
+	if sp.SelectedChoiceID != nil {
+		id := int64(*sp.SelectedChoiceID)
+		p.SelectedChoiceID = &id
+	}
+	if d, ok := sp.TimeSpent(); ok {
+		sec := d.Seconds()
+		p.TimeSpentSec = &sec
+	}
+	return p
+}
+
+// categoryCounter はカテゴリ別の出題数・正答数を初出順に数える。
+type categoryCounter struct {
+	idx  map[string]int
+	cats []dto.Category
+}
+
+func (c *categoryCounter) add(name string, correct bool) {
+	if c.idx == nil {
+		c.idx = make(map[string]int)
+	}
+	i, exists := c.idx[name]
+	if !exists {
+		i = len(c.cats)
+		c.idx[name] = i
+		c.cats = append(c.cats, dto.Category{CategoryName: name})
+	}
+	c.cats[i].Total++
+	if correct {
+		c.cats[i].CorrectCount++
+	}
+}
+
+func (c *categoryCounter) list() []dto.Category {
+	if c.cats == nil {
+		return []dto.Category{}
+	}
+	return c.cats
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+func exportProblems(problemID uint64) (*model.Problem, error) {
+	return &model.Problem{
+		ID:       problemID,
+		Question: "問題",
+		Choices: []model.Choice{
+			{ID: problemID*10 + 1, ChoiceText: "正解", IsCorrect: true},
+			{ID: problemID*10 + 2, ChoiceText: "誤答"},
+		},
+	}, nil
+}
+
+func TestExport_SingleSession_OtherUserForbidden(t *testing.T) {
+	repo := &mockMypageRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "other"}, nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	_, err := svc.Export(&model.User{Sub: "sub-1"}, dto.ExportQuery{SessionID: 1})
+	if !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestExport_AllSessions_OldestFirstWithChoiceText(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+	right, wrong := true, false
+	wrongChoice, rightChoice := uint64(12), uint64(21)
+
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			repository.SessionSummary{SessionID: 2, StartTime: newer},
+			repository.SessionSummary{SessionID: 1, StartTime: older},
+		),
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			if sessionID == 1 {
+				return []model.SessionProblem{
+					{ID: 11, ProblemID: 1, CategoryName: "数と式", SelectedChoiceID: &wrongChoice, IsCorrect: &wrong},
+					{ID: 12, ProblemID: 2, CategoryName: "確率"},
+				}, nil
+			}
+			return []model.SessionProblem{
+				{ID: 21, ProblemID: 2, CategoryName: "確率", SelectedChoiceID: &rightChoice, IsCorrect: &right},
+			}, nil
+		},
+		findProblemWithChoicesFn: exportProblems,
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.Export(&model.User{Sub: "sub-1", UserName: "TestUser"}, dto.ExportQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(result.Sessions) != 2 || result.Sessions[0].SessionID != 1 {
+		t.Fatalf("expected sessions oldest first, got %+v", result.Sessions)
+	}
+
+	p := result.Sessions[0].Problems[0]
+	if p.SelectedChoiceText != "誤答" || p.CorrectChoiceText != "正解" || p.IsCorrect {
+		t.Errorf("unexpected answered problem: %+v", p)
+	}
+	if unanswered := result.Sessions[0].Problems[1]; unanswered.SelectedChoiceID != nil || unanswered.CorrectChoiceID != 21 {
+		t.Errorf("unexpected unanswered problem: %+v", unanswered)
+	}
+	if result.Sessions[1].CorrectCount != 1 {
+		t.Errorf("expected CorrectCount = 1, got %d", result.Sessions[1].CorrectCount)
+	}
+
+	want := []dto.Category{
+		{CategoryName: "数と式", Total: 1, CorrectCount: 0},
+		{CategoryName: "確率", Total: 2, CorrectCount: 1},
+	}
+	if len(result.Categories) != len(want) {
+		t.Fatalf("expected %d categories, got %+v", len(want), result.Categories)
+	}
+	for i, c := range want {
+		if result.Categories[i] != c {
+			t.Errorf("category %d: expected %+v, got %+v", i, c, result.Categories[i])
+		}
+	}
+}
@@ -2,17 +2,89 @@ package service
 
 import (
 	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 )
 
 // TestSessionServicer はテストセッション操作を定義する。
+// actor は入口 (HTTP・CLI・MCP) で認証した利用者。以下のインターフェースも同じ。
 type TestSessionServicer interface {
-	CreateTestSess(userSub string, includeIntegers bool) (*model.TestSession, error)
-	GetProblem(sessionID uint64, userSub string, idx int) (*dto.SessionProblem, error)
-	SubmitAnswer(sessionID uint64, userSub string, idx int, choiceID *int64) error
+	CreateTestSess(actor *identity.Principal, includeIntegers, examMode bool) (*model.TestSession, error)
+	GetProblem(sessionID uint64, actor *identity.Principal, idx int) (*dto.SessionProblem, error)
+	RevealHint(sessionID uint64, actor *identity.Principal, idx int) (*dto.Hint, error)
+	SubmitAnswer(sessionID uint64, actor *identity.Principal, idx int, choiceID *int64, version *int64) error
+	GetAnswerHistory(sessionID uint64, actor *identity.Principal) (*dto.AnswerHistory, error)
+	FinishSession(sessionID uint64, actor *identity.Principal) error
+	// 一覧と再開用の状態は user (閲覧者) のタイムゾーンで日時を返す
+	ListSessions(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error)
+	GetSession(sessionID uint64, user *model.User) (*dto.SessionState, error)
 }
 
 // MypageServicer はマイページ操作を定義する。
+// user は閲覧対象のユーザー。閲覧してよいかは呼び出し側で判定する。
 type MypageServicer interface {
-	GetUserData(user *model.User) (*dto.User, error)
+	GetUserData(user *model.User, q dto.MypageQuery) (*dto.User, error)
+	GetSummary(user *model.User) (*dto.MypageSummary, error)
+	GetTrends(user *model.User, q dto.TrendQuery) (*dto.Trends, error)
+	GetCategoryStats(user *model.User) (*dto.CategoryStats, error)
+	RebuildCategoryStats(userSub string) (*dto.CategoryStats, error)
+	Export(user *model.User, q dto.ExportQuery) (*dto.Export, error)
+}
+
+// ItemAnalysisServicer は問題分析を定義する。
+type ItemAnalysisServicer interface {
+	Analyze() (*dto.ItemAnalysis, error)
+	Rebuild() (*dto.ItemAnalysis, error)
+}
+
+// ClassroomServicer はクラスと名簿の操作を定義する。
+type ClassroomServicer interface {
+	CreateClassroom(actor *identity.Principal, name string) (*dto.Classroom, error)
+	JoinClassroom(actor *identity.Principal, joinCode string) (*dto.Classroom, error)
+	ListClassrooms(actor *identity.Principal) ([]dto.Classroom, error)
+	GetClassroom(actor *identity.Principal, classID uint64) (*dto.ClassroomDetail, error)
+	RemoveStudent(actor *identity.Principal, classID uint64, studentSub string) error
+	StudentUser(actor *identity.Principal, classID uint64, studentSub string) (*model.User, error)
+}
+
+// AssignmentServicer は課題の操作を定義する。
+type AssignmentServicer interface {
+	CreateAssignment(actor *identity.Principal, classID uint64, req dto.CreateAssignmentRequest) (*dto.Assignment, error)
+	ListAssignments(actor *identity.Principal, classID uint64) ([]dto.Assignment, error)
+	GetAssignmentStatus(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentStatus, error)
+	StartAttempt(actor *identity.Principal, classID, assignmentID uint64) (*dto.AssignmentAttempt, bool, error)
+}
+
+// ClassAnalyticsServicer はクラス全体の集計を定義する。
+type ClassAnalyticsServicer interface {
+	GetClassAnalytics(actor *identity.Principal, classID uint64) (*dto.ClassAnalytics, error)
+}
+
+// APIKeyServicer は API キーの管理を定義する。
+type APIKeyServicer interface {
+	CreateAPIKey(actor *identity.Principal, req dto.CreateAPIKeyRequest) (*dto.CreatedAPIKey, error)
+	ListAPIKeys(actor *identity.Principal) ([]dto.APIKey, error)
+	RevokeAPIKey(actor *identity.Principal, keyID string) error
+}
+
+// RoleServicer は役割の判定と管理を定義する。
+type RoleServicer interface {
+	identity.RoleChecker
+	GetRoles(actor *identity.Principal, userSub string) (*dto.UserRoles, error)
+	GrantRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error)
+	RevokeRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error)
+}
+
+// ProfileServicer はプロフィールの操作を定義する。
+type ProfileServicer interface {
+	GetProfile(actor *identity.Principal) (*dto.Profile, error)
+	UpdateProfile(actor *identity.Principal, req dto.UpdateProfileRequest) (*dto.Profile, error)
+	User(actor *identity.Principal) (*model.User, error)
+}
+
+// AccountServicer は利用者のデータのエクスポートと削除を定義する。
+type AccountServicer interface {
+	ExportAccount(actor *identity.Principal, userSub string) (*dto.AccountArchive, error)
+	DeleteAccount(actor *identity.Principal, userSub string, batchSize int) (*dto.AccountDeletion, error)
+	GetAccountDeletion(actor *identity.Principal, userSub string) (*dto.AccountDeletion, error)
 }
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// 問題分析で注意喚起のフラグを付ける基準
+const (
+	// これ未満の回答数の問題にはフラグを付けない
+	itemMinSamples = 10
+	// 正答率がこれ以上なら易しすぎ
+	itemEasyPValue = 0.9
+	// 正答率がこれ以下なら難しすぎ
+	itemHardPValue = 0.2
+	// 識別力がこれ未満なら得点の高い人と低い人を区別できていない
+	itemLowDiscrimination = 0.1
+)
+
+// 問題分析のフラグ
+const (
+	FlagTooEasy                = "too_easy"
+	FlagTooHard                = "too_hard"
+	FlagLowDiscrimination      = "low_discrimination"
+	FlagNegativeDiscrimination = "negative_discrimination" // 正解の設定誤りを疑う
+	FlagDistractorDominant     = "distractor_dominant"     // 正解より多く選ばれた誤答がある
+)
+
+type ItemAnalysisService struct {
+	repo repository.ItemAnalysisRepo
+}
+
+func NewItemAnalysisService(r repository.ItemAnalysisRepo) *ItemAnalysisService {
+	return &ItemAnalysisService{repo: r}
+}
+
+// Analyze は Rebuild で保存した最新の問題分析を返す。まだ作成していなければ apperr.ErrItemAnalysisNotReady。
+func (s *ItemAnalysisService) Analyze() (*dto.ItemAnalysis, error) {
+	snap, err := s.repo.FindItemAnalysis()
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, apperr.ErrItemAnalysisNotReady
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find item analysis: %w", err)
+	}
+	var result dto.ItemAnalysis
+	if err := json.Unmarshal(snap.Payload, &result); err != nil {
+		return nil, fmt.Errorf("decode item analysis: %w", err)
+	}
+	return &result, nil
+}
+
+// Rebuild は全セッションの SP から問題ごとの難易度・識別力・選択肢の選択率・平均所要時間を求めて保存する。
+// テーブル全体を Scan するため、API からは呼ばずに CLI (mathovercome analysis rebuild) で定期的に実行する。
+// 回答済みの SP だけを対象にし、識別力の基準となるセッション得点率は未回答を不正解として計算する。
+func (s *ItemAnalysisService) Rebuild() (*dto.ItemAnalysis, error) {
+	now := time.Now()
+	result, err := s.compute(now)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveItemAnalysis(&model.ItemAnalysisSnapshot{Payload: payload, GeneratedAt: now}); err != nil {
+		return nil, fmt.Errorf("save item analysis: %w", err)
+	}
+	return result, nil
+}
+
+func (s *ItemAnalysisService) compute(now time.Time) (*dto.ItemAnalysis, error) {
+	sps, err := s.repo.ScanSessionProblems()
+	if err != nil {
+		return nil, fmt.Errorf("scan session problems: %w", err)
+	}
+
+	type sessionScore struct{ total, correct int }
+	scores := make(map[uint64]*sessionScore)
+	for _, sp := range sps {
+		sc, ok := scores[sp.TestSessionID]
+		if !ok {
+			sc = &sessionScore{}
+			scores[sp.TestSessionID] = sc
+		}
+		sc.total++
+		if sp.IsCorrect != nil && *sp.IsCorrect {
+			sc.correct++
+		}
+	}
+
+	type response struct {
+		correct bool
+		score   float64
+	}
+	type item struct {
+		categoryName string
+		responses    []response
+		choices      map[uint64]int
+		timed        int
+		spent        time.Duration
+	}
+	items := make(map[uint64]*item)
+	answeredSessions := make(map[uint64]bool)
+	for _, sp := range sps {
+		if sp.SelectedChoiceID == nil {
+			continue
+		}
+		answeredSessions[sp.TestSessionID] = true
+		it, ok := items[sp.ProblemID]
+		if !ok {
+			it = &item{categoryName: sp.CategoryName, choices: make(map[uint64]int)}
+			items[sp.ProblemID] = it
+		}
+		sc := scores[sp.TestSessionID]
+		it.responses = append(it.responses, response{
+			correct: sp.IsCorrect != nil && *sp.IsCorrect,
+			score:   float64(sc.correct) / float64(sc.total),
+		})
+		it.choices[*sp.SelectedChoiceID]++
+		if d, ok := sp.TimeSpent(); ok {
+			it.timed++
+			it.spent += d
+		}
+	}
+
+	ids := make([]uint64, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result := &dto.ItemAnalysis{
+		GeneratedAt:  now.In(jst).Format("2006-01-02 15:04:05"),
+		SessionCount: len(answeredSessions),
+		Items:        make([]dto.ItemStat, 0, len(ids)),
+	}
+	for _, id := range ids {
+		it := items[id]
+		problem, err := s.repo.FindProblemWithChoices(id)
+		if err != nil {
+			return nil, fmt.Errorf("find problem %d: %w", id, err)
+		}
+
+		stat := dto.ItemStat{
+			ProblemID:    int64(id),
+			CategoryName: it.categoryName,
+			Question:     problem.Question,
+			Attempts:     len(it.responses),
+			TimedCount:   it.timed,
+			Flags:        []string{},
+		}
+		correct := make([]bool, len(it.responses))
+		score := make([]float64, len(it.responses))
+		for i, r := range it.responses {
+			correct[i], score[i] = r.correct, r.score
+			if r.correct {
+				stat.CorrectCount++
+			}
+		}
+		stat.PValue = float64(stat.CorrectCount) / float64(stat.Attempts)
+		stat.Discrimination = pointBiserial(correct, score)
+		if it.timed > 0 {
+			avg := avgSeconds(it.spent, it.timed)
+			stat.AvgTimeSec = &avg
+		}
+		stat.Choices = choiceStats(problem.Choices, it.choices, stat.Attempts)
+		stat.Flags = itemFlags(stat)
+		result.Items = append(result.Items, stat)
+	}
+	return result, nil
+}
+
+// pointBiserial は正誤 (2値) と得点 (連続値) の点双列相関係数を返す。
+// 正答者・誤答者のどちらかがいない、または得点にばらつきが無い場合は nil。
+func pointBiserial(correct []bool, score []float64) *float64 {
+	n := float64(len(score))
+	var sum1, sum0, sum float64
+	var n1 float64
+	for i, x := range score {
+		sum += x
+		if correct[i] {
+			sum1 += x
+			n1++
+		} else {
+			sum0 += x
+		}
+	}
+	n0 := n - n1
+	if n1 == 0 || n0 == 0 {
+		return nil
+	}
+
+	mean := sum / n
+	var ss float64
+	for _, x := range score {
+		ss += (x - mean) * (x - mean)
+	}
+	sd := math.Sqrt(ss / n)
+	if sd == 0 {
+		return nil
+	}
+
+	r := (sum1/n1 - sum0/n0) / sd * math.Sqrt(n1/n*n0/n)
+	return &r
+}
+
+// choiceStats は問題の全選択肢について選択回数を並べる。問題から削除された選択肢が選ばれていた場合も末尾に含める。
+func choiceStats(choices []model.Choice, counts map[uint64]int, attempts int) []dto.ChoiceStat {
+	stats := make([]dto.ChoiceStat, 0, len(choices))
+	seen := make(map[uint64]bool, len(choices))
+	for _, c := range choices {
+		seen[c.ID] = true
+		stats = append(stats, dto.ChoiceStat{
+			ChoiceID:   int64(c.ID),
+			ChoiceText: c.ChoiceText,
+			IsCorrect:  c.IsCorrect,
+			Count:      counts[c.ID],
+			Rate:       float64(counts[c.ID]) / float64(attempts),
+		})
+	}
+
+	var unknown []uint64
+	for id := range counts {
+		if !seen[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+	for _, id := range unknown {
+		stats = append(stats, dto.ChoiceStat{
+			ChoiceID: int64(id),
+			Count:    counts[id],
+			Rate:     float64(counts[id]) / float64(attempts),
+		})
+	}
+	return stats
+}
+
+// itemFlags は回答数が itemMinSamples 以上の問題について見直しが必要そうな点を挙げる。
+func itemFlags(st dto.ItemStat) []string {
+	flags := []string{}
+	if st.Attempts < itemMinSamples {
+		return flags
+	}
+	if st.PValue >= itemEasyPValue {
+		flags = append(flags, FlagTooEasy)
+	}
+	if st.PValue <= itemHardPValue {
+		flags = append(flags, FlagTooHard)
+	}
+	if d := st.Discrimination; d != nil {
+		switch {
+		case *d < 0:
+			flags = append(flags, FlagNegativeDiscrimination)
+		case *d < itemLowDiscrimination:
+			flags = append(flags, FlagLowDiscrimination)
+		}
+	}
+
+	correct := 0
+	for _, c := range st.Choices {
+		if c.IsCorrect {
+			correct += c.Count
+		}
+	}
+	for _, c := range st.Choices {
+		if !c.IsCorrect && c.Count > correct {
+			flags = append(flags, FlagDistractorDominant)
+			break
+		}
+	}
+	return flags
+}
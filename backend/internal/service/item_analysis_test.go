@@ -0,0 +1,196 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+type mockItemAnalysisRepo struct {
+	sps      []model.SessionProblem
+	problems map[uint64]*model.Problem
+	saved    *model.ItemAnalysisSnapshot
+	scans    int
+}
+
+func (m *mockItemAnalysisRepo) ScanSessionProblems() ([]model.SessionProblem, error) {
+	m.scans++
+	return m.sps, nil
+}
+
+func (m *mockItemAnalysisRepo) FindItemAnalysis() (*model.ItemAnalysisSnapshot, error) {
+	if m.saved == nil {
+		return nil, apperr.ErrNotFound
+	}
+	return m.saved, nil
+}
+
+func (m *mockItemAnalysisRepo) SaveItemAnalysis(a *model.ItemAnalysisSnapshot) error {
+	m.saved = a
+	return nil
+}
+
+func (m *mockItemAnalysisRepo) FindProblemWithChoices(problemID uint64) (*model.Problem, error) {
+	return m.problems[problemID], nil
+}
+
+// answered は回答済みの SP を作る。choice 1 が正解。
+func answered(sessionID, problemID, choice uint64) model.SessionProblem {
+	correct := choice == 1
+	return model.SessionProblem{
+		TestSessionID: sessionID, ProblemID: problemID, CategoryName: "確率",
+		SelectedChoiceID: &choice, IsCorrect: &correct,
+	}
+}
+
+func threeChoices(id uint64) *model.Problem {
+	return &model.Problem{ID: id, Question: "Q", Choices: []model.Choice{
+		{ID: 1, IsCorrect: true}, {ID: 2}, {ID: 3},
+	}}
+}
+
+func TestRebuild_PValueAndDistractors(t *testing.T) {
+	repo := &mockItemAnalysisRepo{
+		sps: []model.SessionProblem{
+			answered(1, 10, 1),
+			answered(2, 10, 2),
+			answered(3, 10, 2),
+			answered(4, 10, 1),
+			{TestSessionID: 5, ProblemID: 10}, // 未回答は対象外
+		},
+		problems: map[uint64]*model.Problem{10: threeChoices(10)},
+	}
+
+	result, err := service.NewItemAnalysisService(repo).Rebuild()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.SessionCount != 4 || len(result.Items) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	it := result.Items[0]
+	if it.Attempts != 4 || it.CorrectCount != 2 || it.PValue != 0.5 {
+		t.Errorf("unexpected counts: %+v", it)
+	}
+	if len(it.Choices) != 3 || it.Choices[1].Count != 2 || it.Choices[1].Rate != 0.5 || it.Choices[2].Count != 0 {
+		t.Errorf("unexpected choice stats: %+v", it.Choices)
+	}
+	if it.AvgTimeSec != nil {
+		t.Error("expected AvgTimeSec to be nil without timing data")
+	}
+}
+
+func TestRebuild_DiscriminationFollowsSessionScore(t *testing.T) {
+	var sps []model.SessionProblem
+	// 問題 10 は得点の高いセッションだけが正解、問題 20 は得点の低いセッションだけが正解
+	for s := uint64(1); s <= 10; s++ {
+		high := s <= 5
+		if high {
+			sps = append(sps, answered(s, 10, 1), answered(s, 30, 1), answered(s, 20, 2))
+		} else {
+			sps = append(sps, answered(s, 10, 2), answered(s, 30, 2), answered(s, 20, 1))
+		}
+	}
+	repo := &mockItemAnalysisRepo{
+		sps:      sps,
+		problems: map[uint64]*model.Problem{10: threeChoices(10), 20: threeChoices(20), 30: threeChoices(30)},
+	}
+
+	result, err := service.NewItemAnalysisService(repo).Rebuild()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	good, bad := result.Items[0], result.Items[1]
+	if good.Discrimination == nil || *good.Discrimination <= 0.9 {
+		t.Errorf("expected strong positive discrimination, got %v", good.Discrimination)
+	}
+	if bad.Discrimination == nil || *bad.Discrimination >= 0 {
+		t.Fatalf("expected negative discrimination, got %v", bad.Discrimination)
+	}
+	if !hasFlag(bad.Flags, service.FlagNegativeDiscrimination) {
+		t.Errorf("expected negative_discrimination flag, got %v", bad.Flags)
+	}
+}
+
+func TestRebuild_FlagsTooEasyAndAverageTime(t *testing.T) {
+	viewed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := viewed.Add(90 * time.Second)
+	var sps []model.SessionProblem
+	for s := uint64(1); s <= 10; s++ {
+		sp := answered(s, 10, 1)
+		sp.FirstViewedAt, sp.AnsweredAt = &viewed, &at
+		sps = append(sps, sp)
+	}
+	repo := &mockItemAnalysisRepo{sps: sps, problems: map[uint64]*model.Problem{10: threeChoices(10)}}
+
+	result, err := service.NewItemAnalysisService(repo).Rebuild()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	it := result.Items[0]
+	if !hasFlag(it.Flags, service.FlagTooEasy) {
+		t.Errorf("expected too_easy flag, got %v", it.Flags)
+	}
+	if it.Discrimination != nil {
+		t.Errorf("expected nil discrimination when everyone is correct, got %v", *it.Discrimination)
+	}
+	if it.AvgTimeSec == nil || *it.AvgTimeSec != 90 {
+		t.Errorf("expected average time 90s, got %v", it.AvgTimeSec)
+	}
+}
+
+func TestRebuild_FewSamplesNotFlagged(t *testing.T) {
+	repo := &mockItemAnalysisRepo{
+		sps:      []model.SessionProblem{answered(1, 10, 2), answered(2, 10, 2)},
+		problems: map[uint64]*model.Problem{10: threeChoices(10)},
+	}
+
+	result, err := service.NewItemAnalysisService(repo).Rebuild()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if flags := result.Items[0].Flags; len(flags) != 0 {
+		t.Errorf("expected no flags below min samples, got %v", flags)
+	}
+}
+
+func hasFlag(flags []string, want string) bool {
+	for _, f := range flags {
+		if f == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAnalyze_ServesSavedResultWithoutScan(t *testing.T) {
+	repo := &mockItemAnalysisRepo{
+		sps:      []model.SessionProblem{answered(1, 10, 1), answered(2, 10, 2)},
+		problems: map[uint64]*model.Problem{10: threeChoices(10)},
+	}
+	svc := service.NewItemAnalysisService(repo)
+
+	if _, err := svc.Analyze(); !errors.Is(err, apperr.ErrItemAnalysisNotReady) {
+		t.Fatalf("expected ErrItemAnalysisNotReady before rebuild, got %v", err)
+	}
+	built, err := svc.Rebuild()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repo.sps = append(repo.sps, answered(3, 10, 1))
+	result, err := svc.Analyze()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.scans != 1 {
+		t.Errorf("expected Analyze not to scan, got %d scans", repo.scans)
+	}
+	if result.GeneratedAt != built.GeneratedAt || result.SessionCount != 2 || result.Items[0].Attempts != 2 {
+		t.Errorf("expected the saved result, got %+v", result)
+	}
+}
@@ -2,121 +2,244 @@ package service
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strconv"
 	"time"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 )
 
-type MypageService struct {
-	repo repository.MypageRepo
-}
+const (
+	defaultMypageLimit = 20
+	maxMypageLimit     = 100
 
-func NewMypageService(r repository.MypageRepo) *MypageService {
-	return &MypageService{repo: r}
+	// 1問あたりの目標時間の既定値 (共通テスト数学IA は70分で大問4つ・小問20前後)
+	defaultPacingTarget = 3 * time.Minute
+)
+
+// pacingTarget は1問あたりの目標時間を返す。PACING_TARGET_SECONDS で上書きできる。
+func pacingTarget() time.Duration {
+	if s := os.Getenv("PACING_TARGET_SECONDS"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultPacingTarget
 }
 
-func (s *MypageService) GetUserData(user *model.User) (*dto.User, error) {
-	rows, err := s.repo.GetSessionProblemsRaw(user.Sub)
-	if err != nil {
-		return nil, fmt.Errorf("get session problems: %w", err)
+var jst = time.FixedZone("JST", 9*60*60)
+
+// userLocation は user の日時を表示するタイムゾーンを返す。プロフィールで設定されていなければ JST。
+func userLocation(user *model.User) *time.Location {
+	if user.Location != nil {
+		return user.Location
 	}
+	return jst
+}
 
-	jst := time.FixedZone("JST", 9*60*60)
-	sessionMap := make(map[uint64]*dto.TestSession)
-	var sessionIDs []uint64
+type MypageService struct {
+	repo   repository.MypageRepo
+	weak   WeakPolicy
+	policy *AccessPolicy
+}
 
-	// catStats[sessionID][categoryName] = {total, correct}
-	type catStat struct{ total, correct int }
-	catStats := make(map[uint64]map[string]*catStat)
-	catOrder := make(map[uint64][]string)
+// NewMypageService は環境変数で設定した苦手カテゴリの判定基準を使うサービスを返す。
+func NewMypageService(r repository.MypageRepo) *MypageService {
+	return &MypageService{repo: r, weak: WeakPolicyFromEnv(), policy: NewAccessPolicy(nil)}
+}
 
-	for _, row := range rows {
-		if _, exists := sessionMap[row.SessionID]; !exists {
-			d := &dto.TestSession{
-				SessionID: int64(row.SessionID),
-				StartTime: row.StartTime.In(jst).Format("2006-01-02 15:04:05"),
-			}
-			sessionMap[row.SessionID] = d
-			sessionIDs = append(sessionIDs, row.SessionID)
-			catStats[row.SessionID] = make(map[string]*catStat)
-		}
+// WithAccessPolicy はセッションを指定したエクスポートのアクセス判定を差し替える。
+func (s *MypageService) WithAccessPolicy(p *AccessPolicy) *MypageService {
+	s.policy = p
+	return s
+}
 
-		sessionMap[row.SessionID].ProbCategoryDtos = append(
-			sessionMap[row.SessionID].ProbCategoryDtos,
-			dto.ProblemCategory{
-				IsCorrect:    &row.IsCorrect,
-				CategoryName: row.CategoryName,
-			},
-		)
-
-		sm := catStats[row.SessionID]
-		if _, exists := sm[row.CategoryName]; !exists {
-			sm[row.CategoryName] = &catStat{}
-			catOrder[row.SessionID] = append(catOrder[row.SessionID], row.CategoryName)
-		}
-		sm[row.CategoryName].total++
-		if row.IsCorrect {
-			sm[row.CategoryName].correct++
-		}
+// WithWeakPolicy は苦手カテゴリの判定基準を差し替える。
+func (s *MypageService) WithWeakPolicy(p WeakPolicy) *MypageService {
+	s.weak = p
+	return s
+}
+
+func (s *MypageService) GetUserData(user *model.User, q dto.MypageQuery) (*dto.User, error) {
+	loc := userLocation(user)
+	sq, err := toSessionQuery(q, loc)
+	if err != nil {
+		return nil, err
 	}
 
-	finalSessions := make([]dto.TestSession, 0)
-	for _, id := range sessionIDs {
-		sess := sessionMap[id]
+	page, err := s.repo.FindSessionSummaries(user.Sub, sq)
+	if err != nil {
+		return nil, fmt.Errorf("find session summaries: %w", err)
+	}
 
-		total := len(sess.ProbCategoryDtos)
-		correct := 0
-		for _, p := range sess.ProbCategoryDtos {
-			if *p.IsCorrect {
-				correct++
-			}
-		}
-		sess.Total = total
-		sess.CorrectCount = correct
-
-		for _, name := range catOrder[id] {
-			st := catStats[id][name]
-			sess.CategoryDtos = append(sess.CategoryDtos, dto.Category{
-				CategoryName: name,
-				Total:        st.total,
-				CorrectCount: st.correct,
-			})
+	target := pacingTarget()
+	finalSessions := make([]dto.TestSession, 0, len(page.Sessions))
+	for _, sum := range page.Sessions {
+		sess := dto.TestSession{
+			SessionID:          int64(sum.SessionID),
+			StartTime:          sum.StartTime.In(loc).Format("2006-01-02 15:04:05"),
+			Total:              sum.Total,
+			CorrectCount:       sum.CorrectCount,
+			HintedCount:        sum.HintedCount,
+			HintedCorrectCount: sum.HintedCorrectCount,
+			ExamMode:           sum.ExamMode,
+			Pacing:             toPacing(sum.TimedCount, sum.TimeSpent, countOverTarget(sum.Problems, target), target),
 		}
 
-		type weakCat struct {
-			name string
-			rate float64
-		}
-		var weaks []weakCat
-		for _, name := range catOrder[id] {
-			st := catStats[id][name]
-			if st.total == 0 {
-				continue
+		if q.IncludeDetails {
+			for _, st := range sum.Categories {
+				sess.CategoryDtos = append(sess.CategoryDtos, dto.Category{
+					CategoryName:       st.Name,
+					Total:              st.TotalCount,
+					CorrectCount:       st.CorrectCount,
+					HintedCount:        st.HintedCount,
+					HintedCorrectCount: st.HintedCorrectCount,
+					TimedCount:         st.TimedCount,
+					TimeSpentSec:       st.TimeSpent.Seconds(),
+					AvgTimeSec:         avgSeconds(st.TimeSpent, st.TimedCount),
+				})
 			}
-			rate := float64(st.correct) / float64(st.total)
-			if rate < 0.5 {
-				weaks = append(weaks, weakCat{name: name, rate: rate})
+			sess.WeakCategories = s.weak.Select(sum.Categories)
+			for _, p := range sum.Problems {
+				sess.ProblemTimes = append(sess.ProblemTimes, dto.ProblemTime{
+					Idx:          p.Idx,
+					CategoryName: p.CategoryName,
+					TimeSpentSec: p.TimeSpent.Seconds(),
+					OverTarget:   p.TimeSpent > target,
+				})
 			}
 		}
-		sort.Slice(weaks, func(i, j int) bool {
-			return weaks[i].rate < weaks[j].rate
-		})
-		for i := 0; i < len(weaks) && i < 2; i++ {
-			sess.WeakCategories = append(sess.WeakCategories, weaks[i].name)
-		}
 
-		finalSessions = append(finalSessions, *sess)
+		finalSessions = append(finalSessions, sess)
 	}
 
-	sort.Slice(finalSessions, func(i, j int) bool {
+	sort.SliceStable(finalSessions, func(i, j int) bool {
 		return finalSessions[i].StartTime > finalSessions[j].StartTime
 	})
 
 	return &dto.User{
 		UserName:     user.UserName,
 		TestSessDtos: finalSessions,
+		NextCursor:   page.NextCursor,
 	}, nil
 }
+
+// GetSummary は全セッションの保存済み集計を合算した軽量なサマリーを返す。
+// 苦手カテゴリは全セッションを合算した成績から判定する。
+func (s *MypageService) GetSummary(user *model.User) (*dto.MypageSummary, error) {
+	result := &dto.MypageSummary{UserName: user.UserName}
+	var all []repository.SessionSummary
+
+	var last time.Time
+	var timed, overTarget int
+	var spent time.Duration
+	target := pacingTarget()
+	q := repository.SessionQuery{Limit: maxMypageLimit}
+	for {
+		page, err := s.repo.FindSessionSummaries(user.Sub, q)
+		if err != nil {
+			return nil, fmt.Errorf("find session summaries: %w", err)
+		}
+		all = append(all, page.Sessions...)
+		for _, sum := range page.Sessions {
+			result.SessionCount++
+			result.Total += sum.Total
+			result.CorrectCount += sum.CorrectCount
+			result.HintedCount += sum.HintedCount
+			result.HintedCorrectCount += sum.HintedCorrectCount
+			if sum.StartTime.After(last) {
+				last = sum.StartTime
+			}
+			timed += sum.TimedCount
+			spent += sum.TimeSpent
+			overTarget += countOverTarget(sum.Problems, target)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	if result.Total > 0 {
+		result.Accuracy = float64(result.CorrectCount) / float64(result.Total)
+	}
+	if unaided := result.Total - result.HintedCount; unaided > 0 {
+		result.UnaidedAccuracy = float64(result.CorrectCount-result.HintedCorrectCount) / float64(unaided)
+	}
+	if !last.IsZero() {
+		result.LastStartTime = last.In(userLocation(user)).Format("2006-01-02 15:04:05")
+	}
+	result.Pacing = toPacing(timed, spent, overTarget, target)
+	result.WeakCategories = s.weak.SelectFromHistory(all)
+	return result, nil
+}
+
+// toPacing は計測済みの問題数と合計時間から目標に対するペースを作る。計測済みの問題が無ければ nil。
+func toPacing(timed int, spent time.Duration, overTarget int, target time.Duration) *dto.Pacing {
+	if timed == 0 {
+		return nil
+	}
+	avg := avgSeconds(spent, timed)
+	return &dto.Pacing{
+		TargetSec:       target.Seconds(),
+		AvgSec:          avg,
+		Ratio:           avg / target.Seconds(),
+		TimedCount:      timed,
+		OverTargetCount: overTarget,
+	}
+}
+
+func countOverTarget(problems []repository.ProblemTime, target time.Duration) int {
+	n := 0
+	for _, p := range problems {
+		if p.TimeSpent > target {
+			n++
+		}
+	}
+	return n
+}
+
+func avgSeconds(total time.Duration, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return total.Seconds() / float64(n)
+}
+
+// toSessionQuery は dto の取得条件を検証しリポジトリ用に変換する。
+// 日付は loc の日付として解釈し、To はその日の終わりまでを含める。
+func toSessionQuery(q dto.MypageQuery, loc *time.Location) (repository.SessionQuery, error) {
+	sq := repository.SessionQuery{
+		Limit:  defaultMypageLimit,
+		Cursor: q.Cursor,
+	}
+	if q.Limit < 0 || q.Limit > maxMypageLimit {
+		return sq, apperr.ErrInvalidArgument
+	}
+	if q.Limit > 0 {
+		sq.Limit = int32(q.Limit)
+	}
+
+	if q.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", q.From, loc)
+		if err != nil {
+			return sq, apperr.ErrInvalidArgument
+		}
+		sq.From = from
+	}
+	if q.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", q.To, loc)
+		if err != nil {
+			return sq, apperr.ErrInvalidArgument
+		}
+		sq.To = to.AddDate(0, 0, 1)
+	}
+	if !sq.From.IsZero() && !sq.To.IsZero() && !sq.From.Before(sq.To) {
+		return sq, apperr.ErrInvalidArgument
+	}
+	return sq, nil
+}
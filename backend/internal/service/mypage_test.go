@@ -5,30 +5,64 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
 
 type mockMypageRepo struct {
-	getSessionProblemsRawFn func(userSub string) ([]repository.SessionProblemRow, error)
+	findSessionSummariesFn           func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error)
+	findSessionProblemsBySessionIDFn func(sessionID uint64) ([]model.SessionProblem, error)
+	findAnswerEventsFn               func(sessionID uint64) ([]model.AnswerEvent, error)
+	findCategoryStatsFn              func(userSub string) ([]model.CategoryStat, error)
+	replaceCategoryStatsFn           func(userSub string, stats []model.CategoryStat) error
+	findTestSessionFn                func(sessionID uint64) (*model.TestSession, error)
+	findProblemWithChoicesFn         func(problemID uint64) (*model.Problem, error)
 }
 
-func (m *mockMypageRepo) GetSessionProblemsRaw(userSub string) ([]repository.SessionProblemRow, error) {
-	return m.getSessionProblemsRawFn(userSub)
+func (m *mockMypageRepo) FindSessionSummaries(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+	return m.findSessionSummariesFn(userSub, q)
+}
+
+func (m *mockMypageRepo) FindSessionProblemsBySessionID(sessionID uint64) ([]model.SessionProblem, error) {
+	return m.findSessionProblemsBySessionIDFn(sessionID)
+}
+
+func (m *mockMypageRepo) FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error) {
+	return m.findAnswerEventsFn(sessionID)
+}
+
+func (m *mockMypageRepo) FindCategoryStats(userSub string) ([]model.CategoryStat, error) {
+	return m.findCategoryStatsFn(userSub)
+}
+
+func (m *mockMypageRepo) ReplaceCategoryStats(userSub string, stats []model.CategoryStat) error {
+	return m.replaceCategoryStatsFn(userSub, stats)
+}
+
+func (m *mockMypageRepo) FindTestSession(sessionID uint64) (*model.TestSession, error) {
+	return m.findTestSessionFn(sessionID)
+}
+
+func (m *mockMypageRepo) FindProblemWithChoices(problemID uint64) (*model.Problem, error) {
+	return m.findProblemWithChoicesFn(problemID)
+}
+
+func singlePage(sessions ...repository.SessionSummary) func(string, repository.SessionQuery) (*repository.SessionPage, error) {
+	return func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+		return &repository.SessionPage{Sessions: sessions}, nil
+	}
 }
 
 // --- GetUserData ---
 
 func TestGetUserData_NoSessions(t *testing.T) {
-	repo := &mockMypageRepo{
-		getSessionProblemsRawFn: func(userSub string) ([]repository.SessionProblemRow, error) {
-			return []repository.SessionProblemRow{}, nil
-		},
-	}
+	repo := &mockMypageRepo{findSessionSummariesFn: singlePage()}
 	svc := service.NewMypageService(repo)
 
-	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"})
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"}, dto.MypageQuery{IncludeDetails: true})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -44,17 +78,18 @@ func TestGetUserData_SingleSession_CountsCorrect(t *testing.T) {
 	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
 
 	repo := &mockMypageRepo{
-		getSessionProblemsRawFn: func(userSub string) ([]repository.SessionProblemRow, error) {
-			return []repository.SessionProblemRow{
-				{SessionID: 1, StartTime: now, IsCorrect: true, CategoryName: "足し算"},
-				{SessionID: 1, StartTime: now, IsCorrect: false, CategoryName: "引き算"},
-				{SessionID: 1, StartTime: now, IsCorrect: true, CategoryName: "掛け算"},
-			}, nil
-		},
+		findSessionSummariesFn: singlePage(repository.SessionSummary{
+			SessionID: 1, StartTime: now, Total: 6, CorrectCount: 3,
+			Categories: []repository.CategoryStats{
+				{Name: "足し算", TotalCount: 2, CorrectCount: 2},
+				{Name: "引き算", TotalCount: 2, CorrectCount: 0},
+				{Name: "掛け算", TotalCount: 2, CorrectCount: 1},
+			},
+		}),
 	}
 	svc := service.NewMypageService(repo)
 
-	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"})
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"}, dto.MypageQuery{IncludeDetails: true})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -62,11 +97,14 @@ func TestGetUserData_SingleSession_CountsCorrect(t *testing.T) {
 		t.Fatalf("expected 1 session, got %d", len(result.TestSessDtos))
 	}
 	sess := result.TestSessDtos[0]
-	if sess.Total != 3 {
-		t.Errorf("expected Total = 3, got %d", sess.Total)
+	if sess.Total != 6 {
+		t.Errorf("expected Total = 6, got %d", sess.Total)
+	}
+	if sess.CorrectCount != 3 {
+		t.Errorf("expected CorrectCount = 3, got %d", sess.CorrectCount)
 	}
-	if sess.CorrectCount != 2 {
-		t.Errorf("expected CorrectCount = 2, got %d", sess.CorrectCount)
+	if sess.StartTime != "2024-01-15 19:00:00" {
+		t.Errorf("expected StartTime in JST, got %s", sess.StartTime)
 	}
 	if len(sess.CategoryDtos) != 3 {
 		t.Errorf("expected 3 category DTOs, got %d", len(sess.CategoryDtos))
@@ -76,20 +114,89 @@ func TestGetUserData_SingleSession_CountsCorrect(t *testing.T) {
 	}
 }
 
+func TestGetUserData_Pacing(t *testing.T) {
+	t.Setenv("PACING_TARGET_SECONDS", "60")
+
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(repository.SessionSummary{
+			SessionID: 1, StartTime: time.Now(), Total: 3, TimedCount: 2, TimeSpent: 150 * time.Second,
+			Categories: []repository.CategoryStats{
+				{Name: "確率", TotalCount: 2, TimedCount: 2, TimeSpent: 150 * time.Second},
+				{Name: "整数", TotalCount: 1},
+			},
+			Problems: []repository.ProblemTime{
+				{Idx: 0, CategoryName: "確率", TimeSpent: 30 * time.Second},
+				{Idx: 1, CategoryName: "確率", TimeSpent: 120 * time.Second},
+			},
+		}),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{IncludeDetails: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sess := result.TestSessDtos[0]
+	if sess.Pacing == nil {
+		t.Fatal("expected pacing")
+	}
+	if sess.Pacing.TargetSec != 60 || sess.Pacing.AvgSec != 75 || sess.Pacing.Ratio != 1.25 || sess.Pacing.OverTargetCount != 1 {
+		t.Errorf("unexpected pacing: %+v", sess.Pacing)
+	}
+	if sess.CategoryDtos[0].AvgTimeSec != 75 || sess.CategoryDtos[1].AvgTimeSec != 0 {
+		t.Errorf("unexpected category times: %+v", sess.CategoryDtos)
+	}
+	if len(sess.ProblemTimes) != 2 || sess.ProblemTimes[0].OverTarget || !sess.ProblemTimes[1].OverTarget {
+		t.Errorf("unexpected problem times: %+v", sess.ProblemTimes)
+	}
+}
+
+func TestGetUserData_NoPacingWithoutTimings(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(repository.SessionSummary{SessionID: 1, StartTime: time.Now(), Total: 1}),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{IncludeDetails: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.TestSessDtos[0].Pacing != nil {
+		t.Errorf("expected no pacing for untimed session, got %+v", result.TestSessDtos[0].Pacing)
+	}
+}
+
+func TestGetUserData_WithoutDetails(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(repository.SessionSummary{
+			SessionID: 1, StartTime: time.Now(), Total: 1,
+			Categories: []repository.CategoryStats{{Name: "引き算", TotalCount: 1}},
+		}),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{IncludeDetails: false})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sess := result.TestSessDtos[0]
+	if sess.CategoryDtos != nil || sess.WeakCategories != nil {
+		t.Errorf("expected no details, got %v / %v", sess.CategoryDtos, sess.WeakCategories)
+	}
+}
+
 func TestGetUserData_MultipleSessions_OrderPreserved(t *testing.T) {
 	now := time.Now()
 
 	repo := &mockMypageRepo{
-		getSessionProblemsRawFn: func(userSub string) ([]repository.SessionProblemRow, error) {
-			return []repository.SessionProblemRow{
-				{SessionID: 2, StartTime: now, IsCorrect: true, CategoryName: "足し算"},
-				{SessionID: 1, StartTime: now, IsCorrect: false, CategoryName: "引き算"},
-			}, nil
-		},
+		findSessionSummariesFn: singlePage(
+			repository.SessionSummary{SessionID: 2, StartTime: now, Total: 1, CorrectCount: 1},
+			repository.SessionSummary{SessionID: 1, StartTime: now, Total: 1},
+		),
 	}
 	svc := service.NewMypageService(repo)
 
-	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"})
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1", UserName: "TestUser"}, dto.MypageQuery{IncludeDetails: true})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -104,16 +211,213 @@ func TestGetUserData_MultipleSessions_OrderPreserved(t *testing.T) {
 	}
 }
 
-func TestGetUserData_GetSessionProblemsRawError(t *testing.T) {
+func TestGetUserData_QueryConversion(t *testing.T) {
+	var got repository.SessionQuery
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+			got = q
+			return &repository.SessionPage{NextCursor: "next"}, nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{
+		Limit: 5, Cursor: "abc", From: "2025-10-01", To: "2025-10-31",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Limit != 5 || got.Cursor != "abc" {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	if want := time.Date(2025, 9, 30, 15, 0, 0, 0, time.UTC); !got.From.Equal(want) {
+		t.Errorf("expected From = %v, got %v", want, got.From.UTC())
+	}
+	if want := time.Date(2025, 10, 31, 15, 0, 0, 0, time.UTC); !got.To.Equal(want) {
+		t.Errorf("expected To = %v, got %v", want, got.To.UTC())
+	}
+	if result.NextCursor != "next" {
+		t.Errorf("expected NextCursor = next, got %s", result.NextCursor)
+	}
+}
+
+func TestGetUserData_DefaultLimit(t *testing.T) {
+	var got repository.SessionQuery
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+			got = q
+			return &repository.SessionPage{}, nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	if _, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Limit != 20 {
+		t.Errorf("expected default Limit = 20, got %d", got.Limit)
+	}
+}
+
+func TestGetUserData_InvalidQuery(t *testing.T) {
+	cases := map[string]dto.MypageQuery{
+		"limit too large": {Limit: 101},
+		"bad from":        {From: "2025/10/01"},
+		"reversed range":  {From: "2025-10-02", To: "2025-10-01"},
+	}
+	for name, q := range cases {
+		t.Run(name, func(t *testing.T) {
+			svc := service.NewMypageService(&mockMypageRepo{})
+			_, err := svc.GetUserData(&model.User{Sub: "sub-1"}, q)
+			if !errors.Is(err, apperr.ErrInvalidArgument) {
+				t.Errorf("expected ErrInvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetUserData_FindSessionSummariesError(t *testing.T) {
 	repo := &mockMypageRepo{
-		getSessionProblemsRawFn: func(userSub string) ([]repository.SessionProblemRow, error) {
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
 			return nil, errors.New("db error")
 		},
 	}
 	svc := service.NewMypageService(repo)
 
-	_, err := svc.GetUserData(&model.User{Sub: "sub-1"})
+	_, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
 }
+
+// --- GetSummary ---
+
+func TestGetSummary_AggregatesAllPages(t *testing.T) {
+	older := time.Date(2025, 10, 1, 1, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 10, 2, 1, 0, 0, 0, time.UTC)
+
+	var calls int
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+			calls++
+			if q.Cursor == "" {
+				return &repository.SessionPage{
+					Sessions:   []repository.SessionSummary{{SessionID: 2, StartTime: newer, Total: 10, CorrectCount: 7}},
+					NextCursor: "page2",
+				}, nil
+			}
+			return &repository.SessionPage{
+				Sessions: []repository.SessionSummary{{SessionID: 1, StartTime: older, Total: 10, CorrectCount: 3}},
+			}, nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetSummary(&model.User{Sub: "sub-1", UserName: "TestUser"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 repository calls, got %d", calls)
+	}
+	if result.SessionCount != 2 || result.Total != 20 || result.CorrectCount != 10 {
+		t.Errorf("unexpected summary: %+v", result)
+	}
+	if result.Accuracy != 0.5 {
+		t.Errorf("expected Accuracy = 0.5, got %f", result.Accuracy)
+	}
+	if result.LastStartTime != "2025-10-02 10:00:00" {
+		t.Errorf("expected LastStartTime = 2025-10-02 10:00:00, got %s", result.LastStartTime)
+	}
+}
+
+func TestGetSummary_Pacing(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			repository.SessionSummary{SessionID: 2, StartTime: time.Now(), Total: 2, TimedCount: 1, TimeSpent: 4 * time.Minute,
+				Problems: []repository.ProblemTime{{Idx: 0, TimeSpent: 4 * time.Minute}}},
+			repository.SessionSummary{SessionID: 1, StartTime: time.Now(), Total: 2, TimedCount: 1, TimeSpent: 2 * time.Minute,
+				Problems: []repository.ProblemTime{{Idx: 0, TimeSpent: 2 * time.Minute}}},
+		),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetSummary(&model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// 既定の目標は1問3分
+	if result.Pacing == nil || result.Pacing.AvgSec != 180 || result.Pacing.Ratio != 1 || result.Pacing.OverTargetCount != 1 {
+		t.Errorf("unexpected pacing: %+v", result.Pacing)
+	}
+}
+
+func TestGetSummary_SeparatesHintedAnswers(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(repository.SessionSummary{
+			SessionID: 1, StartTime: time.Now(), Total: 10, CorrectCount: 6, HintedCount: 4, HintedCorrectCount: 3,
+		}),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetSummary(&model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.HintedCount != 4 || result.HintedCorrectCount != 3 {
+		t.Errorf("unexpected hinted counts: %+v", result)
+	}
+	// ヒントなし: 6問中3問正解
+	if result.UnaidedAccuracy != 0.5 {
+		t.Errorf("expected UnaidedAccuracy = 0.5, got %f", result.UnaidedAccuracy)
+	}
+}
+
+func TestGetUserData_UsesWeakPolicy(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(repository.SessionSummary{
+			SessionID: 1, StartTime: time.Now(), Total: 7,
+			Categories: []repository.CategoryStats{
+				{Name: "確率", TotalCount: 3, CorrectCount: 1},
+				{Name: "整数", TotalCount: 1, CorrectCount: 0},
+				{Name: "2次関数", TotalCount: 3, CorrectCount: 2},
+			},
+		}),
+	}
+	svc := service.NewMypageService(repo).WithWeakPolicy(service.WeakPolicy{Threshold: 0.7, MaxCount: 1, MinSamples: 2})
+
+	result, err := svc.GetUserData(&model.User{Sub: "sub-1"}, dto.MypageQuery{IncludeDetails: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// 整数は出題数不足で対象外、確率 (0.33) が 2次関数 (0.67) より優先される
+	weak := result.TestSessDtos[0].WeakCategories
+	if len(weak) != 1 || weak[0] != "確率" {
+		t.Errorf("unexpected WeakCategories: %v", weak)
+	}
+}
+
+func TestGetSummary_WeakCategoriesFromHistory(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			repository.SessionSummary{SessionID: 2, StartTime: time.Now(), Categories: []repository.CategoryStats{
+				{Name: "確率", TotalCount: 2, CorrectCount: 2},
+				{Name: "図形の性質", TotalCount: 2, CorrectCount: 0},
+			}},
+			repository.SessionSummary{SessionID: 1, StartTime: time.Now(), Categories: []repository.CategoryStats{
+				{Name: "確率", TotalCount: 2, CorrectCount: 0},
+				{Name: "図形の性質", TotalCount: 2, CorrectCount: 1},
+			}},
+		),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetSummary(&model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// 合算すると確率は 2/4 (苦手ではない)、図形の性質は 1/4
+	if len(result.WeakCategories) != 1 || result.WeakCategories[0] != "図形の性質" {
+		t.Errorf("unexpected WeakCategories: %v", result.WeakCategories)
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // コンテナイメージ (alpine) にタイムゾーンのデータが無いため埋め込む
+	"unicode/utf8"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	maxDisplayNameLength = 30
+	maxGradeLength       = 20
+	maxTargetExamLength  = 50
+
+	defaultTimezone = "Asia/Tokyo"
+)
+
+// ProfileService は利用者のプロフィールを扱う。
+// 表示名はプロフィールのものを使い、未保存ならログイン名 (トークンの name クレーム) を使う。
+type ProfileService struct {
+	repo repository.ProfileRepo
+}
+
+func NewProfileService(r repository.ProfileRepo) *ProfileService {
+	return &ProfileService{repo: r}
+}
+
+// GetProfile は本人のプロフィールを返す。未保存なら既定値を返す (保存はしない)。
+func (s *ProfileService) GetProfile(actor *identity.Principal) (*dto.Profile, error) {
+	p, err := s.profile(actor)
+	if err != nil {
+		return nil, err
+	}
+	return toProfileDto(p), nil
+}
+
+// UpdateProfile は本人のプロフィールを req で置き換える。
+// 表示名が空・長すぎる、未知のカテゴリ・タイムゾーンは ErrInvalidArgument。
+func (s *ProfileService) UpdateProfile(actor *identity.Principal, req dto.UpdateProfileRequest) (*dto.Profile, error) {
+	p := model.UserProfile{
+		Sub:                    actor.Sub,
+		DisplayName:            strings.TrimSpace(req.DisplayName),
+		Grade:                  strings.TrimSpace(req.Grade),
+		TargetExam:             strings.TrimSpace(req.TargetExam),
+		Timezone:               strings.TrimSpace(req.Timezone),
+		DefaultIncludeIntegers: req.SessionDefaults.IncludeIntegers,
+		DefaultExamMode:        req.SessionDefaults.ExamMode,
+	}
+	if p.DisplayName == "" || utf8.RuneCountInString(p.DisplayName) > maxDisplayNameLength ||
+		utf8.RuneCountInString(p.Grade) > maxGradeLength ||
+		utf8.RuneCountInString(p.TargetExam) > maxTargetExamLength {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if p.Timezone == "" {
+		p.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return nil, apperr.ErrInvalidArgument
+	}
+	for _, id := range req.PreferredCategories {
+		if id < minCategoryID || id > maxCategoryID {
+			return nil, apperr.ErrInvalidArgument
+		}
+		if !slices.Contains(p.PreferredCategories, id) {
+			p.PreferredCategories = append(p.PreferredCategories, id)
+		}
+	}
+	slices.Sort(p.PreferredCategories)
+
+	if err := s.repo.SaveUserProfile(&p, time.Now()); err != nil {
+		return nil, fmt.Errorf("save profile: %w", err)
+	}
+	return toProfileDto(&p), nil
+}
+
+// User はマイページなどに渡すユーザーを、プロフィールの表示名とタイムゾーンで返す。
+func (s *ProfileService) User(actor *identity.Principal) (*model.User, error) {
+	p, err := s.profile(actor)
+	if err != nil {
+		return nil, err
+	}
+	u := actor.User()
+	u.UserName = p.DisplayName
+	u.Location, err = time.LoadLocation(p.Timezone)
+	if err != nil {
+		u.Location = jst
+	}
+	return u, nil
+}
+
+// profile は保存されたプロフィール、無ければ既定値を返す。
+func (s *ProfileService) profile(actor *identity.Principal) (*model.UserProfile, error) {
+	p, err := s.repo.FindUserProfile(actor.Sub)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return &model.UserProfile{Sub: actor.Sub, DisplayName: actor.Name, Timezone: defaultTimezone}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find profile: %w", err)
+	}
+	return p, nil
+}
+
+func toProfileDto(p *model.UserProfile) *dto.Profile {
+	d := &dto.Profile{
+		UserSub:             p.Sub,
+		DisplayName:         p.DisplayName,
+		Grade:               p.Grade,
+		TargetExam:          p.TargetExam,
+		PreferredCategories: p.PreferredCategories,
+		Timezone:            p.Timezone,
+		SessionDefaults: dto.SessionDefaults{
+			IncludeIntegers: p.DefaultIncludeIntegers,
+			ExamMode:        p.DefaultExamMode,
+		},
+	}
+	if d.PreferredCategories == nil {
+		d.PreferredCategories = []int{}
+	}
+	if !p.CreatedAt.IsZero() {
+		loc, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			loc = jst
+		}
+		d.CreatedAt = p.CreatedAt.In(loc).Format("2006-01-02 15:04:05")
+		d.UpdatedAt = p.UpdatedAt.In(loc).Format("2006-01-02 15:04:05")
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockProfileRepo はプロフィールをメモリ上に持つ。
+type mockProfileRepo struct {
+	profiles map[string]model.UserProfile
+}
+
+func newMockProfileRepo() *mockProfileRepo {
+	return &mockProfileRepo{profiles: make(map[string]model.UserProfile)}
+}
+
+func (m *mockProfileRepo) FindUserProfile(userSub string) (*model.UserProfile, error) {
+	p, ok := m.profiles[userSub]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (m *mockProfileRepo) SaveUserProfile(p *model.UserProfile, at time.Time) error {
+	p.CreatedAt = at
+	if old, ok := m.profiles[p.Sub]; ok {
+		p.CreatedAt = old.CreatedAt
+	}
+	p.UpdatedAt = at
+	m.profiles[p.Sub] = *p
+	return nil
+}
+
+func TestProfileService_DefaultsWhenUnsaved(t *testing.T) {
+	svc := service.NewProfileService(newMockProfileRepo())
+	actor := &identity.Principal{Sub: "user-1", Name: "Alice", Method: identity.MethodJWT}
+
+	p, err := svc.GetProfile(actor)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	if p.DisplayName != "Alice" || p.Timezone != "Asia/Tokyo" || p.CreatedAt != "" {
+		t.Errorf("profile = %+v, want defaults", p)
+	}
+
+	u, err := svc.User(actor)
+	if err != nil {
+		t.Fatalf("User: %v", err)
+	}
+	if u.UserName != "Alice" || u.Location.String() != "Asia/Tokyo" {
+		t.Errorf("user = %+v", u)
+	}
+}
+
+func TestProfileService_UpdateAndUser(t *testing.T) {
+	repo := newMockProfileRepo()
+	svc := service.NewProfileService(repo)
+	actor := &identity.Principal{Sub: "user-1", Name: "token name", Method: identity.MethodJWT}
+
+	p, err := svc.UpdateProfile(actor, dto.UpdateProfileRequest{
+		DisplayName:         " Alice ",
+		Grade:               "高2",
+		PreferredCategories: []int{3, 1, 3},
+		Timezone:            "America/New_York",
+		SessionDefaults:     dto.SessionDefaults{ExamMode: true},
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if p.DisplayName != "Alice" || !slices.Equal(p.PreferredCategories, []int{1, 3}) || !p.SessionDefaults.ExamMode {
+		t.Errorf("profile = %+v, want trimmed name and sorted, deduped categories", p)
+	}
+	if p.CreatedAt == "" {
+		t.Error("createdAt should be set after save")
+	}
+
+	u, err := svc.User(actor)
+	if err != nil {
+		t.Fatalf("User: %v", err)
+	}
+	if u.UserName != "Alice" || u.Location.String() != "America/New_York" {
+		t.Errorf("user = %+v, want stored name and timezone", u)
+	}
+
+	// タイムゾーンを省略すると既定値
+	p, err = svc.UpdateProfile(actor, dto.UpdateProfileRequest{DisplayName: "Alice"})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if p.Timezone != "Asia/Tokyo" || len(p.PreferredCategories) != 0 {
+		t.Errorf("profile = %+v, want default timezone and no categories", p)
+	}
+}
+
+func TestProfileService_UpdateValidation(t *testing.T) {
+	svc := service.NewProfileService(newMockProfileRepo())
+	for name, req := range map[string]dto.UpdateProfileRequest{
+		"empty name":       {DisplayName: " "},
+		"long name":        {DisplayName: strings.Repeat("あ", 31)},
+		"long grade":       {DisplayName: "a", Grade: strings.Repeat("x", 21)},
+		"long exam":        {DisplayName: "a", TargetExam: strings.Repeat("x", 51)},
+		"unknown timezone": {DisplayName: "a", Timezone: "Mars/Olympus"},
+		"unknown category": {DisplayName: "a", PreferredCategories: []int{99}},
+	} {
+		if _, err := svc.UpdateProfile(principal("user-1"), req); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
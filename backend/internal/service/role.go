@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// RoleService は教師・管理者の役割を判定し、管理者による付与・剥奪を行う。
+// 役割はトークンのクレーム (Cognito のグループ)・テーブルに保存した役割・起動時に与えた固定の割り当ての和。
+// 固定の割り当ては最初の管理者を作るためのもので、剥奪できない。
+type RoleService struct {
+	repo      repository.RoleRepo
+	bootstrap map[string][]string // sub → 役割
+}
+
+func NewRoleService(r repository.RoleRepo) *RoleService {
+	return &RoleService{repo: r, bootstrap: make(map[string][]string)}
+}
+
+// WithBootstrap は subs に role を固定で割り当てる。
+func (s *RoleService) WithBootstrap(role string, subs []string) *RoleService {
+	for _, sub := range subs {
+		if !slices.Contains(s.bootstrap[sub], role) {
+			s.bootstrap[sub] = append(s.bootstrap[sub], role)
+		}
+	}
+	return s
+}
+
+// WithBootstrapFromEnv は ADMIN_USER_SUBS / TEACHER_USER_SUBS (カンマ区切りの Cognito sub) に
+// 管理者・教師の役割を固定で割り当てる。
+func (s *RoleService) WithBootstrapFromEnv() *RoleService {
+	return s.WithBootstrap(identity.RoleAdmin, subsFromEnv("ADMIN_USER_SUBS")).
+		WithBootstrap(identity.RoleTeacher, subsFromEnv("TEACHER_USER_SUBS"))
+}
+
+// HasRole は p が role 以上の役割を持つかを返す。identity.RoleChecker を満たす。
+// クレームで足りる場合はテーブルを読まない。
+func (s *RoleService) HasRole(ctx context.Context, p *identity.Principal, role string) (bool, error) {
+	if p.HasRole(role) {
+		return true, nil
+	}
+	roles, err := s.roles(p.Sub)
+	if err != nil {
+		return false, err
+	}
+	return identity.RolesInclude(roles, role), nil
+}
+
+// GetRoles は userSub の役割を返す。本人か管理者のみ。本人の場合はクレームの役割も含める。
+func (s *RoleService) GetRoles(actor *identity.Principal, userSub string) (*dto.UserRoles, error) {
+	if actor.Sub != userSub {
+		if err := s.requireAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	roles, err := s.roles(userSub)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Sub == userSub {
+		roles = append(roles, actor.Roles...)
+	}
+	return &dto.UserRoles{UserSub: userSub, Roles: normalizeRoles(roles)}, nil
+}
+
+// GrantRole は userSub に役割を付与する。管理者のみ。生徒は全員が持つため付与できない (ErrInvalidArgument)。
+func (s *RoleService) GrantRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	if err := s.requireAdmin(actor); err != nil {
+		return nil, err
+	}
+	if userSub == "" || !identity.IsRole(role) || role == identity.RoleStudent {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if err := s.repo.AddUserRole(userSub, role, time.Now()); err != nil {
+		return nil, fmt.Errorf("grant role: %w", err)
+	}
+	return s.GetRoles(actor, userSub)
+}
+
+// RevokeRole は userSub から保存した役割を剥奪する。管理者のみ。
+// 自分の管理者の役割は剥奪できない (管理者がいなくなるのを防ぐ)。
+func (s *RoleService) RevokeRole(actor *identity.Principal, userSub, role string) (*dto.UserRoles, error) {
+	if err := s.requireAdmin(actor); err != nil {
+		return nil, err
+	}
+	if userSub == "" || !identity.IsRole(role) || role == identity.RoleStudent {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if userSub == actor.Sub && role == identity.RoleAdmin {
+		return nil, apperr.ErrConflict
+	}
+	if err := s.repo.RemoveUserRole(userSub, role, time.Now()); err != nil {
+		return nil, fmt.Errorf("revoke role: %w", err)
+	}
+	return s.GetRoles(actor, userSub)
+}
+
+func (s *RoleService) requireAdmin(actor *identity.Principal) error {
+	return requireAdmin(s, actor)
+}
+
+// requireAdmin は actor が管理者でなければ ErrForbidden を返す。
+func requireAdmin(roles identity.RoleChecker, actor *identity.Principal) error {
+	ok, err := roles.HasRole(context.Background(), actor, identity.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apperr.ErrForbidden
+	}
+	return nil
+}
+
+// roles は保存した役割と固定の割り当てを返す。
+func (s *RoleService) roles(userSub string) ([]string, error) {
+	stored, err := s.repo.FindUserRoles(userSub)
+	if err != nil {
+		return nil, fmt.Errorf("find roles: %w", err)
+	}
+	return append(stored, s.bootstrap[userSub]...), nil
+}
+
+func subsFromEnv(key string) []string {
+	var subs []string
+	for _, sub := range strings.Split(os.Getenv(key), ",") {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// normalizeRoles は既知の役割を重複なく並べる。
+func normalizeRoles(roles []string) []string {
+	result := []string{}
+	for _, r := range roles {
+		if identity.IsRole(r) && r != identity.RoleStudent && !slices.Contains(result, r) {
+			result = append(result, r)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+// mockRoleRepo は保存された役割をメモリ上に持つ。
+type mockRoleRepo struct {
+	roles map[string][]string
+	reads int
+}
+
+func (m *mockRoleRepo) FindUserRoles(userSub string) ([]string, error) {
+	m.reads++
+	return slices.Clone(m.roles[userSub]), nil
+}
+
+func (m *mockRoleRepo) AddUserRole(userSub, role string, at time.Time) error {
+	if !slices.Contains(m.roles[userSub], role) {
+		m.roles[userSub] = append(m.roles[userSub], role)
+	}
+	return nil
+}
+
+func (m *mockRoleRepo) RemoveUserRole(userSub, role string, at time.Time) error {
+	m.roles[userSub] = slices.DeleteFunc(m.roles[userSub], func(r string) bool { return r == role })
+	return nil
+}
+
+func TestRoleService_HasRole(t *testing.T) {
+	repo := &mockRoleRepo{roles: map[string][]string{"stored-teacher": {identity.RoleTeacher}}}
+	svc := service.NewRoleService(repo).WithBootstrap(identity.RoleAdmin, []string{"boot-admin"})
+
+	tests := []struct {
+		name string
+		p    *identity.Principal
+		role string
+		want bool
+	}{
+		{"everyone is student", principal("anyone"), identity.RoleStudent, true},
+		{"student is not teacher", principal("anyone"), identity.RoleTeacher, false},
+		{"stored teacher", principal("stored-teacher"), identity.RoleTeacher, true},
+		{"teacher is not admin", principal("stored-teacher"), identity.RoleAdmin, false},
+		{"bootstrap admin is teacher", principal("boot-admin"), identity.RoleTeacher, true},
+		{"claim admin", &identity.Principal{Sub: "x", Roles: []string{identity.RoleAdmin}}, identity.RoleAdmin, true},
+		{"unknown role", principal("boot-admin"), "owner", false},
+	}
+	for _, tt := range tests {
+		got, err := svc.HasRole(context.Background(), tt.p, tt.role)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: HasRole = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	repo.reads = 0
+	svc.HasRole(context.Background(), &identity.Principal{Sub: "x", Roles: []string{identity.RoleTeacher}}, identity.RoleTeacher)
+	if repo.reads != 0 {
+		t.Errorf("roles in claims should not read the table (reads = %d)", repo.reads)
+	}
+}
+
+func TestRoleService_GrantAndRevoke(t *testing.T) {
+	repo := &mockRoleRepo{roles: map[string][]string{"admin": {identity.RoleAdmin}}}
+	svc := service.NewRoleService(repo)
+	admin := principal("admin")
+
+	if _, err := svc.GrantRole(principal("user-1"), "user-2", identity.RoleTeacher); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("grant by non-admin: err = %v, want ErrForbidden", err)
+	}
+	for _, role := range []string{identity.RoleStudent, "owner", ""} {
+		if _, err := svc.GrantRole(admin, "user-2", role); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("grant %q: err = %v, want ErrInvalidArgument", role, err)
+		}
+	}
+
+	got, err := svc.GrantRole(admin, "user-2", identity.RoleTeacher)
+	if err != nil {
+		t.Fatalf("GrantRole: %v", err)
+	}
+	if !slices.Equal(got.Roles, []string{identity.RoleTeacher}) {
+		t.Errorf("roles = %v, want [teacher]", got.Roles)
+	}
+	if ok, _ := svc.HasRole(context.Background(), principal("user-2"), identity.RoleTeacher); !ok {
+		t.Error("granted teacher role is not effective")
+	}
+
+	got, err = svc.RevokeRole(admin, "user-2", identity.RoleTeacher)
+	if err != nil || len(got.Roles) != 0 {
+		t.Errorf("RevokeRole = %v, %v; want no roles", got, err)
+	}
+	if _, err := svc.RevokeRole(admin, "admin", identity.RoleAdmin); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("revoke own admin: err = %v, want ErrConflict", err)
+	}
+}
+
+func TestRoleService_GetRolesSelfOrAdmin(t *testing.T) {
+	repo := &mockRoleRepo{roles: map[string][]string{"admin": {identity.RoleAdmin}}}
+	svc := service.NewRoleService(repo)
+
+	self := &identity.Principal{Sub: "user-1", Roles: []string{identity.RoleTeacher}}
+	got, err := svc.GetRoles(self, "user-1")
+	if err != nil || !slices.Equal(got.Roles, []string{identity.RoleTeacher}) {
+		t.Errorf("GetRoles(self) = %v, %v; want claim role", got, err)
+	}
+	if _, err := svc.GetRoles(self, "user-2"); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("GetRoles(other) err = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.GetRoles(principal("admin"), "user-2"); err != nil {
+		t.Errorf("GetRoles by admin: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// セッションの状態
+const (
+	SessionInProgress = "in_progress"
+	SessionFinished   = "finished"
+	SessionExpired    = "expired" // 終了していないが、課題の締切か制限時間を過ぎて回答できない
+)
+
+// ListSessions は user のセッションを新しい順に1ページ分返す。作成途中のセッションは含めない。
+// 一覧では制限時間切れを判定しない (SP を読まないため)。GetSession では判定する。
+func (s *TestSessionService) ListSessions(user *model.User, q dto.SessionListQuery) (*dto.SessionList, error) {
+	sq := repository.SessionQuery{Limit: defaultMypageLimit, Cursor: q.Cursor}
+	if q.Limit < 0 || q.Limit > maxMypageLimit {
+		return nil, apperr.ErrInvalidArgument
+	}
+	if q.Limit > 0 {
+		sq.Limit = int32(q.Limit)
+	}
+	switch q.Status {
+	case "":
+	case SessionInProgress, SessionExpired:
+		finished := false
+		sq.Finished = &finished
+	case SessionFinished:
+		finished := true
+		sq.Finished = &finished
+	default:
+		return nil, apperr.ErrInvalidArgument
+	}
+
+	page, err := s.repo.FindSessionSummaries(user.Sub, sq)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := userLocation(user)
+	now := time.Now()
+	result := &dto.SessionList{Sessions: []dto.SessionListItem{}, NextCursor: page.NextCursor}
+	for _, sum := range page.Sessions {
+		status := SessionInProgress
+		switch {
+		case sum.FinishedAt != nil:
+			status = SessionFinished
+		case sum.ClosesAt != nil && !now.Before(*sum.ClosesAt):
+			status = SessionExpired
+		}
+		if q.Status != "" && q.Status != status {
+			continue
+		}
+		result.Sessions = append(result.Sessions, dto.SessionListItem{
+			SessionID:       strconv.FormatUint(sum.SessionID, 10),
+			Status:          status,
+			ExamMode:        sum.ExamMode,
+			IncludeIntegers: sum.IncludeIntegers,
+			AssignmentID:    optID(sum.AssignmentID),
+			Attempt:         sum.Attempt,
+			Answered:        sum.Answered,
+			Total:           sum.Total,
+			CreatedAt:       sum.StartTime.In(loc).Format("2006-01-02 15:04:05"),
+			FinishedAt:      optFormat(sum.FinishedAt, loc),
+			ClosesAt:        optFormat(sum.ClosesAt, loc),
+		})
+	}
+	return result, nil
+}
+
+// GetSession はセッションを再開するための状態 (どの問題に回答済みか、次に解く問題) を返す。
+// 閲覧できるのは本人と、課題のセッションならクラスの教師。
+func (s *TestSessionService) GetSession(sessionID uint64, user *model.User) (*dto.SessionState, error) {
+	sess, err := s.repo.FindTestSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.IsReady() {
+		return nil, apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionRead(user.Sub, sess); err != nil {
+		return nil, err
+	}
+
+	sps, err := s.repo.FindSessionProblemsBySessionID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := userLocation(user)
+	accepts := sess.FinishedAt == nil && acceptsAnswer(sess, sps, time.Now())
+	status := SessionInProgress
+	switch {
+	case sess.FinishedAt != nil:
+		status = SessionFinished
+	case !accepts:
+		status = SessionExpired
+	}
+
+	state := &dto.SessionState{
+		SessionListItem: dto.SessionListItem{
+			SessionID:       strconv.FormatUint(sess.ID, 10),
+			Status:          status,
+			ExamMode:        sess.ExamMode,
+			IncludeIntegers: sess.IncludeIntegers,
+			AssignmentID:    optID(sess.AssignmentID),
+			Attempt:         sess.Attempt,
+			Total:           len(sps),
+			CreatedAt:       sess.StartTime.In(loc).Format("2006-01-02 15:04:05"),
+			FinishedAt:      optFormat(sess.FinishedAt, loc),
+			ClosesAt:        optFormat(sess.ClosesAt, loc),
+		},
+		TimeLimitSec:   int64(sess.TimeLimit.Seconds()),
+		Deadline:       optFormat(timeLimitEnd(sess, sps), loc),
+		AcceptsAnswers: accepts,
+		Problems:       make([]dto.SessionProblemState, len(sps)),
+	}
+	for i, sp := range sps {
+		answered := sp.SelectedChoiceID != nil
+		if answered {
+			state.Answered++
+		} else if state.NextIdx == nil {
+			idx := i
+			state.NextIdx = &idx
+		}
+		state.Problems[i] = dto.SessionProblemState{
+			Idx:          i,
+			CategoryName: sp.CategoryName,
+			Answered:     answered,
+			Viewed:       sp.FirstViewedAt != nil,
+			HintRevealed: sp.HintRevealedAt != nil,
+			Version:      sp.Version,
+		}
+	}
+	return state, nil
+}
+
+// optID は ID を文字列で返す。0 (無し) は空文字。
+func optID(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+// optFormat は t を loc の日時で返す。nil は空文字。
+func optFormat(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format("2006-01-02 15:04:05")
+}
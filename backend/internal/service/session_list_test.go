@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+func TestListSessions_StatusAndProgress(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	finished := start.Add(time.Hour)
+	closed := start.Add(-time.Hour)
+	var got repository.SessionQuery
+	repo := &mockTestSessionRepo{
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+			got = q
+			return &repository.SessionPage{
+				Sessions: []repository.SessionSummary{
+					{SessionID: 3, StartTime: start, Total: 12, Answered: 5, ExamMode: true},
+					{SessionID: 2, StartTime: start, Total: 10, Answered: 2, AssignmentID: 9, Attempt: 1, ClosesAt: &closed},
+					{SessionID: 1, StartTime: start, Total: 12, Answered: 12, FinishedAt: &finished},
+				},
+				NextCursor: "next",
+			}, nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	list, err := svc.ListSessions(&model.User{Sub: "sub-1"}, dto.SessionListQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Limit != 20 || got.Finished != nil {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	if len(list.Sessions) != 3 || list.NextCursor != "next" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+	s := list.Sessions[0]
+	if s.SessionID != "3" || s.Status != service.SessionInProgress || s.Answered != 5 || s.Total != 12 || !s.ExamMode {
+		t.Errorf("unexpected in-progress session: %+v", s)
+	}
+	if s.CreatedAt != "2026-01-01 09:00:00" {
+		t.Errorf("expected JST timestamp, got %q", s.CreatedAt)
+	}
+	if s := list.Sessions[1]; s.Status != service.SessionExpired || s.AssignmentID != "9" {
+		t.Errorf("expected closed assignment session to be expired, got %+v", s)
+	}
+	if s := list.Sessions[2]; s.Status != service.SessionFinished || s.FinishedAt == "" {
+		t.Errorf("expected finished session, got %+v", s)
+	}
+
+	// in_progress は未終了だけを取得し、締切を過ぎたものを除く
+	list, err = svc.ListSessions(&model.User{Sub: "sub-1"}, dto.SessionListQuery{Status: service.SessionInProgress})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Finished == nil || *got.Finished {
+		t.Errorf("expected unfinished filter, got %+v", got)
+	}
+	if len(list.Sessions) != 1 || list.Sessions[0].SessionID != "3" {
+		t.Errorf("expected only session 3, got %+v", list.Sessions)
+	}
+}
+
+func TestListSessions_InvalidQuery(t *testing.T) {
+	svc := service.NewTestSessionService(&mockTestSessionRepo{})
+	user := &model.User{Sub: "sub-1"}
+
+	for _, q := range []dto.SessionListQuery{{Status: "paused"}, {Limit: -1}, {Limit: 101}} {
+		if _, err := svc.ListSessions(user, q); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("%+v: expected ErrInvalidArgument, got %v", q, err)
+		}
+	}
+}
+
+func resumeRepo(sess *model.TestSession, sps []model.SessionProblem) *mockTestSessionRepo {
+	return &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return sess, nil
+		},
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			return sps, nil
+		},
+	}
+}
+
+func TestGetSession_NextUnanswered(t *testing.T) {
+	viewed := time.Now().Add(-time.Minute)
+	choice := uint64(1)
+	sps := []model.SessionProblem{
+		{ID: 1, CategoryName: "数と式", SelectedChoiceID: &choice, FirstViewedAt: &viewed, Version: 1},
+		{ID: 2, CategoryName: "数と式", FirstViewedAt: &viewed, HintRevealedAt: &viewed},
+		{ID: 3, CategoryName: "確率", SelectedChoiceID: &choice, Version: 2},
+		{ID: 4, CategoryName: "確率"},
+	}
+	sess := &model.TestSession{ID: 7, UserID: "sub-1", Status: model.SessionStatusReady, TimeLimit: 30 * time.Minute}
+	svc := service.NewTestSessionService(resumeRepo(sess, sps))
+
+	state, err := svc.GetSession(7, &model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state.Status != service.SessionInProgress || !state.AcceptsAnswers {
+		t.Errorf("expected in-progress session accepting answers, got %+v", state)
+	}
+	if state.NextIdx == nil || *state.NextIdx != 1 {
+		t.Errorf("expected nextIdx 1, got %v", state.NextIdx)
+	}
+	if state.Answered != 2 || state.Total != 4 || len(state.Problems) != 4 {
+		t.Errorf("unexpected progress: %+v", state)
+	}
+	if p := state.Problems[1]; p.Answered || !p.Viewed || !p.HintRevealed {
+		t.Errorf("unexpected problem state: %+v", p)
+	}
+	if state.TimeLimitSec != 1800 || state.Deadline == "" {
+		t.Errorf("expected time limit and deadline, got %d %q", state.TimeLimitSec, state.Deadline)
+	}
+}
+
+func TestGetSession_ExpiredByTimeLimit(t *testing.T) {
+	viewed := time.Now().Add(-time.Hour)
+	sps := []model.SessionProblem{{ID: 1, FirstViewedAt: &viewed}}
+	sess := &model.TestSession{ID: 7, UserID: "sub-1", TimeLimit: 10 * time.Minute}
+	svc := service.NewTestSessionService(resumeRepo(sess, sps))
+
+	state, err := svc.GetSession(7, &model.User{Sub: "sub-1"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state.Status != service.SessionExpired || state.AcceptsAnswers {
+		t.Errorf("expected expired session, got %+v", state)
+	}
+}
+
+func TestGetSession_Forbidden(t *testing.T) {
+	sess := &model.TestSession{ID: 7, UserID: "sub-1"}
+	svc := service.NewTestSessionService(resumeRepo(sess, nil))
+
+	if _, err := svc.GetSession(7, &model.User{Sub: "other"}); !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+	}
+
+	sess.Status = model.SessionStatusPending
+	if _, err := svc.GetSession(7, &model.User{Sub: "sub-1"}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for pending session, got %v", err)
+	}
+}
@@ -1,32 +1,36 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 )
 
 type TestSessionService struct {
-	repo repository.TestSessionRepo
+	repo   repository.TestSessionRepo
+	policy *AccessPolicy
 }
 
+// NewTestSessionService は本人のみアクセスできるサービスを返す。
+// 教師に生徒のセッションを閲覧させる場合は WithAccessPolicy でクラス所属を参照するポリシーを渡す。
 func NewTestSessionService(r repository.TestSessionRepo) *TestSessionService {
-	return &TestSessionService{repo: r}
+	return &TestSessionService{repo: r, policy: NewAccessPolicy(nil)}
 }
 
-func (s *TestSessionService) CreateTestSess(userSub string, includeIntegers bool) (*model.TestSession, error) {
-	session := model.TestSession{
-		UserID:          userSub,
-		IncludeIntegers: includeIntegers,
-	}
-
-	if err := s.repo.SaveTestSession(&session); err != nil {
-		return nil, fmt.Errorf("save test session: %w", err)
-	}
+// WithAccessPolicy はアクセス判定を差し替える。
+func (s *TestSessionService) WithAccessPolicy(p *AccessPolicy) *TestSessionService {
+	s.policy = p
+	return s
+}
 
+// CreateTestSess はテストセッションを作成する。examMode のセッションではヒントを表示できない。
+func (s *TestSessionService) CreateTestSess(actor *identity.Principal, includeIntegers, examMode bool) (*model.TestSession, error) {
 	maxCategory := 6
 	if includeIntegers {
 		maxCategory = 7
@@ -44,26 +48,34 @@ func (s *TestSessionService) CreateTestSess(userSub string, includeIntegers bool
 	var sessProbs []model.SessionProblem
 	for _, p := range problems {
 		sessProbs = append(sessProbs, model.SessionProblem{
-			TestSessionID: session.ID,
-			ProblemID:     p.ID,
+			ProblemID:  p.ID,
+			CategoryID: p.CategoryID,
 		})
 	}
 
-	if err := s.repo.SaveSessionProblems(sessProbs); err != nil {
-		return nil, fmt.Errorf("save session problems: %w", err)
+	session := model.TestSession{
+		UserID:          actor.Sub,
+		IncludeIntegers: includeIntegers,
+		ExamMode:        examMode,
+	}
+	if err := s.repo.CreateTestSession(&session, sessProbs); err != nil {
+		return nil, fmt.Errorf("create test session: %w", err)
 	}
 
 	session.SessionProblems = sessProbs
 	return &session, nil
 }
 
-func (s *TestSessionService) GetProblem(sessionID uint64, userSub string, idx int) (*dto.SessionProblem, error) {
+func (s *TestSessionService) GetProblem(sessionID uint64, actor *identity.Principal, idx int) (*dto.SessionProblem, error) {
 	sess, err := s.repo.FindTestSession(sessionID)
 	if err != nil {
 		return nil, err
 	}
-	if sess.UserID != userSub {
-		return nil, apperr.ErrForbidden
+	if !sess.IsReady() {
+		return nil, apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionWrite(actor.Sub, sess); err != nil {
+		return nil, err
 	}
 
 	total, err := s.repo.CountSessionProblems(sessionID)
@@ -78,6 +90,12 @@ func (s *TestSessionService) GetProblem(sessionID uint64, userSub string, idx in
 	if err != nil {
 		return nil, apperr.ErrNotFound
 	}
+	// 所要時間の計測用に初回表示時刻を記録する (2回目以降は変わらない)
+	if sp.FirstViewedAt == nil {
+		if err := s.repo.MarkSessionProblemViewed(sp, time.Now()); err != nil {
+			return nil, fmt.Errorf("mark viewed: %w", err)
+		}
+	}
 
 	var choices []dto.Choice
 	for _, c := range sp.Problem.Choices {
@@ -94,16 +112,62 @@ func (s *TestSessionService) GetProblem(sessionID uint64, userSub string, idx in
 	}
 
 	return &dto.SessionProblem{
-		ID:         int64(sp.ID),
-		Question:   sp.Problem.Question,
-		Choices:    choices,
-		Hint:       sp.Problem.Hint,
-		SelectedID: selectedChoiceID,
-		Total:      int(total),
+		ID:            int64(sp.ID),
+		Question:      sp.Problem.Question,
+		Choices:       choices,
+		HintAvailable: !sess.ExamMode && sp.Problem.Hint != "",
+		HintRevealed:  sp.HintRevealedAt != nil,
+		SelectedID:    selectedChoiceID,
+		Total:         int(total),
+		Version:       sp.Version,
 	}, nil
 }
 
-func (s *TestSessionService) SubmitAnswer(sessionID uint64, userSub string, idx int, choiceID *int64) error {
+// RevealHint はヒントを返し、表示したことを記録する。
+// 試験モードのセッションでは ErrHintNotAllowed (ErrForbidden)、ヒントの無い問題では ErrHintNotFound (ErrNotFound) を返す。
+func (s *TestSessionService) RevealHint(sessionID uint64, actor *identity.Principal, idx int) (*dto.Hint, error) {
+	sess, err := s.repo.FindTestSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.IsReady() {
+		return nil, apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionWrite(actor.Sub, sess); err != nil {
+		return nil, err
+	}
+	if sess.ExamMode {
+		return nil, apperr.ErrHintNotAllowed
+	}
+
+	total, err := s.repo.CountSessionProblems(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= int(total) {
+		return nil, apperr.ErrOutOfRange
+	}
+
+	sp, err := s.repo.FindSessionProblemByIdx(sessionID, idx)
+	if err != nil {
+		return nil, apperr.ErrNotFound
+	}
+	if sp.Problem.Hint == "" {
+		return nil, apperr.ErrHintNotFound
+	}
+	if sp.HintRevealedAt == nil {
+		if err := s.repo.MarkHintRevealed(sp, time.Now()); err != nil {
+			return nil, fmt.Errorf("mark hint revealed: %w", err)
+		}
+	}
+
+	return &dto.Hint{Idx: idx, Hint: sp.Problem.Hint}, nil
+}
+
+// SubmitAnswer は回答を保存する。version を省略した場合は読み取った時点の version を期待値にする。
+// 終了したセッションと、課題の締切・制限時間を過ぎたセッションには ErrSessionFinished を返す。
+// 他のリクエストが先に回答していた場合は現在の dto.AnswerState を持つ *apperr.ConflictError を返す。
+func (s *TestSessionService) SubmitAnswer(sessionID uint64, actor *identity.Principal, idx int, choiceID *int64, version *int64) error {
 	if choiceID == nil {
 		return nil
 	}
@@ -112,8 +176,14 @@ func (s *TestSessionService) SubmitAnswer(sessionID uint64, userSub string, idx
 	if err != nil {
 		return err
 	}
-	if sess.UserID != userSub {
-		return apperr.ErrForbidden
+	if !sess.IsReady() {
+		return apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionWrite(actor.Sub, sess); err != nil {
+		return err
+	}
+	if sess.FinishedAt != nil {
+		return apperr.ErrSessionFinished
 	}
 
 	sps, err := s.repo.FindSessionProblemsBySessionID(sessionID)
@@ -123,14 +193,268 @@ func (s *TestSessionService) SubmitAnswer(sessionID uint64, userSub string, idx
 	if idx < 0 || idx >= len(sps) {
 		return apperr.ErrOutOfRange
 	}
+	now := time.Now()
+	if !acceptsAnswer(sess, sps, now) {
+		return apperr.ErrSessionFinished
+	}
 
 	sp := sps[idx]
 	choice, err := s.repo.FindChoiceByProblemAndChoiceID(sp.ProblemID, uint64(*choiceID))
+	if errors.Is(err, apperr.ErrNotFound) {
+		return apperr.ErrChoiceNotFound
+	}
 	if err != nil {
-		return apperr.ErrNotFound
+		return err
+	}
+
+	// 累計の差分は読み取った SP を基準に計算するため、期待する version は読み取った version と一致していなければならない
+	if version != nil && *version != sp.Version {
+		return &apperr.ConflictError{Current: toAnswerState(idx, sp)}
 	}
+	expected := sp.Version
 
+	delta := answerDelta(actor.Sub, sp, choice.IsCorrect, now)
+	prev := sp
 	sp.SelectedChoiceID = &choice.ID
 	sp.IsCorrect = &choice.IsCorrect
-	return s.repo.SaveSessionProblem(&sp)
+	sp.AnsweredWithHint = sp.HintRevealedAt != nil
+	event := model.AnswerEvent{
+		SessionID:        sessionID,
+		SessionProblemID: sp.ID,
+		ProblemID:        sp.ProblemID,
+		CategoryName:     sp.CategoryName,
+		ChoiceID:         choice.ID,
+		IsCorrect:        choice.IsCorrect,
+		WithHint:         sp.AnsweredWithHint,
+		AnsweredAt:       now,
+	}
+	summary := summaryDelta(sps, idx, prev, sp, now)
+	if err := s.repo.UpdateSessionProblemAnswer(&sp, expected, &event, delta, summary); err != nil {
+		var ce *apperr.ConflictError
+		if errors.As(err, &ce) {
+			if current, ok := ce.Current.(model.SessionProblem); ok {
+				return &apperr.ConflictError{Current: toAnswerState(idx, current)}
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// FinishSession はセッションを終了し、未回答の問題を不正解としてカテゴリ別累計に加える。
+// 終了後は回答できない。終了済みのセッションに対しては何もせず成功を返す。
+// 課題の締切を過ぎたセッションは終了できず ErrSessionFinished を返す。
+func (s *TestSessionService) FinishSession(sessionID uint64, actor *identity.Principal) error {
+	sess, err := s.repo.FindTestSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if !sess.IsReady() {
+		return apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionWrite(actor.Sub, sess); err != nil {
+		return err
+	}
+	if sess.FinishedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	// 課題の締切後は提出を受け付けない。制限時間を過ぎていても終了はできる
+	if sess.ClosesAt != nil && !now.Before(*sess.ClosesAt) {
+		return apperr.ErrSessionFinished
+	}
+
+	sps, err := s.repo.FindSessionProblemsBySessionID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	var unanswered []model.SessionProblem
+	var deltas []model.CategoryStatDelta
+	idx := make(map[int]int)
+	for _, sp := range sps {
+		if sp.SelectedChoiceID != nil {
+			continue
+		}
+		unanswered = append(unanswered, sp)
+		i, exists := idx[sp.CategoryID]
+		if !exists {
+			i = len(deltas)
+			idx[sp.CategoryID] = i
+			deltas = append(deltas, model.CategoryStatDelta{
+				UserSub:      actor.Sub,
+				CategoryID:   sp.CategoryID,
+				CategoryName: sp.CategoryName,
+				At:           now,
+			})
+		}
+		deltas[i].Attempts++
+	}
+
+	if err := s.repo.FinishTestSession(sess, unanswered, deltas, now); err != nil {
+		if errors.Is(err, apperr.ErrSessionFinished) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// acceptsAnswer は課題のセッションが締切・制限時間内かを返す。
+// 制限時間は最初に問題を表示した時刻から数える。自習のセッションは常に true。
+func acceptsAnswer(sess *model.TestSession, sps []model.SessionProblem, now time.Time) bool {
+	if sess.ClosesAt != nil && !now.Before(*sess.ClosesAt) {
+		return false
+	}
+	end := timeLimitEnd(sess, sps)
+	return end == nil || now.Before(*end)
+}
+
+// timeLimitEnd は制限時間の終わりを返す。制限時間が無いか、まだ問題を表示していなければ nil。
+func timeLimitEnd(sess *model.TestSession, sps []model.SessionProblem) *time.Time {
+	if sess.TimeLimit <= 0 {
+		return nil
+	}
+	var started *time.Time
+	for _, sp := range sps {
+		if sp.FirstViewedAt != nil && (started == nil || sp.FirstViewedAt.Before(*started)) {
+			started = sp.FirstViewedAt
+		}
+	}
+	if started == nil {
+		return nil
+	}
+	end := started.Add(sess.TimeLimit)
+	return &end
+}
+
+// answerDelta は sp への回答を正誤 correct に変えたときのカテゴリ別累計の差分を返す。
+// 初回回答なら回答数を1増やし、回答の変更では正答数だけを増減する。
+func answerDelta(userSub string, sp model.SessionProblem, correct bool, at time.Time) model.CategoryStatDelta {
+	d := model.CategoryStatDelta{
+		UserSub:      userSub,
+		CategoryID:   sp.CategoryID,
+		CategoryName: sp.CategoryName,
+		At:           at,
+	}
+	if sp.SelectedChoiceID == nil {
+		d.Attempts = 1
+	}
+	if sp.IsCorrect != nil && *sp.IsCorrect {
+		d.CorrectCount--
+	}
+	if correct {
+		d.CorrectCount++
+	}
+	return d
+}
+
+// summaryDelta は sps[idx] の回答が prev から next に変わったときのセッション集計の差分を返す。
+// 所要時間は初回回答 (answered_at が記録される回答) でのみ加える。
+func summaryDelta(sps []model.SessionProblem, idx int, prev, next model.SessionProblem, at time.Time) model.SessionSummaryDelta {
+	// カテゴリの位置は summarize と同じく出題順の初出順
+	order := make(map[string]int)
+	for _, sp := range sps[:idx+1] {
+		if _, ok := order[sp.CategoryName]; !ok {
+			order[sp.CategoryName] = len(order)
+		}
+	}
+	d := model.SessionSummaryDelta{CategoryIdx: order[next.CategoryName], CategoryName: next.CategoryName, ProblemIdx: idx}
+
+	correct := func(sp model.SessionProblem) bool { return sp.IsCorrect != nil && *sp.IsCorrect }
+	hinted := func(sp model.SessionProblem) bool { return sp.SelectedChoiceID != nil && sp.AnsweredWithHint }
+	count := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	d.Answered = count(next.SelectedChoiceID != nil) - count(prev.SelectedChoiceID != nil)
+	d.Correct = count(correct(next)) - count(correct(prev))
+	d.Hinted = count(hinted(next)) - count(hinted(prev))
+	d.HintedCorrect = count(hinted(next) && correct(next)) - count(hinted(prev) && correct(prev))
+	if prev.AnsweredAt == nil && next.FirstViewedAt != nil {
+		d.Timed = true
+		d.TimeSpent = max(at.Sub(*next.FirstViewedAt), 0)
+	}
+	return d
+}
+
+// GetAnswerHistory はセッション内の全回答を時系列で返し、問題ごとに正誤の変化を集計する。
+func (s *TestSessionService) GetAnswerHistory(sessionID uint64, actor *identity.Principal) (*dto.AnswerHistory, error) {
+	sess, err := s.repo.FindTestSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.IsReady() {
+		return nil, apperr.ErrSessionNotFound
+	}
+	if err := s.policy.AuthorizeSessionRead(actor.Sub, sess); err != nil {
+		return nil, err
+	}
+
+	sps, err := s.repo.FindSessionProblemsBySessionID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.FindAnswerEvents(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &dto.AnswerHistory{
+		SessionID: int64(sessionID),
+		Timeline:  make([]dto.AnswerEvent, 0, len(events)),
+		Problems:  make([]dto.ProblemHistory, len(sps)),
+	}
+	idxBySP := make(map[uint64]int, len(sps))
+	for i, sp := range sps {
+		idxBySP[sp.ID] = i
+		history.Problems[i] = dto.ProblemHistory{
+			Idx:          i,
+			CategoryName: sp.CategoryName,
+			Events:       []dto.AnswerEvent{},
+		}
+	}
+
+	for _, e := range events {
+		idx, ok := idxBySP[e.SessionProblemID]
+		if !ok {
+			continue
+		}
+		de := dto.AnswerEvent{
+			Idx:        idx,
+			ChoiceID:   int64(e.ChoiceID),
+			IsCorrect:  e.IsCorrect,
+			WithHint:   e.WithHint,
+			AnsweredAt: e.AnsweredAt.In(jst).Format("2006-01-02 15:04:05"),
+		}
+		history.Timeline = append(history.Timeline, de)
+
+		ph := &history.Problems[idx]
+		if n := len(ph.Events); n > 0 {
+			prev := ph.Events[n-1]
+			switch {
+			case prev.IsCorrect && !e.IsCorrect:
+				ph.RightToWrong++
+				history.RightToWrong++
+			case !prev.IsCorrect && e.IsCorrect:
+				ph.WrongToRight++
+				history.WrongToRight++
+			}
+		}
+		ph.Attempts++
+		ph.Events = append(ph.Events, de)
+	}
+
+	return history, nil
+}
+
+func toAnswerState(idx int, sp model.SessionProblem) dto.AnswerState {
+	state := dto.AnswerState{Idx: idx, Version: sp.Version}
+	if sp.SelectedChoiceID != nil {
+		id := int64(*sp.SelectedChoiceID)
+		state.SelectedID = &id
+	}
+	return state
 }
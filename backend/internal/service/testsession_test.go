@@ -3,26 +3,35 @@ package service_test
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/identity"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
 	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
 )
 
 // mockTestSessionRepo は repository.TestSessionRepo のテスト用実装。
 type mockTestSessionRepo struct {
-	saveTestSessionFn                func(session *model.TestSession) error
+	createTestSessionFn              func(session *model.TestSession, sps []model.SessionProblem) error
 	findTestSessionFn                func(sessionID uint64) (*model.TestSession, error)
 	findProblemsPerCategoryFn        func(categoryIDs []int, countPerCategory int) ([]model.Problem, error)
-	saveSessionProblemsFn            func(sps []model.SessionProblem) error
 	countSessionProblemsFn           func(sessionID uint64) (int64, error)
 	findSessionProblemByIdxFn        func(sessionID uint64, idx int) (*model.SessionProblem, error)
 	findSessionProblemsBySessionIDFn func(sessionID uint64) ([]model.SessionProblem, error)
 	findChoiceByProblemAndChoiceIDFn func(problemID, choiceID uint64) (*model.Choice, error)
-	saveSessionProblemFn             func(sp *model.SessionProblem) error
+	updateSessionProblemAnswerFn     func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error
+	findAnswerEventsFn               func(sessionID uint64) ([]model.AnswerEvent, error)
+	markSessionProblemViewedFn       func(sp *model.SessionProblem, at time.Time) error
+	markHintRevealedFn               func(sp *model.SessionProblem, at time.Time) error
+	finishTestSessionFn              func(session *model.TestSession, unanswered []model.SessionProblem, deltas []model.CategoryStatDelta, at time.Time) error
+	findSessionSummariesFn           func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error)
 }
 
-func (m *mockTestSessionRepo) SaveTestSession(session *model.TestSession) error {
-	return m.saveTestSessionFn(session)
+func (m *mockTestSessionRepo) CreateTestSession(session *model.TestSession, sps []model.SessionProblem) error {
+	return m.createTestSessionFn(session, sps)
 }
 
 func (m *mockTestSessionRepo) FindTestSession(sessionID uint64) (*model.TestSession, error) {
@@ -36,10 +45,6 @@ func (m *mockTestSessionRepo) FindProblemsPerCategory(categoryIDs []int, countPe
 	return m.findProblemsPerCategoryFn(categoryIDs, countPerCategory)
 }
 
-func (m *mockTestSessionRepo) SaveSessionProblems(sps []model.SessionProblem) error {
-	return m.saveSessionProblemsFn(sps)
-}
-
 func (m *mockTestSessionRepo) CountSessionProblems(sessionID uint64) (int64, error) {
 	return m.countSessionProblemsFn(sessionID)
 }
@@ -56,34 +61,76 @@ func (m *mockTestSessionRepo) FindChoiceByProblemAndChoiceID(problemID, choiceID
 	return m.findChoiceByProblemAndChoiceIDFn(problemID, choiceID)
 }
 
-func (m *mockTestSessionRepo) SaveSessionProblem(sp *model.SessionProblem) error {
-	return m.saveSessionProblemFn(sp)
+func (m *mockTestSessionRepo) UpdateSessionProblemAnswer(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+	return m.updateSessionProblemAnswerFn(sp, expectedVersion, event, delta, summary)
+}
+
+func (m *mockTestSessionRepo) MarkSessionProblemViewed(sp *model.SessionProblem, at time.Time) error {
+	if m.markSessionProblemViewedFn != nil {
+		return m.markSessionProblemViewedFn(sp, at)
+	}
+	sp.FirstViewedAt = &at
+	return nil
+}
+
+func (m *mockTestSessionRepo) MarkHintRevealed(sp *model.SessionProblem, at time.Time) error {
+	if m.markHintRevealedFn != nil {
+		return m.markHintRevealedFn(sp, at)
+	}
+	sp.HintRevealedAt = &at
+	return nil
+}
+
+func (m *mockTestSessionRepo) FindAnswerEvents(sessionID uint64) ([]model.AnswerEvent, error) {
+	return m.findAnswerEventsFn(sessionID)
+}
+
+func (m *mockTestSessionRepo) FinishTestSession(session *model.TestSession, unanswered []model.SessionProblem, deltas []model.CategoryStatDelta, at time.Time) error {
+	return m.finishTestSessionFn(session, unanswered, deltas, at)
+}
+
+func (m *mockTestSessionRepo) FindSessionSummaries(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+	return m.findSessionSummariesFn(userSub, q)
+}
+
+// principal はテスト用の認証済み利用者を返す。
+func principal(sub string) *identity.Principal {
+	return &identity.Principal{Sub: sub, Method: identity.MethodJWT}
 }
 
 func makeProblems(n int) []model.Problem {
 	probs := make([]model.Problem, n)
 	for i := range probs {
-		probs[i] = model.Problem{ID: uint64(i + 1)}
+		probs[i] = model.Problem{ID: uint64(i + 1), CategoryID: i/2 + 1}
 	}
 	return probs
 }
 
+// createWithID はリポジトリと同様にセッションと SP に ID を採番する createTestSessionFn を返す。
+func createWithID(id uint64) func(*model.TestSession, []model.SessionProblem) error {
+	return func(session *model.TestSession, sps []model.SessionProblem) error {
+		session.ID = id
+		session.Status = model.SessionStatusReady
+		for i := range sps {
+			sps[i].TestSessionID = id
+			sps[i].ID = id*100 + uint64(i)
+		}
+		return nil
+	}
+}
+
 // --- CreateTestSess ---
 
 func TestCreateTestSess_Success(t *testing.T) {
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			session.ID = 1
-			return nil
-		},
+		createTestSessionFn: createWithID(1),
 		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
 			return makeProblems(len(categoryIDs) * countPerCategory), nil
 		},
-		saveSessionProblemsFn: func(sps []model.SessionProblem) error { return nil },
 	}
 	svc := service.NewTestSessionService(repo)
 
-	sess, err := svc.CreateTestSess("sub-1", false)
+	sess, err := svc.CreateTestSess(principal("sub-1"), false, false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -99,19 +146,15 @@ func TestCreateTestSess_Success(t *testing.T) {
 func TestCreateTestSess_WithIntegers(t *testing.T) {
 	var gotCategoryIDs []int
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			session.ID = 1
-			return nil
-		},
+		createTestSessionFn: createWithID(1),
 		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
 			gotCategoryIDs = categoryIDs
 			return makeProblems(len(categoryIDs) * countPerCategory), nil
 		},
-		saveSessionProblemsFn: func(sps []model.SessionProblem) error { return nil },
 	}
 	svc := service.NewTestSessionService(repo)
 
-	sess, err := svc.CreateTestSess("sub-1", true)
+	sess, err := svc.CreateTestSess(principal("sub-1"), true, false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -124,20 +167,39 @@ func TestCreateTestSess_WithIntegers(t *testing.T) {
 	}
 }
 
-func TestCreateTestSess_SessionProblemsLinkedToSession(t *testing.T) {
+func TestCreateTestSess_PassesCategoryToRepository(t *testing.T) {
+	var got []model.SessionProblem
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			session.ID = 99
-			return nil
+		createTestSessionFn: func(session *model.TestSession, sps []model.SessionProblem) error {
+			got = sps
+			return createWithID(1)(session, sps)
 		},
 		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
 			return makeProblems(len(categoryIDs) * countPerCategory), nil
 		},
-		saveSessionProblemsFn: func(sps []model.SessionProblem) error { return nil },
 	}
 	svc := service.NewTestSessionService(repo)
 
-	sess, err := svc.CreateTestSess("sub-5", false)
+	if _, err := svc.CreateTestSess(principal("sub-1"), false, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i, sp := range got {
+		if sp.CategoryID != i/2+1 {
+			t.Errorf("expected CategoryID = %d at %d, got %d", i/2+1, i, sp.CategoryID)
+		}
+	}
+}
+
+func TestCreateTestSess_SessionProblemsLinkedToSession(t *testing.T) {
+	repo := &mockTestSessionRepo{
+		createTestSessionFn: createWithID(99),
+		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
+			return makeProblems(len(categoryIDs) * countPerCategory), nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	sess, err := svc.CreateTestSess(principal("sub-5"), false, false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -148,55 +210,565 @@ func TestCreateTestSess_SessionProblemsLinkedToSession(t *testing.T) {
 	}
 }
 
-func TestCreateTestSess_SaveSessionError(t *testing.T) {
+func TestCreateTestSess_FindProblemsError(t *testing.T) {
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			return errors.New("db error")
+		createTestSessionFn: createWithID(1),
+		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
+			return nil, errors.New("db error")
 		},
 	}
 	svc := service.NewTestSessionService(repo)
 
-	_, err := svc.CreateTestSess("sub-1", false)
+	_, err := svc.CreateTestSess(principal("sub-1"), false, false)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
 }
 
-func TestCreateTestSess_FindProblemsError(t *testing.T) {
+func TestCreateTestSess_CreateTestSessionError(t *testing.T) {
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			session.ID = 1
-			return nil
+		createTestSessionFn: func(session *model.TestSession, sps []model.SessionProblem) error {
+			return errors.New("transaction canceled")
 		},
 		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
-			return nil, errors.New("db error")
+			return makeProblems(len(categoryIDs) * countPerCategory), nil
 		},
 	}
 	svc := service.NewTestSessionService(repo)
 
-	_, err := svc.CreateTestSess("sub-1", false)
+	_, err := svc.CreateTestSess(principal("sub-1"), false, false)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
 }
 
-func TestCreateTestSess_SaveSessionProblemsError(t *testing.T) {
+// --- GetProblem ---
+
+// problemRepo は GetProblem のテスト用に、指定の SP を1件持つリポジトリを返す。
+func problemRepo(sp model.SessionProblem, mark func(sp *model.SessionProblem, at time.Time) error) *mockTestSessionRepo {
+	return &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1"}, nil
+		},
+		countSessionProblemsFn: func(sessionID uint64) (int64, error) { return 1, nil },
+		findSessionProblemByIdxFn: func(sessionID uint64, idx int) (*model.SessionProblem, error) {
+			return &sp, nil
+		},
+		markSessionProblemViewedFn: mark,
+	}
+}
+
+func TestGetProblem_MarksFirstView(t *testing.T) {
+	var marked bool
+	svc := service.NewTestSessionService(problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7}, func(sp *model.SessionProblem, at time.Time) error {
+		marked = true
+		if at.IsZero() {
+			t.Error("expected view time to be set")
+		}
+		return nil
+	}))
+
+	if _, err := svc.GetProblem(7, principal("sub-1"), 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !marked {
+		t.Error("expected first view to be recorded")
+	}
+}
+
+func TestGetProblem_AlreadyViewedNotMarked(t *testing.T) {
+	viewed := time.Now().Add(-time.Minute)
+	svc := service.NewTestSessionService(problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7, FirstViewedAt: &viewed}, func(sp *model.SessionProblem, at time.Time) error {
+		t.Error("expected no write for an already viewed problem")
+		return nil
+	}))
+
+	if _, err := svc.GetProblem(7, principal("sub-1"), 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestGetProblem_PendingSessionNotFound(t *testing.T) {
+	repo := &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1", Status: model.SessionStatusPending}, nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	_, err := svc.GetProblem(1, principal("sub-1"), 0)
+	if !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetProblem_HintHiddenInExamMode(t *testing.T) {
+	repo := problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7, Problem: model.Problem{Hint: "因数分解"}}, nil)
+	repo.findTestSessionFn = func(sessionID uint64) (*model.TestSession, error) {
+		return &model.TestSession{ID: sessionID, UserID: "sub-1", ExamMode: true}, nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	p, err := svc.GetProblem(7, principal("sub-1"), 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if p.HintAvailable {
+		t.Error("expected hint to be unavailable in exam mode")
+	}
+}
+
+// --- RevealHint ---
+
+func TestRevealHint_RecordsReveal(t *testing.T) {
+	repo := problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7, Problem: model.Problem{Hint: "因数分解"}}, nil)
+	var marked bool
+	repo.markHintRevealedFn = func(sp *model.SessionProblem, at time.Time) error {
+		marked = true
+		return nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	h, err := svc.RevealHint(7, principal("sub-1"), 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if h.Hint != "因数分解" {
+		t.Errorf("expected hint text, got %q", h.Hint)
+	}
+	if !marked {
+		t.Error("expected hint reveal to be recorded")
+	}
+}
+
+func TestRevealHint_ExamModeForbidden(t *testing.T) {
+	repo := problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7, Problem: model.Problem{Hint: "因数分解"}}, nil)
+	repo.findTestSessionFn = func(sessionID uint64) (*model.TestSession, error) {
+		return &model.TestSession{ID: sessionID, UserID: "sub-1", ExamMode: true}, nil
+	}
+	repo.markHintRevealedFn = func(sp *model.SessionProblem, at time.Time) error {
+		t.Error("expected no reveal in exam mode")
+		return nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	_, err := svc.RevealHint(7, principal("sub-1"), 0)
+	if !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestRevealHint_NoHintNotFound(t *testing.T) {
+	svc := service.NewTestSessionService(problemRepo(model.SessionProblem{ID: 1, TestSessionID: 7}, nil))
+
+	_, err := svc.RevealHint(7, principal("sub-1"), 0)
+	if !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// --- SubmitAnswer ---
+
+func TestSubmitAnswer_PendingSessionNotFound(t *testing.T) {
 	repo := &mockTestSessionRepo{
-		saveTestSessionFn: func(session *model.TestSession) error {
-			session.ID = 1
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1", Status: model.SessionStatusPending}, nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	err := svc.SubmitAnswer(1, principal("sub-1"), 0, &choiceID, nil)
+	if !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSubmitAnswer_UpdatesSummary(t *testing.T) {
+	viewed := time.Now().Add(-time.Minute)
+	var got model.SessionSummaryDelta
+	repo := &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1"}, nil
+		},
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			return []model.SessionProblem{
+				{ID: 1, TestSessionID: sessionID, ProblemID: 10, CategoryName: "数と式"},
+				{ID: 2, TestSessionID: sessionID, ProblemID: 20, CategoryName: "2次関数"},
+				{ID: 3, TestSessionID: sessionID, ProblemID: 30, CategoryName: "2次関数", FirstViewedAt: &viewed},
+			}, nil
+		},
+		findChoiceByProblemAndChoiceIDFn: func(problemID, choiceID uint64) (*model.Choice, error) {
+			return &model.Choice{ID: choiceID, ProblemID: problemID, IsCorrect: true}, nil
+		},
+		updateSessionProblemAnswerFn: func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+			got = summary
 			return nil
 		},
-		findProblemsPerCategoryFn: func(categoryIDs []int, countPerCategory int) ([]model.Problem, error) {
-			return makeProblems(len(categoryIDs) * countPerCategory), nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 2, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.CategoryIdx != 1 || got.CategoryName != "2次関数" || got.Answered != 1 || got.Correct != 1 || got.Hinted != 0 {
+		t.Errorf("unexpected summary delta: %+v", got)
+	}
+	if !got.Timed || got.ProblemIdx != 2 || got.TimeSpent < time.Minute {
+		t.Errorf("expected first answer to be timed, got %+v", got)
+	}
+}
+
+func TestSubmitAnswer_ChangedAnswerSummary(t *testing.T) {
+	var got model.SessionSummaryDelta
+	prevChoice, prevCorrect := uint64(4), true
+	answered := time.Now().Add(-time.Minute)
+	repo := answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got = summary
+		return nil
+	})
+	repo.findSessionProblemsBySessionIDFn = func(sessionID uint64) ([]model.SessionProblem, error) {
+		return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, SelectedChoiceID: &prevChoice, IsCorrect: &prevCorrect, FirstViewedAt: &answered, AnsweredAt: &answered}}, nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Answered != 0 || got.Correct != -1 || got.Timed {
+		t.Errorf("unexpected summary delta: %+v", got)
+	}
+}
+
+// answerRepo は SubmitAnswer のテスト用に、version 3 の回答済み SP を1件持つリポジトリを返す。
+func answerRepo(update func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error) *mockTestSessionRepo {
+	return &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1"}, nil
 		},
-		saveSessionProblemsFn: func(sps []model.SessionProblem) error {
-			return errors.New("db error")
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, Version: 3}}, nil
 		},
+		findChoiceByProblemAndChoiceIDFn: func(problemID, choiceID uint64) (*model.Choice, error) {
+			return &model.Choice{ID: choiceID, ProblemID: problemID}, nil
+		},
+		updateSessionProblemAnswerFn: update,
+	}
+}
+
+func TestSubmitAnswer_DefaultsToReadVersion(t *testing.T) {
+	var got int64
+	svc := service.NewTestSessionService(answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got = expectedVersion
+		return nil
+	}))
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got != 3 {
+		t.Errorf("expected expected version = 3, got %d", got)
+	}
+}
+
+func TestSubmitAnswer_RecordsAnswerEvent(t *testing.T) {
+	var got *model.AnswerEvent
+	svc := service.NewTestSessionService(answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got = event
+		return nil
+	}))
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected answer event to be passed to repository")
+	}
+	if got.SessionID != 7 || got.SessionProblemID != 1 || got.ProblemID != 10 || got.ChoiceID != 5 {
+		t.Errorf("unexpected event: %+v", got)
+	}
+	if got.AnsweredAt.IsZero() {
+		t.Error("expected AnsweredAt to be set")
+	}
+}
+
+func TestSubmitAnswer_MarksAnswerWithHint(t *testing.T) {
+	revealed := time.Now().Add(-time.Minute)
+	var got *model.SessionProblem
+	var event *model.AnswerEvent
+	repo := answerRepo(func(sp *model.SessionProblem, expectedVersion int64, e *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got, event = sp, e
+		return nil
+	})
+	repo.findSessionProblemsBySessionIDFn = func(sessionID uint64) ([]model.SessionProblem, error) {
+		return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, HintRevealedAt: &revealed}}, nil
 	}
 	svc := service.NewTestSessionService(repo)
 
-	_, err := svc.CreateTestSess("sub-1", false)
-	if err == nil {
-		t.Error("expected error, got nil")
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !got.AnsweredWithHint || !event.WithHint {
+		t.Error("expected answer after hint reveal to be marked as with hint")
+	}
+}
+
+func TestSubmitAnswer_StaleClientVersionConflicts(t *testing.T) {
+	called := false
+	svc := service.NewTestSessionService(answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		called = true
+		return nil
+	}))
+
+	choiceID, version := int64(5), int64(2)
+	err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, &version)
+	var ce *apperr.ConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *apperr.ConflictError, got %v", err)
+	}
+	if state, ok := ce.Current.(dto.AnswerState); !ok || state.Version != 3 {
+		t.Errorf("expected current version 3, got %+v", ce.Current)
+	}
+	if called {
+		t.Error("expected repository not to be updated with a stale version")
+	}
+}
+
+func TestSubmitAnswer_FirstAnswerAddsAttempt(t *testing.T) {
+	var got model.CategoryStatDelta
+	repo := answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got = delta
+		return nil
+	})
+	repo.findSessionProblemsBySessionIDFn = func(sessionID uint64) ([]model.SessionProblem, error) {
+		return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, CategoryID: 2, CategoryName: "2次関数"}}, nil
+	}
+	repo.findChoiceByProblemAndChoiceIDFn = func(problemID, choiceID uint64) (*model.Choice, error) {
+		return &model.Choice{ID: choiceID, ProblemID: problemID, IsCorrect: true}, nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.UserSub != "sub-1" || got.CategoryID != 2 || got.Attempts != 1 || got.CorrectCount != 1 {
+		t.Errorf("unexpected delta: %+v", got)
+	}
+}
+
+func TestSubmitAnswer_ChangedAnswerAdjustsCorrectOnly(t *testing.T) {
+	var got model.CategoryStatDelta
+	prevChoice, prevCorrect := uint64(4), true
+	repo := answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		got = delta
+		return nil
+	})
+	repo.findSessionProblemsBySessionIDFn = func(sessionID uint64) ([]model.SessionProblem, error) {
+		return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, CategoryID: 2,
+			SelectedChoiceID: &prevChoice, IsCorrect: &prevCorrect, Version: 1}}, nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Attempts != 0 || got.CorrectCount != -1 {
+		t.Errorf("expected delta attempts=0 correct=-1, got %+v", got)
+	}
+}
+
+func TestSubmitAnswer_FinishedSession(t *testing.T) {
+	finished := time.Now()
+	repo := answerRepo(nil)
+	repo.findTestSessionFn = func(sessionID uint64) (*model.TestSession, error) {
+		return &model.TestSession{ID: sessionID, UserID: "sub-1", FinishedAt: &finished}, nil
+	}
+	svc := service.NewTestSessionService(repo)
+
+	choiceID := int64(5)
+	if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); !errors.Is(err, apperr.ErrSessionFinished) {
+		t.Errorf("expected ErrSessionFinished, got %v", err)
+	}
+}
+
+func TestSubmitAnswer_AssignmentClosedOrTimedOut(t *testing.T) {
+	now := time.Now()
+	closed := now.Add(-time.Minute)
+	open := now.Add(time.Hour)
+	viewed := now.Add(-31 * time.Minute)
+
+	tests := []struct {
+		name     string
+		sess     model.TestSession
+		viewedAt *time.Time
+	}{
+		{"closed", model.TestSession{ClosesAt: &closed}, nil},
+		{"time limit", model.TestSession{ClosesAt: &open, TimeLimit: 30 * time.Minute}, &viewed},
+	}
+	for _, tt := range tests {
+		repo := answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+			t.Errorf("%s: answer must not be saved", tt.name)
+			return nil
+		})
+		repo.findTestSessionFn = func(sessionID uint64) (*model.TestSession, error) {
+			sess := tt.sess
+			sess.ID, sess.UserID = sessionID, "sub-1"
+			return &sess, nil
+		}
+		repo.findSessionProblemsBySessionIDFn = func(sessionID uint64) ([]model.SessionProblem, error) {
+			return []model.SessionProblem{{ID: 1, TestSessionID: sessionID, ProblemID: 10, FirstViewedAt: tt.viewedAt}}, nil
+		}
+		svc := service.NewTestSessionService(repo)
+
+		choiceID := int64(5)
+		if err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil); !errors.Is(err, apperr.ErrSessionFinished) {
+			t.Errorf("%s: expected ErrSessionFinished, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestSubmitAnswer_ConflictReturnsCurrentState(t *testing.T) {
+	current := uint64(6)
+	svc := service.NewTestSessionService(answerRepo(func(sp *model.SessionProblem, expectedVersion int64, event *model.AnswerEvent, delta model.CategoryStatDelta, summary model.SessionSummaryDelta) error {
+		return &apperr.ConflictError{Current: model.SessionProblem{ID: sp.ID, SelectedChoiceID: &current, Version: 4}}
+	}))
+
+	choiceID := int64(5)
+	err := svc.SubmitAnswer(7, principal("sub-1"), 0, &choiceID, nil)
+	if !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	var ce *apperr.ConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *apperr.ConflictError, got %T", err)
+	}
+	state, ok := ce.Current.(dto.AnswerState)
+	if !ok {
+		t.Fatalf("expected dto.AnswerState, got %T", ce.Current)
+	}
+	if state.Idx != 0 || state.Version != 4 || state.SelectedID == nil || *state.SelectedID != 6 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+}
+
+// --- FinishSession ---
+
+func TestFinishSession_CountsUnansweredPerCategory(t *testing.T) {
+	choice, correct := uint64(1), true
+	var gotUnanswered []model.SessionProblem
+	var gotDeltas []model.CategoryStatDelta
+	repo := &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1"}, nil
+		},
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			return []model.SessionProblem{
+				{ID: 1, CategoryID: 1, SelectedChoiceID: &choice, IsCorrect: &correct},
+				{ID: 2, CategoryID: 1},
+				{ID: 3, CategoryID: 2},
+				{ID: 4, CategoryID: 2},
+			}, nil
+		},
+		finishTestSessionFn: func(session *model.TestSession, unanswered []model.SessionProblem, deltas []model.CategoryStatDelta, at time.Time) error {
+			gotUnanswered, gotDeltas = unanswered, deltas
+			return nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	if err := svc.FinishSession(7, principal("sub-1")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(gotUnanswered) != 3 {
+		t.Errorf("expected 3 unanswered problems, got %d", len(gotUnanswered))
+	}
+	if len(gotDeltas) != 2 || gotDeltas[0].Attempts != 1 || gotDeltas[1].Attempts != 2 {
+		t.Fatalf("unexpected deltas: %+v", gotDeltas)
+	}
+	for _, d := range gotDeltas {
+		if d.CorrectCount != 0 || d.UserSub != "sub-1" {
+			t.Errorf("unexpected delta: %+v", d)
+		}
+	}
+}
+
+func TestFinishSession_AlreadyFinishedIsNoop(t *testing.T) {
+	finished := time.Now()
+	repo := &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1", FinishedAt: &finished}, nil
+		},
+	}
+	svc := service.NewTestSessionService(repo)
+
+	if err := svc.FinishSession(7, principal("sub-1")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func historyRepo(events []model.AnswerEvent) *mockTestSessionRepo {
+	return &mockTestSessionRepo{
+		findTestSessionFn: func(sessionID uint64) (*model.TestSession, error) {
+			return &model.TestSession{ID: sessionID, UserID: "sub-1"}, nil
+		},
+		findSessionProblemsBySessionIDFn: func(sessionID uint64) ([]model.SessionProblem, error) {
+			return []model.SessionProblem{
+				{ID: 11, TestSessionID: sessionID, CategoryName: "数と式"},
+				{ID: 12, TestSessionID: sessionID, CategoryName: "関数"},
+			}, nil
+		},
+		findAnswerEventsFn: func(sessionID uint64) ([]model.AnswerEvent, error) {
+			return events, nil
+		},
+	}
+}
+
+func TestGetAnswerHistory_CountsChanges(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := service.NewTestSessionService(historyRepo([]model.AnswerEvent{
+		{ID: 1, SessionProblemID: 11, ChoiceID: 1, IsCorrect: true, AnsweredAt: at},
+		{ID: 2, SessionProblemID: 12, ChoiceID: 3, IsCorrect: false, AnsweredAt: at.Add(time.Minute)},
+		{ID: 3, SessionProblemID: 11, ChoiceID: 2, IsCorrect: false, AnsweredAt: at.Add(2 * time.Minute)},
+		{ID: 4, SessionProblemID: 12, ChoiceID: 4, IsCorrect: true, AnsweredAt: at.Add(3 * time.Minute)},
+		{ID: 5, SessionProblemID: 12, ChoiceID: 4, IsCorrect: true, AnsweredAt: at.Add(4 * time.Minute)},
+	}))
+
+	h, err := svc.GetAnswerHistory(7, principal("sub-1"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(h.Timeline) != 5 {
+		t.Fatalf("expected 5 timeline events, got %d", len(h.Timeline))
+	}
+	if h.Timeline[0].AnsweredAt != "2026-01-01 09:00:00" {
+		t.Errorf("expected JST timestamp, got %q", h.Timeline[0].AnsweredAt)
+	}
+	if h.Timeline[1].Idx != 1 {
+		t.Errorf("expected second event for idx 1, got %d", h.Timeline[1].Idx)
+	}
+	if p := h.Problems[0]; p.Attempts != 2 || p.RightToWrong != 1 || p.WrongToRight != 0 {
+		t.Errorf("unexpected history for idx 0: %+v", p)
+	}
+	if p := h.Problems[1]; p.Attempts != 3 || p.RightToWrong != 0 || p.WrongToRight != 1 {
+		t.Errorf("unexpected history for idx 1: %+v", p)
+	}
+	if h.RightToWrong != 1 || h.WrongToRight != 1 {
+		t.Errorf("expected totals 1/1, got %d/%d", h.RightToWrong, h.WrongToRight)
+	}
+}
+
+func TestGetAnswerHistory_Forbidden(t *testing.T) {
+	svc := service.NewTestSessionService(historyRepo(nil))
+
+	_, err := svc.GetAnswerHistory(7, principal("other"))
+	if !errors.Is(err, apperr.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
 	}
 }
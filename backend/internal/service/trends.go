@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+const (
+	defaultTrendSessions = 10
+	defaultTrendWindow   = 3
+	// 得意・苦手カテゴリとして返す件数
+	trendRankSize = 3
+)
+
+// GetTrends は直近 N セッションを通したカテゴリ別の推移・得意/苦手カテゴリ・連続学習日数を返す。
+func (s *MypageService) GetTrends(user *model.User, q dto.TrendQuery) (*dto.Trends, error) {
+	n, window := q.Sessions, q.Window
+	if n == 0 {
+		n = defaultTrendSessions
+	}
+	if window == 0 {
+		window = defaultTrendWindow
+	}
+	if n < 0 || n > maxMypageLimit || window < 0 {
+		return nil, apperr.ErrInvalidArgument
+	}
+
+	sessions, err := s.recentSessions(user.Sub, n)
+	if err != nil {
+		return nil, err
+	}
+	// 推移は古い順に並べる
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+
+	result := &dto.Trends{
+		SessionCount: len(sessions),
+		Window:       window,
+		Categories:   []dto.CategoryTrend{},
+		Best:         []dto.CategoryRank{},
+		Worst:        []dto.CategoryRank{},
+	}
+
+	idx := make(map[string]int)
+	var ranks []dto.CategoryRank
+	for _, sum := range sessions {
+		for _, c := range sum.Categories {
+			if c.TotalCount == 0 {
+				continue
+			}
+			i, exists := idx[c.Name]
+			if !exists {
+				i = len(result.Categories)
+				idx[c.Name] = i
+				result.Categories = append(result.Categories, dto.CategoryTrend{CategoryName: c.Name})
+				ranks = append(ranks, dto.CategoryRank{CategoryName: c.Name})
+			}
+			result.Categories[i].Points = append(result.Categories[i].Points, dto.TrendPoint{
+				SessionID:    int64(sum.SessionID),
+				StartTime:    sum.StartTime.In(userLocation(user)).Format("2006-01-02 15:04:05"),
+				Total:        c.TotalCount,
+				CorrectCount: c.CorrectCount,
+				Accuracy:     float64(c.CorrectCount) / float64(c.TotalCount),
+			})
+			ranks[i].Total += c.TotalCount
+			ranks[i].CorrectCount += c.CorrectCount
+		}
+	}
+
+	for i := range result.Categories {
+		fillRolling(&result.Categories[i], window)
+	}
+
+	for i := range ranks {
+		ranks[i].Accuracy = float64(ranks[i].CorrectCount) / float64(ranks[i].Total)
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return ranks[i].Accuracy > ranks[j].Accuracy
+	})
+	for i := 0; i < len(ranks) && i < trendRankSize; i++ {
+		result.Best = append(result.Best, ranks[i])
+	}
+	for i := len(ranks) - 1; i >= 0 && len(result.Worst) < trendRankSize; i-- {
+		result.Worst = append(result.Worst, ranks[i])
+	}
+
+	result.WeakCategories = s.weak.SelectFromHistory(sessions)
+	result.Streak = studyStreak(sessions, time.Now(), userLocation(user))
+	return result, nil
+}
+
+// recentSessions は新しい順に最大 n 件のセッション集計を取得する。
+func (s *MypageService) recentSessions(userSub string, n int) ([]repository.SessionSummary, error) {
+	var sessions []repository.SessionSummary
+	q := repository.SessionQuery{Limit: int32(n)}
+	for len(sessions) < n {
+		page, err := s.repo.FindSessionSummaries(userSub, q)
+		if err != nil {
+			return nil, fmt.Errorf("find session summaries: %w", err)
+		}
+		sessions = append(sessions, page.Sessions...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(sessions) > n {
+		sessions = sessions[:n]
+	}
+	return sessions, nil
+}
+
+// allSessions は全セッションの集計を新しい順に取得する。
+func (s *MypageService) allSessions(userSub string) ([]repository.SessionSummary, error) {
+	return findAllSessions(s.repo, userSub)
+}
+
+// sessionSummaryFinder はセッション集計をページ単位で返すリポジトリ。
+type sessionSummaryFinder interface {
+	FindSessionSummaries(userSub string, q repository.SessionQuery) (*repository.SessionPage, error)
+}
+
+// findAllSessions はページをたどってユーザーの全セッションの集計を新しい順に取得する。
+func findAllSessions(r sessionSummaryFinder, userSub string) ([]repository.SessionSummary, error) {
+	var sessions []repository.SessionSummary
+	q := repository.SessionQuery{Limit: maxMypageLimit}
+	for {
+		page, err := r.FindSessionSummaries(userSub, q)
+		if err != nil {
+			return nil, fmt.Errorf("find session summaries: %w", err)
+		}
+		sessions = append(sessions, page.Sessions...)
+		if page.NextCursor == "" {
+			return sessions, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// fillRolling は直近 window 回分の正答数・出題数から移動正答率を埋め、最初と最後の差を Change に入れる。
+func fillRolling(t *dto.CategoryTrend, window int) {
+	for i := range t.Points {
+		start := i - window + 1
+		if start < 0 {
+			start = 0
+		}
+		var total, correct int
+		for _, p := range t.Points[start : i+1] {
+			total += p.Total
+			correct += p.CorrectCount
+		}
+		t.Points[i].RollingAccuracy = float64(correct) / float64(total)
+	}
+	if n := len(t.Points); n >= 2 {
+		change := t.Points[n-1].RollingAccuracy - t.Points[0].RollingAccuracy
+		t.Change = &change
+	}
+}
+
+// studyStreak は受験した日 (loc の日付) の連続日数を数える。
+// 今日または昨日に受験していれば Current はそこから遡った連続日数、そうでなければ 0。
+func studyStreak(sessions []repository.SessionSummary, now time.Time, loc *time.Location) dto.Streak {
+	days := make(map[string]bool)
+	var dates []time.Time
+	for _, sum := range sessions {
+		t := sum.StartTime.In(loc)
+		d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		key := d.Format("2006-01-02")
+		if !days[key] {
+			days[key] = true
+			dates = append(dates, d)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var streak dto.Streak
+	run := 0
+	for i, d := range dates {
+		if i > 0 && dates[i-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > streak.LongestDays {
+			streak.LongestDays = run
+		}
+	}
+	if len(dates) == 0 {
+		return streak
+	}
+
+	streak.LastStudyDate = dates[len(dates)-1].Format("2006-01-02")
+	today := now.In(loc)
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	if !days[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day.Format("2006-01-02")] {
+		streak.CurrentDays++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/apperr"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/dto"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/model"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+func catSession(id uint64, start time.Time, cats ...repository.CategoryStats) repository.SessionSummary {
+	return repository.SessionSummary{SessionID: id, StartTime: start, Categories: cats}
+}
+
+func TestGetTrends_RollingAccuracyAndRanks(t *testing.T) {
+	base := time.Date(2025, 10, 1, 1, 0, 0, 0, time.UTC)
+	// リポジトリは新しい順に返す
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			catSession(3, base.Add(48*time.Hour),
+				repository.CategoryStats{Name: "2次関数", TotalCount: 2, CorrectCount: 2},
+				repository.CategoryStats{Name: "確率", TotalCount: 2, CorrectCount: 0}),
+			catSession(2, base.Add(24*time.Hour),
+				repository.CategoryStats{Name: "2次関数", TotalCount: 2, CorrectCount: 1}),
+			catSession(1, base,
+				repository.CategoryStats{Name: "2次関数", TotalCount: 2, CorrectCount: 0},
+				repository.CategoryStats{Name: "確率", TotalCount: 2, CorrectCount: 1}),
+		),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetTrends(&model.User{Sub: "sub-1"}, dto.TrendQuery{Window: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.SessionCount != 3 || len(result.Categories) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	quad := result.Categories[0]
+	if quad.CategoryName != "2次関数" || len(quad.Points) != 3 {
+		t.Fatalf("unexpected first category: %+v", quad)
+	}
+	if quad.Points[0].SessionID != 1 {
+		t.Errorf("expected oldest session first, got %d", quad.Points[0].SessionID)
+	}
+	want := []float64{0, 0.25, 0.75}
+	for i, p := range quad.Points {
+		if p.RollingAccuracy != want[i] {
+			t.Errorf("point %d: expected rolling %.2f, got %.2f", i, want[i], p.RollingAccuracy)
+		}
+	}
+	if quad.Change == nil || *quad.Change != 0.75 {
+		t.Errorf("expected change 0.75, got %v", quad.Change)
+	}
+
+	if result.Best[0].CategoryName != "2次関数" || result.Worst[0].CategoryName != "確率" {
+		t.Errorf("unexpected ranks: best=%+v worst=%+v", result.Best, result.Worst)
+	}
+}
+
+func TestGetTrends_SingleAppearanceHasNoChange(t *testing.T) {
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			catSession(1, time.Now(), repository.CategoryStats{Name: "整数", TotalCount: 2, CorrectCount: 1}),
+		),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetTrends(&model.User{Sub: "sub-1"}, dto.TrendQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Categories[0].Change != nil {
+		t.Errorf("expected no change for a single point, got %v", *result.Categories[0].Change)
+	}
+	if result.Window != 3 {
+		t.Errorf("expected default window 3, got %d", result.Window)
+	}
+}
+
+func TestGetTrends_LimitsToRecentSessions(t *testing.T) {
+	var calls int
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: func(userSub string, q repository.SessionQuery) (*repository.SessionPage, error) {
+			calls++
+			if q.Limit != 2 {
+				t.Errorf("expected limit 2, got %d", q.Limit)
+			}
+			return &repository.SessionPage{
+				Sessions:   []repository.SessionSummary{catSession(3, time.Now(), repository.CategoryStats{Name: "確率", TotalCount: 1})},
+				NextCursor: "next",
+			}, nil
+		},
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetTrends(&model.User{Sub: "sub-1"}, dto.TrendQuery{Sessions: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 2 || result.SessionCount != 2 {
+		t.Errorf("expected 2 calls and 2 sessions, got %d / %d", calls, result.SessionCount)
+	}
+}
+
+func TestGetTrends_Streak(t *testing.T) {
+	now := time.Now()
+	repo := &mockMypageRepo{
+		findSessionSummariesFn: singlePage(
+			catSession(5, now),
+			catSession(4, now.AddDate(0, 0, -1)),
+			catSession(3, now.AddDate(0, 0, -1)),
+			catSession(2, now.AddDate(0, 0, -5)),
+			catSession(1, now.AddDate(0, 0, -6)),
+			catSession(0, now.AddDate(0, 0, -7)),
+		),
+	}
+	svc := service.NewMypageService(repo)
+
+	result, err := svc.GetTrends(&model.User{Sub: "sub-1"}, dto.TrendQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Streak.CurrentDays != 2 || result.Streak.LongestDays != 3 {
+		t.Errorf("unexpected streak: %+v", result.Streak)
+	}
+}
+
+func TestGetTrends_InvalidQuery(t *testing.T) {
+	svc := service.NewMypageService(&mockMypageRepo{})
+
+	for _, q := range []dto.TrendQuery{{Sessions: -1}, {Sessions: 101}, {Window: -1}} {
+		if _, err := svc.GetTrends(&model.User{Sub: "sub-1"}, q); !errors.Is(err, apperr.ErrInvalidArgument) {
+			t.Errorf("%+v: expected ErrInvalidArgument, got %v", q, err)
+		}
+	}
+}
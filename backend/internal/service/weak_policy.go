@@ -0,0 +1,97 @@
+package service
+
+import (
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+)
+
+// WeakPolicy は苦手カテゴリの判定基準。
+// 出題数が MinSamples 以上で正答率が Threshold 未満のカテゴリを、正答率の低い順に最大 MaxCount 件選ぶ。
+// MinSamples があるため、1問たまたま正解しただけで苦手から外れることはない。
+type WeakPolicy struct {
+	Threshold  float64
+	MaxCount   int
+	MinSamples int
+}
+
+// DefaultWeakPolicy は既定の判定基準 (正答率 0.5 未満・最大2件・2問以上) を返す。
+func DefaultWeakPolicy() WeakPolicy {
+	return WeakPolicy{Threshold: 0.5, MaxCount: 2, MinSamples: 2}
+}
+
+// WeakPolicyFromEnv は既定値を WEAK_THRESHOLD / WEAK_MAX_COUNT / WEAK_MIN_SAMPLES で上書きした基準を返す。
+// 不正な値は無視して既定値を使う。
+func WeakPolicyFromEnv() WeakPolicy {
+	p := DefaultWeakPolicy()
+	if s := os.Getenv("WEAK_THRESHOLD"); s != "" {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 && v <= 1 {
+			p.Threshold = v
+		}
+	}
+	if s := os.Getenv("WEAK_MAX_COUNT"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v >= 0 {
+			p.MaxCount = v
+		}
+	}
+	if s := os.Getenv("WEAK_MIN_SAMPLES"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v >= 1 {
+			p.MinSamples = v
+		}
+	}
+	return p
+}
+
+// Select は1セッション分 (または集計済み) のカテゴリ別成績から苦手カテゴリを選ぶ。
+func (p WeakPolicy) Select(stats []repository.CategoryStats) []string {
+	type weakCat struct {
+		name string
+		rate float64
+	}
+	var weaks []weakCat
+	for _, st := range stats {
+		if st.TotalCount == 0 || st.TotalCount < p.MinSamples {
+			continue
+		}
+		rate := float64(st.CorrectCount) / float64(st.TotalCount)
+		if rate < p.Threshold {
+			weaks = append(weaks, weakCat{name: st.Name, rate: rate})
+		}
+	}
+	sort.SliceStable(weaks, func(i, j int) bool {
+		return weaks[i].rate < weaks[j].rate
+	})
+
+	names := []string{}
+	for i := 0; i < len(weaks) && i < p.MaxCount; i++ {
+		names = append(names, weaks[i].name)
+	}
+	return names
+}
+
+// SelectFromHistory は複数セッションのカテゴリ別成績を合算してから苦手カテゴリを選ぶ。
+// 1セッションの結果に左右されにくい判定が必要な場合に使う。
+func (p WeakPolicy) SelectFromHistory(sessions []repository.SessionSummary) []string {
+	return p.Select(aggregateCategories(sessions))
+}
+
+// aggregateCategories はセッションをまたいでカテゴリ別の出題数・正答数を合算する。カテゴリは初出順。
+func aggregateCategories(sessions []repository.SessionSummary) []repository.CategoryStats {
+	idx := make(map[string]int)
+	var stats []repository.CategoryStats
+	for _, sum := range sessions {
+		for _, c := range sum.Categories {
+			i, exists := idx[c.Name]
+			if !exists {
+				i = len(stats)
+				idx[c.Name] = i
+				stats = append(stats, repository.CategoryStats{Name: c.Name})
+			}
+			stats[i].TotalCount += c.TotalCount
+			stats[i].CorrectCount += c.CorrectCount
+		}
+	}
+	return stats
+}
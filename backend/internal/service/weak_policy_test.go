@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/Kyouheip/MathOvercome_serverless/internal/repository"
+	"github.com/Kyouheip/MathOvercome_serverless/internal/service"
+)
+
+func TestWeakPolicy_Select(t *testing.T) {
+	stats := []repository.CategoryStats{
+		{Name: "数と式", TotalCount: 4, CorrectCount: 1},
+		{Name: "確率", TotalCount: 4, CorrectCount: 0},
+		{Name: "整数", TotalCount: 1, CorrectCount: 0},
+		{Name: "2次関数", TotalCount: 4, CorrectCount: 2},
+		{Name: "データの分析", TotalCount: 4, CorrectCount: 1},
+	}
+
+	got := service.DefaultWeakPolicy().Select(stats)
+	if len(got) != 2 || got[0] != "確率" || got[1] != "数と式" {
+		t.Errorf("unexpected weak categories: %v", got)
+	}
+}
+
+func TestWeakPolicy_MinSamples(t *testing.T) {
+	stats := []repository.CategoryStats{{Name: "確率", TotalCount: 1, CorrectCount: 0}}
+
+	if got := (service.WeakPolicy{Threshold: 0.5, MaxCount: 2, MinSamples: 2}).Select(stats); len(got) != 0 {
+		t.Errorf("expected no weak categories below min samples, got %v", got)
+	}
+	if got := (service.WeakPolicy{Threshold: 0.5, MaxCount: 2, MinSamples: 1}).Select(stats); len(got) != 1 {
+		t.Errorf("expected 1 weak category, got %v", got)
+	}
+}
+
+func TestWeakPolicyFromEnv(t *testing.T) {
+	t.Setenv("WEAK_THRESHOLD", "0.6")
+	t.Setenv("WEAK_MAX_COUNT", "3")
+	t.Setenv("WEAK_MIN_SAMPLES", "invalid")
+
+	p := service.WeakPolicyFromEnv()
+	if p.Threshold != 0.6 || p.MaxCount != 3 || p.MinSamples != service.DefaultWeakPolicy().MinSamples {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+}
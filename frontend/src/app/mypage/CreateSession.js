@@ -9,6 +9,7 @@ import { getAuthHeader } from "@/lib/auth";
 
 export default function CreateSession(){
     const [includeIntegers,setIncludeIntegers] = useState(false);
+    const [examMode,setExamMode] = useState(false);
     const [error,setError] = useState(null);
     const router = useRouter();
     const errorHandler = useErrorHandler(setError);
@@ -17,7 +18,7 @@ export default function CreateSession(){
     const startTest = async () => {
       try{
         const res = await fetch(
-            `${process.env.NEXT_PUBLIC_API_URL}/session/test?includeIntegers=${includeIntegers}`,
+            `${process.env.NEXT_PUBLIC_API_URL}/session/test?includeIntegers=${includeIntegers}&examMode=${examMode}`,
             {
                 method: 'POST',
                 headers: await getAuthHeader(),
@@ -54,6 +55,18 @@ export default function CreateSession(){
                 整数分野も問題に含める
               </label>
             </div>
+            <div className="form-check mb-3">
+              <input 
+                type="checkbox"
+                className="form-check-input"
+                id="examMode"
+                checked={examMode}
+                onChange={e => setExamMode(e.target.checked)}
+              />
+              <label htmlFor="examMode" className="form-check-label">
+                試験モード（ヒントなし）
+              </label>
+            </div>
 
             <button className="btn btn-primary" onClick={startTest}>テスト開始</button>
       
@@ -0,0 +1,69 @@
+// /mypage/ResumeSessions.js
+'use client'
+import { useEffect, useState } from "react";
+import { useRouter } from "next/navigation";
+import { useErrorHandler } from "@/hooks/useErrorHandler";
+import { getAuthHeader } from "@/lib/auth";
+
+
+// 途中のセッションを一覧し、最初の未回答の問題から再開できるようにする
+export default function ResumeSessions() {
+    const [sessions, setSessions] = useState([]);
+    const [error, setError] = useState(null);
+    const router = useRouter();
+    const errorHandler = useErrorHandler(setError);
+
+    useEffect(() => {
+        const load = async () => {
+            try {
+                const res = await fetch(
+                    `${process.env.NEXT_PUBLIC_API_URL}/v1/sessions?status=in_progress&limit=5`,
+                    { headers: await getAuthHeader() }
+                );
+
+                if (!errorHandler(res)) return;
+
+                const data = await res.json();
+                setSessions(data.sessions);
+            } catch {
+                setError("通信エラーが発生しました。");
+            }
+        };
+        load();
+    }, []);
+
+    // 一覧には次の問題が無いので、再開時にセッションの状態を取得する
+    const resume = async (sessionId) => {
+        try {
+            const res = await fetch(
+                `${process.env.NEXT_PUBLIC_API_URL}/v1/sessions/${sessionId}`,
+                { headers: await getAuthHeader() }
+            );
+
+            if (!errorHandler(res)) return;
+
+            const state = await res.json();
+            router.push(`/problems?idx=${state.nextIdx ?? 0}&sessionId=${sessionId}`);
+        } catch {
+            setError("通信エラーが発生しました。");
+        }
+    };
+
+    if (error) return <div className="alert alert-danger">{error}</div>;
+    if (sessions.length === 0) return null;
+
+    return (
+        <div className="mb-5">
+            <h3 className="mb-3">⏸ 途中のテスト</h3>
+            {sessions.map((s) => (
+                <div key={s.sessionId} className="d-flex justify-content-between align-items-center p-3 mb-2 border rounded bg-secondary text-light">
+                    <span>
+                        {s.createdAt}　{s.answered} / {s.total} 問回答
+                        {s.examMode && "（試験モード）"}
+                    </span>
+                    <button className="btn btn-primary" onClick={() => resume(s.sessionId)}>再開</button>
+                </div>
+            ))}
+        </div>
+    );
+}
@@ -4,6 +4,7 @@ import { useRouter } from "next/navigation";
 import { useEffect, useState } from "react";
 import { signOut } from "aws-amplify/auth";
 import CreateSession from './CreateSession';
+import ResumeSessions from './ResumeSessions';
 import ErrorMessage from '@/components/ErrorMessage';
 import { useErrorHandler } from "@/hooks/useErrorHandler";
 import { getAuthHeader } from "@/lib/auth";
@@ -52,6 +53,8 @@ export default function Mypage() {
                 <button className="btn btn-danger" onClick={handleLogout}>ログアウト</button>
             </div>
 
+            <ResumeSessions />
+
             <CreateSession />
 
             <h3 className="mb-4"> 📊 テスト結果分析</h3>
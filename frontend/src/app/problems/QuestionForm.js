@@ -6,7 +6,7 @@ import { useErrorHandler } from "@/hooks/useErrorHandler";
 import ErrorMessage from '@/components/ErrorMessage';
 import { getAuthHeader } from "@/lib/auth";
 
-export default function QuestionForm({idx,sessionId,choices,initialselectedId,total}){
+export default function QuestionForm({idx,sessionId,choices,initialselectedId,version,total}){
     const [selectedId,setSelectedId] = useState(initialselectedId ?? null);
     const router = useRouter();
     const [error,setError] = useState(null);
@@ -30,7 +30,8 @@ export default function QuestionForm({idx,sessionId,choices,initialselectedId,to
                     "Content-Type": "application/json",
                     ...(await getAuthHeader()),
                 },
-                body: JSON.stringify({selectedChoiceId: selectedId}),
+                //versionは競合検知用。他のタブで先に回答されていると409になる
+                body: JSON.stringify({selectedChoiceId: selectedId, version}),
             }
         );
 
@@ -44,6 +45,20 @@ export default function QuestionForm({idx,sessionId,choices,initialselectedId,to
         if(nextIdx < total){
             router.push(`/problems?idx=${nextIdx}&sessionId=${sessionId}`)
         } else{
+            //最後の問題に回答したらセッションを終了する(未回答の問題は不正解として集計される)
+            try{
+                const res = await fetch(
+                    `${process.env.NEXT_PUBLIC_API_URL}/session/current/finish?sessionId=${sessionId}`,
+                    {
+                        method: "post",
+                        headers: await getAuthHeader(),
+                    }
+                );
+                if (!errorHandler(res)) return;
+            }catch (e) {
+                setError("通信エラーが発生しました");
+                return ;
+            }
             router.push(`/mypage`)
         }
     };
@@ -15,7 +15,7 @@ function ProblemsContent() {
 
   const [sp, setSp] = useState(null);
   const [error, setError] = useState(null);
-  const [showHint, setShowHint] = useState(false);
+  const [hint, setHint] = useState(null);
   const errorHandler = useErrorHandler(setError);
 
   useEffect(() => {
@@ -38,9 +38,26 @@ function ProblemsContent() {
   }, [idx]);
 
   useEffect(() => {
-    setShowHint(false);
+    setHint(null);
   }, [idx]);
 
+  // ヒントは明示的に要求したときだけ取得する（表示したことがサーバーに記録される）
+  const revealHint = async () => {
+    try {
+      const res = await fetch(
+        `${process.env.NEXT_PUBLIC_API_URL}/session/current/problems/${idx}/hint?sessionId=${sessionId}`,
+        { method: "POST", headers: await getAuthHeader() }
+      );
+
+      if (!errorHandler(res)) return;
+
+      const data = await res.json();
+      setHint(data.hint);
+    } catch {
+      setError("通信エラーが発生しました。");
+    }
+  };
+
 
   if (error) return <ErrorMessage error={error} />;
   if (!sp) return <p>読み込み中...</p>;
@@ -61,20 +78,28 @@ function ProblemsContent() {
         sessionId={sessionId}
         choices={sp.choices}
         initialselectedId={sp.selectedId}
+        version={sp.version}
         total={sp.total}
       />
 
-      <div className="mt-4">
-        <button className="btn btn-outline-info" onClick={() => setShowHint(!showHint)}>
-          ヒント！
-        </button>
-
-        {showHint && (
-          <div className="mt-3 p-3 rounded bg-secondary text-dark">
-            <strong>ヒント：</strong> {sp.hint}
-          </div>
-        )}
-      </div>
+      {sp.hintAvailable && (
+        <div className="mt-4">
+          {hint === null ? (
+            <button className="btn btn-outline-info" onClick={revealHint}>
+              ヒント！
+            </button>
+          ) : (
+            <div className="mt-3 p-3 rounded bg-secondary text-dark">
+              <strong>ヒント：</strong> {hint}
+            </div>
+          )}
+          {hint === null && !sp.hintRevealed && (
+            <p className="mt-2 text-white" style={{ fontSize: "0.9rem" }}>
+              ※ ヒントを見た問題は成績で「ヒントあり」として集計されます。
+            </p>
+          )}
+        </div>
+      )}
     </div>
   );
 }
@@ -5,6 +5,10 @@ export function useErrorHandler(setError) {
       setError("セッションが切れたか不正なアクセスです。ログインし直してください。");
       return false;
     }
+    if (res.status === 409) {
+      setError("別の画面で先に回答が更新されました。ページを再読み込みしてください。");
+      return false;
+    }
     if (!res.ok) {
       setError(`送信に失敗しました(${res.status})`);
       return false;
@@ -4,25 +4,15 @@ resource "aws_apigatewayv2_api" "http_api" {
 
   cors_configuration {
     allow_origins     = ["https://${aws_cloudfront_distribution.cdn.domain_name}"]
-    allow_methods     = ["GET", "POST", "OPTIONS"]
+    allow_methods     = ["GET", "POST", "PUT", "DELETE", "OPTIONS"]
     allow_headers     = ["Content-Type", "Authorization"]
     allow_credentials = true
     max_age           = 43200
   }
 }
 
-# Cognito JWT オーソライザー
-resource "aws_apigatewayv2_authorizer" "cognito" {
-  api_id           = aws_apigatewayv2_api.http_api.id
-  authorizer_type  = "JWT"
-  identity_sources = ["$request.header.Authorization"]
-  name             = "${var.project_name}-cognito-authorizer"
-
-  jwt_configuration {
-    audience = [aws_cognito_user_pool_client.main.id]
-    issuer   = "https://cognito-idp.${var.aws_region}.amazonaws.com/${aws_cognito_user_pool.main.id}"
-  }
-}
+# 認証は Lambda 側で行う (Cognito の JWT は JWKS で検証し、API キー (mok_...) はテーブルのハッシュと照合する)。
+# API Gateway の JWT オーソライザーは API キーを通せないため使わない。
 
 resource "aws_apigatewayv2_stage" "default" {
   api_id      = aws_apigatewayv2_api.http_api.id
@@ -40,28 +30,34 @@ resource "aws_apigatewayv2_integration" "lambda" {
   integration_type       = "AWS_PROXY"
   integration_uri        = aws_lambda_function.backend.invoke_arn
   payload_format_version = "2.0"
-
-  # JWT認証済みのCognitoクレームをヘッダーに注入（Lambda側でX-User-Subを読む）
-  request_parameters = {
-    "overwrite:header.X-User-Sub"  = "$context.authorizer.claims.sub"
-    "overwrite:header.X-User-Name" = "$context.authorizer.claims.name"
-  }
 }
 
 resource "aws_apigatewayv2_route" "get" {
   api_id             = aws_apigatewayv2_api.http_api.id
   route_key          = "GET /{proxy+}"
   target             = "integrations/${aws_apigatewayv2_integration.lambda.id}"
-  authorization_type = "JWT"
-  authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
+  authorization_type = "NONE"
 }
 
 resource "aws_apigatewayv2_route" "post" {
   api_id             = aws_apigatewayv2_api.http_api.id
   route_key          = "POST /{proxy+}"
   target             = "integrations/${aws_apigatewayv2_integration.lambda.id}"
-  authorization_type = "JWT"
-  authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
+  authorization_type = "NONE"
+}
+
+resource "aws_apigatewayv2_route" "put" {
+  api_id             = aws_apigatewayv2_api.http_api.id
+  route_key          = "PUT /{proxy+}"
+  target             = "integrations/${aws_apigatewayv2_integration.lambda.id}"
+  authorization_type = "NONE"
+}
+
+resource "aws_apigatewayv2_route" "delete" {
+  api_id             = aws_apigatewayv2_api.http_api.id
+  route_key          = "DELETE /{proxy+}"
+  target             = "integrations/${aws_apigatewayv2_integration.lambda.id}"
+  authorization_type = "NONE"
 }
 
 # Lambda側にAPI Gatewayからの呼び出しを許可
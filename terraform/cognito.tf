@@ -43,3 +43,16 @@ resource "aws_cognito_user_pool_client" "main" {
   # IDトークンにname, emailクレームを含める
   read_attributes = ["email", "name"]
 }
+
+# 役割のグループ。所属は ID トークンの cognito:groups に入り、Lambda が教師・管理者の役割として扱う
+resource "aws_cognito_user_group" "teacher" {
+  name         = "teacher"
+  user_pool_id = aws_cognito_user_pool.main.id
+  description  = "クラスと課題を管理できる教師"
+}
+
+resource "aws_cognito_user_group" "admin" {
+  name         = "admin"
+  user_pool_id = aws_cognito_user_pool.main.id
+  description  = "問題分析と役割の管理ができる管理者"
+}
@@ -10,8 +10,12 @@ resource "aws_lambda_function" "backend" {
 
   environment {
     variables = {
-      DYNAMODB_TABLE = aws_dynamodb_table.main.name
-      ALLOW_ORIGIN   = "https://${aws_cloudfront_distribution.cdn.domain_name}"
+      DYNAMODB_TABLE    = aws_dynamodb_table.main.name
+      ALLOW_ORIGIN      = "https://${aws_cloudfront_distribution.cdn.domain_name}"
+      ADMIN_USER_SUBS   = var.admin_user_subs
+      TEACHER_USER_SUBS = var.teacher_user_subs
+      JWT_ISSUER        = "https://cognito-idp.${var.aws_region}.amazonaws.com/${aws_cognito_user_pool.main.id}"
+      JWT_AUDIENCE      = aws_cognito_user_pool_client.main.id
     }
   }
 }
@@ -6,3 +6,13 @@ variable "aws_region" {
 variable "project_name" {
   default = "mathovercome"
 }
+
+# 管理者の役割を固定で割り当てる Cognito sub (カンマ区切り)。最初の管理者用で、以降は Cognito の admin グループか mathovercome role grant で付与する
+variable "admin_user_subs" {
+  default = ""
+}
+
+# 教師の役割を固定で割り当てる Cognito sub (カンマ区切り)
+variable "teacher_user_subs" {
+  default = ""
+}